@@ -7,6 +7,7 @@ require (
 	github.com/gorilla/mux v1.8.1
 	github.com/spf13/cobra v1.8.0
 	github.com/videonext/onvif v0.0.0-20250201124620-6da7ff2620eb
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"onvif_manager/internal/backend/backup"
+	"onvif_manager/internal/backend/camera"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleBackupCamera returns the configuration archive of a camera as a JSON download
+func HandleBackupCamera(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	log.Printf("Received backup request for camera ID: %s", cameraID)
+
+	archive, err := backup.Create(cameraID)
+	if err != nil {
+		log.Printf("Error backing up camera %s: %v", cameraID, err)
+		http.Error(w, fmt.Sprintf("Failed to back up camera: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", archive.FileName()))
+	json.NewEncoder(w).Encode(archive)
+}
+
+// HandleRestoreCamera writes a configuration archive from the request body to a camera.
+// Archives of another manufacturer or model are refused with 409 unless force=true is
+// given; cameras that cannot be reached are reported with 502.
+func HandleRestoreCamera(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	log.Printf("Received restore request for camera ID: %s", cameraID)
+
+	force := false
+	if value := r.URL.Query().Get("force"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid force value %q", value), http.StatusBadRequest)
+			return
+		}
+		force = parsed
+	}
+
+	archive := new(backup.Archive)
+	if err := json.NewDecoder(r.Body).Decode(archive); err != nil {
+		log.Printf("Error decoding restore request body: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := archive.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := backup.Restore(cameraID, archive, force)
+	if err != nil {
+		log.Printf("Error restoring camera %s: %v", cameraID, err)
+		switch {
+		case errors.Is(err, camera.ErrNotFound):
+			http.Error(w, fmt.Sprintf("Camera with ID %s not found", cameraID), http.StatusNotFound)
+		case errors.Is(err, backup.ErrModelMismatch):
+			http.Error(w, fmt.Sprintf("Failed to restore camera: %v", err), http.StatusConflict)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to restore camera: %v", err), http.StatusBadGateway)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
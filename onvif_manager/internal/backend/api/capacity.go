@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"onvif_manager/internal/backend/capacity"
+	"onvif_manager/internal/backend/templates"
+)
+
+// capacityPlanRequest is the body of /capacity-plan. Without streams and template the
+// current configuration of the selected profiles is planned.
+type capacityPlanRequest struct {
+	selectionRequest
+	Streams         []templates.Stream `json:"streams"`         // Target configuration of every camera
+	Template        string             `json:"template"`        // Template resolved for the model of each camera
+	Profiles        []string           `json:"profiles"`        // Profiles planned with their current configuration, or ["all"]
+	Measure         bool               `json:"measure"`         // Measure the bitrate of each stream with FFmpeg
+	MeasureSeconds  int                `json:"measureSeconds"`  // Seconds each stream is sampled
+	RetentionDays   []int              `json:"retentionDays"`   // Retention periods to compute storage for
+	BudgetMbps      float64            `json:"budgetMbps"`      // Overrides ONVIF_MANAGER_BANDWIDTH_BUDGET
+	BudgetStorageGB float64            `json:"budgetStorageGB"` // Overrides ONVIF_MANAGER_STORAGE_BUDGET
+}
+
+// HandleCapacityPlan computes the aggregate bitrate and recording storage of the selected
+// cameras for a target configuration, or their current one, without changing anything
+func HandleCapacityPlan(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received /capacity-plan request")
+
+	var input capacityPlanRequest
+	cameraIDs, ok := decodeSelectionRequest(w, r, &input, &input.selectionRequest)
+	if !ok {
+		return
+	}
+	for _, days := range input.RetentionDays {
+		if days <= 0 {
+			http.Error(w, "Retention days must be positive", http.StatusBadRequest)
+			return
+		}
+	}
+
+	request := capacity.Request{
+		Streams:        input.Streams,
+		Profiles:       input.Profiles,
+		Measure:        input.Measure,
+		MeasureSeconds: input.MeasureSeconds,
+		RetentionDays:  input.RetentionDays,
+		Budget:         capacity.DefaultBudget().WithOverrides(input.BudgetMbps, input.BudgetStorageGB),
+	}
+	if input.Template != "" {
+		if len(input.Streams) > 0 {
+			http.Error(w, "Give either streams or a template, not both", http.StatusBadRequest)
+			return
+		}
+		template, err := templates.Shared.Get().Get(input.Template)
+		if err != nil {
+			http.Error(w, err.Error(), templateErrorStatus(err))
+			return
+		}
+		request.Template = template
+	}
+
+	plan := capacity.Run(r.Context(), cameraIDs, request, input.poolOptions())
+	log.Printf("Capacity plan: %d cameras, %.1f Mbit/s, %d warning(s)", plan.Total.Cameras, plan.Total.BitrateMbps, len(plan.Warnings))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
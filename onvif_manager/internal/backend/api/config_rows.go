@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"onvif_manager/internal/backend/camera"
+)
+
+// configRow is one row of a per-camera config CSV: the camera, named by ID or IP,
+// and the settings of the stream to configure on it
+type configRow struct {
+	Row      int    `json:"row,omitempty"` // CSV line number, used in results and errors
+	CameraID string `json:"cameraId,omitempty"`
+	IP       string `json:"ip,omitempty"`
+	streamConfig
+}
+
+// configRowTarget is a config row resolved to its inventoried camera
+type configRowTarget struct {
+	row      configRow
+	cameraID string
+	pass     int   // Index of the pass the row is applied in
+	err      error // Set when the row cannot be applied
+}
+
+// resolveConfigRow returns the inventoried camera a config row names by cameraId or ip
+func resolveConfigRow(row configRow) (string, error) {
+	switch {
+	case row.CameraID != "":
+		cam, found := camera.DefaultRegistry().Camera(row.CameraID)
+		if !found {
+			return "", fmt.Errorf("camera with ID %s not found", row.CameraID)
+		}
+		if row.IP != "" && cam.IP != row.IP {
+			return "", fmt.Errorf("camera %s has IP %s, not %s", cam.ID, cam.IP, row.IP)
+		}
+		return cam.ID, nil
+	case row.IP != "":
+		cam, found := camera.FindCameraByIP(row.IP)
+		if !found {
+			return "", fmt.Errorf("no camera with IP %s", row.IP)
+		}
+		return cam.ID, nil
+	default:
+		return "", fmt.Errorf("cameraId or ip is required")
+	}
+}
+
+// resolveConfigRows resolves the camera of every row. Rows are only resolved to cameras in
+// allowedIDs unless it is nil. Rows of one camera are spread over passes so that every pass
+// configures each camera at most once.
+func resolveConfigRows(rows []configRow, allowedIDs []string) []configRowTarget {
+	var allowed map[string]bool
+	if allowedIDs != nil {
+		allowed = make(map[string]bool, len(allowedIDs))
+		for _, cameraID := range allowedIDs {
+			allowed[cameraID] = true
+		}
+	}
+
+	targets := make([]configRowTarget, 0, len(rows))
+	rowsPerCamera := make(map[string]int)
+	seen := make(map[string]int)
+	for i, row := range rows {
+		if row.Row == 0 {
+			row.Row = i + 1
+		}
+		target := configRowTarget{row: row}
+
+		cameraID, err := resolveConfigRow(row)
+		key := cameraID + "/" + camera.ProfileSelectorKey(row.Profile)
+		previous, duplicate := seen[key]
+		switch {
+		case err != nil:
+			target.err = err
+		case allowed != nil && !allowed[cameraID]:
+			target.err = fmt.Errorf("camera %s is not among the selected cameras", cameraID)
+		case duplicate:
+			target.err = fmt.Errorf("row %d already targets camera %s and this profile", previous, cameraID)
+		default:
+			seen[key] = row.Row
+			target.cameraID = cameraID
+			target.pass = rowsPerCamera[cameraID]
+			rowsPerCamera[cameraID]++
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// rowCameraIDs returns the cameras the rows of the request configure, limited to the
+// listed cameras and the selector when they are given
+func (input applyConfigRequest) rowCameraIDs(cameraIDs []string) ([]string, error) {
+	var allowed []string
+	if len(cameraIDs) > 0 || input.Selector != "" {
+		var err error
+		if allowed, err = resolveCameraIDs(cameraIDs, input.Selector); err != nil {
+			return nil, err
+		}
+		if allowed == nil {
+			allowed = []string{}
+		}
+	}
+
+	var targets []string
+	for _, target := range resolveConfigRows(input.Rows, allowed) {
+		if target.err == nil && target.pass == 0 {
+			targets = append(targets, target.cameraID)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no row targets a selected camera of the inventory")
+	}
+	return targets, nil
+}
+
+// runConfigRows applies every row of a request to its camera as one batch and builds the
+// response with the result of each row. Each pass configures and validates the cameras
+// with a row in that pass, so cameras with rows for several profiles are changed one
+// stream after the other.
+func runConfigRows(ctx context.Context, cameraIDs []string, input applyConfigRequest, progress progressFunc) map[string]interface{} {
+	targets := resolveConfigRows(input.Rows, cameraIDs)
+	opts := input.poolOptions()
+
+	var passes [][]string
+	var requests []map[string]applyConfigRequest
+	for _, target := range targets {
+		if target.err != nil {
+			log.Printf("Skipping config row %d: %v", target.row.Row, target.err)
+			continue
+		}
+		for len(passes) <= target.pass {
+			passes = append(passes, nil)
+			requests = append(requests, make(map[string]applyConfigRequest))
+		}
+		passes[target.pass] = append(passes[target.pass], target.cameraID)
+		requests[target.pass][target.cameraID] = input.withStream(target.row.streamConfig)
+	}
+
+	log.Printf("Applying %d config rows to %d camera(s) in %d pass(es)", len(targets), len(cameraIDs), len(passes))
+	results := make([]map[string]camera.ApplyResult, len(passes))
+	validationResults := make([]map[string]interface{}, len(passes))
+	for pass, passCameraIDs := range passes {
+		results[pass], validationResults[pass] = runCameraConfigs(ctx, passCameraIDs, requests[pass], input.DryRun, opts, input.startCheck, progress)
+	}
+
+	rows := make([]map[string]interface{}, 0, len(targets))
+	configured, failed, skipped, validationPassed, validationFailed := 0, 0, 0, 0, 0
+	for _, target := range targets {
+		var rowResult map[string]interface{}
+		if target.err != nil {
+			rowResult = map[string]interface{}{
+				"success": false,
+				"skipped": true,
+				"error":   target.err.Error(),
+			}
+			skipped++
+		} else {
+			result := results[target.pass][target.cameraID]
+			rowResult = cameraResultResponse(result, validationResults[target.pass], input.DryRun)
+			if !result.Success {
+				failed++
+			} else {
+				configured++
+			}
+			if validation, ok := validationResults[target.pass][target.cameraID].(map[string]interface{}); ok && result.Success {
+				if isValid, _ := validation["isValid"].(bool); isValid {
+					validationPassed++
+				} else {
+					validationFailed++
+				}
+			}
+		}
+		rowResult["row"] = target.row.Row
+		rowResult["cameraId"] = target.cameraID
+		rowResult["ip"] = target.row.IP
+		rowResult["config"] = target.row.streamConfig
+		rows = append(rows, rowResult)
+	}
+	log.Printf("Config rows summary: %d configured, %d failed, %d skipped", configured, failed, skipped)
+
+	status := "configuration applied"
+	if input.DryRun {
+		status = "dry run"
+	}
+	return map[string]interface{}{
+		"status":         status,
+		"dryRun":         input.DryRun,
+		"rollbackPolicy": input.RollbackPolicy,
+		"rows":           rows,
+		"summary": map[string]int{
+			"rows":             len(targets),
+			"configured":       configured,
+			"failed":           failed,
+			"skipped":          skipped,
+			"validationPassed": validationPassed,
+			"validationFailed": validationFailed,
+		},
+		"cameraOrder": cameraIDs,
+	}
+}
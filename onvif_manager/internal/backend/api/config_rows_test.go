@@ -0,0 +1,96 @@
+package api
+
+import (
+	"testing"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
+
+func TestResolveConfigRows(t *testing.T) {
+	store := &camera.MemoryStore{}
+	store.Save([]models.Camera{
+		{ID: "1", IP: "10.0.0.1"},
+		{ID: "2", IP: "10.0.0.2"},
+		{ID: "3", IP: "10.0.0.3"},
+	})
+	if err := camera.UseStore(store); err != nil {
+		t.Fatalf("UseStore() error = %v", err)
+	}
+
+	type want struct {
+		cameraID string
+		pass     int
+		err      bool
+	}
+	tests := []struct {
+		name    string
+		rows    []configRow
+		allowed []string
+		want    []want
+	}{
+		{
+			name: "by ID and by IP",
+			rows: []configRow{{CameraID: "1"}, {IP: "10.0.0.2"}, {CameraID: "3", IP: "10.0.0.3"}},
+			want: []want{{cameraID: "1"}, {cameraID: "2"}, {cameraID: "3"}},
+		},
+		{
+			name: "unknown or mismatched camera",
+			rows: []configRow{{CameraID: "9"}, {IP: "10.0.0.9"}, {CameraID: "1", IP: "10.0.0.2"}, {}},
+			want: []want{{err: true}, {err: true}, {err: true}, {err: true}},
+		},
+		{
+			name:    "camera not selected",
+			rows:    []configRow{{CameraID: "1"}, {CameraID: "2"}},
+			allowed: []string{"2"},
+			want:    []want{{err: true}, {cameraID: "2"}},
+		},
+		{
+			name: "profiles of one camera in separate passes",
+			rows: []configRow{
+				{CameraID: "1", streamConfig: streamConfig{Profile: "main"}},
+				{IP: "10.0.0.1", streamConfig: streamConfig{Profile: "sub"}},
+				{CameraID: "2", streamConfig: streamConfig{Profile: "sub"}},
+			},
+			want: []want{{cameraID: "1"}, {cameraID: "1", pass: 1}, {cameraID: "2"}},
+		},
+		{
+			name: "same profile twice",
+			rows: []configRow{
+				{CameraID: "1", streamConfig: streamConfig{Profile: "Sub"}},
+				{IP: "10.0.0.1", streamConfig: streamConfig{Profile: "sub"}},
+			},
+			want: []want{{cameraID: "1"}, {err: true}},
+		},
+		{
+			name: "empty profile is the main stream",
+			rows: []configRow{
+				{CameraID: "1"},
+				{CameraID: "1", streamConfig: streamConfig{Profile: "main"}},
+			},
+			want: []want{{cameraID: "1"}, {err: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := resolveConfigRows(tt.rows, tt.allowed)
+			if len(targets) != len(tt.want) {
+				t.Fatalf("resolveConfigRows() returned %d targets, want %d", len(targets), len(tt.want))
+			}
+			for i, target := range targets {
+				if target.row.Row != i+1 {
+					t.Errorf("row %d numbered %d", i+1, target.row.Row)
+				}
+				if (target.err != nil) != tt.want[i].err {
+					t.Errorf("row %d error = %v, wantErr %v", i+1, target.err, tt.want[i].err)
+					continue
+				}
+				if target.cameraID != tt.want[i].cameraID || target.pass != tt.want[i].pass {
+					t.Errorf("row %d = camera %q pass %d, want camera %q pass %d",
+						i+1, target.cameraID, target.pass, tt.want[i].cameraID, tt.want[i].pass)
+				}
+			}
+		})
+	}
+}
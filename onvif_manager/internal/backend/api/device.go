@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"onvif_manager/internal/backend/camera"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleGetDeviceInfo returns the manufacturer, model, firmware version, serial number
+// and hardware ID of an inventoried camera
+func HandleGetDeviceInfo(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	log.Printf("Received device-info request for camera ID: %s", cameraID)
+
+	// Only inventoried cameras are locked, so requests for unknown IDs leave no lock behind
+	if _, found := camera.DefaultRegistry().Camera(cameraID); !found {
+		http.Error(w, fmt.Sprintf("Camera with ID %s not found", cameraID), http.StatusNotFound)
+		return
+	}
+	unlock := camera.LockCamera(cameraID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		log.Printf("Error getting camera client for %s: %v", cameraID, err)
+		if errors.Is(err, camera.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("Camera with ID %s not found", cameraID), http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to connect to camera: %v", err), http.StatusBadGateway)
+		}
+		return
+	}
+
+	deviceInfo, err := client.GetDeviceInformation()
+	if err != nil {
+		log.Printf("Error getting device information for camera %s: %v", cameraID, err)
+		http.Error(w, fmt.Sprintf("Failed to get device information: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cameraId":        cameraID,
+		"ip":              client.Camera.IP,
+		"manufacturer":    deviceInfo.Manufacturer,
+		"model":           deviceInfo.Model,
+		"firmwareVersion": deviceInfo.FirmwareVersion,
+		"serialNumber":    deviceInfo.SerialNumber,
+		"hardwareId":      deviceInfo.HardwareID,
+	})
+}
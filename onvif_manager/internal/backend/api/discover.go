@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"onvif_manager/internal/backend/discovery"
+)
+
+// maxDiscoveryTimeout caps the probe duration a client may request
+const maxDiscoveryTimeout = 30 * time.Second
+
+// HandleDiscover probes the network for ONVIF devices and reports which of them
+// are already in the inventory. The optional timeout query parameter sets how
+// long to wait for answers, e.g. /discover?timeout=5s.
+func HandleDiscover(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received /discover request")
+
+	timeout, err := discoveryTimeout(r.URL.Query().Get("timeout"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	devices, err := discovery.Probe(r.Context(), discovery.Options{Timeout: timeout})
+	if err != nil {
+		log.Printf("Error discovering cameras: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to discover cameras: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	candidates := discovery.MatchInventory(devices)
+	newCount := 0
+	for _, candidate := range candidates {
+		if !candidate.InInventory {
+			newCount++
+		}
+	}
+	log.Printf("Discovered %d device(s), %d not in inventory", len(candidates), newCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"devices":  candidates,
+		"count":    len(candidates),
+		"newCount": newCount,
+	})
+}
+
+// HandleAddDiscovered adds discovered devices to the inventory. The request lists
+// the devices to add by IP and port, optionally with the device service XAddr they
+// advertised; when none are given the network is probed
+// and every device found is added. All devices share the given credentials.
+func HandleAddDiscovered(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received /discover/add request")
+
+	var input struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Devices  []struct {
+			IP    string `json:"ip"`
+			Port  int    `json:"port"`
+			XAddr string `json:"xaddr"`
+		} `json:"devices"`
+		Timeout string `json:"timeout"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		log.Printf("Error decoding discover-add request body: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if input.Username == "" {
+		log.Println("Error: Missing username in discover-add request")
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+
+	var devices []discovery.Device
+	if len(input.Devices) > 0 {
+		for _, device := range input.Devices {
+			devices = append(devices, discovery.Device{IP: device.IP, Port: device.Port, XAddr: device.XAddr})
+		}
+	} else {
+		timeout, err := discoveryTimeout(input.Timeout)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		devices, err = discovery.Probe(r.Context(), discovery.Options{Timeout: timeout})
+		if err != nil {
+			log.Printf("Error discovering cameras: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to discover cameras: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	results := discovery.AddToInventory(devices, input.Username, input.Password)
+
+	addedCount, existingCount, failedCount := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case !result.Success:
+			failedCount++
+		case result.Existing:
+			existingCount++
+		default:
+			addedCount++
+		}
+	}
+	log.Printf("Added %d discovered camera(s), %d already in inventory, %d failed", addedCount, existingCount, failedCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results":       results,
+		"addedCount":    addedCount,
+		"existingCount": existingCount,
+		"failedCount":   failedCount,
+	})
+}
+
+// discoveryTimeout parses a requested probe duration, using the default when empty
+func discoveryTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return discovery.DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	if timeout > maxDiscoveryTimeout {
+		timeout = maxDiscoveryTimeout
+	}
+	return timeout, nil
+}
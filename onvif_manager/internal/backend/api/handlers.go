@@ -18,7 +18,11 @@ import (
 
 	"onvif_manager/internal/backend/camera"
 	"onvif_manager/internal/backend/ffmpeg"
+	"onvif_manager/internal/backend/jobs"
 	"onvif_manager/internal/backend/loader"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/internal/backend/rollout"
+	"onvif_manager/internal/backend/templates"
 	"onvif_manager/internal/backend/vlc"
 	"onvif_manager/pkg/models"
 
@@ -28,17 +32,61 @@ import (
 func RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/cameras", HandleGetCameras).Methods("GET")
 	r.HandleFunc("/cameras", HandleAddCamera).Methods("POST")
+	r.HandleFunc("/cameras/time-sync", HandleTimeSync).Methods("POST")
+	r.HandleFunc("/cameras/reboot", HandleRebootCameras).Methods("POST")
+	r.HandleFunc("/groups", HandleGetGroups).Methods("GET")
+	r.HandleFunc("/groups/{name}", HandleSetGroup).Methods("PUT")
+	r.HandleFunc("/groups/{name}", HandleDeleteGroup).Methods("DELETE")
+	r.HandleFunc("/groups/{name}/cameras", HandleAddGroupCameras).Methods("POST")
+	r.HandleFunc("/groups/{name}/cameras/{id}", HandleRemoveGroupCamera).Methods("DELETE")
+	r.HandleFunc("/tags", HandleGetTags).Methods("GET")
+	r.HandleFunc("/drift", HandleGetDrift).Methods("GET")
+	r.HandleFunc("/templates", HandleGetTemplates).Methods("GET")
+	r.HandleFunc("/templates/{name}", HandleGetTemplate).Methods("GET")
+	r.HandleFunc("/templates/{name}", HandlePutTemplate).Methods("PUT")
+	r.HandleFunc("/templates/{name}", HandleDeleteTemplate).Methods("DELETE")
+	r.HandleFunc("/templates/{name}/resolve", HandleResolveTemplate).Methods("POST")
+	r.HandleFunc("/capacity-plan", HandleCapacityPlan).Methods("POST")
+	r.HandleFunc("/schedules", HandleGetSchedules).Methods("GET")
+	r.HandleFunc("/schedules/{name}", HandleGetSchedule).Methods("GET")
+	r.HandleFunc("/schedules/{name}", HandlePutSchedule).Methods("PUT")
+	r.HandleFunc("/schedules/{name}", HandleDeleteSchedule).Methods("DELETE")
+	r.HandleFunc("/schedules/{name}/run", HandleRunSchedule).Methods("POST")
+	r.HandleFunc("/schedules/{name}/runs", HandleGetScheduleRuns).Methods("GET")
+	r.HandleFunc("/maintenance-windows", HandleGetMaintenanceWindows).Methods("GET")
+	r.HandleFunc("/maintenance-windows/{name}", HandlePutMaintenanceWindow).Methods("PUT")
+	r.HandleFunc("/maintenance-windows/{name}", HandleDeleteMaintenanceWindow).Methods("DELETE")
 	r.HandleFunc("/cameras/{id}", HandleDeleteCamera).Methods("DELETE")
+	r.HandleFunc("/cameras/{id}/device-info", HandleGetDeviceInfo).Methods("GET")
+	r.HandleFunc("/cameras/{id}/profiles", HandleGetProfiles).Methods("GET")
+	r.HandleFunc("/cameras/{id}/groups", HandleSetCameraGroups).Methods("PUT")
+	r.HandleFunc("/cameras/{id}/tags", HandleSetCameraTags).Methods("PUT")
+	r.HandleFunc("/cameras/{id}/tags", HandleAddCameraTags).Methods("POST")
+	r.HandleFunc("/cameras/{id}/tags/{tag}", HandleRemoveCameraTag).Methods("DELETE")
+	r.HandleFunc("/cameras/{id}/backup", HandleBackupCamera).Methods("GET")
+	r.HandleFunc("/cameras/{id}/restore", HandleRestoreCamera).Methods("POST")
+	r.HandleFunc("/cameras/{id}/config-history", HandleGetConfigHistory).Methods("GET")
+	r.HandleFunc("/cameras/{id}/config-history/revert", HandleRevertConfig).Methods("POST")
 	r.HandleFunc("/load-cam-list", HandleLoadCamList).Methods("GET")
 	r.HandleFunc("/check-single-cam/{id}", HandleCheckSingleCam).Methods("GET")
 	r.HandleFunc("/config-single-cam/{id}", HandleConfigSingleCam).Methods("POST")
 	r.HandleFunc("/validate-cam/{id}", HandleValidateCam).Methods("GET")
+	r.HandleFunc("/validate-cams", HandleValidateCameras).Methods("POST")
 	r.HandleFunc("/cameras/import-csv", HandleImportCamerasCSV).Methods("POST")
 	r.HandleFunc("/import-config-csv", HandleImportConfigCSV).Methods("POST")
 	r.HandleFunc("/choose-cam-from-csv", HandleChooseCamFromCSV).Methods("POST")
 	r.HandleFunc("/apply-config", HandleApplyConfig).Methods("POST")
 	r.HandleFunc("/export-validation-csv", HandleExportValidationCSV).Methods("POST")
 	r.HandleFunc("/vlc", HandleVLC).Methods("POST")
+	r.HandleFunc("/discover", HandleDiscover).Methods("GET")
+	r.HandleFunc("/discover/add", HandleAddDiscovered).Methods("POST")
+	r.HandleFunc("/jobs", HandleListJobs).Methods("GET")
+	r.HandleFunc("/jobs/apply-config", HandleCreateApplyConfigJob).Methods("POST")
+	r.HandleFunc("/jobs/{id}", HandleGetJob).Methods("GET")
+	r.HandleFunc("/jobs/{id}/events", HandleJobEvents).Methods("GET")
+	r.HandleFunc("/jobs/{id}/pause", HandlePauseRollout).Methods("POST")
+	r.HandleFunc("/jobs/{id}/resume", HandleResumeRollout).Methods("POST")
+	r.HandleFunc("/jobs/{id}/abort", HandleAbortRollout).Methods("POST")
 
 	// Debug: catch-all route to log unmatched requests
 	r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -65,6 +113,16 @@ func RegisterRoutes(r *mux.Router) {
 func HandleGetCameras(w http.ResponseWriter, r *http.Request) {
 	// Get cameras from in-memory storage instead of CSV file
 	cameras := camera.GetAllCameras()
+
+	// ?selector= lists only the matching cameras
+	if expr := r.URL.Query().Get("selector"); expr != "" {
+		selector, err := camera.ParseSelector(expr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cameras = camera.SelectCameras(selector)
+	}
 	json.NewEncoder(w).Encode(cameras)
 }
 
@@ -100,7 +158,7 @@ func HandleAddCamera(w http.ResponseWriter, r *http.Request) {
 	// Password can be empty for some cameras, so we don't check for it
 	log.Printf("Adding new camera with IP: %s, Port: %d, URL: %s, Username: %s",
 		input.IP, input.Port, input.URL, input.Username)
-	newID, err := camera.AddNewCamera(input.IP, input.Port, input.URL, input.Username, input.Password)
+	newID, err := camera.AddNewCamera(input.IP, input.Port, input.URL, "", input.Username, input.Password)
 	if err != nil {
 		log.Printf("Error adding new camera: %v", err)
 		http.Error(w, fmt.Sprintf("Failed to add new camera: %v", err), http.StatusInternalServerError)
@@ -262,10 +320,17 @@ func HandleCheckSingleCam(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(result)
 		return
 	}
+	result["services"] = client.Services
+
+	// Report how far the camera's clock is off; requests are signed with the corrected time
+	result["clockSkewMeasured"] = client.ClockMeasured()
+	if client.ClockMeasured() {
+		result["clockSkewSeconds"] = client.ClockOffset().Seconds()
+	}
 
 	// Try to get configuration
 	log.Printf("Getting profiles and configs for camera %s (IP: %s:%d)", targetCamera.ID, client.Camera.IP, client.Camera.Port)
-	profileTokens, configTokens, err := camera.GetProfilesAndConfigs(client)
+	profiles, err := camera.GetProfiles(client)
 	if err != nil {
 		log.Printf("Failed to get camera profiles and configs for %s (IP: %s:%d): %v", targetCamera.ID, client.Camera.IP, client.Camera.Port, err)
 
@@ -283,6 +348,12 @@ func HandleCheckSingleCam(w http.ResponseWriter, r *http.Request) {
 				result["error"] = "Connection refused: check ONVIF port and service"
 			} else if strings.Contains(errorMsg, "no route to host") {
 				result["error"] = "No route to host: check network connectivity"
+			} else if strings.Contains(errorMsg, "NotAuthorized") || strings.Contains(strings.ToLower(errorMsg), "not authorized") {
+				if client.ClockMeasured() {
+					result["error"] = fmt.Sprintf("Authentication failed: check username and password (camera clock offset %s was compensated)", client.ClockOffset())
+				} else {
+					result["error"] = "Authentication failed: check username and password, and that the camera clock is correct"
+				}
 			} else {
 				result["error"] = fmt.Sprintf("Camera reachable but ONVIF profiles failed: %v", err)
 			}
@@ -303,31 +374,26 @@ func HandleCheckSingleCam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(profileTokens) == 0 {
-		log.Printf("No profiles found for camera %s", targetCamera.ID)
-		result["status"] = "error"
-		result["error"] = "No profiles found"
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(result)
-		return
-	}
+	result["profiles"] = profiles
 
-	if len(configTokens) == 0 {
-		log.Printf("No video encoder configuration found for camera %s", targetCamera.ID)
+	// Check the profile asked for with ?profile=, the main stream by default
+	profile, err := camera.SelectProfile(profiles, r.URL.Query().Get("profile"))
+	if err != nil {
+		log.Printf("No usable profile for camera %s: %v", targetCamera.ID, err)
 		result["status"] = "error"
-		result["error"] = "No video encoder configuration found"
+		result["error"] = err.Error()
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
 		return
 	}
 
-	// Use the first token found
-	profileToken := profileTokens[0]
-	configToken := configTokens[0]
+	profileToken := profile.Token
+	configToken := profile.ConfigToken
+	result["profile"] = profile
 	result["profileToken"] = profileToken
 	result["configToken"] = configToken
 
-	log.Printf("Using profile token %s and config token %s for camera %s", profileToken, configToken, targetCamera.ID)
+	log.Printf("Using profile %s (token %s, config token %s) for camera %s", profile, profileToken, configToken, targetCamera.ID)
 
 	// Try to get current encoder config
 	log.Printf("Getting current encoder config for camera %s", targetCamera.ID)
@@ -343,7 +409,16 @@ func HandleCheckSingleCam(w http.ResponseWriter, r *http.Request) {
 
 	// Try to get available encoder options
 	log.Printf("Getting available encoder options for camera %s", targetCamera.ID)
-	encoderOptions, err := camera.GetCurrentEncoderOptions(client, profileToken, configToken)
+	encoderOptions, err := camera.GetEncoderOptions(client, profileToken, configToken)
+	if err == nil {
+		result["encoderOptions"] = encoderOptions
+	}
+
+	// Resolutions of the encoding asked for with ?encoding=, the current encoding by default
+	var encodingOptions *models.EncodingOptions
+	if err == nil {
+		encodingOptions, err = camera.SelectEncodingOptions(encoderOptions, camera.TargetEncoding(r.URL.Query().Get("encoding"), currentConfig.Encoding))
+	}
 	if err != nil {
 		log.Printf("Failed to get encoder options for %s: %v", targetCamera.ID, err)
 		// Still mark as online since we got the current config
@@ -378,8 +453,8 @@ func HandleCheckSingleCam(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Prepare available resolutions
-	availableResolutions := make([]map[string]int, len(encoderOptions.Resolutions))
-	for i, resolution := range encoderOptions.Resolutions {
+	availableResolutions := make([]map[string]int, len(encodingOptions.Resolutions))
+	for i, resolution := range encodingOptions.Resolutions {
 		availableResolutions[i] = map[string]int{
 			"width":  resolution.Width,
 			"height": resolution.Height,
@@ -387,30 +462,162 @@ func HandleCheckSingleCam(w http.ResponseWriter, r *http.Request) {
 	}
 	result["availableResolutions"] = availableResolutions
 
-	// Add encoder options information
-	result["encoderOptions"] = map[string]interface{}{
-		"availableFPS":     encoderOptions.FPSOptions,
-		"availableBitrate": encoderOptions.Bitrate,
-		"availableQuality": encoderOptions.Quality,
-	}
-
 	log.Printf("Successfully checked camera %s - status: online", targetCamera.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
+// applyConfigRequest is the body of an /apply-config request
+type applyConfigRequest struct {
+	CameraID  string   `json:"cameraId"`  // For backward compatibility
+	CameraIDs []string `json:"cameraIds"` // New field for multiple cameras
+	Selector  string   `json:"selector"`  // Selector expression, e.g. group=lobby,tag=outdoor
+	Width     int      `json:"width"`
+	Height    int      `json:"height"`
+	FPS       int      `json:"fps"`
+	Bitrate   int      `json:"bitrate"`
+	Encoding  string   `json:"encoding"`
+	Profile   string   `json:"profile"` // Profile token, name or role (main/sub); the main stream when empty
+
+	// Optional H.264/H.265 settings; the camera's current values are kept when unset
+	GOP            int    `json:"gop"`            // Frames between keyframes
+	EncoderProfile string `json:"encoderProfile"` // e.g. Baseline, Main, High
+
+	// Optional rate control settings; the camera's current values are kept when unset
+	RateControl      string `json:"rateControl"`      // CBR or VBR
+	Quality          int    `json:"quality"`          // Quality level within the camera's quality range
+	EncodingInterval int    `json:"encodingInterval"` // Encode every n-th frame (Media service only)
+
+	// DryRun only reads the cameras and reports per camera what would change;
+	// nothing is written and the streams are not validated
+	DryRun bool `json:"dryRun"`
+
+	// RollbackPolicy restores the previous configuration of cameras whose stream fails
+	// validation: never (default), on-failure or on-resolution-mismatch
+	RollbackPolicy string `json:"rollbackPolicy"`
+
+	// Streams configures several profiles of each camera in one request, e.g. main
+	// and sub stream. When set, the settings above are ignored.
+	Streams []streamConfig `json:"streams"`
+
+	// Rows configures each camera with its own settings, e.g. the rows of a per-camera
+	// config CSV. When set, the settings above and streams are ignored and cameraIds
+	// and selector only limit the rows that are applied.
+	Rows []configRow `json:"rows"`
+
+	// Template applies the named configuration template, resolved for the model each
+	// camera reports. When set, the settings above, streams and rows are ignored.
+	Template string `json:"template"`
+
+	// Rollout applies the configuration in waves, starting with a canary wave, and pauses
+	// when too many cameras fail. Only background jobs can be rolled out in waves.
+	Rollout *rollout.Options `json:"rollout"`
+
+	// Optional worker pool overrides; defaults come from the environment
+	Concurrency    int `json:"concurrency"`
+	TimeoutSeconds int `json:"timeoutSeconds"`
+
+	// startCheck is set by scheduled runs and asked before each camera is configured
+	startCheck func(cameraID string) error
+}
+
+// streamConfig is the target configuration of one profile in an /apply-config request
+type streamConfig struct {
+	Profile  string `json:"profile"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FPS      int    `json:"fps"`
+	Bitrate  int    `json:"bitrate"`
+	Encoding string `json:"encoding"`
+
+	GOP            int    `json:"gop"`
+	EncoderProfile string `json:"encoderProfile"`
+
+	RateControl      string `json:"rateControl"`
+	Quality          int    `json:"quality"`
+	EncodingInterval int    `json:"encodingInterval"`
+}
+
+// targetCameraIDs returns the cameras addressed by the request, accepting the legacy
+// single cameraId, the cameraIds list and a selector expression
+func (input applyConfigRequest) targetCameraIDs() ([]string, error) {
+	cameraIDs := input.CameraIDs
+	if len(cameraIDs) == 0 && input.CameraID != "" {
+		cameraIDs = []string{input.CameraID}
+	}
+	if len(input.Rows) > 0 && input.Template == "" {
+		return input.rowCameraIDs(cameraIDs)
+	}
+	return resolveCameraIDs(cameraIDs, input.Selector)
+}
+
+// streamRequests splits a request with streams into one request per stream,
+// each addressing the same cameras. A request without streams is returned as is.
+func (input applyConfigRequest) streamRequests() []applyConfigRequest {
+	if len(input.Streams) == 0 {
+		return []applyConfigRequest{input}
+	}
+
+	requests := make([]applyConfigRequest, 0, len(input.Streams))
+	for _, stream := range input.Streams {
+		requests = append(requests, input.withStream(stream))
+	}
+	return requests
+}
+
+// withStream returns the request configuring a single stream with the given settings
+func (input applyConfigRequest) withStream(stream streamConfig) applyConfigRequest {
+	request := input
+	request.Streams = nil
+	request.Rows = nil
+	request.Profile = stream.Profile
+	request.Width = stream.Width
+	request.Height = stream.Height
+	request.FPS = stream.FPS
+	request.Bitrate = stream.Bitrate
+	request.Encoding = stream.Encoding
+	request.GOP = stream.GOP
+	request.EncoderProfile = stream.EncoderProfile
+	request.RateControl = stream.RateControl
+	request.Quality = stream.Quality
+	request.EncodingInterval = stream.EncodingInterval
+	return request
+}
+
+// encoderConfig returns the encoder settings of the request at the given resolution
+func (input applyConfigRequest) encoderConfig(resolution models.Resolution) models.EncoderConfig {
+	return models.EncoderConfig{
+		Resolution:       resolution,
+		Quality:          input.Quality, // 0 keeps the current quality
+		FPS:              input.FPS,
+		Bitrate:          input.Bitrate,
+		Encoding:         input.Encoding,
+		GOP:              input.GOP,
+		EncoderProfile:   camera.NormalizeEncoderProfile(input.EncoderProfile),
+		RateControl:      camera.NormalizeRateControl(input.RateControl),
+		EncodingInterval: input.EncodingInterval,
+	}
+}
+
+// poolOptions returns the worker pool settings for this request
+func (input applyConfigRequest) poolOptions() pool.Options {
+	return pool.DefaultOptions().WithOverrides(input.Concurrency, time.Duration(input.TimeoutSeconds)*time.Second)
+}
+
+// progressFunc receives per-camera phase changes while a bulk operation runs
+type progressFunc func(cameraID string, phase jobs.Phase, message string)
+
+// report forwards a phase change; a nil progressFunc ignores it
+func (p progressFunc) report(cameraID string, phase jobs.Phase, message string) {
+	if p != nil {
+		p(cameraID, phase, message)
+	}
+}
+
 func HandleApplyConfig(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received /apply-config request")
-	var input struct {
-		CameraID  string   `json:"cameraId"`  // For backward compatibility
-		CameraIDs []string `json:"cameraIds"` // New field for multiple cameras
-		Width     int      `json:"width"`
-		Height    int      `json:"height"`
-		FPS       int      `json:"fps"`
-		Bitrate   int      `json:"bitrate"`
-		Encoding  string   `json:"encoding"`
-	}
+	var input applyConfigRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		log.Printf("Error decoding apply-config request body: %v", err)
@@ -418,325 +625,396 @@ func HandleApplyConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Handle both legacy (single camera) and new (multiple cameras) format
-	var cameraIDs []string
-	if len(input.CameraIDs) > 0 {
-		cameraIDs = input.CameraIDs
-		log.Printf("Applying config for multiple cameras (%d): Width: %d, Height: %d, FPS: %d, Bitrate: %d, Encoding: %s",
-			len(cameraIDs), input.Width, input.Height, input.FPS, input.Bitrate, input.Encoding)
-	} else if input.CameraID != "" {
-		cameraIDs = []string{input.CameraID}
-		log.Printf("Applying config for single camera %s: Width: %d, Height: %d, FPS: %d, Bitrate: %d, Encoding: %s",
-			input.CameraID, input.Width, input.Height, input.FPS, input.Bitrate, input.Encoding)
-	} else {
+	cameraIDs, err := input.targetCameraIDs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(cameraIDs) == 0 {
 		log.Println("Error: No camera IDs provided in request")
 		http.Error(w, "No camera IDs provided", http.StatusBadRequest)
 		return
 	}
+	policy, err := camera.ParseRollbackPolicy(input.RollbackPolicy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.RollbackPolicy = policy
+	if input.Template != "" {
+		if _, err := templates.Shared.Get().Get(input.Template); err != nil {
+			http.Error(w, err.Error(), templateErrorStatus(err))
+			return
+		}
+	}
+	if input.Rollout != nil {
+		http.Error(w, "Staged rollouts run as background jobs, post the request to /jobs/apply-config", http.StatusBadRequest)
+		return
+	}
 
-	// Create a target resolution object once
-	targetResolution := models.Resolution{Width: input.Width, Height: input.Height}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(runApplyConfigRequest(r.Context(), cameraIDs, input, nil))
+}
 
-	// Create result structures for tracking progress
-	type CameraConfigResult struct {
-		CameraID           string
-		Success            bool
-		Error              error
-		AppliedConfig      map[string]interface{}
-		ResolutionAdjusted bool
-		ProfileToken       string
-		StreamURL          string
+// runApplyConfigRequest runs an /apply-config request and builds its response body.
+// A request with streams configures one stream after the other and responds with
+// the result of each stream under "streams"; a request with rows or a template responds
+// with the result of each row under "rows".
+func runApplyConfigRequest(ctx context.Context, cameraIDs []string, input applyConfigRequest, progress progressFunc) map[string]interface{} {
+	if input.Template != "" {
+		return runTemplateConfig(ctx, cameraIDs, input, progress)
+	}
+	if len(input.Rows) > 0 {
+		return runConfigRows(ctx, cameraIDs, input, progress)
 	}
+	opts := input.poolOptions()
 
-	results := make(map[string]CameraConfigResult)
-	validationResults := make(map[string]interface{})
+	var responses []map[string]interface{}
+	for _, request := range input.streamRequests() {
+		log.Printf("Applying config for %d camera(s): Profile: %q, Width: %d, Height: %d, FPS: %d, Bitrate: %d, Encoding: %s, GOP: %d, Encoder profile: %q, Rate control: %q, Quality: %d, Encoding interval: %d",
+			len(cameraIDs), request.Profile, request.Width, request.Height, request.FPS, request.Bitrate, request.Encoding, request.GOP, request.EncoderProfile,
+			request.RateControl, request.Quality, request.EncodingInterval)
 
-	log.Printf("===== PHASE 1: Applying configuration to all cameras =====")
-	// PHASE 1: Apply configuration to all cameras first
-	for _, cameraID := range cameraIDs {
-		// Initialize result for this camera
-		result := CameraConfigResult{
-			CameraID: cameraID,
-			Success:  false,
-		}
+		results, validationResults := runApplyConfig(ctx, cameraIDs, request, opts, progress)
+		responses = append(responses, buildApplyConfigResponse(request, cameraIDs, results, validationResults))
+	}
 
-		// Get the camera client
-		client, err := camera.GetCameraClient(cameraID)
-		if err != nil {
-			log.Printf("Error getting camera client for %s: %v", cameraID, err)
-			result.Error = fmt.Errorf("camera not found: %w", err)
-			results[cameraID] = result
-			continue
-		}
+	if len(input.Streams) == 0 {
+		return responses[0]
+	}
+	status := "configuration applied"
+	if input.DryRun {
+		status = "dry run"
+	}
+	return map[string]interface{}{
+		"status":      status,
+		"dryRun":      input.DryRun,
+		"streams":     responses,
+		"cameraOrder": cameraIDs,
+	}
+}
 
-		// Proceed with config application
-		log.Printf("\n Getting profiles and configs for camera %s (IP: %s:%d)", cameraID, client.Camera.IP, client.Camera.Port)
-		profileTokens, configTokens, err := camera.GetProfilesAndConfigs(client)
-		if err != nil {
-			log.Printf("Failed to get camera profiles and configs for %s (IP: %s:%d): %v", cameraID, client.Camera.IP, client.Camera.Port, err)
-			// Add more specific error information for network issues
-			errorMsg := err.Error()
-			if strings.Contains(errorMsg, "i/o timeout") || strings.Contains(errorMsg, "dial tcp") {
-				result.Error = fmt.Errorf("network timeout: camera at %s:%d is not responding. Please check: 1) Camera is powered on and connected to network, 2) IP address %s is correct, 3) Port %d is the correct ONVIF port, 4) Camera supports ONVIF protocol", client.Camera.IP, client.Camera.Port, client.Camera.IP, client.Camera.Port)
-			} else if strings.Contains(errorMsg, "connection refused") {
-				result.Error = fmt.Errorf("connection refused: camera at %s:%d refused connection. Please check: 1) Correct ONVIF port (common ports: 80, 8080, 554), 2) ONVIF service is enabled on camera, 3) Firewall settings", client.Camera.IP, client.Camera.Port)
-			} else if strings.Contains(errorMsg, "no route to host") {
-				result.Error = fmt.Errorf("no route to host: cannot reach camera at %s:%d. Please check: 1) Camera and server are on same network, 2) IP address is correct, 3) Network routing", client.Camera.IP, client.Camera.Port)
-			} else {
-				result.Error = fmt.Errorf("failed to get camera profiles and configs: %w", err)
+// runApplyConfig configures all cameras with the same settings and then validates them,
+// running each phase through the bounded worker pool. Both returned maps are keyed by camera ID.
+func runApplyConfig(ctx context.Context, cameraIDs []string, input applyConfigRequest, opts pool.Options, progress progressFunc) (map[string]camera.ApplyResult, map[string]interface{}) {
+	requests := make(map[string]applyConfigRequest, len(cameraIDs))
+	for _, cameraID := range cameraIDs {
+		requests[cameraID] = input
+	}
+	return runCameraConfigs(ctx, cameraIDs, requests, input.DryRun, opts, input.startCheck, progress)
+}
+
+// runCameraConfigs configures every camera with its own request and then validates them,
+// running each phase through the bounded worker pool. Each camera stays locked from its
+// configuration until it has been validated and, if needed, rolled back. startCheck, when
+// set, is asked before a camera is configured; a configured camera is always validated.
+// Both returned maps are keyed by camera ID.
+func runCameraConfigs(ctx context.Context, cameraIDs []string, requests map[string]applyConfigRequest, dryRun bool, opts pool.Options, startCheck func(cameraID string) error, progress progressFunc) (map[string]camera.ApplyResult, map[string]interface{}) {
+	locks := camera.NewOperationLocks()
+	defer locks.Release()
+
+	log.Printf("===== PHASE 1: Applying configuration to all cameras (concurrency %d, timeout %s) =====", opts.Concurrency, opts.Timeout)
+	configureOpts := opts
+	configureOpts.StartCheck = startCheck
+	configured := pool.Run(ctx, cameraIDs, configureOpts,
+		func(ctx context.Context, cameraID string) camera.ApplyResult {
+			if !locks.Lock(cameraID) {
+				return camera.ApplyResult{CameraID: cameraID, Error: fmt.Errorf("operation ended before camera %s was free", cameraID)}
 			}
-			results[cameraID] = result
-			continue
-		}
+			return applyConfigToCamera(ctx, cameraID, requests[cameraID], progress)
+		},
+		func(cameraID string, err error) camera.ApplyResult {
+			log.Printf("Configuration of camera %s aborted: %v", cameraID, err)
+			return camera.ApplyResult{CameraID: cameraID, Error: err}
+		})
 
-		if len(profileTokens) == 0 {
-			log.Printf("No profiles found for camera %s", cameraID)
-			result.Error = fmt.Errorf("no profiles found")
-			results[cameraID] = result
-			continue
+	results := make(map[string]camera.ApplyResult, len(cameraIDs))
+	for _, result := range configured {
+		results[result.CameraID] = result
+		switch {
+		case !result.Success:
+			progress.report(result.CameraID, jobs.PhaseFailed, result.Error.Error())
+		case dryRun:
+			progress.report(result.CameraID, jobs.PhaseDone, fmt.Sprintf("dry run: %d change(s) planned", len(result.Plan.Changes)))
+		default:
+			progress.report(result.CameraID, jobs.PhaseConfiguring, "configuration applied, waiting for validation")
 		}
+	}
 
-		if len(configTokens) == 0 {
-			log.Printf("No video encoder configuration found for camera %s", cameraID)
-			result.Error = fmt.Errorf("no video encoder configuration found")
-			results[cameraID] = result
-			continue
-		}
+	// A dry run wrote nothing, so there is nothing to validate
+	if dryRun {
+		log.Printf("===== DRY RUN: configuration planned, nothing applied =====")
+		return results, map[string]interface{}{}
+	}
 
-		// Use the first token found
-		profileToken := profileTokens[0]
-		configToken := configTokens[0]
-		result.ProfileToken = profileToken
+	// PHASE 2: Validate all successfully configured cameras
+	// Wait for camera configurations to stabilize
+	time.Sleep(1 * time.Second)
 
-		log.Printf("Using profile token %s and config token %s for camera %s", profileToken, configToken, cameraID)
+	log.Printf("===== PHASE 2: Validating all cameras =====")
+	log.Printf("Original camera order: %v", cameraIDs)
+	validated := pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) map[string]interface{} {
+			defer locks.Unlock(cameraID)
+			input := requests[cameraID]
+			validation := validateConfiguredCamera(results[cameraID], input, progress)
+			if rollback := rollbackCamera(ctx, results[cameraID], input, validation, progress); rollback != nil {
+				validation["rollback"] = rollback
+			}
+			return validation
+		},
+		func(cameraID string, err error) map[string]interface{} {
+			log.Printf("Validation of camera %s aborted: %v", cameraID, err)
+			input := requests[cameraID]
+			return map[string]interface{}{
+				"isValid":             false,
+				"expectedWidth":       input.Width,
+				"expectedHeight":      input.Height,
+				"expectedFPS":         input.FPS,
+				"expectedBitrate":     input.Bitrate,
+				"expectedEncoding":    input.Encoding,
+				"expectedGOP":         input.GOP,
+				"expectedRateControl": camera.NormalizeRateControl(input.RateControl),
+				"error":               fmt.Sprintf("Validation aborted: %v", err),
+			}
+		})
 
-		// Get current encoder config
-		log.Printf("Getting current encoder config for camera %s", cameraID)
-		currentConfig, err := camera.GetCurrentConfig(client, configToken)
-		if err != nil {
-			log.Printf("Failed to get current encoder config for %s: %v", cameraID, err)
-			result.Error = fmt.Errorf("failed to get current encoder config: %w", err)
-			results[cameraID] = result
-			continue
+	validationResults := make(map[string]interface{}, len(cameraIDs))
+	for i, cameraID := range cameraIDs {
+		if deviceInfo := results[cameraID].DeviceInfo; deviceInfo != nil {
+			validated[i]["deviceInfo"] = deviceInfo
 		}
-		// log.Printf("Current encoding for camera %s: %+v", cameraID, currentConfig.Encoding)
-
-		// Get available encoder options
-		log.Printf("Getting available encoder options for camera %s", cameraID)
-		encoderOptions, err := camera.GetCurrentEncoderOptions(client, profileToken, configToken)
-		if err != nil {
-			log.Printf("Failed to get encoder options for %s: %v", cameraID, err)
-			result.Error = fmt.Errorf("failed to get encoder options: %w", err)
-			results[cameraID] = result
-			continue
+		if profile := results[cameraID].Profile; profile != nil {
+			validated[i]["profile"] = profile.String()
 		}
-
-		// Find closest matching resolution
-		log.Printf("Finding closest matching resolution for camera %s", cameraID)
-		closestResolution := camera.FindClosestResolution(targetResolution, encoderOptions.Resolutions)
-		log.Printf("Closest resolution found for camera %s: %dx%d", cameraID, closestResolution.Width, closestResolution.Height)
-
-		newConfig := models.EncoderConfig{
-			Resolution: closestResolution,
-			Quality:    currentConfig.Quality, // Keep the current quality
-			FPS:        input.FPS,
-			Bitrate:    input.Bitrate,
-			Encoding:   input.Encoding,
+		validationResults[cameraID] = validated[i]
+		if results[cameraID].Success {
+			phase := jobs.PhaseDone
+			if valid, _ := validated[i]["isValid"].(bool); !valid {
+				phase = jobs.PhaseFailed
+			}
+			progress.report(cameraID, phase, validationSummary(validated[i]))
 		}
-		log.Printf("Prepared new config for camera %s: %+v", cameraID, newConfig)
+	}
+	log.Printf("===== PHASE 2 COMPLETED =====")
 
-		// Set the new encoder config
-		log.Printf("Setting new encoder config for camera %s", cameraID)
-		if err := camera.SetEncoderConfig(client, configToken, currentConfig, newConfig); err != nil {
-			log.Printf("Failed to set encoder config for %s: %v", cameraID, err)
-			result.Error = fmt.Errorf("failed to set encoder config: %w", err)
-			results[cameraID] = result
-			continue
-		}
-		log.Printf("Successfully applied config for camera %s", cameraID)
+	return results, validationResults
+}
 
-		// Get stream URI for later validation
-		streamURI, err := client.GetStreamURI(profileToken)
-		if err != nil {
-			log.Printf("Failed to get stream URI for %s: %v", cameraID, err)
-			result.Error = fmt.Errorf("failed to get stream URI: %w", err)
-			results[cameraID] = result
-			continue
-		}
+// applyConfigToCamera configures a single camera for an /apply-config request, reporting
+// its steps as job phases. Nothing is written once ctx is done. The caller holds the
+// camera's operation lock.
+func applyConfigToCamera(ctx context.Context, cameraID string, input applyConfigRequest, progress progressFunc) camera.ApplyResult {
+	requested := input.encoderConfig(models.Resolution{Width: input.Width, Height: input.Height})
+	return camera.ApplyStreamConfig(ctx, cameraID, input.Profile, requested, input.DryRun, func(step, message string) {
+		progress.report(cameraID, jobs.Phase(step), message)
+	})
+}
 
-		// The ONVIF GetStreamUri typically doesn't include credentials. Embed them for FFmpeg validation.
-		parsedURI, err := url.Parse(streamURI)
-		if err != nil {
-			log.Printf("Failed to parse stream URI for %s: %v", cameraID, err)
-			result.Error = fmt.Errorf("failed to parse stream URI: %w", err)
-			results[cameraID] = result
-			continue
+// validateConfiguredCamera validates the stream of a camera configured by
+// applyConfigToCamera and returns its entry for the validation results.
+// Cameras whose configuration failed get a failed entry without touching the stream.
+// The caller holds the camera's operation lock.
+func validateConfiguredCamera(result camera.ApplyResult, input applyConfigRequest, progress progressFunc) map[string]interface{} {
+	cameraID := result.CameraID
+	if !result.Success {
+		// Skip validation for failed configs - add validation results for failed configuration cameras
+		log.Printf("Adding failed validation results for camera %s", cameraID)
+		errorMessage := "Configuration failed - camera not reachable"
+		if result.Error != nil {
+			errorMessage = result.Error.Error()
+		}
+		return map[string]interface{}{
+			"isValid":             false,
+			"expectedWidth":       input.Width,
+			"expectedHeight":      input.Height,
+			"expectedFPS":         input.FPS,
+			"expectedBitrate":     input.Bitrate,
+			"expectedEncoding":    input.Encoding,
+			"expectedGOP":         input.GOP,
+			"expectedRateControl": camera.NormalizeRateControl(input.RateControl),
+			"actualWidth":         0,
+			"actualHeight":        0,
+			"actualFPS":           0.0,
+			"actualBitrate":       0,
+			"error":               errorMessage,
+		}
+	}
+
+	// Validate the stream using FFmpeg CGO, against the resolution actually written
+	log.Printf("Starting FFmpeg validation for camera %s", cameraID)
+	progress.report(cameraID, jobs.PhaseValidating, "analyzing stream")
+	validationResult, validationErr := ffmpeg.ValidateStream(result.StreamURL, result.Resolution.Width, result.Resolution.Height, input.FPS, input.Bitrate, input.Encoding, input.GOP, camera.NormalizeRateControl(input.RateControl))
+	if validationErr != nil {
+		log.Printf("FFmpeg validation failed for camera %s: %v", cameraID, validationErr)
+		return map[string]interface{}{
+			"isValid":             false,
+			"error":               validationErr.Error(),
+			"expectedWidth":       result.Resolution.Width,
+			"expectedHeight":      result.Resolution.Height,
+			"expectedFPS":         input.FPS,
+			"expectedBitrate":     input.Bitrate,
+			"expectedEncoding":    input.Encoding,
+			"expectedGOP":         input.GOP,
+			"expectedRateControl": camera.NormalizeRateControl(input.RateControl),
+		}
+	}
+
+	// Determine validation status based on business rules
+	// Resolution mismatch = failure, FPS/bitrate mismatch = warning
+	resolutionMatches := validationResult.ActualWidth > 0 && validationResult.ActualHeight > 0 &&
+		validationResult.ActualWidth == validationResult.ExpectedWidth &&
+		validationResult.ActualHeight == validationResult.ExpectedHeight
+
+	fpsMatches := validationResult.ActualFPS > 0 &&
+		int(validationResult.ActualFPS+0.5) == validationResult.ExpectedFPS
+
+	bitrateMatches := true // Default to true if no expected bitrate
+	if validationResult.ExpectedBitrate > 0 && validationResult.ActualBitrate > 0 {
+		tolerance := float64(validationResult.ExpectedBitrate) * 0.1
+		diff := float64(validationResult.ActualBitrate - validationResult.ExpectedBitrate)
+		if diff < 0 {
+			diff = -diff
+		}
+		bitrateMatches = diff <= tolerance
+	}
+
+	// Override validation result: resolution mismatch = failure, others = warning
+	overrideIsValid := resolutionMatches // Only consider valid if resolution matches
+
+	// Create a map from the validation result
+	validationMap := map[string]interface{}{
+		"isValid":             overrideIsValid, // Use our override logic
+		"actualWidth":         validationResult.ActualWidth,
+		"actualHeight":        validationResult.ActualHeight,
+		"actualFPS":           validationResult.ActualFPS,
+		"actualBitrate":       validationResult.ActualBitrate,
+		"actualEncoding":      validationResult.ActualEncoding,
+		"actualGOP":           validationResult.ActualGOP,
+		"expectedWidth":       validationResult.ExpectedWidth,
+		"expectedHeight":      validationResult.ExpectedHeight,
+		"expectedFPS":         validationResult.ExpectedFPS,
+		"expectedBitrate":     validationResult.ExpectedBitrate,
+		"expectedEncoding":    input.Encoding,
+		"expectedGOP":         input.GOP,
+		"expectedRateControl": validationResult.ExpectedRateControl,
+		"bitrateSamples":      validationResult.BitrateSamples,
+		"bitrateVariation":    validationResult.BitrateVariation,
+	}
+
+	// Build warning/error messages
+	var messages []string
+	if !resolutionMatches {
+		if validationResult.ActualWidth > 0 && validationResult.ActualHeight > 0 {
+			messages = append(messages, fmt.Sprintf("RESOLUTION MISMATCH: got %dx%d, expected %dx%d",
+				validationResult.ActualWidth, validationResult.ActualHeight,
+				validationResult.ExpectedWidth, validationResult.ExpectedHeight))
+		} else {
+			messages = append(messages, "RESOLUTION VALIDATION FAILED: unable to detect actual resolution")
 		}
+	}
 
-		// Construct the URL with embedded credentials
-		fullStreamURL := fmt.Sprintf("%s://%s:%s@%s%s", parsedURI.Scheme, client.Camera.Username, client.Camera.Password, parsedURI.Host, parsedURI.RequestURI())
-		// Mark this camera as successfully configured
-		result.Success = true
-		result.AppliedConfig = map[string]interface{}{
-			"resolution": map[string]int{
-				"width":  closestResolution.Width,
-				"height": closestResolution.Height,
-			},
-			"fps":      input.FPS,
-			"bitrate":  input.Bitrate,
-			"encoding": input.Encoding,
-		}
-		result.ResolutionAdjusted = input.Width != closestResolution.Width || input.Height != closestResolution.Height
-		result.StreamURL = fullStreamURL
+	if !fpsMatches && validationResult.ActualFPS > 0 {
+		messages = append(messages, fmt.Sprintf("FPS DIFFERENCE (warning): got %.2f fps, expected %d fps",
+			validationResult.ActualFPS, validationResult.ExpectedFPS))
+	}
 
-		results[cameraID] = result
-		continue
+	if !bitrateMatches && validationResult.ExpectedBitrate > 0 && validationResult.ActualBitrate > 0 {
+		messages = append(messages, fmt.Sprintf("BITRATE DIFFERENCE (warning): got %d kbps, expected %d kbps",
+			validationResult.ActualBitrate, validationResult.ExpectedBitrate))
 	}
 
-	// Helper function to find position of a camera ID in the original order
-	findCameraPosition := func(cameraID string) int {
-		for i, id := range cameraIDs {
-			if id == cameraID {
-				return i
-			}
+	// Check for encoding mismatches
+	encodingMatches := true
+	if input.Encoding != "" && validationResult.ActualEncoding != "" {
+		encodingMatches = ffmpeg.SameEncoding(validationResult.ActualEncoding, input.Encoding)
+		if !encodingMatches {
+			messages = append(messages, fmt.Sprintf("ENCODING DIFFERENCE (warning): got %s, expected %s",
+				validationResult.ActualEncoding, input.Encoding))
 		}
-		return -1
 	}
 
-	// PHASE 2: Validate all successfully configured cameras
-	// Wait for camera configurations to stabilize
-	time.Sleep(1 * time.Second)
-
-	// Log that we're starting validation phase
-	log.Printf("===== PHASE 2: Validating all cameras in original order =====")
-	log.Printf("Original camera order: %v", cameraIDs)
-
-	// Iterate through cameras in the SAME order as the configuration phase
-	for _, cameraID := range cameraIDs {
-		result, exists := results[cameraID]
-		if !exists {
-			log.Printf("No configuration result found for camera %s, skipping validation", cameraID)
-			continue
-		}
-		if !result.Success {
-			// Skip validation for failed configs - add validation results for failed configuration cameras
-			position := findCameraPosition(cameraID)
-			log.Printf("Adding failed validation results for camera %s (position %d in original order)", cameraID, position)
-			errorMessage := "Configuration failed - camera not reachable"
-			if result.Error != nil {
-				errorMessage = result.Error.Error()
-			}
-			validationResults[cameraID] = map[string]interface{}{
-				"isValid":          false,
-				"expectedWidth":    input.Width,
-				"expectedHeight":   input.Height,
-				"expectedFPS":      input.FPS,
-				"expectedBitrate":  input.Bitrate,
-				"expectedEncoding": input.Encoding,
-				"actualWidth":      0,
-				"actualHeight":     0,
-				"actualFPS":        0.0,
-				"actualBitrate":    0,
-				"error":            errorMessage,
-			}
-			continue
-		}
-		// Validate the stream using FFmpeg CGO
-		position := findCameraPosition(cameraID)
-		log.Printf("Starting FFmpeg validation for camera %s (position %d in original order)", cameraID, position)
-		validationResult, validationErr := ffmpeg.ValidateStream(result.StreamURL, input.Width, input.Height, input.FPS, input.Bitrate, input.Encoding)
-		if validationErr != nil {
-			log.Printf("FFmpeg validation failed for camera %s: %v", cameraID, validationErr)
-			validationResults[cameraID] = map[string]interface{}{
-				"isValid":          false,
-				"error":            validationErr.Error(),
-				"expectedWidth":    input.Width,
-				"expectedHeight":   input.Height,
-				"expectedFPS":      input.FPS,
-				"expectedBitrate":  input.Bitrate,
-				"expectedEncoding": input.Encoding,
-			}
+	// GOP is measured from the keyframe spacing; a difference is a warning
+	if !ffmpeg.GOPMatches(validationResult.ActualGOP, input.GOP) {
+		if validationResult.ActualGOP > 0 {
+			messages = append(messages, fmt.Sprintf("GOP DIFFERENCE (warning): keyframes every %d frames, expected %d",
+				validationResult.ActualGOP, input.GOP))
 		} else {
-			// Determine validation status based on business rules
-			// Resolution mismatch = failure, FPS/bitrate mismatch = warning
-			resolutionMatches := validationResult.ActualWidth > 0 && validationResult.ActualHeight > 0 &&
-				validationResult.ActualWidth == validationResult.ExpectedWidth &&
-				validationResult.ActualHeight == validationResult.ExpectedHeight
-
-			fpsMatches := validationResult.ActualFPS > 0 &&
-				int(validationResult.ActualFPS+0.5) == validationResult.ExpectedFPS
-
-			bitrateMatches := true // Default to true if no expected bitrate
-			if validationResult.ExpectedBitrate > 0 && validationResult.ActualBitrate > 0 {
-				tolerance := float64(validationResult.ExpectedBitrate) * 0.1
-				diff := float64(validationResult.ActualBitrate - validationResult.ExpectedBitrate)
-				if diff < 0 {
-					diff = -diff
-				}
-				bitrateMatches = diff <= tolerance
-			}
+			messages = append(messages, fmt.Sprintf("GOP DIFFERENCE (warning): unable to measure keyframe spacing, expected %d", input.GOP))
+		}
+	}
 
-			// Override validation result: resolution mismatch = failure, others = warning
-			overrideIsValid := resolutionMatches // Only consider valid if resolution matches
-
-			// Create a map from the validation result
-			validationMap := map[string]interface{}{
-				"isValid":          overrideIsValid, // Use our override logic
-				"actualWidth":      validationResult.ActualWidth,
-				"actualHeight":     validationResult.ActualHeight,
-				"actualFPS":        validationResult.ActualFPS,
-				"actualBitrate":    validationResult.ActualBitrate,
-				"actualEncoding":   validationResult.ActualEncoding,
-				"expectedWidth":    validationResult.ExpectedWidth,
-				"expectedHeight":   validationResult.ExpectedHeight,
-				"expectedFPS":      validationResult.ExpectedFPS,
-				"expectedBitrate":  validationResult.ExpectedBitrate,
-				"expectedEncoding": input.Encoding,
-			}
+	// The bitrate of a CBR stream must stay steady; a variation beyond the tolerance is a warning
+	if !ffmpeg.RateControlMatches(validationResult.ExpectedRateControl, validationResult.BitrateVariation, validationResult.BitrateSamples) {
+		if validationResult.BitrateSamples >= 2 {
+			messages = append(messages, fmt.Sprintf("RATE CONTROL DIFFERENCE (warning): bitrate varies by %.1f%% over %d s, expected %s",
+				validationResult.BitrateVariation, validationResult.BitrateSamples, validationResult.ExpectedRateControl))
+		} else {
+			messages = append(messages, fmt.Sprintf("RATE CONTROL DIFFERENCE (warning): unable to sample the bitrate, expected %s", validationResult.ExpectedRateControl))
+		}
+	}
 
-			// Build warning/error messages
-			var messages []string
-			if !resolutionMatches {
-				if validationResult.ActualWidth > 0 && validationResult.ActualHeight > 0 {
-					messages = append(messages, fmt.Sprintf("RESOLUTION MISMATCH: got %dx%d, expected %dx%d",
-						validationResult.ActualWidth, validationResult.ActualHeight,
-						validationResult.ExpectedWidth, validationResult.ExpectedHeight))
-				} else {
-					messages = append(messages, "RESOLUTION VALIDATION FAILED: unable to detect actual resolution")
-				}
-			}
+	// Set error/warning message
+	if len(messages) > 0 {
+		validationMap["error"] = strings.Join(messages, "; ")
+	} else if validationResult.Error != "" {
+		validationMap["error"] = validationResult.Error
+	}
 
-			if !fpsMatches && validationResult.ActualFPS > 0 {
-				messages = append(messages, fmt.Sprintf("FPS DIFFERENCE (warning): got %.2f fps, expected %d fps",
-					validationResult.ActualFPS, validationResult.ExpectedFPS))
-			}
+	log.Printf("FFmpeg validation completed for camera %s: valid=%v", cameraID, validationResult.IsValid)
+	return validationMap
+}
 
-			if !bitrateMatches && validationResult.ExpectedBitrate > 0 && validationResult.ActualBitrate > 0 {
-				messages = append(messages, fmt.Sprintf("BITRATE DIFFERENCE (warning): got %d kbps, expected %d kbps",
-					validationResult.ActualBitrate, validationResult.ExpectedBitrate))
-			}
+// rollbackCamera restores the configuration a camera had before the request when its
+// validation failed and the rollback policy asks for it, then validates the stream again
+// against the restored configuration. It returns nil when no rollback was needed.
+// Nothing is written once ctx is done. The caller holds the camera's operation lock.
+func rollbackCamera(ctx context.Context, result camera.ApplyResult, input applyConfigRequest, validation map[string]interface{}, progress progressFunc) *models.RollbackResult {
+	if !result.Success || result.PreviousConfig == nil {
+		return nil
+	}
+	actualWidth, _ := validation["actualWidth"].(int)
+	actualHeight, _ := validation["actualHeight"].(int)
+	if !camera.NeedsRollback(input.RollbackPolicy, result.Resolution, models.Resolution{Width: actualWidth, Height: actualHeight}) {
+		return nil
+	}
 
-			// Check for encoding mismatches
-			encodingMatches := true
-			if input.Encoding != "" && validationResult.ActualEncoding != "" {
-				encodingMatches = strings.EqualFold(validationResult.ActualEncoding, input.Encoding)
-				if !encodingMatches {
-					messages = append(messages, fmt.Sprintf("ENCODING DIFFERENCE (warning): got %s, expected %s",
-						validationResult.ActualEncoding, input.Encoding))
-				}
-			}
+	cameraID := result.CameraID
+	previous := *result.PreviousConfig
+	reason, _ := validation["error"].(string)
+	progress.report(cameraID, jobs.PhaseRollingBack, fmt.Sprintf("restoring %dx%d @ %d fps", previous.Resolution.Width, previous.Resolution.Height, previous.FPS))
 
-			// Set error/warning message
-			if len(messages) > 0 {
-				validationMap["error"] = strings.Join(messages, "; ")
-			} else if validationResult.Error != "" {
-				validationMap["error"] = validationResult.Error
-			}
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		return &models.RollbackResult{Policy: input.RollbackPolicy, Reason: reason, RestoredConfig: previous, Error: err.Error()}
+	}
+	return camera.Rollback(ctx, client, result.ConfigToken, previous, input.RollbackPolicy, reason, result.StreamURL)
+}
 
-			log.Printf("FFmpeg validation completed for camera %s: valid=%v", cameraID, validationResult.IsValid)
-			validationResults[cameraID] = validationMap
+// validationSummary returns a short description of a validation entry for progress messages
+func validationSummary(validation map[string]interface{}) string {
+	if rollback, ok := validation["rollback"].(*models.RollbackResult); ok {
+		summary := "validation failed, " + camera.RollbackSummary(*rollback)
+		if rollback.Error != "" {
+			summary += ": " + rollback.Error
 		}
+		return summary
 	}
-	log.Printf("===== PHASE 2 COMPLETED =====")
+	if valid, _ := validation["isValid"].(bool); valid {
+		if msg, _ := validation["error"].(string); msg != "" {
+			return "validation passed with warnings: " + msg
+		}
+		return "validation passed"
+	}
+	if msg, _ := validation["error"].(string); msg != "" {
+		return "validation failed: " + msg
+	}
+	return "validation failed"
+}
+
+// buildApplyConfigResponse logs a summary of an /apply-config run and builds the response body
+func buildApplyConfigResponse(input applyConfigRequest, cameraIDs []string, results map[string]camera.ApplyResult, validationResults map[string]interface{}) map[string]interface{} {
 	// Log summary of configuration results
 	successCount := 0
 	failureCount := 0
@@ -783,37 +1061,63 @@ func HandleApplyConfig(w http.ResponseWriter, r *http.Request) {
 				"width":  input.Width,
 				"height": input.Height,
 			},
-			"fps":      input.FPS,
-			"bitrate":  input.Bitrate,
-			"encoding": input.Encoding,
+			"fps":              input.FPS,
+			"bitrate":          input.Bitrate,
+			"encoding":         input.Encoding,
+			"profile":          input.Profile,
+			"gop":              input.GOP,
+			"encoderProfile":   input.EncoderProfile,
+			"rateControl":      input.RateControl,
+			"quality":          input.Quality,
+			"encodingInterval": input.EncodingInterval,
+			"rollbackPolicy":   input.RollbackPolicy,
 		},
 		"results":             make(map[string]interface{}),
 		"configurationErrors": configurationErrors,
+		"cameraOrder":         cameraIDs,
+	}
+	if input.DryRun {
+		finalResponse["status"] = "dry run"
+		finalResponse["dryRun"] = true
 	}
 
 	// Add individual camera results
 	for cameraID, result := range results {
-		cameraResult := map[string]interface{}{
-			"success": result.Success,
-		}
+		finalResponse["results"].(map[string]interface{})[cameraID] = cameraResultResponse(result, validationResults, input.DryRun)
+	}
 
-		if result.Success {
-			cameraResult["appliedConfig"] = result.AppliedConfig
-			cameraResult["resolutionAdjusted"] = result.ResolutionAdjusted
+	return finalResponse
+}
 
-			if validation, ok := validationResults[cameraID]; ok {
-				cameraResult["validation"] = validation
-			}
-		} else if result.Error != nil {
-			cameraResult["error"] = result.Error.Error()
-		}
+// cameraResultResponse builds the response entry of one configured camera
+func cameraResultResponse(result camera.ApplyResult, validationResults map[string]interface{}, dryRun bool) map[string]interface{} {
+	cameraResult := map[string]interface{}{
+		"success": result.Success,
+	}
 
-		finalResponse["results"].(map[string]interface{})[cameraID] = cameraResult
+	if result.DeviceInfo != nil {
+		cameraResult["deviceInfo"] = result.DeviceInfo
+	}
+	if result.Profile != nil {
+		cameraResult["profile"] = result.Profile
+	}
+	if result.Plan != nil {
+		cameraResult["plan"] = result.Plan
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(finalResponse)
+	if result.Success && dryRun {
+		cameraResult["resolutionAdjusted"] = result.ResolutionAdjusted
+	} else if result.Success {
+		cameraResult["appliedConfig"] = result.AppliedConfig
+		cameraResult["resolutionAdjusted"] = result.ResolutionAdjusted
+
+		if validation, ok := validationResults[result.CameraID]; ok {
+			cameraResult["validation"] = validation
+		}
+	} else if result.Error != nil {
+		cameraResult["error"] = result.Error.Error()
+	}
+	return cameraResult
 }
 
 func HandleVLC(w http.ResponseWriter, r *http.Request) {
@@ -821,6 +1125,7 @@ func HandleVLC(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
 		CameraID string `json:"cameraId"`
+		Profile  string `json:"profile"` // Profile token, name or role; the main stream when empty
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -864,24 +1169,24 @@ func HandleVLC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get active profile
-	log.Printf("Getting active profile for camera %s", input.CameraID)
-	profileTokens, _, err := camera.GetProfilesAndConfigs(client)
+	// Get the requested profile
+	log.Printf("Getting profile %q for camera %s", input.Profile, input.CameraID)
+	profiles, err := camera.GetProfiles(client)
 	if err != nil {
 		log.Printf("Failed to get camera profiles for %s: %v", input.CameraID, err)
 		http.Error(w, fmt.Sprintf("Failed to get camera profiles: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	if len(profileTokens) == 0 {
-		log.Printf("No profiles found for camera %s", input.CameraID)
-		http.Error(w, "No profiles found", http.StatusInternalServerError)
+	profile, err := camera.SelectProfile(profiles, input.Profile)
+	if err != nil {
+		log.Printf("No usable profile for camera %s: %v", input.CameraID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Use the first profile token
-	profileToken := profileTokens[0]
-	log.Printf("Using profile token %s for camera %s", profileToken, input.CameraID)
+	profileToken := profile.Token
+	log.Printf("Using profile %s (token %s) for camera %s", profile, profileToken, input.CameraID)
 
 	// Get stream URI for the profile
 	streamURI, err := client.GetStreamURI(profileToken)
@@ -918,6 +1223,7 @@ func HandleVLC(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"message":   message,
 		"streamUrl": authenticatedStreamURI,
+		"profile":   profile,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -930,6 +1236,7 @@ func HandleExportValidationCSV(w http.ResponseWriter, r *http.Request) {
 		Validation          interface{}   `json:"validation"`
 		ConfigurationErrors []interface{} `json:"configurationErrors"`
 		CameraOrder         []string      `json:"cameraOrder"`
+		Selector            string        `json:"selector"` // Optional, exports only the matching cameras
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -973,6 +1280,29 @@ func HandleExportValidationCSV(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Leave out the cameras that do not match the selector
+	if input.Selector != "" {
+		selector, err := camera.ParseSelector(input.Selector)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		matched := make(map[string]bool)
+		for _, cam := range camera.SelectCameras(selector) {
+			matched[cam.ID] = true
+		}
+		for cameraID := range validationMap {
+			if !matched[cameraID] {
+				delete(validationMap, cameraID)
+			}
+		}
+		for cameraID := range configErrorsMap {
+			if !matched[cameraID] {
+				delete(configErrorsMap, cameraID)
+			}
+		}
+	}
+
 	// Generate CSV content
 	csvContent, err := generateValidationCSV(validationMap, configErrorsMap, input.CameraOrder, cameras)
 	if err != nil {
@@ -1000,7 +1330,7 @@ func generateValidationCSV(validation map[string]interface{}, configErrors map[s
 		cameraMap[camera.ID] = camera
 	}
 	// Write CSV header with IP column and notes
-	header := []string{"cam_id", "cam_ip", "result", "reso_expected", "reso_actual", "fps_expected", "fps_actual", "encoding_expected", "encoding_actual", "notes"}
+	header := []string{"cam_id", "cam_ip", "result", "reso_expected", "reso_actual", "fps_expected", "fps_actual", "encoding_expected", "encoding_actual", "notes", "manufacturer", "model", "firmware_version", "serial_number", "profile", "gop_expected", "gop_actual", "rate_control_expected", "bitrate_variation", "rollback", "rollback_error"}
 	if err := writer.Write(header); err != nil {
 		return "", fmt.Errorf("failed to write CSV header: %v", err)
 	}
@@ -1051,7 +1381,7 @@ func generateValidationCSV(validation map[string]interface{}, configErrors map[s
 			}
 
 			// Write CSV row for configuration error
-			row := []string{cameraID, cameraIP, "CONFIG_ERROR", "", "", "", "", "", "", fmt.Sprintf("Configuration Error: %s", errorMsg)}
+			row := []string{cameraID, cameraIP, "CONFIG_ERROR", "", "", "", "", "", "", fmt.Sprintf("Configuration Error: %s", errorMsg), "", "", "", "", "", "", "", "", "", "", ""}
 			if err := writer.Write(row); err != nil {
 				return "", fmt.Errorf("failed to write CSV row for camera %s: %v", cameraID, err)
 			}
@@ -1142,13 +1472,24 @@ func generateValidationCSV(validation map[string]interface{}, configErrors map[s
 					if actualEncoding, hasActEncoding := validationMap["actualEncoding"]; hasActEncoding {
 						if expEnc, ok1 := expectedEncoding.(string); ok1 && expEnc != "" {
 							if actEnc, ok2 := actualEncoding.(string); ok2 && actEnc != "" {
-								encodingMatches = strings.EqualFold(actEnc, expEnc)
+								encodingMatches = ffmpeg.SameEncoding(actEnc, expEnc)
 							}
 						}
 					}
 				}
 
-				if resolutionMatches && fpsMatches && bitrateMatches && encodingMatches {
+				// Check GOP match, only when a GOP was requested
+				expGOP, _ := validationMap["expectedGOP"].(float64)
+				actGOP, _ := validationMap["actualGOP"].(float64)
+				gopMatches := ffmpeg.GOPMatches(int(actGOP), int(expGOP))
+
+				// Check the bitrate variation against the rate control mode, only when one was requested
+				expRateControl, _ := validationMap["expectedRateControl"].(string)
+				variation, _ := validationMap["bitrateVariation"].(float64)
+				samples, _ := validationMap["bitrateSamples"].(float64)
+				rateControlMatches := ffmpeg.RateControlMatches(expRateControl, variation, int(samples))
+
+				if resolutionMatches && fpsMatches && bitrateMatches && encodingMatches && gopMatches && rateControlMatches {
 					result = "PASS"
 					notes.WriteString("All parameters match expected values")
 				} else if resolutionMatches {
@@ -1169,6 +1510,18 @@ func generateValidationCSV(validation map[string]interface{}, configErrors map[s
 						}
 						notes.WriteString("Encoding mismatch")
 					}
+					if !gopMatches {
+						if notes.Len() > 0 {
+							notes.WriteString("; ")
+						}
+						notes.WriteString("GOP mismatch")
+					}
+					if !rateControlMatches {
+						if notes.Len() > 0 {
+							notes.WriteString("; ")
+						}
+						notes.WriteString("Bitrate variation exceeds rate control")
+					}
 				} else {
 					// Resolution doesn't match = fail (this shouldn't happen if isValid=true, but just in case)
 					result = "FAIL"
@@ -1248,8 +1601,45 @@ func generateValidationCSV(validation map[string]interface{}, configErrors map[s
 			}
 		}
 
-		// Write CSV row with IP column and notes
-		row := []string{cameraID, cameraIP, result, resoExpected, resoActual, fpsExpected, fpsActual, encodingExpected, encodingActual, notes.String()}
+		// Device information collected while configuring the camera, if any
+		var manufacturer, model, firmwareVersion, serialNumber string
+		if deviceInfo, ok := validationMap["deviceInfo"].(map[string]interface{}); ok {
+			manufacturer, _ = deviceInfo["manufacturer"].(string)
+			model, _ = deviceInfo["model"].(string)
+			firmwareVersion, _ = deviceInfo["firmwareVersion"].(string)
+			serialNumber, _ = deviceInfo["serialNumber"].(string)
+		}
+		profile, _ := validationMap["profile"].(string)
+
+		// Format GOP expected and actual; empty when no GOP was requested or measured
+		gopExpected, gopActual := "", ""
+		if gop, ok := validationMap["expectedGOP"].(float64); ok && gop > 0 {
+			gopExpected = strconv.Itoa(int(gop))
+		}
+		if gop, ok := validationMap["actualGOP"].(float64); ok && gop > 0 {
+			gopActual = strconv.Itoa(int(gop))
+		}
+
+		// Format the requested rate control mode and the measured bitrate variation in percent
+		rateControlExpected, _ := validationMap["expectedRateControl"].(string)
+		bitrateVariation := ""
+		if samples, ok := validationMap["bitrateSamples"].(float64); ok && samples >= 2 {
+			variation, _ := validationMap["bitrateVariation"].(float64)
+			bitrateVariation = fmt.Sprintf("%.1f", variation)
+		}
+
+		// Format the outcome of a rollback; empty when the configuration was kept
+		rollbackStatus, rollbackError := "", ""
+		if rollback, ok := validationMap["rollback"].(map[string]interface{}); ok {
+			restored, _ := rollback["restored"].(bool)
+			valid, _ := rollback["valid"].(bool)
+			rollbackStatus = camera.RollbackStatus(restored, valid)
+			rollbackError, _ = rollback["error"].(string)
+		}
+
+		// Write CSV row with IP column, notes, device information, profile, GOP, rate control and rollback
+		row := []string{cameraID, cameraIP, result, resoExpected, resoActual, fpsExpected, fpsActual, encodingExpected, encodingActual, notes.String(),
+			manufacturer, model, firmwareVersion, serialNumber, profile, gopExpected, gopActual, rateControlExpected, bitrateVariation, rollbackStatus, rollbackError}
 		if err := writer.Write(row); err != nil {
 			return "", fmt.Errorf("failed to write CSV row for camera %s: %v", cameraID, err)
 		}
@@ -1449,7 +1839,7 @@ func HandleImportCamerasCSV(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Adding camera from row %d: IP=%s, Port=%d, Username=%s",
 			rowNum, cameraData.IP, cameraData.Port, cameraData.Username)
 
-		newID, err := camera.AddNewCamera(cameraData.IP, cameraData.Port, cameraData.URL, cameraData.Username, cameraData.Password)
+		newID, err := camera.AddNewCamera(cameraData.IP, cameraData.Port, cameraData.URL, "", cameraData.Username, cameraData.Password)
 		if err != nil {
 			log.Printf("Row %d: Failed to add camera: %v", rowNum, err)
 			results = append(results, map[string]interface{}{
@@ -1568,116 +1958,238 @@ func HandleImportConfigCSV(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Config CSV header parsed successfully. Found columns: %v", columnIndices)
 
-	// Process the first data row (should only be 1 row)
-	dataRow := records[1]
-	log.Printf("Processing config data row: %v", dataRow)
-
-	// Parse configuration values
-	configData := struct {
-		Width   int
-		Height  int
-		FPS     int
-		Bitrate int // Optional, defaults to 0 if not provided
-	}{
-		Bitrate: 0, // Default value for optional bitrate
+	// Each data row configures one stream; several rows need a profile column
+	// naming the profile (main, sub, name or token) each row is meant for
+	_, hasProfile := columnIndices["profile"]
+	if !hasProfile {
+		if index, exists := columnIndices["stream"]; exists {
+			columnIndices["profile"] = index
+			hasProfile = true
+		}
 	}
 
-	// Extract Width (required)
-	if widthIndex, exists := columnIndices["width"]; exists && widthIndex < len(dataRow) {
-		widthStr := strings.TrimSpace(dataRow[widthIndex])
-		if widthStr == "" {
-			log.Println("Error: Width value is empty")
-			http.Error(w, "Width value is required", http.StatusBadRequest)
-			return
-		}
-		width, err := strconv.Atoi(widthStr)
-		if err != nil || width <= 0 {
-			log.Printf("Error: Invalid width value '%s'", widthStr)
-			http.Error(w, fmt.Sprintf("Invalid width value: %s", widthStr), http.StatusBadRequest)
-			return
+	// A cam_id or ip column makes every row target its own camera
+	if _, hasIP := columnIndices["ip"]; !hasIP {
+		if index, exists := columnIndices["cam_ip"]; exists {
+			columnIndices["ip"] = index
 		}
-		configData.Width = width
-	} else {
-		log.Println("Error: Width column not found or empty")
-		http.Error(w, "Width value is required", http.StatusBadRequest)
-		return
 	}
+	_, hasIP := columnIndices["ip"]
+	_, hasCameraID := columnIndices["cam_id"]
+	perCamera := hasIP || hasCameraID
 
-	// Extract Height (required)
-	if heightIndex, exists := columnIndices["height"]; exists && heightIndex < len(dataRow) {
-		heightStr := strings.TrimSpace(dataRow[heightIndex])
-		if heightStr == "" {
-			log.Println("Error: Height value is empty")
-			http.Error(w, "Height value is required", http.StatusBadRequest)
-			return
+	var streams []streamConfig
+	var rows []configRow
+	seenProfiles := make(map[string]int)
+	for i, dataRow := range records[1:] {
+		rowNum := i + 2 // +2 because we skip header and arrays are 0-indexed
+		if isBlankRow(dataRow) {
+			continue
 		}
-		height, err := strconv.Atoi(heightStr)
-		if err != nil || height <= 0 {
-			log.Printf("Error: Invalid height value '%s'", heightStr)
-			http.Error(w, fmt.Sprintf("Invalid height value: %s", heightStr), http.StatusBadRequest)
+		log.Printf("Processing config data row %d: %v", rowNum, dataRow)
+
+		stream, err := parseConfigRow(columnIndices, dataRow)
+		if err != nil {
+			log.Printf("Error in config CSV row %d: %v", rowNum, err)
+			http.Error(w, fmt.Sprintf("Row %d: %v", rowNum, err), http.StatusBadRequest)
 			return
 		}
-		configData.Height = height
-	} else {
-		log.Println("Error: Height column not found or empty")
-		http.Error(w, "Height value is required", http.StatusBadRequest)
-		return
-	}
 
-	// Extract FPS (required)
-	if fpsIndex, exists := columnIndices["fps"]; exists && fpsIndex < len(dataRow) {
-		fpsStr := strings.TrimSpace(dataRow[fpsIndex])
-		if fpsStr == "" {
-			log.Println("Error: FPS value is empty")
-			http.Error(w, "FPS value is required", http.StatusBadRequest)
-			return
+		key := strings.ToLower(stream.Profile)
+		if perCamera {
+			row := configRow{Row: rowNum, streamConfig: stream}
+			if index, exists := columnIndices["cam_id"]; exists && index < len(dataRow) {
+				row.CameraID = strings.TrimSpace(dataRow[index])
+			}
+			if index, exists := columnIndices["ip"]; exists && index < len(dataRow) {
+				row.IP = strings.TrimSpace(dataRow[index])
+			}
+			if row.CameraID == "" && row.IP == "" {
+				log.Printf("Error in config CSV row %d: no camera", rowNum)
+				http.Error(w, fmt.Sprintf("Row %d: ip or cam_id value is required", rowNum), http.StatusBadRequest)
+				return
+			}
+			rows = append(rows, row)
+			key = strings.ToLower(row.CameraID + "/" + row.IP + "/" + stream.Profile)
 		}
-		fps, err := strconv.Atoi(fpsStr)
-		if err != nil || fps <= 0 {
-			log.Printf("Error: Invalid FPS value '%s'", fpsStr)
-			http.Error(w, fmt.Sprintf("Invalid FPS value: %s", fpsStr), http.StatusBadRequest)
+		if previous, exists := seenProfiles[key]; exists {
+			target := "profile"
+			if perCamera {
+				target = "camera and profile"
+			}
+			log.Printf("Error: config CSV rows %d and %d target the same %s %q", previous, rowNum, target, stream.Profile)
+			http.Error(w, fmt.Sprintf("Rows %d and %d target the same %s %q", previous, rowNum, target, stream.Profile), http.StatusBadRequest)
 			return
 		}
-		configData.FPS = fps
-	} else {
-		log.Println("Error: FPS column not found or empty")
-		http.Error(w, "FPS value is required", http.StatusBadRequest)
+		seenProfiles[key] = rowNum
+		streams = append(streams, stream)
+	}
+
+	if len(streams) == 0 {
+		log.Println("Error: CSV file contains no configuration rows")
+		http.Error(w, "CSV file must contain header and configuration data", http.StatusBadRequest)
 		return
 	}
 
-	// Extract Bitrate (optional)
-	if bitrateIndex, exists := columnIndices["bitrate"]; exists && bitrateIndex < len(dataRow) {
-		bitrateStr := strings.TrimSpace(dataRow[bitrateIndex])
-		if bitrateStr != "" {
-			bitrate, err := strconv.Atoi(bitrateStr)
-			if err != nil || bitrate < 0 {
-				log.Printf("Warning: Invalid bitrate value '%s', using default 0", bitrateStr)
-			} else {
-				configData.Bitrate = bitrate
-			}
-		}
+	if len(streams) > 1 && !hasProfile && !perCamera {
+		log.Println("Error: config CSV has several rows but no profile column")
+		http.Error(w, "A profile column is required when the CSV contains more than one configuration row", http.StatusBadRequest)
+		return
 	}
 
-	log.Printf("Parsed config from CSV: Width=%d, Height=%d, FPS=%d, Bitrate=%d",
-		configData.Width, configData.Height, configData.FPS, configData.Bitrate)
+	configData := streams[0]
+	log.Printf("Parsed config from CSV: Profile=%q, Width=%d, Height=%d, FPS=%d, Bitrate=%d, Encoding=%s (%d stream(s))",
+		configData.Profile, configData.Width, configData.Height, configData.FPS, configData.Bitrate, configData.Encoding, len(streams))
 
-	// Prepare response with parsed configuration
+	// Prepare response with parsed configuration; "config" is the first row,
+	// "streams" holds every row and can be sent as is to /apply-config
 	response := map[string]interface{}{
 		"message": "Configuration CSV imported successfully",
 		"config": map[string]interface{}{
-			"width":   configData.Width,
-			"height":  configData.Height,
-			"fps":     configData.FPS,
-			"bitrate": configData.Bitrate,
+			"width":            configData.Width,
+			"height":           configData.Height,
+			"fps":              configData.FPS,
+			"bitrate":          configData.Bitrate,
+			"encoding":         configData.Encoding,
+			"profile":          configData.Profile,
+			"gop":              configData.GOP,
+			"encoderProfile":   configData.EncoderProfile,
+			"rateControl":      configData.RateControl,
+			"quality":          configData.Quality,
+			"encodingInterval": configData.EncodingInterval,
 		},
-		"status": "ready_to_apply",
+		"streams": streams,
+		"status":  "ready_to_apply",
+	}
+	if perCamera {
+		// "rows" can be sent as is to /apply-config; each row names its camera
+		var rowErrors []map[string]interface{}
+		for i, row := range rows {
+			cameraID, err := resolveConfigRow(row)
+			if err != nil {
+				rowErrors = append(rowErrors, map[string]interface{}{"row": row.Row, "error": err.Error()})
+				continue
+			}
+			rows[i].CameraID = cameraID
+		}
+		delete(response, "streams")
+		response["rows"] = rows
+		if len(rowErrors) > 0 {
+			response["rowErrors"] = rowErrors
+		}
+		log.Printf("Config CSV has %d per-camera rows, %d without a camera in the inventory", len(rows), len(rowErrors))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 
-	log.Printf("Config CSV import completed successfully: %+v", configData)
+	log.Printf("Config CSV import completed successfully: %+v", streams)
+}
+
+// parseConfigRow reads the stream configuration of one config CSV row.
+// Width, height and FPS are required; bitrate, encoding, profile, gop, encoder_profile,
+// rate_control, quality and encoding_interval are optional.
+func parseConfigRow(columnIndices map[string]int, dataRow []string) (streamConfig, error) {
+	var stream streamConfig
+
+	value := func(column string) string {
+		if index, exists := columnIndices[column]; exists && index < len(dataRow) {
+			return strings.TrimSpace(dataRow[index])
+		}
+		return ""
+	}
+
+	// Extract Width (required)
+	widthStr := value("width")
+	if widthStr == "" {
+		return stream, fmt.Errorf("width value is required")
+	}
+	width, err := strconv.Atoi(widthStr)
+	if err != nil || width <= 0 {
+		return stream, fmt.Errorf("invalid width value: %s", widthStr)
+	}
+	stream.Width = width
+
+	// Extract Height (required)
+	heightStr := value("height")
+	if heightStr == "" {
+		return stream, fmt.Errorf("height value is required")
+	}
+	height, err := strconv.Atoi(heightStr)
+	if err != nil || height <= 0 {
+		return stream, fmt.Errorf("invalid height value: %s", heightStr)
+	}
+	stream.Height = height
+
+	// Extract FPS (required)
+	fpsStr := value("fps")
+	if fpsStr == "" {
+		return stream, fmt.Errorf("FPS value is required")
+	}
+	fps, err := strconv.Atoi(fpsStr)
+	if err != nil || fps <= 0 {
+		return stream, fmt.Errorf("invalid FPS value: %s", fpsStr)
+	}
+	stream.FPS = fps
+
+	// Extract Bitrate (optional)
+	if bitrateStr := value("bitrate"); bitrateStr != "" {
+		bitrate, err := strconv.Atoi(bitrateStr)
+		if err != nil || bitrate < 0 {
+			log.Printf("Warning: Invalid bitrate value '%s', using default 0", bitrateStr)
+		} else {
+			stream.Bitrate = bitrate
+		}
+	}
+
+	// Extract Encoding and Profile (optional)
+	stream.Encoding = strings.ToUpper(value("encoding"))
+	stream.Profile = value("profile")
+
+	// Extract GOP and encoder profile (optional); the camera keeps its current values when empty
+	if gopStr := value("gop"); gopStr != "" {
+		gop, err := strconv.Atoi(gopStr)
+		if err != nil || gop <= 0 {
+			return stream, fmt.Errorf("invalid gop value: %s", gopStr)
+		}
+		stream.GOP = gop
+	}
+	stream.EncoderProfile = camera.NormalizeEncoderProfile(value("encoder_profile"))
+
+	// Extract rate control mode, quality and encoding interval (optional)
+	if rateControl := camera.NormalizeRateControl(value("rate_control")); rateControl != "" {
+		if rateControl != camera.RateControlCBR && rateControl != camera.RateControlVBR {
+			return stream, fmt.Errorf("invalid rate_control value: %s (use CBR or VBR)", value("rate_control"))
+		}
+		stream.RateControl = rateControl
+	}
+	if qualityStr := value("quality"); qualityStr != "" {
+		quality, err := strconv.Atoi(qualityStr)
+		if err != nil || quality <= 0 {
+			return stream, fmt.Errorf("invalid quality value: %s", qualityStr)
+		}
+		stream.Quality = quality
+	}
+	if intervalStr := value("encoding_interval"); intervalStr != "" {
+		interval, err := strconv.Atoi(intervalStr)
+		if err != nil || interval <= 0 {
+			return stream, fmt.Errorf("invalid encoding_interval value: %s", intervalStr)
+		}
+		stream.EncodingInterval = interval
+	}
+
+	return stream, nil
+}
+
+// isBlankRow reports whether every field of a CSV row is empty
+func isBlankRow(row []string) bool {
+	for _, field := range row {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
 }
 
 func HandleChooseCamFromCSV(w http.ResponseWriter, r *http.Request) {
@@ -1849,6 +2361,14 @@ func HandleConfigSingleCam(w http.ResponseWriter, r *http.Request) {
 		FPS      int    `json:"fps"`
 		Bitrate  int    `json:"bitrate"`
 		Encoding string `json:"encoding"`
+		Profile  string `json:"profile"` // Profile token, name or role; the main stream when empty
+
+		GOP            int    `json:"gop"`            // Optional; the current GOP is kept when 0
+		EncoderProfile string `json:"encoderProfile"` // Optional H.264/H.265 profile, e.g. High
+
+		RateControl      string `json:"rateControl"`      // Optional CBR or VBR
+		Quality          int    `json:"quality"`          // Optional; the current quality is kept when 0
+		EncodingInterval int    `json:"encodingInterval"` // Optional; encode every n-th frame
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -1897,8 +2417,27 @@ func HandleConfigSingleCam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Configuring camera %s: Width=%d, Height=%d, FPS=%d, Bitrate=%d, Encoding=%s",
-		cameraID, input.Width, input.Height, input.FPS, input.Bitrate, input.Encoding)
+	if input.GOP < 0 {
+		log.Printf("Invalid GOP %d for camera %s (must be positive)", input.GOP, cameraID)
+		http.Error(w, "GOP must be a positive number of frames", http.StatusBadRequest)
+		return
+	}
+
+	input.RateControl = camera.NormalizeRateControl(input.RateControl)
+	if input.RateControl != "" && input.RateControl != camera.RateControlCBR && input.RateControl != camera.RateControlVBR {
+		log.Printf("Invalid rate control '%s' for camera %s (must be CBR or VBR)", input.RateControl, cameraID)
+		http.Error(w, "Rate control must be CBR or VBR", http.StatusBadRequest)
+		return
+	}
+
+	if input.Quality < 0 || input.EncodingInterval < 0 {
+		log.Printf("Invalid quality %d or encoding interval %d for camera %s", input.Quality, input.EncodingInterval, cameraID)
+		http.Error(w, "Quality and encoding interval must be positive", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Configuring camera %s: Width=%d, Height=%d, FPS=%d, Bitrate=%d, Encoding=%s, GOP=%d, EncoderProfile=%q, RateControl=%q, Quality=%d, EncodingInterval=%d",
+		cameraID, input.Width, input.Height, input.FPS, input.Bitrate, input.Encoding, input.GOP, input.EncoderProfile, input.RateControl, input.Quality, input.EncodingInterval)
 
 	// Load cameras from CSV to find the requested camera
 	cameras, err := loader.LoadCameraList()
@@ -1923,6 +2462,10 @@ func HandleConfigSingleCam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Serialize with other operations on this camera
+	unlock := camera.LockCamera(targetCamera.ID)
+	defer unlock()
+
 	// Initialize camera client
 	client, err := camera.NewCameraClient(*targetCamera)
 	if err != nil {
@@ -1933,30 +2476,24 @@ func HandleConfigSingleCam(w http.ResponseWriter, r *http.Request) {
 
 	// Proceed with actual configuration
 	log.Printf("Getting profiles and configs for camera %s", targetCamera.ID)
-	profileTokens, configTokens, err := camera.GetProfilesAndConfigs(client)
+	profiles, err := camera.GetProfiles(client)
 	if err != nil {
 		log.Printf("Failed to get camera profiles and configs for %s: %v", targetCamera.ID, err)
 		http.Error(w, fmt.Sprintf("Failed to get camera profiles and configs: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	if len(profileTokens) == 0 {
-		log.Printf("No profiles found for camera %s", targetCamera.ID)
-		http.Error(w, "No profiles found", http.StatusInternalServerError)
-		return
-	}
-
-	if len(configTokens) == 0 {
-		log.Printf("No video encoder configuration found for camera %s", targetCamera.ID)
-		http.Error(w, "No video encoder configuration found", http.StatusInternalServerError)
+	profile, err := camera.SelectProfile(profiles, input.Profile)
+	if err != nil {
+		log.Printf("No usable profile for camera %s: %v", targetCamera.ID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Use the first token found
-	profileToken := profileTokens[0]
-	configToken := configTokens[0]
+	profileToken := profile.Token
+	configToken := profile.ConfigToken
 
-	log.Printf("Using profile token %s and config token %s for camera %s", profileToken, configToken, targetCamera.ID)
+	log.Printf("Using profile %s (token %s, config token %s) for camera %s", profile, profileToken, configToken, targetCamera.ID)
 
 	// Get current encoder config for quality value
 	currentConfig, err := camera.GetCurrentConfig(client, configToken)
@@ -1967,25 +2504,42 @@ func HandleConfigSingleCam(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get available encoder options to find closest resolution
-	encoderOptions, err := camera.GetCurrentEncoderOptions(client, profileToken, configToken)
+	encoderOptions, err := camera.GetEncoderOptions(client, profileToken, configToken)
 	if err != nil {
 		log.Printf("Failed to get encoder options for %s: %v", targetCamera.ID, err)
 		http.Error(w, fmt.Sprintf("Failed to get encoder options: %v", err), http.StatusInternalServerError)
 		return
 	}
+	encodingOptions, err := camera.SelectEncodingOptions(encoderOptions, camera.TargetEncoding(input.Encoding, currentConfig.Encoding))
+	if err != nil {
+		log.Printf("Requested encoding not available on camera %s: %v", targetCamera.ID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Find closest matching resolution
 	targetResolution := models.Resolution{Width: input.Width, Height: input.Height}
-	closestResolution := camera.FindClosestResolution(targetResolution, encoderOptions.Resolutions)
+	closestResolution := camera.FindClosestResolution(targetResolution, encodingOptions.Resolutions)
 	log.Printf("Closest resolution found for camera %s: %dx%d", targetCamera.ID, closestResolution.Width, closestResolution.Height)
 
 	// Create new configuration
 	newConfig := models.EncoderConfig{
-		Resolution: closestResolution,
-		Quality:    currentConfig.Quality, // Keep the current quality
-		FPS:        input.FPS,
-		Bitrate:    input.Bitrate,
-		Encoding:   input.Encoding,
+		Resolution:       closestResolution,
+		Quality:          input.Quality, // 0 keeps the current quality
+		FPS:              input.FPS,
+		Bitrate:          input.Bitrate,
+		Encoding:         input.Encoding,
+		GOP:              input.GOP,
+		EncoderProfile:   camera.NormalizeEncoderProfile(input.EncoderProfile),
+		RateControl:      input.RateControl,
+		EncodingInterval: input.EncodingInterval,
+	}
+
+	// Reject values the camera does not offer before anything is written
+	if err := camera.ValidateEncoderConfig(encodingOptions, newConfig); err != nil {
+		log.Printf("Requested config not supported by camera %s: %v", targetCamera.ID, err)
+		http.Error(w, fmt.Sprintf("Requested config not supported: %v", err), http.StatusBadRequest)
+		return
 	}
 
 	// Apply the configuration
@@ -2004,6 +2558,7 @@ func HandleConfigSingleCam(w http.ResponseWriter, r *http.Request) {
 		"cameraId": targetCamera.ID,
 		"status":   "success",
 		"message":  "Configuration applied successfully",
+		"profile":  profile,
 		"appliedConfig": map[string]interface{}{
 			"resolution": map[string]int{
 				"width":  closestResolution.Width,
@@ -2012,6 +2567,11 @@ func HandleConfigSingleCam(w http.ResponseWriter, r *http.Request) {
 			"fps":                input.FPS,
 			"bitrate":            input.Bitrate,
 			"encoding":           input.Encoding,
+			"gop":                input.GOP,
+			"encoderProfile":     newConfig.EncoderProfile,
+			"rateControl":        input.RateControl,
+			"quality":            input.Quality,
+			"encodingInterval":   input.EncodingInterval,
 			"resolutionAdjusted": input.Width != closestResolution.Width || input.Height != closestResolution.Height,
 		},
 	}
@@ -2057,6 +2617,10 @@ func HandleValidateCam(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Found camera %s (IP: %s:%d)", targetCamera.ID, targetCamera.IP, targetCamera.Port)
 
+	// Serialize with other operations on this camera
+	unlock := camera.LockCamera(targetCamera.ID)
+	defer unlock()
+
 	// Create a camera client for this specific camera
 	client, err := camera.NewCameraClient(*targetCamera)
 	if err != nil {
@@ -2065,43 +2629,56 @@ func HandleValidateCam(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	response, status, err := validateCurrentStream(client, r.URL.Query().Get("profile"))
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// validateCurrentStream validates the stream of a camera profile against the camera's current
+// encoder configuration, the main stream when profileRef is empty. On failure it also returns
+// the HTTP status that fits the error.
+func validateCurrentStream(client *camera.CameraClient, profileRef string) (map[string]interface{}, int, error) {
+	cameraID := client.Camera.ID
+
 	// Get current camera configuration to use as expected values
 	log.Printf("Getting current configuration for camera %s", cameraID)
-	profileTokens, configTokens, err := camera.GetProfilesAndConfigs(client)
+	profiles, err := camera.GetProfiles(client)
 	if err != nil {
 		log.Printf("Failed to get camera profiles and configs for %s: %v", cameraID, err)
-		http.Error(w, fmt.Sprintf("Failed to get camera profiles and configs: %v", err), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to get camera profiles and configs: %v", err)
 	}
 
-	if len(profileTokens) == 0 || len(configTokens) == 0 {
-		log.Printf("No profiles or configs found for camera %s", cameraID)
-		http.Error(w, "No profiles or configs found", http.StatusInternalServerError)
-		return
+	// Validate the profile asked for, the main stream by default
+	profile, err := camera.SelectProfile(profiles, profileRef)
+	if err != nil {
+		log.Printf("No usable profile for camera %s: %v", cameraID, err)
+		return nil, http.StatusBadRequest, err
 	}
 
 	// Get current encoder config
-	currentConfig, err := camera.GetCurrentConfig(client, configTokens[0])
+	currentConfig, err := camera.GetCurrentConfig(client, profile.ConfigToken)
 	if err != nil {
 		log.Printf("Failed to get current encoder config for %s: %v", cameraID, err)
-		http.Error(w, fmt.Sprintf("Failed to get current encoder config: %v", err), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to get current encoder config: %v", err)
 	}
 
 	// Get stream URI
-	streamURI, err := client.GetStreamURI(profileTokens[0])
+	streamURI, err := client.GetStreamURI(profile.Token)
 	if err != nil {
 		log.Printf("Failed to get stream URI for camera %s: %v", cameraID, err)
-		http.Error(w, fmt.Sprintf("Failed to get stream URI: %v", err), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to get stream URI: %v", err)
 	}
 
 	// Add credentials to the stream URL
 	parsedURI, err := url.Parse(streamURI)
 	if err != nil {
 		log.Printf("Failed to parse stream URI for camera %s: %v", cameraID, err)
-		http.Error(w, fmt.Sprintf("Failed to parse stream URI: %v", err), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to parse stream URI: %v", err)
 	}
 
 	// Add username and password to the URL
@@ -2118,26 +2695,25 @@ func HandleValidateCam(w http.ResponseWriter, r *http.Request) {
 		currentConfig.FPS,
 		currentConfig.Bitrate,
 		currentConfig.Encoding,
+		currentConfig.GOP,
+		currentConfig.RateControl,
 	)
 
 	if err != nil {
 		log.Printf("Failed to validate stream for camera %s: %v", cameraID, err)
-		http.Error(w, fmt.Sprintf("Failed to validate stream: %v", err), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to validate stream: %v", err)
 	}
 
 	log.Printf("Validation completed for camera %s: valid=%t", cameraID, validationResult.IsValid)
 
 	// Prepare response
-	response := map[string]interface{}{
-		"cameraId":         targetCamera.ID,
+	return map[string]interface{}{
+		"cameraId":         cameraID,
+		"profile":          profile,
 		"isValid":          validationResult.IsValid,
 		"message":          validationResult.Error,
 		"validationResult": validationResult,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	}, http.StatusOK, nil
 }
 
 // pingHost checks if a host is reachable via ping
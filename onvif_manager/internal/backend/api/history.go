@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"onvif_manager/internal/backend/history"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleGetConfigHistory returns the recorded history of the encoder configurations of a camera:
+// every configuration seen or applied with its time, and the settings that drifted from the
+// last applied configuration
+func HandleGetConfigHistory(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	log.Printf("Received config-history request for camera ID: %s", cameraID)
+
+	histories, err := history.Shared.Get().History(cameraID)
+	if err != nil {
+		log.Printf("Error reading config history of camera %s: %v", cameraID, err)
+		http.Error(w, fmt.Sprintf("Failed to read config history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	drifted := false
+	for _, configHistory := range histories {
+		drifted = drifted || configHistory.Drifted()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cameraId": cameraID,
+		"drifted":  drifted,
+		"configs":  histories,
+	})
+}
+
+// HandleRevertConfig writes the last applied encoder configuration back to a camera, for
+// every configuration that drifted or only for the configToken given in the request body
+func HandleRevertConfig(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	log.Printf("Received config revert request for camera ID: %s", cameraID)
+
+	var input struct {
+		ConfigToken string `json:"configToken"`
+	}
+	// The body is optional
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := history.Revert(history.Shared.Get(), cameraID, input.ConfigToken)
+	if err != nil {
+		log.Printf("Error reverting config of camera %s: %v", cameraID, err)
+		http.Error(w, fmt.Sprintf("Failed to revert config: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cameraId": cameraID,
+		"reverted": results,
+	})
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/jobs"
+	"onvif_manager/internal/backend/rollout"
+	"onvif_manager/internal/backend/templates"
+
+	"github.com/gorilla/mux"
+)
+
+// jobManager keeps the asynchronous jobs started through the API
+var jobManager = jobs.NewManager()
+
+// sseKeepAliveInterval is how often an idle event stream sends a comment so proxies keep it open
+const sseKeepAliveInterval = 15 * time.Second
+
+// HandleCreateApplyConfigJob starts an /apply-config run in the background and returns its job ID
+func HandleCreateApplyConfigJob(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received /jobs/apply-config request")
+	var input applyConfigRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		log.Printf("Error decoding apply-config job request body: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	cameraIDs, err := input.targetCameraIDs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(cameraIDs) == 0 {
+		log.Println("Error: No camera IDs provided in job request")
+		http.Error(w, "No camera IDs provided", http.StatusBadRequest)
+		return
+	}
+	policy, err := camera.ParseRollbackPolicy(input.RollbackPolicy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.RollbackPolicy = policy
+	if input.Template != "" {
+		if _, err := templates.Shared.Get().Get(input.Template); err != nil {
+			http.Error(w, err.Error(), templateErrorStatus(err))
+			return
+		}
+	}
+	if input.Rollout != nil {
+		if input.DryRun {
+			http.Error(w, "A dry run cannot be rolled out in waves", http.StatusBadRequest)
+			return
+		}
+		waves, err := rollout.Waves(cameraIDs, *input.Rollout)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		startRolloutJob(w, cameraIDs, waves, input)
+		return
+	}
+
+	job := jobManager.Create("apply-config", cameraIDs)
+	log.Printf("Created apply-config job %s for %d camera(s)", job.ID(), len(cameraIDs))
+
+	go func() {
+		job.Start()
+		// The job outlives the HTTP request, so it must not use the request context
+		job.Complete(runApplyConfigRequest(context.Background(), cameraIDs, input, job.SetPhase))
+		log.Printf("Apply-config job %s completed", job.ID())
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId":     job.ID(),
+		"status":    jobs.StatusPending,
+		"cameraIds": cameraIDs,
+	})
+}
+
+// HandleListJobs returns all jobs known to the server, newest first
+func HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobManager.List())
+}
+
+// HandleGetJob returns the per-camera progress of a job and, once finished, its result
+func HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+
+	job, err := jobManager.Get(jobID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.Snapshot())
+}
+
+// HandleJobEvents streams the progress events of a job as Server-Sent Events.
+// Every event is sent with its sequence number as the SSE id, so a client that
+// reconnects with Last-Event-ID resumes where it left off. The stream ends after
+// the job's final status event.
+func HandleJobEvents(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+
+	job, err := jobManager.Get(jobID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported by this connection", http.StatusInternalServerError)
+		return
+	}
+
+	lastSeq := 0
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		if seq, err := strconv.Atoi(lastEventID); err == nil {
+			lastSeq = seq
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		events, changed, finished := job.EventsSince(lastSeq)
+		for _, event := range events {
+			if err := writeSSEEvent(w, event); err != nil {
+				log.Printf("Event stream for job %s closed: %v", jobID, err)
+				return
+			}
+			lastSeq = event.Seq
+		}
+		flusher.Flush()
+
+		if finished {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSEEvent writes one job event in Server-Sent Events format. Camera phase
+// changes use the "phase" event type and job status changes use "status".
+func writeSSEEvent(w http.ResponseWriter, event jobs.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	eventType := "phase"
+	if event.CameraID == "" {
+		eventType = "status"
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, eventType, data)
+	return err
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"onvif_manager/internal/backend/jobs"
+
+	"github.com/gorilla/mux"
+)
+
+// sseEvent is an event read from a Server-Sent Events stream
+type sseEvent struct {
+	id        string
+	eventType string
+}
+
+// readEvents requests the event stream of a job and reads it until the server ends it
+func readEvents(t *testing.T, server *httptest.Server, jobID, lastEventID string) []sseEvent {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/jobs/"+jobID+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET events error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET events = %d %s, want 200 text/event-stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.eventType = strings.TrimPrefix(line, "event: ")
+		case line == "" && current.id != "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("reading events: %v", err)
+	}
+	return events
+}
+
+// newJobServer serves the job routes
+func newJobServer(t *testing.T) *httptest.Server {
+	router := mux.NewRouter()
+	router.HandleFunc("/jobs/{id}", HandleGetJob).Methods("GET")
+	router.HandleFunc("/jobs/{id}/events", HandleJobEvents).Methods("GET")
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandleJobEvents(t *testing.T) {
+	server := newJobServer(t)
+
+	finished := jobManager.Create("apply-config", []string{"1"})
+	finished.Start()
+	finished.SetPhase("1", jobs.PhaseConnecting, "connecting")
+	finished.SetPhase("1", jobs.PhaseDone, "valid")
+	finished.Complete(nil)
+
+	all := []sseEvent{{"1", "status"}, {"2", "phase"}, {"3", "phase"}, {"4", "status"}}
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []sseEvent
+	}{
+		{name: "from the start", want: all},
+		{name: "resumed", lastEventID: "2", want: all[2:]},
+		{name: "resumed after the last event", lastEventID: "4", want: nil},
+		{name: "invalid Last-Event-ID", lastEventID: "latest", want: all},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readEvents(t, server, finished.ID(), tt.lastEventID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleJobEventsLive(t *testing.T) {
+	server := newJobServer(t)
+	job := jobManager.Create("apply-config", []string{"1"})
+	job.Start()
+
+	// The stream replays the first event, then follows the job until it finishes
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		job.SetPhase("1", jobs.PhaseConfiguring, "setting 1920x1080 @ 25 fps")
+		job.Complete(nil)
+	}()
+
+	want := []sseEvent{{"1", "status"}, {"2", "phase"}, {"3", "status"}}
+	if got := readEvents(t, server, job.ID(), ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestHandleGetJobNotFound(t *testing.T) {
+	server := newJobServer(t)
+	for _, path := range []string{"/jobs/missing", "/jobs/missing/events"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+
+	"github.com/gorilla/mux"
+)
+
+// labelsRequest is the body of the group and tag requests
+type labelsRequest struct {
+	CameraIDs []string `json:"cameraIds"`
+	Groups    []string `json:"groups"`
+	Tags      []string `json:"tags"`
+}
+
+// decodeLabelsRequest decodes a group or tag request body
+func decodeLabelsRequest(w http.ResponseWriter, r *http.Request) (*labelsRequest, bool) {
+	var input labelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return &input, true
+}
+
+// updateLabels applies a group or tag change and responds with the changed cameras
+func updateLabels(w http.ResponseWriter, cameraIDs []string, update func(cam *models.Camera)) {
+	if err := camera.UpdateCameraLabels(cameraIDs, update); err != nil {
+		log.Printf("Error updating camera labels: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cameras := []models.Camera{}
+	for _, id := range cameraIDs {
+		if cam, ok := camera.DefaultRegistry().Camera(id); ok {
+			cameras = append(cameras, cam)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cameras)
+}
+
+// labelParam returns the normalized group or tag name of a route variable
+func labelParam(w http.ResponseWriter, r *http.Request, variable string) (string, bool) {
+	name, err := camera.NormalizeLabel(mux.Vars(r)[variable])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
+
+// HandleGetGroups lists every group with its cameras
+func HandleGetGroups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(camera.GetGroups())
+}
+
+// HandleSetGroup makes the cameras in the request body the members of a group,
+// creating the group or replacing its members
+func HandleSetGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := labelParam(w, r, "name")
+	if !ok {
+		return
+	}
+	input, ok := decodeLabelsRequest(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("Setting members of group %s: %v", group, input.CameraIDs)
+
+	var cameraIDs []string
+	for _, cam := range camera.GetAllCameras() {
+		cameraIDs = append(cameraIDs, cam.ID)
+	}
+	for _, id := range input.CameraIDs {
+		if _, found := camera.DefaultRegistry().Camera(id); !found {
+			http.Error(w, fmt.Sprintf("camera with ID %s not found", id), http.StatusBadRequest)
+			return
+		}
+	}
+
+	members := make(map[string]bool, len(input.CameraIDs))
+	for _, id := range input.CameraIDs {
+		members[id] = true
+	}
+	if err := camera.UpdateCameraLabels(cameraIDs, func(cam *models.Camera) {
+		if members[cam.ID] {
+			cam.Groups = camera.AddLabels(cam.Groups, []string{group})
+		} else {
+			cam.Groups = camera.RemoveLabels(cam.Groups, []string{group})
+		}
+	}); err != nil {
+		log.Printf("Error setting members of group %s: %v", group, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(camera.LabelSet{Name: group, CameraIDs: append([]string{}, input.CameraIDs...)})
+}
+
+// HandleDeleteGroup removes a group from every camera
+func HandleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := labelParam(w, r, "name")
+	if !ok {
+		return
+	}
+	log.Printf("Deleting group %s", group)
+
+	var cameraIDs []string
+	for _, cam := range camera.GetAllCameras() {
+		cameraIDs = append(cameraIDs, cam.ID)
+	}
+	if err := camera.UpdateCameraLabels(cameraIDs, func(cam *models.Camera) {
+		cam.Groups = camera.RemoveLabels(cam.Groups, []string{group})
+	}); err != nil {
+		log.Printf("Error deleting group %s: %v", group, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAddGroupCameras adds the cameras in the request body to a group
+func HandleAddGroupCameras(w http.ResponseWriter, r *http.Request) {
+	group, ok := labelParam(w, r, "name")
+	if !ok {
+		return
+	}
+	input, ok := decodeLabelsRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(input.CameraIDs) == 0 {
+		http.Error(w, "No camera IDs provided", http.StatusBadRequest)
+		return
+	}
+	log.Printf("Adding cameras %v to group %s", input.CameraIDs, group)
+
+	updateLabels(w, input.CameraIDs, func(cam *models.Camera) {
+		cam.Groups = camera.AddLabels(cam.Groups, []string{group})
+	})
+}
+
+// HandleRemoveGroupCamera removes a camera from a group
+func HandleRemoveGroupCamera(w http.ResponseWriter, r *http.Request) {
+	group, ok := labelParam(w, r, "name")
+	if !ok {
+		return
+	}
+	cameraID := mux.Vars(r)["id"]
+	log.Printf("Removing camera %s from group %s", cameraID, group)
+
+	updateLabels(w, []string{cameraID}, func(cam *models.Camera) {
+		cam.Groups = camera.RemoveLabels(cam.Groups, []string{group})
+	})
+}
+
+// HandleSetCameraGroups replaces the groups of a camera
+func HandleSetCameraGroups(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	input, ok := decodeLabelsRequest(w, r)
+	if !ok {
+		return
+	}
+	groups, err := camera.NormalizeLabels(input.Groups)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Setting groups of camera %s: %v", cameraID, groups)
+
+	updateLabels(w, []string{cameraID}, func(cam *models.Camera) {
+		cam.Groups = groups
+	})
+}
+
+// HandleGetTags lists every tag with its cameras
+func HandleGetTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(camera.GetTags())
+}
+
+// HandleSetCameraTags replaces the tags of a camera
+func HandleSetCameraTags(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	input, ok := decodeLabelsRequest(w, r)
+	if !ok {
+		return
+	}
+	tags, err := camera.NormalizeLabels(input.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Setting tags of camera %s: %v", cameraID, tags)
+
+	updateLabels(w, []string{cameraID}, func(cam *models.Camera) {
+		cam.Tags = tags
+	})
+}
+
+// HandleAddCameraTags adds the tags in the request body to a camera
+func HandleAddCameraTags(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	input, ok := decodeLabelsRequest(w, r)
+	if !ok {
+		return
+	}
+	tags, err := camera.NormalizeLabels(input.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(tags) == 0 {
+		http.Error(w, "No tags provided", http.StatusBadRequest)
+		return
+	}
+	log.Printf("Adding tags %v to camera %s", tags, cameraID)
+
+	updateLabels(w, []string{cameraID}, func(cam *models.Camera) {
+		cam.Tags = camera.AddLabels(cam.Tags, tags)
+	})
+}
+
+// HandleRemoveCameraTag removes a tag from a camera
+func HandleRemoveCameraTag(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	tag, ok := labelParam(w, r, "tag")
+	if !ok {
+		return
+	}
+	log.Printf("Removing tag %s from camera %s", tag, cameraID)
+
+	updateLabels(w, []string{cameraID}, func(cam *models.Camera) {
+		cam.Tags = camera.RemoveLabels(cam.Tags, []string{tag})
+	})
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"onvif_manager/internal/backend/camera"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleGetProfiles returns the media profiles of an inventoried camera with
+// their video encoder configuration and main/sub role
+func HandleGetProfiles(w http.ResponseWriter, r *http.Request) {
+	cameraID := mux.Vars(r)["id"]
+	log.Printf("Received profiles request for camera ID: %s", cameraID)
+
+	// Only inventoried cameras are locked, so requests for unknown IDs leave no lock behind
+	if _, found := camera.DefaultRegistry().Camera(cameraID); !found {
+		http.Error(w, fmt.Sprintf("Camera with ID %s not found", cameraID), http.StatusNotFound)
+		return
+	}
+	unlock := camera.LockCamera(cameraID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		log.Printf("Error getting camera client for %s: %v", cameraID, err)
+		if errors.Is(err, camera.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("Camera with ID %s not found", cameraID), http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to connect to camera: %v", err), http.StatusBadGateway)
+		}
+		return
+	}
+
+	profiles, err := camera.GetProfiles(client)
+	if err != nil {
+		log.Printf("Error getting profiles of camera %s: %v", cameraID, err)
+		http.Error(w, fmt.Sprintf("Failed to get profiles: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cameraId": cameraID,
+		"ip":       client.Camera.IP,
+		"profiles": profiles,
+	})
+}
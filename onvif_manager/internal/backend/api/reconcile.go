@@ -0,0 +1,24 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"onvif_manager/internal/backend/reconcile"
+)
+
+// HandleGetDrift returns how the cameras of the desired-state file differed from it in the
+// last reconcile run. Reconciling is enabled with ONVIF_MANAGER_DESIRED_STATE.
+func HandleGetDrift(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received /drift request")
+
+	status := reconcile.Latest()
+	if !status.Enabled {
+		http.Error(w, "Reconciling is not enabled; set "+reconcile.DesiredStateEnvVar+" to a desired-state file", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"onvif_manager/internal/backend/jobs"
+	"onvif_manager/internal/backend/rollout"
+
+	"github.com/gorilla/mux"
+)
+
+// rolloutControls holds the controls of the staged rollouts that are running, by job ID
+var (
+	rolloutMu       sync.Mutex
+	rolloutControls = make(map[string]*rollout.Control)
+)
+
+// startRolloutJob starts a staged rollout of an /jobs/apply-config request in the background
+// and responds with its job ID and waves
+func startRolloutJob(w http.ResponseWriter, cameraIDs []string, waves [][]string, input applyConfigRequest) {
+	job := jobManager.Create("rollout", cameraIDs)
+	for i, wave := range waves {
+		for _, cameraID := range wave {
+			job.SetWave(cameraID, i+1)
+		}
+	}
+
+	control := rollout.NewControl()
+	control.OnChange(func(state rollout.State, reason string) {
+		switch state {
+		case rollout.StatePaused:
+			job.SetStatus(jobs.StatusPaused, reason)
+		case rollout.StateRunning:
+			job.SetStatus(jobs.StatusRunning, "resumed")
+		case rollout.StateAborted:
+			job.SetStatus(jobs.StatusRunning, "aborting, the remaining waves are skipped: "+reason)
+		}
+	})
+	rolloutMu.Lock()
+	rolloutControls[job.ID()] = control
+	rolloutMu.Unlock()
+	log.Printf("Created rollout job %s for %d camera(s) in %d wave(s)", job.ID(), len(cameraIDs), len(waves))
+
+	go func() {
+		job.Start()
+		// The job outlives the HTTP request, so it must not use the request context
+		result := runRollout(context.Background(), job, waves, input, control)
+
+		rolloutMu.Lock()
+		delete(rolloutControls, job.ID())
+		rolloutMu.Unlock()
+		job.Complete(result)
+		log.Printf("Rollout job %s %s", job.ID(), result["status"])
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId":     job.ID(),
+		"status":    jobs.StatusPending,
+		"cameraIds": cameraIDs,
+		"waves":     waves,
+	})
+}
+
+// runRollout applies the request to one wave after the other and builds the job result:
+// the rollout outcome and the /apply-config response of every wave that ran
+func runRollout(ctx context.Context, job *jobs.Job, waves [][]string, input applyConfigRequest, control *rollout.Control) map[string]interface{} {
+	options := *input.Rollout
+	input.Rollout = nil
+
+	responses := []map[string]interface{}{}
+	result := rollout.Run(ctx, waves, options, control, func(ctx context.Context, wave int, cameraIDs []string) []string {
+		job.SetStatus(jobs.StatusRunning, fmt.Sprintf("wave %d of %d: %d camera(s)", wave, len(waves), len(cameraIDs)))
+
+		var mu sync.Mutex
+		failed := make(map[string]bool)
+		progress := func(cameraID string, phase jobs.Phase, message string) {
+			if phase == jobs.PhaseFailed {
+				mu.Lock()
+				failed[cameraID] = true
+				mu.Unlock()
+			}
+			job.SetPhase(cameraID, phase, message)
+		}
+
+		response := runApplyConfigRequest(ctx, cameraIDs, input.forWave(cameraIDs), progress)
+		response["wave"] = wave
+		responses = append(responses, response)
+
+		var failedIDs []string
+		for _, cameraID := range cameraIDs {
+			if failed[cameraID] {
+				failedIDs = append(failedIDs, cameraID)
+			}
+		}
+		return failedIDs
+	})
+
+	for _, cameraID := range result.Skipped {
+		job.SetPhase(cameraID, jobs.PhaseSkipped, "rollout aborted before its wave")
+	}
+	return map[string]interface{}{
+		"status":  result.Status,
+		"rollout": result,
+		"waves":   responses,
+	}
+}
+
+// forWave returns the request for the cameras of one wave. Per-camera rows are limited
+// to the rows of those cameras, so the other rows are not reported as skipped.
+func (input applyConfigRequest) forWave(cameraIDs []string) applyConfigRequest {
+	if len(input.Rows) == 0 || input.Template != "" {
+		return input
+	}
+
+	var rows []configRow
+	for _, target := range resolveConfigRows(input.Rows, cameraIDs) {
+		if target.err == nil {
+			rows = append(rows, target.row)
+		}
+	}
+	input.Rows = rows
+	return input
+}
+
+// HandlePauseRollout pauses a staged rollout before its next wave
+func HandlePauseRollout(w http.ResponseWriter, r *http.Request) {
+	controlRollout(w, r, func(control *rollout.Control) error {
+		return control.Pause("paused through the API")
+	})
+}
+
+// HandleResumeRollout continues a paused staged rollout
+func HandleResumeRollout(w http.ResponseWriter, r *http.Request) {
+	controlRollout(w, r, func(control *rollout.Control) error {
+		return control.Resume()
+	})
+}
+
+// HandleAbortRollout aborts a staged rollout before its next wave; the cameras of the
+// waves that have not started are skipped
+func HandleAbortRollout(w http.ResponseWriter, r *http.Request) {
+	controlRollout(w, r, func(control *rollout.Control) error {
+		return control.Abort("aborted through the API")
+	})
+}
+
+// controlRollout applies an action to the rollout of the job in the URL and responds with the job
+func controlRollout(w http.ResponseWriter, r *http.Request, action func(control *rollout.Control) error) {
+	jobID := mux.Vars(r)["id"]
+	job, err := jobManager.Get(jobID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	rolloutMu.Lock()
+	control, running := rolloutControls[jobID]
+	rolloutMu.Unlock()
+	if !running {
+		http.Error(w, fmt.Sprintf("job %s is not a running staged rollout", jobID), http.StatusConflict)
+		return
+	}
+	if err := action(control); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	state, _ := control.State()
+	log.Printf("Rollout job %s is %s", jobID, state)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job.Snapshot())
+}
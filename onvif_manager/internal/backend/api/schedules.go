@@ -0,0 +1,384 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/jobs"
+	"onvif_manager/internal/backend/scheduler"
+	"onvif_manager/internal/backend/templates"
+	"onvif_manager/internal/backend/timesync"
+
+	"github.com/gorilla/mux"
+)
+
+// The operations of scheduled runs are those of the API, so a scheduled run behaves
+// like the request it stands for
+func init() {
+	scheduler.RegisterOperation(scheduler.OperationApplyConfig, scheduler.Operation{
+		Validate: func(params map[string]interface{}) error {
+			_, err := scheduledApplyConfig(params)
+			return err
+		},
+		Run: runScheduledApplyConfig,
+	})
+	scheduler.RegisterOperation(scheduler.OperationValidate, scheduler.Operation{
+		Validate: func(params map[string]interface{}) error {
+			return decodeParams(params, &scheduledValidateParams{})
+		},
+		Run: runScheduledValidate,
+	})
+	scheduler.RegisterOperation(scheduler.OperationReboot, scheduler.Operation{
+		Validate: func(params map[string]interface{}) error {
+			return decodeParams(params, &scheduledPoolParams{})
+		},
+		Run: runScheduledReboot,
+	})
+	scheduler.RegisterOperation(scheduler.OperationTimeSync, scheduler.Operation{
+		Validate: func(params map[string]interface{}) error {
+			_, _, err := scheduledTimeSync(params)
+			return err
+		},
+		Run: runScheduledTimeSync,
+	})
+}
+
+// decodeParams decodes the parameters of a schedule into input, rejecting unknown fields
+func decodeParams(params map[string]interface{}, input interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(input)
+}
+
+// scheduledPoolParams are the worker pool overrides every scheduled operation accepts
+type scheduledPoolParams struct {
+	Concurrency    int `json:"concurrency"`
+	TimeoutSeconds int `json:"timeoutSeconds"`
+}
+
+// scheduledValidateParams are the parameters of a scheduled validation
+type scheduledValidateParams struct {
+	scheduledPoolParams
+	Profile string `json:"profile"`
+}
+
+// scheduledApplyConfig decodes and checks the /apply-config request of a schedule. The
+// cameras are those of the schedule, so the request must not select any.
+func scheduledApplyConfig(params map[string]interface{}) (applyConfigRequest, error) {
+	var input applyConfigRequest
+	if err := decodeParams(params, &input); err != nil {
+		return input, err
+	}
+	if input.CameraID != "" || len(input.CameraIDs) > 0 || input.Selector != "" {
+		return input, fmt.Errorf("select the cameras with the cameraIds and selector of the schedule")
+	}
+	if input.Rollout != nil {
+		return input, fmt.Errorf("staged rollouts cannot be scheduled")
+	}
+	policy, err := camera.ParseRollbackPolicy(input.RollbackPolicy)
+	if err != nil {
+		return input, err
+	}
+	input.RollbackPolicy = policy
+	if input.Template != "" {
+		if _, err := templates.Shared.Get().Get(input.Template); err != nil {
+			return input, err
+		}
+	}
+	return input, nil
+}
+
+// runScheduledApplyConfig applies the request of a schedule and reports the final phase
+// of each camera; cameras without a row are skipped
+func runScheduledApplyConfig(ctx context.Context, cameraIDs []string, params map[string]interface{}, startCheck func(cameraID string) error) []scheduler.CameraResult {
+	input, err := scheduledApplyConfig(params)
+	if err != nil {
+		return failAll(cameraIDs, err)
+	}
+
+	var mu sync.Mutex
+	final := make(map[string]scheduler.CameraResult)
+	progress := func(cameraID string, phase jobs.Phase, message string) {
+		status := ""
+		switch phase {
+		case jobs.PhaseDone:
+			status = scheduler.CameraSucceeded
+		case jobs.PhaseFailed:
+			status = scheduler.CameraFailed
+		default:
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// A failed stream fails the camera even when a later stream succeeds
+		if final[cameraID].Status != scheduler.CameraFailed {
+			final[cameraID] = scheduler.CameraResult{CameraID: cameraID, Status: status, Message: message}
+		}
+	}
+	input.startCheck = startCheck
+	runApplyConfigRequest(ctx, cameraIDs, input.forWave(cameraIDs), progress)
+
+	results := make([]scheduler.CameraResult, 0, len(cameraIDs))
+	for _, cameraID := range cameraIDs {
+		result, reported := final[cameraID]
+		if !reported {
+			result = scheduler.CameraResult{CameraID: cameraID, Status: scheduler.CameraSkipped, Message: "no configuration for this camera"}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// runScheduledValidate validates the current stream of each camera
+func runScheduledValidate(ctx context.Context, cameraIDs []string, params map[string]interface{}, startCheck func(cameraID string) error) []scheduler.CameraResult {
+	var input scheduledValidateParams
+	if err := decodeParams(params, &input); err != nil {
+		return failAll(cameraIDs, err)
+	}
+
+	selection := selectionRequest{Concurrency: input.Concurrency, TimeoutSeconds: input.TimeoutSeconds}
+	opts := selection.poolOptions()
+	opts.StartCheck = startCheck
+	results := make([]scheduler.CameraResult, 0, len(cameraIDs))
+	for i, validation := range validateCameras(ctx, cameraIDs, input.Profile, opts) {
+		status := scheduler.CameraFailed
+		if valid, _ := validation["isValid"].(bool); valid {
+			status = scheduler.CameraSucceeded
+		}
+		results = append(results, scheduler.CameraResult{CameraID: cameraIDs[i], Status: status, Message: validationSummary(validation), Details: validation})
+	}
+	return results
+}
+
+// runScheduledReboot reboots the cameras
+func runScheduledReboot(ctx context.Context, cameraIDs []string, params map[string]interface{}, startCheck func(cameraID string) error) []scheduler.CameraResult {
+	var input scheduledPoolParams
+	if err := decodeParams(params, &input); err != nil {
+		return failAll(cameraIDs, err)
+	}
+
+	selection := selectionRequest{Concurrency: input.Concurrency, TimeoutSeconds: input.TimeoutSeconds}
+	opts := selection.poolOptions()
+	opts.StartCheck = startCheck
+	results := make([]scheduler.CameraResult, 0, len(cameraIDs))
+	for _, reboot := range camera.RebootCameras(ctx, cameraIDs, opts) {
+		result := scheduler.CameraResult{CameraID: reboot.CameraID, Status: scheduler.CameraSucceeded, Message: reboot.Message}
+		if !reboot.Success {
+			result.Status, result.Message = scheduler.CameraFailed, reboot.Error
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// scheduledTimeSync decodes and checks the time-sync request of a schedule
+func scheduledTimeSync(params map[string]interface{}) (timesync.Request, selectionRequest, error) {
+	var input struct {
+		scheduledPoolParams
+		Mode                  string   `json:"mode"`
+		NTPServers            []string `json:"ntpServers"`
+		DriftToleranceSeconds float64  `json:"driftToleranceSeconds"`
+	}
+	if err := decodeParams(params, &input); err != nil {
+		return timesync.Request{}, selectionRequest{}, err
+	}
+	request := timesync.Request{
+		Mode:           input.Mode,
+		NTPServers:     input.NTPServers,
+		DriftTolerance: time.Duration(input.DriftToleranceSeconds * float64(time.Second)),
+	}
+	selection := selectionRequest{Concurrency: input.Concurrency, TimeoutSeconds: input.TimeoutSeconds}
+	return request, selection, request.Validate()
+}
+
+// runScheduledTimeSync checks or sets the clocks of the cameras
+func runScheduledTimeSync(ctx context.Context, cameraIDs []string, params map[string]interface{}, startCheck func(cameraID string) error) []scheduler.CameraResult {
+	request, selection, err := scheduledTimeSync(params)
+	if err != nil {
+		return failAll(cameraIDs, err)
+	}
+
+	opts := selection.poolOptions()
+	opts.StartCheck = startCheck
+	synced := timesync.Run(ctx, cameraIDs, request, opts)
+	results := make([]scheduler.CameraResult, 0, len(cameraIDs))
+	for _, cameraID := range cameraIDs {
+		cameraResult, found := synced.CameraResults[cameraID]
+		switch {
+		case !found:
+			results = append(results, scheduler.CameraResult{CameraID: cameraID, Status: scheduler.CameraFailed, Message: "no time sync result"})
+		case cameraResult.Success:
+			results = append(results, scheduler.CameraResult{CameraID: cameraID, Status: scheduler.CameraSucceeded, Message: "action: " + cameraResult.Action, Details: cameraResult})
+		default:
+			results = append(results, scheduler.CameraResult{CameraID: cameraID, Status: scheduler.CameraFailed, Message: cameraResult.Error, Details: cameraResult})
+		}
+	}
+	return results
+}
+
+// failAll reports the same error for every camera
+func failAll(cameraIDs []string, err error) []scheduler.CameraResult {
+	results := make([]scheduler.CameraResult, 0, len(cameraIDs))
+	for _, cameraID := range cameraIDs {
+		results = append(results, scheduler.CameraResult{CameraID: cameraID, Status: scheduler.CameraFailed, Message: err.Error()})
+	}
+	return results
+}
+
+// scheduleErrorStatus maps a schedule store error to its HTTP status
+func scheduleErrorStatus(err error) int {
+	if errors.Is(err, scheduler.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// HandleGetSchedules lists every schedule with its next run
+func HandleGetSchedules(w http.ResponseWriter, r *http.Request) {
+	list, err := scheduler.Shared.Get().List()
+	if err != nil {
+		log.Printf("Error listing schedules: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// HandleGetSchedule returns a schedule
+func HandleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := scheduler.Shared.Get().Get(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// HandlePutSchedule creates a schedule or replaces the one with the name in the URL
+func HandlePutSchedule(w http.ResponseWriter, r *http.Request) {
+	var schedule scheduler.Schedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	schedule.Name = mux.Vars(r)["name"]
+
+	created, err := scheduler.Shared.Get().Put(&schedule)
+	if err != nil {
+		log.Printf("Error saving schedule %s: %v", schedule.Name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Saved schedule %s (%s)", schedule.Name, schedule.Operation)
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// HandleDeleteSchedule removes a schedule and its runs
+func HandleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := scheduler.Shared.Get().Delete(name); err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+	log.Printf("Deleted schedule %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRunSchedule starts a run of a schedule right away, within the maintenance
+// windows of its cameras, and responds with the run
+func HandleRunSchedule(w http.ResponseWriter, r *http.Request) {
+	// The run outlives the HTTP request, so it must not use the request context
+	run, err := scheduler.RunNow(context.Background(), scheduler.Shared.Get(), mux.Vars(r)["name"])
+	if errors.Is(err, scheduler.ErrAlreadyRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// HandleGetScheduleRuns returns the recent runs of a schedule, newest first
+func HandleGetScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := scheduler.Shared.Get().Runs(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// HandleGetMaintenanceWindows lists every maintenance window
+func HandleGetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := scheduler.Shared.Get().Windows()
+	if err != nil {
+		log.Printf("Error listing maintenance windows: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(windows)
+}
+
+// HandlePutMaintenanceWindow creates a maintenance window or replaces the one with the name in the URL
+func HandlePutMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var window scheduler.Window
+	if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	window.Name = mux.Vars(r)["name"]
+
+	created, err := scheduler.Shared.Get().PutWindow(&window)
+	if err != nil {
+		log.Printf("Error saving maintenance window %s: %v", window.Name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Saved maintenance window %s (%s-%s)", window.Name, window.Start, window.End)
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(window)
+}
+
+// HandleDeleteMaintenanceWindow removes a maintenance window
+func HandleDeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := scheduler.Shared.Get().DeleteWindow(name); err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+	log.Printf("Deleted maintenance window %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+)
+
+// resolveCameraIDs returns the cameras addressed by a request: the listed IDs, the cameras
+// matching the selector expression, or the listed IDs that match the selector when both are
+// given. An ID listed more than once is kept at its first position.
+func resolveCameraIDs(cameraIDs []string, selector string) ([]string, error) {
+	cameraIDs = uniqueIDs(cameraIDs)
+	if selector == "" {
+		return cameraIDs, nil
+	}
+	selected, err := camera.SelectCameraIDs(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(cameraIDs) == 0 {
+		return selected, nil
+	}
+
+	matched := make(map[string]bool, len(selected))
+	for _, id := range selected {
+		matched[id] = true
+	}
+	filtered := []string{}
+	for _, id := range cameraIDs {
+		if matched[id] {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
+
+// uniqueIDs returns the IDs without repetitions, in the order they first appear
+func uniqueIDs(ids []string) []string {
+	if ids == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// selectionRequest is the body of operations that address a set of cameras
+// by ID, by selector expression or both
+type selectionRequest struct {
+	CameraIDs []string `json:"cameraIds"`
+	Selector  string   `json:"selector"`
+
+	// Optional worker pool overrides; defaults come from the environment
+	Concurrency    int `json:"concurrency"`
+	TimeoutSeconds int `json:"timeoutSeconds"`
+}
+
+// cameraIDs resolves the cameras addressed by the request
+func (input selectionRequest) cameraIDs() ([]string, error) {
+	return resolveCameraIDs(input.CameraIDs, input.Selector)
+}
+
+// poolOptions returns the worker pool settings for this request
+func (input selectionRequest) poolOptions() pool.Options {
+	return pool.DefaultOptions().WithOverrides(input.Concurrency, time.Duration(input.TimeoutSeconds)*time.Second)
+}
+
+// decodeSelectionRequest decodes the request body into input and resolves its cameras.
+// It writes the error response and returns false when the request is unusable.
+func decodeSelectionRequest(w http.ResponseWriter, r *http.Request, input interface{}, selection *selectionRequest) ([]string, bool) {
+	if err := json.NewDecoder(r.Body).Decode(input); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	cameraIDs, err := selection.cameraIDs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if len(cameraIDs) == 0 {
+		http.Error(w, "No cameras selected", http.StatusBadRequest)
+		return nil, false
+	}
+	return cameraIDs, true
+}
+
+// HandleRebootCameras reboots the cameras given by ID or selector
+func HandleRebootCameras(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received /cameras/reboot request")
+
+	var input selectionRequest
+	cameraIDs, ok := decodeSelectionRequest(w, r, &input, &input)
+	if !ok {
+		return
+	}
+
+	results := camera.RebootCameras(r.Context(), cameraIDs, input.poolOptions())
+
+	rebooted := 0
+	for _, result := range results {
+		if result.Success {
+			rebooted++
+		}
+	}
+	log.Printf("Reboot completed: %d of %d cameras rebooting", rebooted, len(results))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cameraOrder": cameraIDs,
+		"results":     results,
+		"summary": map[string]int{
+			"totalCameras":   len(results),
+			"successfulCams": rebooted,
+			"failedCams":     len(results) - rebooted,
+		},
+	})
+}
+
+// HandleValidateCameras validates the streams of the cameras given by ID or selector
+// against their current encoder configuration
+func HandleValidateCameras(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received /validate-cams request")
+
+	var input struct {
+		selectionRequest
+		Profile string `json:"profile"` // Profile token, name or role (main/sub); the main stream when empty
+	}
+	cameraIDs, ok := decodeSelectionRequest(w, r, &input, &input.selectionRequest)
+	if !ok {
+		return
+	}
+
+	results := validateCameras(r.Context(), cameraIDs, input.Profile, input.poolOptions())
+
+	valid := 0
+	for _, result := range results {
+		if result["isValid"] == true {
+			valid++
+		}
+	}
+	log.Printf("Validation completed: %d of %d cameras valid", valid, len(results))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cameraOrder": cameraIDs,
+		"results":     results,
+		"summary": map[string]int{
+			"totalCameras": len(results),
+			"validCams":    valid,
+			"invalidCams":  len(results) - valid,
+		},
+	})
+}
+
+// validateCameras validates the current stream of each camera through the worker pool
+// and returns one result per camera in the order of cameraIDs
+func validateCameras(ctx context.Context, cameraIDs []string, profile string, opts pool.Options) []map[string]interface{} {
+	return pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) map[string]interface{} {
+			unlock := camera.LockCamera(cameraID)
+			defer unlock()
+
+			client, err := camera.GetCameraClient(cameraID)
+			if err != nil {
+				return map[string]interface{}{"cameraId": cameraID, "isValid": false, "error": err.Error()}
+			}
+			result, _, err := validateCurrentStream(client, profile)
+			if err != nil {
+				return map[string]interface{}{"cameraId": cameraID, "isValid": false, "error": err.Error()}
+			}
+			return result
+		},
+		func(cameraID string, err error) map[string]interface{} {
+			return map[string]interface{}{"cameraId": cameraID, "isValid": false, "error": fmt.Sprintf("Validation aborted: %v", err)}
+		})
+}
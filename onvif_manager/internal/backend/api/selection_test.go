@@ -0,0 +1,47 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
+
+func TestResolveCameraIDs(t *testing.T) {
+	store := &camera.MemoryStore{}
+	store.Save([]models.Camera{
+		{ID: "1", Groups: []string{"lobby"}},
+		{ID: "2"},
+		{ID: "3", Groups: []string{"lobby"}},
+	})
+	if err := camera.UseStore(store); err != nil {
+		t.Fatalf("UseStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		cameraIDs []string
+		selector  string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "listed", cameraIDs: []string{"3", "1"}, want: []string{"3", "1"}},
+		{name: "listed twice", cameraIDs: []string{"1", "2", "1"}, want: []string{"1", "2"}},
+		{name: "selector", selector: "group=lobby", want: []string{"1", "3"}},
+		{name: "listed and selector", cameraIDs: []string{"3", "2", "3"}, selector: "group=lobby", want: []string{"3"}},
+		{name: "nothing", want: nil},
+		{name: "invalid selector", selector: "lobby", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveCameraIDs(tt.cameraIDs, tt.selector)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: resolveCameraIDs() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: resolveCameraIDs(%v, %q) = %v, want %v", tt.name, tt.cameraIDs, tt.selector, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"onvif_manager/internal/backend/jobs"
+	"onvif_manager/internal/backend/templates"
+
+	"github.com/gorilla/mux"
+)
+
+// templateErrorStatus maps a template store error to its HTTP status
+func templateErrorStatus(err error) int {
+	if errors.Is(err, templates.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// HandleGetTemplates lists every configuration template
+func HandleGetTemplates(w http.ResponseWriter, r *http.Request) {
+	list, err := templates.Shared.Get().List()
+	if err != nil {
+		log.Printf("Error listing templates: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// HandleGetTemplate returns a template. With ?model= the response also holds the
+// streams the template resolves to for that camera model.
+func HandleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := templates.Shared.Get().Get(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), templateErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		json.NewEncoder(w).Encode(template)
+		return
+	}
+	streams, variant := template.Resolve(model)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"template": template,
+		"resolved": templates.CameraStreams{Model: model, Variant: variant, Streams: streams},
+	})
+}
+
+// HandlePutTemplate creates a template or replaces the one with the name in the URL
+func HandlePutTemplate(w http.ResponseWriter, r *http.Request) {
+	var template templates.Template
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	template.Name = mux.Vars(r)["name"]
+
+	created, err := templates.Shared.Get().Put(&template)
+	if err != nil {
+		log.Printf("Error saving template %s: %v", template.Name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Saved template %s (%d stream(s), %d model variant(s))", template.Name, len(template.Streams), len(template.Models))
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(template)
+}
+
+// HandleDeleteTemplate removes a template
+func HandleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := templates.Shared.Get().Delete(name); err != nil {
+		http.Error(w, err.Error(), templateErrorStatus(err))
+		return
+	}
+	log.Printf("Deleted template %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleResolveTemplate reads the model of the selected cameras and returns the
+// streams the template resolves to for each of them, without changing anything
+func HandleResolveTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := templates.Shared.Get().Get(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), templateErrorStatus(err))
+		return
+	}
+	var input selectionRequest
+	cameraIDs, ok := decodeSelectionRequest(w, r, &input, &input)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates.ResolveForCameras(r.Context(), template, cameraIDs, input.poolOptions()))
+}
+
+// runTemplateConfig applies a template to the cameras of an /apply-config request. The
+// template is resolved for the model of each camera and the resulting streams are applied
+// as per-camera rows, so the response has the result of each camera and stream under "rows".
+func runTemplateConfig(ctx context.Context, cameraIDs []string, input applyConfigRequest, progress progressFunc) map[string]interface{} {
+	template, err := templates.Shared.Get().Get(input.Template)
+	if err != nil {
+		for _, cameraID := range cameraIDs {
+			progress.report(cameraID, jobs.PhaseFailed, err.Error())
+		}
+		return map[string]interface{}{
+			"status":      "failed",
+			"error":       err.Error(),
+			"template":    input.Template,
+			"cameraOrder": cameraIDs,
+		}
+	}
+
+	resolved := templates.ResolveForCameras(ctx, template, cameraIDs, input.poolOptions())
+	var rows []configRow
+	unresolved := 0
+	for _, cam := range resolved {
+		if cam.Error != "" {
+			log.Printf("Template %s not applied to camera %s: %s", template.Name, cam.CameraID, cam.Error)
+			progress.report(cam.CameraID, jobs.PhaseFailed, cam.Error)
+			unresolved++
+			continue
+		}
+		for _, stream := range cam.Streams {
+			// templates.Stream has the fields of streamConfig
+			rows = append(rows, configRow{CameraID: cam.CameraID, streamConfig: streamConfig(stream)})
+		}
+	}
+
+	input.Rows = rows
+	response := runConfigRows(ctx, cameraIDs, input, progress)
+	response["template"] = template.Name
+	response["cameras"] = resolved
+	response["summary"].(map[string]int)["unresolvedCameras"] = unresolved
+	return response
+}
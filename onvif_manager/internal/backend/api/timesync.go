@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/internal/backend/timesync"
+)
+
+// HandleTimeSync reads the clocks of the selected cameras, or of every camera in the
+// inventory when none are selected, and optionally sets their NTP servers or their time
+func HandleTimeSync(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received /cameras/time-sync request")
+
+	var input struct {
+		CameraIDs             []string `json:"cameraIds"`
+		Selector              string   `json:"selector"`
+		Mode                  string   `json:"mode"`
+		NTPServers            []string `json:"ntpServers"`
+		DriftToleranceSeconds float64  `json:"driftToleranceSeconds"`
+
+		// Optional worker pool overrides; defaults come from the environment
+		Concurrency    int `json:"concurrency"`
+		TimeoutSeconds int `json:"timeoutSeconds"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		log.Printf("Error decoding time-sync request body: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to decode request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	request := timesync.Request{
+		Mode:           input.Mode,
+		NTPServers:     input.NTPServers,
+		DriftTolerance: time.Duration(input.DriftToleranceSeconds * float64(time.Second)),
+	}
+	if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cameraIDs, err := resolveCameraIDs(input.CameraIDs, input.Selector)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(cameraIDs) == 0 && input.Selector == "" {
+		for _, cam := range camera.GetAllCameras() {
+			cameraIDs = append(cameraIDs, cam.ID)
+		}
+	}
+	if len(cameraIDs) == 0 {
+		http.Error(w, "No cameras selected", http.StatusBadRequest)
+		return
+	}
+
+	opts := pool.DefaultOptions().WithOverrides(input.Concurrency, time.Duration(input.TimeoutSeconds)*time.Second)
+	results := timesync.Run(r.Context(), cameraIDs, request, opts)
+
+	log.Printf("Time sync completed: %d successful, %d failed, %d drifted, %d updated",
+		results.Summary.SuccessfulCams, results.Summary.FailedCams, results.Summary.DriftedCams, results.Summary.UpdatedCams)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,275 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/pkg/models"
+)
+
+// FormatVersion is the version of the archive format written by this build.
+// Archives with a newer version are refused.
+const FormatVersion = 1
+
+// ErrModelMismatch is wrapped by the errors returned for archives of another camera model
+var ErrModelMismatch = errors.New("backup is for another camera model")
+
+// Archive is the portable snapshot of a camera's configuration
+type Archive struct {
+	FormatVersion int                `json:"formatVersion"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	CameraID      string             `json:"cameraId"`
+	IP            string             `json:"ip"`
+	DeviceInfo    *models.DeviceInfo `json:"deviceInfo"`
+	Settings      *camera.Settings   `json:"settings"`
+}
+
+// RestoreResult is the outcome of restoring an archive to a camera
+type RestoreResult struct {
+	CameraID       string               `json:"cameraId"`
+	SourceCameraID string               `json:"sourceCameraId"` // Camera the archive was taken from
+	BackupTime     time.Time            `json:"backupTime"`
+	Success        bool                 `json:"success"` // Whether every step succeeded
+	Error          string               `json:"error,omitempty"`
+	Steps          []camera.RestoreStep `json:"steps"`
+}
+
+// Results represents the overall results of a backup run
+type Results struct {
+	CameraOrder   []string                 `json:"cameraOrder"` // Camera IDs in the order they were requested
+	CameraResults map[string]*CameraResult `json:"cameraResults"`
+	Summary       Summary                  `json:"summary"`
+}
+
+// CameraResult represents the backup result of a single camera
+type CameraResult struct {
+	CameraID    string   `json:"cameraId"`
+	Success     bool     `json:"success"`
+	Error       string   `json:"error,omitempty"`
+	File        string   `json:"file,omitempty"`
+	Unavailable []string `json:"unavailable,omitempty"` // Sections the camera could not report
+}
+
+// Summary represents a summary of a backup run
+type Summary struct {
+	TotalCameras   int `json:"totalCameras"`
+	SuccessfulCams int `json:"successfulCams"`
+	FailedCams     int `json:"failedCams"`
+	PartialCams    int `json:"partialCams"` // Backed up with unavailable sections
+}
+
+// Create reads the complete configuration of a camera into an archive
+func Create(cameraID string) (*Archive, error) {
+	unlock := camera.LockCamera(cameraID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceInfo, err := client.GetDeviceInformation()
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := camera.ReadSettings(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		CameraID:      cameraID,
+		IP:            client.Camera.IP,
+		DeviceInfo:    deviceInfo,
+		Settings:      settings,
+	}, nil
+}
+
+// Restore writes an archive to a camera. The camera must be the same manufacturer
+// and model as the one the archive was taken from, unless force is set. Configurations
+// are written to the camera's counterparts of the backed up ones; those it has none
+// for are reported as failed steps.
+func Restore(cameraID string, archive *Archive, force bool) (*RestoreResult, error) {
+	if err := archive.Validate(); err != nil {
+		return nil, err
+	}
+
+	unlock := camera.LockCamera(cameraID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !force {
+		deviceInfo, err := client.GetDeviceInformation()
+		if err != nil {
+			return nil, err
+		}
+		if err := archive.CheckModel(deviceInfo); err != nil {
+			return nil, err
+		}
+	}
+
+	// The camera's own tokens are read first, as a replacement camera may use other ones
+	target, err := camera.ReadSettings(client)
+	if err != nil {
+		return nil, err
+	}
+	settings, unmatched := camera.MatchSettings(archive.Settings, target)
+
+	log.Printf("Restoring backup of camera %s taken %s to camera %s", archive.CameraID, archive.CreatedAt.Format(time.RFC3339), cameraID)
+	result := &RestoreResult{
+		CameraID:       cameraID,
+		SourceCameraID: archive.CameraID,
+		BackupTime:     archive.CreatedAt,
+		Success:        true,
+		Steps:          append(unmatched, camera.RestoreSettings(client, settings)...),
+	}
+	failed := 0
+	for _, step := range result.Steps {
+		if !step.Success {
+			failed++
+		}
+	}
+	if failed > 0 {
+		result.Success = false
+		result.Error = fmt.Sprintf("%d of %d settings could not be restored", failed, len(result.Steps))
+	}
+	return result, nil
+}
+
+// Validate checks that the archive can be restored by this build
+func (a *Archive) Validate() error {
+	if a.FormatVersion == 0 || a.Settings == nil {
+		return fmt.Errorf("not a camera backup")
+	}
+	if a.FormatVersion > FormatVersion {
+		return fmt.Errorf("backup format version %d is newer than the supported version %d", a.FormatVersion, FormatVersion)
+	}
+	return nil
+}
+
+// CheckModel checks that the archive was taken from a camera of the given manufacturer
+// and model, or returns an error wrapping ErrModelMismatch
+func (a *Archive) CheckModel(deviceInfo *models.DeviceInfo) error {
+	if a.DeviceInfo == nil {
+		return fmt.Errorf("%w: backup has no device information", ErrModelMismatch)
+	}
+	if !strings.EqualFold(a.DeviceInfo.Manufacturer, deviceInfo.Manufacturer) || !strings.EqualFold(a.DeviceInfo.Model, deviceInfo.Model) {
+		return fmt.Errorf("%w: backup was taken from a %s %s but the camera is a %s %s", ErrModelMismatch,
+			a.DeviceInfo.Manufacturer, a.DeviceInfo.Model, deviceInfo.Manufacturer, deviceInfo.Model)
+	}
+	return nil
+}
+
+// FileName returns the name the archive is written under, e.g. camera_12_20240101T120000Z.json
+func (a *Archive) FileName() string {
+	return fmt.Sprintf("camera_%s_%s.json", a.CameraID, a.CreatedAt.UTC().Format("20060102T150405Z"))
+}
+
+// WriteFile writes the archive into dir and returns the path of the file
+func WriteFile(dir string, archive *Archive) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode backup: %w", err)
+	}
+
+	path := filepath.Join(dir, archive.FileName())
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	return path, nil
+}
+
+// ReadFile reads and validates an archive
+func ReadFile(path string) (*Archive, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	archive := new(Archive)
+	if err := json.Unmarshal(data, archive); err != nil {
+		return nil, fmt.Errorf("failed to decode backup %s: %w", path, err)
+	}
+	if err := archive.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return archive, nil
+}
+
+// Run backs up the cameras into dir through the bounded worker pool
+func Run(ctx context.Context, cameraIDs []string, dir string, opts pool.Options) *Results {
+	log.Printf("Backing up %d cameras to %s (concurrency %d, timeout %s)", len(cameraIDs), dir, opts.Concurrency, opts.Timeout)
+
+	results := &Results{
+		CameraOrder:   cameraIDs,
+		CameraResults: make(map[string]*CameraResult),
+	}
+
+	backedUp := pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) *CameraResult {
+			return backupCamera(cameraID, dir)
+		},
+		func(cameraID string, err error) *CameraResult {
+			return &CameraResult{CameraID: cameraID, Error: fmt.Sprintf("Backup aborted: %v", err)}
+		})
+
+	results.Summary.TotalCameras = len(cameraIDs)
+	for i, cameraID := range cameraIDs {
+		result := backedUp[i]
+		results.CameraResults[cameraID] = result
+
+		if result.Success {
+			results.Summary.SuccessfulCams++
+			if len(result.Unavailable) > 0 {
+				results.Summary.PartialCams++
+			}
+		} else {
+			results.Summary.FailedCams++
+		}
+	}
+
+	return results
+}
+
+// backupCamera writes the archive of one camera
+func backupCamera(cameraID, dir string) *CameraResult {
+	result := &CameraResult{CameraID: cameraID}
+
+	archive, err := Create(cameraID)
+	if err != nil {
+		log.Printf("Failed to back up camera %s: %v", cameraID, err)
+		result.Error = err.Error()
+		return result
+	}
+
+	path, err := WriteFile(dir, archive)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Success = true
+	result.File = path
+	result.Unavailable = archive.Settings.Unavailable
+	return result
+}
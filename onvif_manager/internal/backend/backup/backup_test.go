@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
+
+func TestCheckModel(t *testing.T) {
+	archive := &Archive{DeviceInfo: &models.DeviceInfo{Manufacturer: "Dahua", Model: "IPC-HDW2431T"}}
+
+	tests := []struct {
+		name       string
+		archive    *Archive
+		deviceInfo models.DeviceInfo
+		wantErr    bool
+	}{
+		{name: "same model", archive: archive, deviceInfo: models.DeviceInfo{Manufacturer: "Dahua", Model: "IPC-HDW2431T"}},
+		{name: "case differs", archive: archive, deviceInfo: models.DeviceInfo{Manufacturer: "DAHUA", Model: "ipc-hdw2431t"}},
+		{name: "other model", archive: archive, deviceInfo: models.DeviceInfo{Manufacturer: "Dahua", Model: "IPC-HFW2431S"}, wantErr: true},
+		{name: "other manufacturer", archive: archive, deviceInfo: models.DeviceInfo{Manufacturer: "Hikvision", Model: "IPC-HDW2431T"}, wantErr: true},
+		{name: "no device information", archive: &Archive{}, deviceInfo: models.DeviceInfo{Manufacturer: "Dahua"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := tt.archive.CheckModel(&tt.deviceInfo)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: CheckModel() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr && !errors.Is(err, ErrModelMismatch) {
+			t.Errorf("%s: CheckModel() error = %v, want ErrModelMismatch", tt.name, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive Archive
+		wantErr bool
+	}{
+		{name: "current version", archive: Archive{FormatVersion: FormatVersion, Settings: &camera.Settings{}}},
+		{name: "newer version", archive: Archive{FormatVersion: FormatVersion + 1, Settings: &camera.Settings{}}, wantErr: true},
+		{name: "no version", archive: Archive{Settings: &camera.Settings{}}, wantErr: true},
+		{name: "no settings", archive: Archive{FormatVersion: FormatVersion}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.archive.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWriteAndReadFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+	archive := &Archive{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		CameraID:      "12",
+		IP:            "10.0.0.12",
+		DeviceInfo:    &models.DeviceInfo{Manufacturer: "Dahua", Model: "IPC-HDW2431T"},
+		Settings: &camera.Settings{
+			Profiles: []camera.Profile{{Token: "Profile_1", Role: camera.ProfileRoleMain, ConfigToken: "VideoEncoder_1"}},
+		},
+	}
+
+	path, err := WriteFile(dir, archive)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if want := filepath.Join(dir, "camera_12_20240101T120000Z.json"); path != want {
+		t.Errorf("WriteFile() = %q, want %q", path, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("backup file mode = %o, want 600", mode)
+	}
+
+	read, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !reflect.DeepEqual(read, archive) {
+		t.Errorf("ReadFile() = %+v, want %+v", read, archive)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"cameraId": "12"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(path); err == nil {
+		t.Error("ReadFile() of a file that is not a backup succeeded")
+	}
+}
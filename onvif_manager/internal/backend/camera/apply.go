@@ -0,0 +1,211 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/pkg/models"
+)
+
+// Steps reported while a stream is configured; they are named like the phases of a job
+const (
+	StepConnecting  = "connecting"
+	StepConfiguring = "configuring"
+)
+
+// ApplyResult is the outcome of configuring one stream of a camera
+type ApplyResult struct {
+	CameraID           string                 `json:"cameraId"`
+	Success            bool                   `json:"success"`
+	Error              error                  `json:"error,omitempty"`
+	AppliedConfig      map[string]interface{} `json:"appliedConfig,omitempty"`
+	ResolutionAdjusted bool                   `json:"resolutionAdjusted"`
+	Profile            *Profile               `json:"profile,omitempty"`
+	StreamURL          string                 `json:"streamUrl,omitempty"`
+	DeviceInfo         *models.DeviceInfo     `json:"deviceInfo,omitempty"`
+	Plan               *ConfigPlan            `json:"plan,omitempty"`           // Set for dry runs
+	ConfigToken        string                 `json:"configToken,omitempty"`    // Encoder configuration that was changed
+	PreviousConfig     *models.EncoderConfig  `json:"previousConfig,omitempty"` // Configuration before the change, restored by a rollback
+	Resolution         models.Resolution      `json:"resolution"`               // Resolution written, the requested one snapped to the camera's options
+}
+
+// ApplyStreamConfig configures the stream of the selected profile of a camera. requested holds
+// the settings to apply, with the resolution asked for; zero values keep the current settings.
+// The resolution is snapped to the closest one the camera offers and the result is checked
+// against the camera's options before anything is written. A stream that already has the
+// requested settings is left alone. With dryRun the camera is only read and the result holds
+// the planned changes. Nothing is written once ctx is done. progress, when set, is told about
+// the steps. The caller holds the camera's operation lock.
+func ApplyStreamConfig(ctx context.Context, cameraID string, profileRef string, requested models.EncoderConfig, dryRun bool, progress func(step, message string)) ApplyResult {
+	report := func(step, message string) {
+		if progress != nil {
+			progress(step, message)
+		}
+	}
+	result := ApplyResult{
+		CameraID: cameraID,
+		Success:  false,
+	}
+
+	// Get the camera client
+	client, err := GetCameraClient(cameraID)
+	if err != nil {
+		log.Printf("Error getting camera client for %s: %v", cameraID, err)
+		result.Error = err
+		return result
+	}
+	report(StepConnecting, fmt.Sprintf("connecting to %s:%d", client.Camera.IP, client.Camera.Port))
+
+	log.Printf("Getting profiles and configs for camera %s (IP: %s:%d)", cameraID, client.Camera.IP, client.Camera.Port)
+	profiles, err := GetProfiles(client)
+	if err != nil {
+		log.Printf("Failed to get camera profiles and configs for %s (IP: %s:%d): %v", cameraID, client.Camera.IP, client.Camera.Port, err)
+		result.Error = connectionError(client.Camera, err)
+		return result
+	}
+
+	profile, err := SelectProfile(profiles, profileRef)
+	if err != nil {
+		log.Printf("No usable profile for camera %s: %v", cameraID, err)
+		result.Error = err
+		return result
+	}
+	profileToken := profile.Token
+	configToken := profile.ConfigToken
+	result.Profile = &profile
+	log.Printf("Using profile %s (token %s, config token %s) for camera %s", profile, profileToken, configToken, cameraID)
+
+	// Device information is only reported, so a camera that does not answer is still configured
+	if deviceInfo, err := client.GetDeviceInformation(); err != nil {
+		log.Printf("Failed to get device information for %s: %v", cameraID, err)
+	} else {
+		result.DeviceInfo = deviceInfo
+	}
+
+	log.Printf("Getting current encoder config for camera %s", cameraID)
+	currentConfig, err := GetCurrentConfig(client, configToken)
+	if err != nil {
+		log.Printf("Failed to get current encoder config for %s: %v", cameraID, err)
+		result.Error = fmt.Errorf("failed to get current encoder config: %w", err)
+		return result
+	}
+
+	log.Printf("Getting available encoder options for camera %s", cameraID)
+	encoderOptions, err := GetEncoderOptions(client, profileToken, configToken)
+	if err != nil {
+		log.Printf("Failed to get encoder options for %s: %v", cameraID, err)
+		result.Error = fmt.Errorf("failed to get encoder options: %w", err)
+		return result
+	}
+	targetResolution := requested.Resolution
+	encodingOptions, err := SelectEncodingOptions(encoderOptions, TargetEncoding(requested.Encoding, currentConfig.Encoding))
+	if err != nil {
+		log.Printf("Requested encoding not available on camera %s: %v", cameraID, err)
+		result.Error = err
+		if dryRun {
+			plan := PlanEncoderConfig(currentConfig, requested, targetResolution)
+			result.Plan = &plan
+		}
+		return result
+	}
+
+	closestResolution := FindClosestResolution(targetResolution, encodingOptions.Resolutions)
+	log.Printf("Closest resolution found for camera %s: %dx%d", cameraID, closestResolution.Width, closestResolution.Height)
+	newConfig := requested
+	newConfig.Resolution = closestResolution
+	result.Resolution = closestResolution
+	result.ResolutionAdjusted = targetResolution != closestResolution
+
+	// A dry run stops here and reports the planned changes
+	plan := PlanEncoderConfig(currentConfig, newConfig, targetResolution)
+	validationErr := ValidateEncoderConfig(encodingOptions, newConfig)
+	if dryRun {
+		result.Plan = &plan
+		result.ResolutionAdjusted = plan.ResolutionAdjusted
+		if validationErr != nil {
+			result.Error = fmt.Errorf("requested config not supported: %w", validationErr)
+		} else {
+			result.Success = true
+		}
+		log.Printf("Dry run for camera %s: %d change(s) planned", cameraID, len(plan.Changes))
+		return result
+	}
+
+	if len(plan.Changes) == 0 {
+		log.Printf("Camera %s already has the requested configuration (Resolution: %dx%d, FPS: %d, Bitrate: %d, Encoding: %s), skipping config change",
+			cameraID, closestResolution.Width, closestResolution.Height, currentConfig.FPS, currentConfig.Bitrate, currentConfig.Encoding)
+		result.AppliedConfig = appliedSettings(currentConfig)
+		result.AppliedConfig["unchanged"] = true
+	} else {
+		// Reject values the camera does not offer before anything is written
+		if validationErr != nil {
+			log.Printf("Requested config not supported by camera %s: %v", cameraID, validationErr)
+			result.Error = fmt.Errorf("requested config not supported: %w", validationErr)
+			return result
+		}
+
+		// Set the new encoder config, unless the camera was given up on meanwhile
+		if err := pool.Abandoned(ctx); err != nil {
+			result.Error = err
+			return result
+		}
+		log.Printf("Setting new encoder config for camera %s: %+v", cameraID, newConfig)
+		report(StepConfiguring, fmt.Sprintf("setting %dx%d @ %d fps", closestResolution.Width, closestResolution.Height, newConfig.FPS))
+		if err := SetEncoderConfig(client, configToken, currentConfig, newConfig); err != nil {
+			log.Printf("Failed to set encoder config for %s: %v", cameraID, err)
+			result.Error = fmt.Errorf("failed to set encoder config: %w", err)
+			return result
+		}
+		log.Printf("Successfully applied config for camera %s", cameraID)
+		result.ConfigToken = configToken
+		result.PreviousConfig = &currentConfig
+		result.AppliedConfig = appliedSettings(newConfig)
+	}
+
+	// Get the stream URI with credentials for the validation
+	streamURL, err := client.GetAuthenticatedStreamURI(profileToken)
+	if err != nil {
+		log.Printf("Failed to get stream URI for %s: %v", cameraID, err)
+		result.Error = fmt.Errorf("failed to get stream URI: %w", err)
+		return result
+	}
+	result.StreamURL = streamURL
+	result.Success = true
+	return result
+}
+
+// appliedSettings reports the settings of a configuration in an apply result
+func appliedSettings(config models.EncoderConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"resolution": map[string]int{
+			"width":  config.Resolution.Width,
+			"height": config.Resolution.Height,
+		},
+		"fps":              config.FPS,
+		"bitrate":          config.Bitrate,
+		"encoding":         config.Encoding,
+		"gop":              config.GOP,
+		"encoderProfile":   config.EncoderProfile,
+		"rateControl":      config.RateControl,
+		"quality":          config.Quality,
+		"encodingInterval": config.EncodingInterval,
+	}
+}
+
+// connectionError explains a failure to read a camera, with hints for common network issues
+func connectionError(cam models.Camera, err error) error {
+	errorMsg := err.Error()
+	switch {
+	case strings.Contains(errorMsg, "i/o timeout") || strings.Contains(errorMsg, "dial tcp"):
+		return fmt.Errorf("network timeout: camera at %s:%d is not responding. Please check: 1) Camera is powered on and connected to network, 2) IP address %s is correct, 3) Port %d is the correct ONVIF port, 4) Camera supports ONVIF protocol", cam.IP, cam.Port, cam.IP, cam.Port)
+	case strings.Contains(errorMsg, "connection refused"):
+		return fmt.Errorf("connection refused: camera at %s:%d refused connection. Please check: 1) Correct ONVIF port (common ports: 80, 8080, 554), 2) ONVIF service is enabled on camera, 3) Firewall settings", cam.IP, cam.Port)
+	case strings.Contains(errorMsg, "no route to host"):
+		return fmt.Errorf("no route to host: cannot reach camera at %s:%d. Please check: 1) Camera and server are on same network, 2) IP address is correct, 3) Network routing", cam.IP, cam.Port)
+	default:
+		return fmt.Errorf("failed to get camera profiles and configs: %w", err)
+	}
+}
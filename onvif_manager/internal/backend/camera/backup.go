@@ -0,0 +1,260 @@
+package camera
+
+import (
+	"fmt"
+	"log"
+)
+
+// Sections of the camera settings, as named in restore steps and unavailable lists
+const (
+	SectionProfiles      = "profiles"
+	SectionVideoSources  = "videoSources"
+	SectionVideoEncoders = "videoEncoders"
+	SectionAudioEncoders = "audioEncoders"
+	SectionImaging       = "imaging"
+	SectionNetwork       = "network"
+	SectionHostname      = "hostname"
+	SectionDNS           = "dns"
+	SectionTime          = "time"
+)
+
+// Settings are all settings of a camera that can be read through its ONVIF services
+type Settings struct {
+	Profiles      []Profile            `json:"profiles"`
+	VideoSources  []VideoSourceConfig  `json:"videoSources"`
+	AudioEncoders []AudioEncoderConfig `json:"audioEncoders"`
+	Imaging       []ImagingSettings    `json:"imaging"`
+	Network       *NetworkSettings     `json:"network,omitempty"`
+	// Unavailable lists the sections the camera could not report, with the reason
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// RestoreStep is the outcome of writing one configuration back to a camera
+type RestoreStep struct {
+	Section string `json:"section"`
+	Token   string `json:"token,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReadSettings reads all settings of the camera. Only the profiles are required;
+// sections the camera does not support are recorded as unavailable.
+func ReadSettings(client *CameraClient) (*Settings, error) {
+	profiles, err := GetProfiles(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profiles: %w", err)
+	}
+
+	settings := &Settings{
+		Profiles:      profiles,
+		VideoSources:  []VideoSourceConfig{},
+		AudioEncoders: []AudioEncoderConfig{},
+		Imaging:       []ImagingSettings{},
+	}
+	unavailable := func(section string, err error) {
+		log.Printf("Camera %s: %s not backed up: %v", client.Camera.ID, section, err)
+		settings.Unavailable = append(settings.Unavailable, fmt.Sprintf("%s: %v", section, err))
+	}
+
+	if sources, err := client.GetVideoSourceConfigs(); err != nil {
+		unavailable(SectionVideoSources, err)
+	} else {
+		settings.VideoSources = sources
+	}
+
+	if encoders, err := client.GetAudioEncoderConfigs(); err != nil {
+		unavailable(SectionAudioEncoders, err)
+	} else {
+		settings.AudioEncoders = encoders
+	}
+
+	// Several configurations may crop the same physical input
+	seen := make(map[string]bool)
+	for _, source := range settings.VideoSources {
+		if source.SourceToken == "" || seen[source.SourceToken] {
+			continue
+		}
+		seen[source.SourceToken] = true
+		imaging, err := client.GetImagingSettings(source.SourceToken)
+		if err != nil {
+			unavailable(SectionImaging+" "+source.SourceToken, err)
+			continue
+		}
+		settings.Imaging = append(settings.Imaging, *imaging)
+	}
+
+	if network, err := client.GetNetworkSettings(); err != nil {
+		unavailable(SectionNetwork, err)
+	} else {
+		settings.Network = network
+	}
+
+	return settings, nil
+}
+
+// configRef identifies a configuration when settings are matched to another camera
+type configRef struct {
+	role  string
+	name  string
+	token string
+}
+
+// pairConfig returns the index of the configuration in targets that ref is restored to,
+// or -1 when the camera has none. Configurations are paired by role, then by name and
+// last by token; each one in targets is paired once.
+func pairConfig(ref configRef, targets []configRef, paired map[int]bool) int {
+	same := []func(a, b configRef) bool{
+		func(a, b configRef) bool { return a.role != "" && a.role == b.role },
+		func(a, b configRef) bool { return a.name != "" && a.name == b.name },
+		func(a, b configRef) bool { return a.token != "" && a.token == b.token },
+	}
+	for _, match := range same {
+		for i, target := range targets {
+			if !paired[i] && match(ref, target) {
+				paired[i] = true
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// MatchSettings rewrites backed up settings to the tokens of the camera they are restored
+// to, whose own settings are target. A replacement camera of the same model may name its
+// configurations alike but give them other tokens, so profiles are matched by role, and
+// profiles, video sources and audio encoders by name, before their tokens are compared.
+// Configurations the camera has no counterpart for are returned as failed steps and left
+// out of the settings.
+func MatchSettings(settings, target *Settings) (*Settings, []RestoreStep) {
+	matched := &Settings{Network: settings.Network}
+	var unmatched []RestoreStep
+	missing := func(section, token, what string) {
+		unmatched = append(unmatched, RestoreStep{
+			Section: section,
+			Token:   token,
+			Error:   fmt.Sprintf("the camera has no %s matching the backup", what),
+		})
+	}
+
+	// Physical inputs are renamed along with the video source configurations that use them
+	inputs := make(map[string]string)
+	targetSources := make([]configRef, len(target.VideoSources))
+	for i, source := range target.VideoSources {
+		targetSources[i] = configRef{name: source.Name, token: source.Token}
+	}
+	paired := make(map[int]bool)
+	for _, source := range settings.VideoSources {
+		i := pairConfig(configRef{name: source.Name, token: source.Token}, targetSources, paired)
+		if i < 0 {
+			missing(SectionVideoSources, source.Token, fmt.Sprintf("video source configuration %q", source.Name))
+			continue
+		}
+		if source.SourceToken != "" {
+			inputs[source.SourceToken] = target.VideoSources[i].SourceToken
+		}
+		source.Token = target.VideoSources[i].Token
+		source.SourceToken = target.VideoSources[i].SourceToken
+		matched.VideoSources = append(matched.VideoSources, source)
+	}
+
+	targetProfiles := make([]configRef, len(target.Profiles))
+	for i, profile := range target.Profiles {
+		targetProfiles[i] = configRef{role: profile.Role, name: profile.Name, token: profile.Token}
+	}
+	paired = make(map[int]bool)
+	for _, profile := range settings.Profiles {
+		if !profile.HasEncoder() || profile.Encoder == nil {
+			continue
+		}
+		i := pairConfig(configRef{role: profile.Role, name: profile.Name, token: profile.Token}, targetProfiles, paired)
+		if i < 0 || !target.Profiles[i].HasEncoder() {
+			missing(SectionVideoEncoders, profile.ConfigToken, fmt.Sprintf("profile %s with a video encoder", profile))
+			continue
+		}
+		profile.Token = target.Profiles[i].Token
+		profile.ConfigToken = target.Profiles[i].ConfigToken
+		matched.Profiles = append(matched.Profiles, profile)
+	}
+
+	targetEncoders := make([]configRef, len(target.AudioEncoders))
+	for i, encoder := range target.AudioEncoders {
+		targetEncoders[i] = configRef{name: encoder.Name, token: encoder.Token}
+	}
+	paired = make(map[int]bool)
+	for _, encoder := range settings.AudioEncoders {
+		i := pairConfig(configRef{name: encoder.Name, token: encoder.Token}, targetEncoders, paired)
+		if i < 0 {
+			missing(SectionAudioEncoders, encoder.Token, fmt.Sprintf("audio encoder configuration %q", encoder.Name))
+			continue
+		}
+		encoder.Token = target.AudioEncoders[i].Token
+		matched.AudioEncoders = append(matched.AudioEncoders, encoder)
+	}
+
+	targetInputs := make(map[string]bool)
+	for _, source := range target.VideoSources {
+		targetInputs[source.SourceToken] = true
+	}
+	for _, imaging := range settings.Imaging {
+		if input, ok := inputs[imaging.VideoSourceToken]; ok {
+			imaging.VideoSourceToken = input
+		} else if !targetInputs[imaging.VideoSourceToken] {
+			missing(SectionImaging, imaging.VideoSourceToken, "video input")
+			continue
+		}
+		matched.Imaging = append(matched.Imaging, imaging)
+	}
+
+	return matched, unmatched
+}
+
+// RestoreSettings writes settings read by ReadSettings back to a camera. The settings
+// must carry the camera's own tokens; see MatchSettings. Every configuration is written
+// separately so that one rejected setting does not stop the rest. Network interfaces,
+// gateways and protocols are not written, as a wrong address would make the camera
+// unreachable.
+func RestoreSettings(client *CameraClient, settings *Settings) []RestoreStep {
+	var steps []RestoreStep
+	record := func(section, token string, err error) {
+		step := RestoreStep{Section: section, Token: token, Success: err == nil}
+		if err != nil {
+			log.Printf("Camera %s: failed to restore %s %s: %v", client.Camera.ID, section, token, err)
+			step.Error = err.Error()
+		}
+		steps = append(steps, step)
+	}
+
+	for _, source := range settings.VideoSources {
+		record(SectionVideoSources, source.Token, client.SetVideoSourceConfig(source))
+	}
+
+	// Profiles may share an encoder configuration; write each one once
+	restored := make(map[string]bool)
+	for _, profile := range settings.Profiles {
+		if !profile.HasEncoder() || profile.Encoder == nil || restored[profile.ConfigToken] {
+			continue
+		}
+		restored[profile.ConfigToken] = true
+		record(SectionVideoEncoders, profile.ConfigToken, RestoreEncoderConfig(client, profile.ConfigToken, *profile.Encoder))
+	}
+
+	for _, encoder := range settings.AudioEncoders {
+		record(SectionAudioEncoders, encoder.Token, client.SetAudioEncoderConfig(encoder))
+	}
+
+	for _, imaging := range settings.Imaging {
+		record(SectionImaging, imaging.VideoSourceToken, client.SetImagingSettings(imaging))
+	}
+
+	if network := settings.Network; network != nil {
+		if !network.HostnameFromDHCP && network.Hostname != "" {
+			record(SectionHostname, "", client.SetHostname(network.Hostname))
+		}
+		record(SectionDNS, "", client.SetDNS(network.DNSFromDHCP, network.SearchDomains, network.DNSServers))
+		if network.Time != nil {
+			record(SectionTime, "", client.SetTimeSettings(*network.Time))
+		}
+	}
+
+	return steps
+}
@@ -0,0 +1,122 @@
+package camera
+
+import (
+	"reflect"
+	"testing"
+
+	"onvif_manager/pkg/models"
+)
+
+func TestMatchSettings(t *testing.T) {
+	mainEncoder := &models.EncoderConfig{Resolution: models.Resolution{Width: 1920, Height: 1080}, FPS: 25}
+	subEncoder := &models.EncoderConfig{Resolution: models.Resolution{Width: 640, Height: 360}, FPS: 15}
+	brightness := 60.0
+
+	backedUp := &Settings{
+		Profiles: []Profile{
+			{Token: "Profile_1", Name: "mainStream", Role: ProfileRoleMain, ConfigToken: "VideoEncoder_1", Encoder: mainEncoder},
+			{Token: "Profile_2", Name: "subStream", Role: ProfileRoleSub, ConfigToken: "VideoEncoder_2", Encoder: subEncoder},
+			{Token: "Profile_3", Name: "metadata"},
+		},
+		VideoSources:  []VideoSourceConfig{{Token: "VideoSourceConfig_1", Name: "VideoSource", SourceToken: "VideoSource_1"}},
+		AudioEncoders: []AudioEncoderConfig{{Token: "AudioEncoder_1", Name: "AudioEncoder", Encoding: "G711"}},
+		Imaging:       []ImagingSettings{{VideoSourceToken: "VideoSource_1", Brightness: &brightness}},
+		Network:       &NetworkSettings{Hostname: "lobby"},
+	}
+
+	tests := []struct {
+		name          string
+		target        *Settings
+		want          *Settings
+		wantUnmatched []RestoreStep
+	}{
+		{
+			name:   "same camera",
+			target: backedUp,
+			want: &Settings{
+				Profiles:      backedUp.Profiles[:2],
+				VideoSources:  backedUp.VideoSources,
+				AudioEncoders: backedUp.AudioEncoders,
+				Imaging:       backedUp.Imaging,
+				Network:       backedUp.Network,
+			},
+		},
+		{
+			name: "replacement with other tokens",
+			target: &Settings{
+				Profiles: []Profile{
+					{Token: "sub", Name: "Sub", Role: ProfileRoleSub, ConfigToken: "enc-sub"},
+					{Token: "main", Name: "Main", Role: ProfileRoleMain, ConfigToken: "enc-main"},
+				},
+				VideoSources:  []VideoSourceConfig{{Token: "vsc-0", Name: "VideoSource", SourceToken: "input-0"}},
+				AudioEncoders: []AudioEncoderConfig{{Token: "aenc-0", Name: "AudioEncoder"}},
+			},
+			want: &Settings{
+				Profiles: []Profile{
+					{Token: "main", Name: "mainStream", Role: ProfileRoleMain, ConfigToken: "enc-main", Encoder: mainEncoder},
+					{Token: "sub", Name: "subStream", Role: ProfileRoleSub, ConfigToken: "enc-sub", Encoder: subEncoder},
+				},
+				VideoSources:  []VideoSourceConfig{{Token: "vsc-0", Name: "VideoSource", SourceToken: "input-0"}},
+				AudioEncoders: []AudioEncoderConfig{{Token: "aenc-0", Name: "AudioEncoder", Encoding: "G711"}},
+				Imaging:       []ImagingSettings{{VideoSourceToken: "input-0", Brightness: &brightness}},
+				Network:       backedUp.Network,
+			},
+		},
+		{
+			name: "profiles matched by name",
+			target: &Settings{
+				Profiles: []Profile{
+					{Token: "p1", Name: "subStream", ConfigToken: "enc-1"},
+					{Token: "p0", Name: "mainStream", ConfigToken: "enc-0"},
+				},
+			},
+			want: &Settings{
+				Profiles: []Profile{
+					{Token: "p0", Name: "mainStream", Role: ProfileRoleMain, ConfigToken: "enc-0", Encoder: mainEncoder},
+					{Token: "p1", Name: "subStream", Role: ProfileRoleSub, ConfigToken: "enc-1", Encoder: subEncoder},
+				},
+				Network: backedUp.Network,
+			},
+			wantUnmatched: []RestoreStep{
+				{Section: SectionVideoSources, Token: "VideoSourceConfig_1", Error: `the camera has no video source configuration "VideoSource" matching the backup`},
+				{Section: SectionAudioEncoders, Token: "AudioEncoder_1", Error: `the camera has no audio encoder configuration "AudioEncoder" matching the backup`},
+				{Section: SectionImaging, Token: "VideoSource_1", Error: "the camera has no video input matching the backup"},
+			},
+		},
+		{
+			name: "camera with fewer profiles",
+			target: &Settings{
+				Profiles: []Profile{
+					{Token: "p0", Role: ProfileRoleMain, ConfigToken: "enc-0"},
+					{Token: "p1", Name: "metadata"},
+				},
+				VideoSources:  backedUp.VideoSources,
+				AudioEncoders: backedUp.AudioEncoders,
+			},
+			want: &Settings{
+				Profiles: []Profile{
+					{Token: "p0", Name: "mainStream", Role: ProfileRoleMain, ConfigToken: "enc-0", Encoder: mainEncoder},
+				},
+				VideoSources:  backedUp.VideoSources,
+				AudioEncoders: backedUp.AudioEncoders,
+				Imaging:       backedUp.Imaging,
+				Network:       backedUp.Network,
+			},
+			wantUnmatched: []RestoreStep{
+				{Section: SectionVideoEncoders, Token: "VideoEncoder_2", Error: "the camera has no profile subStream (sub) with a video encoder matching the backup"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unmatched := MatchSettings(backedUp, tt.target)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchSettings() settings = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(unmatched, tt.wantUnmatched) {
+				t.Errorf("MatchSettings() unmatched = %+v, want %+v", unmatched, tt.wantUnmatched)
+			}
+		})
+	}
+}
@@ -1,54 +1,86 @@
 package camera
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"sync/atomic"
 	"time"
 
 	"onvif_manager/pkg/models"
 
+	"github.com/videonext/onvif/profiles/devicemgmt"
 	"github.com/videonext/onvif/profiles/media"
 	"github.com/videonext/onvif/soap"
 )
 
 type CameraClient struct {
-	Camera models.Camera
-	Client *soap.Client
-	Media  media.Media // ONVIF Media service client
+	Camera   models.Camera
+	Client   *soap.Client
+	Device   devicemgmt.Device // ONVIF Device service client
+	Media    media.Media       // ONVIF Media service client
+	Services ServiceEndpoints  // Service XAddrs advertised by the camera
+
+	clockOffset   atomic.Int64 // Camera clock minus local clock, in nanoseconds
+	clockMeasured atomic.Bool
+
+	registry *Registry // Registry that connected the client; nil for clients created directly
 }
 
-// NewCameraClient connects to the ONVIF camera and returns a usable CameraClient
-func NewCameraClient(cam models.Camera) (*CameraClient, error) {
-	// Initialize SOAP client with timeout
-	client := soap.NewClient(soap.WithTimeout(5 * time.Second))
-	client.AddHeader(soap.NewWSSSecurityHeader(cam.Username, cam.Password, time.Now()))
-
-	// Determine port to use (default 80 if port is 0)
-	port := cam.Port
-	if port == 0 {
-		port = 80
+// configRecorder returns the recorder of the client's registry, or nil
+func (c *CameraClient) configRecorder() ConfigRecorder {
+	if c.registry == nil {
+		return nil
 	}
+	return c.registry.configRecorder()
+}
 
-	// Determine URL path to use (default onvif/media_service if URL is empty)
-	urlPath := cam.URL
-	if urlPath == "" {
-		urlPath = "onvif/media_service"
+// NewCameraClient connects to the ONVIF camera and returns a usable CameraClient.
+// The camera's clock is read first so that requests are signed with the camera's
+// time, then the service endpoints are read from the camera's device service;
+// when the camera answers but advertises no media service, the conventional media
+// endpoint is used instead. It fails when the camera answers none of the requests.
+func NewCameraClient(cam models.Camera) (*CameraClient, error) {
+	cameraClient := &CameraClient{
+		Camera:   cam,
+		Services: ServiceEndpoints{Device: deviceServiceURL(cam)},
 	}
 
-	// Build media service endpoint with port and URL path
-	endpoint := fmt.Sprintf("http://%s:%d/%s", cam.IP, port, urlPath)
+	// Initialize SOAP client with timeout; every request gets a fresh security header
+	cameraClient.Client = soap.NewClient(
+		soap.WithTimeout(5*time.Second),
+		soap.WithWSSCallback(cameraClient.securityHeader),
+	)
 
-	// Initialize ONVIF media service
-	mediaService := media.NewMedia(client, endpoint)
+	offset, clockErr := cameraClient.MeasureClockOffset()
+	if clockErr != nil {
+		log.Printf("Warning: could not read the clock of camera %s, signing requests with local time: %v", cam.ID, clockErr)
+	} else if offset.Abs() >= time.Second {
+		log.Printf("Camera %s clock is off by %s, compensating in WS-Security headers", cam.ID, offset)
+	}
+
+	services, err := resolveServiceEndpoints(cameraClient.Client, cam)
+	var resolveErr *resolveError
+	if errors.As(err, &resolveErr) && resolveErr.noResponse() && noResponse(clockErr) {
+		return nil, fmt.Errorf("camera %s did not respond at %s: %w", cam.ID, cameraClient.Services.Device, resolveErr.services)
+	}
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
 
-	return &CameraClient{
-		Camera: cam,
-		Client: client,
-		Media:  mediaService,
-	}, nil
+	cameraClient.Services = services
+	cameraClient.Device = devicemgmt.NewDevice(cameraClient.Client, services.Device)
+	cameraClient.Media = media.NewMedia(cameraClient.Client, services.Media)
+	return cameraClient, nil
 }
 
-// GetStreamURI retrieves the RTSP stream URI for a given profile.
+// GetStreamURI retrieves the RTSP stream URI for a given profile, through Media2 when the camera advertises it.
 func (c *CameraClient) GetStreamURI(profileToken string) (string, error) {
+	if c.UsesMedia2() {
+		return getMedia2StreamURI(c, profileToken)
+	}
+
 	request := &media.GetStreamUri{
 		StreamSetup: media.StreamSetup{
 			Stream: media.StreamType("RTP-Unicast"),
@@ -70,3 +102,19 @@ func (c *CameraClient) GetStreamURI(profileToken string) (string, error) {
 
 	return string(resp.MediaUri.Uri), nil
 }
+
+// GetAuthenticatedStreamURI returns the stream URI of a profile with the camera's
+// credentials embedded, as FFmpeg needs them to open the stream
+func (c *CameraClient) GetAuthenticatedStreamURI(profileToken string) (string, error) {
+	streamURI, err := c.GetStreamURI(profileToken)
+	if err != nil {
+		return "", err
+	}
+
+	parsedURI, err := url.Parse(streamURI)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse stream URI: %w", err)
+	}
+	parsedURI.User = url.UserPassword(c.Camera.Username, c.Camera.Password)
+	return parsedURI.String(), nil
+}
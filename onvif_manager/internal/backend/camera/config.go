@@ -2,75 +2,86 @@ package camera
 
 import (
 	"fmt"
+	"log"
 	"onvif_manager/pkg/models"
+	"strings"
 
 	"github.com/videonext/onvif/profiles/media"
 )
 
-// GetProfilesAndConfigs returns all profile tokens and config tokens.
-func GetProfilesAndConfigs(client *CameraClient) (profileTokens, configTokens []string, err error) {
-	resp, err := client.Media.GetProfiles(&media.GetProfiles{})
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get profiles: %w", err)
-	}
+// Video encodings by their ONVIF names. The Media service only knows H264 and JPEG,
+// H265 needs the Media2 service.
+const (
+	EncodingH264 = "H264"
+	EncodingH265 = "H265"
+	EncodingJPEG = "JPEG"
+)
 
-	for _, profile := range resp.Profiles {
-		profileTokens = append(profileTokens, string(profile.Token))
-		if profile.VideoEncoderConfiguration.Token != "" {
-			configTokens = append(configTokens, string(profile.VideoEncoderConfiguration.Token))
-		}
-	}
-	if len(profileTokens) == 0 || len(configTokens) == 0 {
-		return nil, nil, fmt.Errorf("no usable profiles or config tokens found")
+// NormalizeEncoding maps the encoding names used in config files and by cameras
+// (h264, H.265, HEVC, MJPEG, ...) to the ONVIF names. Unknown names are returned upper cased.
+func NormalizeEncoding(encoding string) string {
+	switch name := strings.ToUpper(strings.TrimSpace(encoding)); name {
+	case "H.264", "AVC":
+		return EncodingH264
+	case "H.265", "HEVC":
+		return EncodingH265
+	case "MJPEG", "MJPG":
+		return EncodingJPEG
+	default:
+		return name
 	}
+}
+
+// Rate control modes. Only Media2 can switch between them, through ConstantBitRate.
+const (
+	RateControlCBR = "CBR"
+	RateControlVBR = "VBR"
+)
 
-	return profileTokens, configTokens, nil
+// NormalizeRateControl maps a rate control mode given as cbr/constant or vbr/variable
+// to CBR or VBR. Unknown names are returned upper cased.
+func NormalizeRateControl(mode string) string {
+	switch name := strings.ToUpper(strings.TrimSpace(mode)); name {
+	case "CONSTANT":
+		return RateControlCBR
+	case "VARIABLE":
+		return RateControlVBR
+	default:
+		return name
+	}
 }
 
-// GetCurrentEncoderOptions returns the available encoder options for a given config.
-func GetCurrentEncoderOptions(client *CameraClient, profileToken, configToken string) (models.EncoderOption, error) {
-	resp, err := client.Media.GetVideoEncoderConfigurationOptions(&media.GetVideoEncoderConfigurationOptions{
-		ConfigurationToken: media.ReferenceToken(configToken),
-		ProfileToken:       media.ReferenceToken(profileToken),
-	})
-	if err != nil {
-		return models.EncoderOption{}, fmt.Errorf("failed to get encoder options: %w", err)
-	}
-	var resolutions []models.Resolution
-	for _, res := range resp.Options.H264.ResolutionsAvailable {
-		resolutions = append(resolutions, models.Resolution{
-			Width:  int(res.Width),
-			Height: int(res.Height),
-		})
-	}
-	var fpsList []int
-	// Iterate from min to max frame rate
-	for fps := resp.Options.H264.FrameRateRange.Min; fps <= resp.Options.H264.FrameRateRange.Max; fps++ {
-		fpsList = append(fpsList, int(fps))
-	}
-	var bitrateList []int
-	// Check if bitrate range is available in the extension
-	if resp.Options.Extension.H264.BitrateRange.Min > 0 && resp.Options.Extension.H264.BitrateRange.Max > 0 {
-		// Use only the actual min and max values from camera's range
-		minBitrate := int(resp.Options.Extension.H264.BitrateRange.Min)
-		maxBitrate := int(resp.Options.Extension.H264.BitrateRange.Max)
-
-		// Only provide min and max bitrate options
-		bitrateList = append(bitrateList, minBitrate)
-		if minBitrate != maxBitrate {
-			bitrateList = append(bitrateList, maxBitrate)
+// NormalizeEncoderProfile maps an H.264/H.265 profile name given in any case to the
+// spelling ONVIF uses, e.g. "main" to "Main". Unknown names are returned as given.
+func NormalizeEncoderProfile(profile string) string {
+	profile = strings.TrimSpace(profile)
+	for _, name := range []string{"Baseline", "Main", "Main10", "Extended", "High"} {
+		if strings.EqualFold(profile, name) {
+			return name
 		}
 	}
+	return profile
+}
 
-	return models.EncoderOption{
-		Resolutions: resolutions,
-		FPSOptions:  fpsList,
-		Bitrate:     bitrateList,
-	}, nil
+// TargetEncoding returns the encoding a config change ends up with:
+// the requested one, or the current one when none is requested.
+func TargetEncoding(requested, current string) string {
+	if requested != "" {
+		return NormalizeEncoding(requested)
+	}
+	return NormalizeEncoding(current)
 }
 
 // GetCurrentConfig retrieves the actual current video encoder configuration.
 func GetCurrentConfig(client *CameraClient, configToken string) (models.EncoderConfig, error) {
+	if client.UsesMedia2() {
+		cfg, err := getMedia2EncoderConfig(client, configToken)
+		if err != nil {
+			return models.EncoderConfig{}, fmt.Errorf("failed to get encoder config: %w", err)
+		}
+		return cfg.encoderConfig(), nil
+	}
+
 	resp, err := client.Media.GetVideoEncoderConfiguration(&media.GetVideoEncoderConfiguration{
 		ConfigurationToken: media.ReferenceToken(configToken),
 	})
@@ -78,8 +89,12 @@ func GetCurrentConfig(client *CameraClient, configToken string) (models.EncoderC
 		return models.EncoderConfig{}, fmt.Errorf("failed to get encoder config: %w", err)
 	}
 
-	cfg := resp.Configuration
-	return models.EncoderConfig{
+	return mediaEncoderConfig(resp.Configuration), nil
+}
+
+// mediaEncoderConfig converts a video encoder configuration of the Media service to the common model
+func mediaEncoderConfig(cfg media.VideoEncoderConfiguration) models.EncoderConfig {
+	config := models.EncoderConfig{
 		Resolution: models.Resolution{
 			Width:  int(cfg.Resolution.Width),
 			Height: int(cfg.Resolution.Height),
@@ -87,48 +102,21 @@ func GetCurrentConfig(client *CameraClient, configToken string) (models.EncoderC
 		Quality:  int(cfg.Quality),
 		FPS:      int(cfg.RateControl.FrameRateLimit),
 		Bitrate:  int(cfg.RateControl.BitrateLimit),
-		Encoding: string(cfg.Encoding),
-	}, nil
-}
-
-// SetEncoderConfig updates the camera's encoder configuration.
-func SetEncoderConfig(client *CameraClient, configToken string, config models.EncoderConfig, input models.EncoderConfig) error {
-	resp, err := client.Media.GetVideoEncoderConfiguration(&media.GetVideoEncoderConfiguration{
-		ConfigurationToken: media.ReferenceToken(configToken),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to get video encoder config: %w", err)
+		Encoding: NormalizeEncoding(string(cfg.Encoding)),
+		// The Media service has no rate control mode, only the encoding interval
+		EncodingInterval: int(cfg.RateControl.EncodingInterval),
 	}
-
-	// Get the current configuration to work with
-	cfg := resp.Configuration
-
-	// Test if encoding change is supported first (if an encoding was specified)
-	encodingSupported := true
-	if input.Encoding != "" {
-		// Only test encoding change if input encoding differs from current
-		if string(cfg.Encoding) != input.Encoding {
-			// Create a copy for testing encoding only
-			cfgTest := resp.Configuration
-			cfgTest.Encoding = media.VideoEncoding(input.Encoding)
-
-			reqTest := &media.SetVideoEncoderConfiguration{
-				Configuration:    cfgTest,
-				ForcePersistence: true,
-			}
-
-			_, encErr := client.Media.SetVideoEncoderConfiguration(reqTest)
-			if encErr != nil {
-				fmt.Printf("Camera does not support %s encoding: %v\n", input.Encoding, encErr)
-				encodingSupported = false
-			} else {
-				fmt.Printf("Camera supports %s encoding\n", input.Encoding)
-			}
-		}
+	// The Media service only has GOP and profile settings for H.264
+	if config.Encoding == EncodingH264 {
+		config.GOP = int(cfg.H264.GovLength)
+		config.EncoderProfile = string(cfg.H264.H264Profile)
 	}
+	return config
+}
 
-	// Now apply the full configuration
-
+// mergeEncoderConfig returns the configuration SetEncoderConfig writes for input:
+// unset (zero) values are taken from the current config, names are normalized.
+func mergeEncoderConfig(config models.EncoderConfig, input models.EncoderConfig) models.EncoderConfig {
 	// Use existing values if input values are not provided (0)
 	if input.Quality == 0 {
 		input.Quality = config.Quality
@@ -153,17 +141,101 @@ func SetEncoderConfig(client *CameraClient, configToken string, config models.En
 		}
 	}
 
-	// Only change encoding if the test was successful
-	if encodingSupported && input.Encoding != "" {
-		cfg.Encoding = media.VideoEncoding(input.Encoding)
+	input.Encoding = TargetEncoding(input.Encoding, config.Encoding)
+	if input.GOP == 0 {
+		input.GOP = config.GOP
+	}
+	// Encoder profiles belong to one encoding, so the current one is only kept with its encoding
+	if input.EncoderProfile == "" && input.Encoding == NormalizeEncoding(config.Encoding) {
+		input.EncoderProfile = config.EncoderProfile
+	}
+	input.EncoderProfile = NormalizeEncoderProfile(input.EncoderProfile)
+	if input.RateControl == "" {
+		input.RateControl = config.RateControl
+	}
+	input.RateControl = NormalizeRateControl(input.RateControl)
+	if input.EncodingInterval == 0 {
+		input.EncodingInterval = config.EncodingInterval
+	}
+	return input
+}
+
+// ConfigRecorder is told about every encoder configuration written through SetEncoderConfig
+type ConfigRecorder interface {
+	RecordApplied(cameraID, configToken string, config models.EncoderConfig)
+}
+
+// SetEncoderConfig updates the camera's encoder configuration.
+// A change of encoding is checked against the encoder options before anything is written.
+// The written configuration is reported to the recorder of the registry the client belongs to.
+func SetEncoderConfig(client *CameraClient, configToken string, config models.EncoderConfig, input models.EncoderConfig) error {
+	input = mergeEncoderConfig(config, input)
+	if err := setEncoderConfig(client, configToken, config, input); err != nil {
+		return err
 	}
 
-	// Apply the rest of the configuration changes
+	if configRecorder := client.configRecorder(); configRecorder != nil {
+		// Cameras may adjust values they store, so the configuration is read back
+		applied, err := GetCurrentConfig(client, configToken)
+		if err != nil {
+			log.Printf("Failed to read back encoder config %s of camera %s: %v", configToken, client.Camera.ID, err)
+			applied = input
+		}
+		configRecorder.RecordApplied(client.Camera.ID, configToken, applied)
+	}
+	return nil
+}
+
+// setEncoderConfig writes the merged configuration input
+func setEncoderConfig(client *CameraClient, configToken string, config models.EncoderConfig, input models.EncoderConfig) error {
+	sameEncoding := input.Encoding == NormalizeEncoding(config.Encoding)
+
+	if !sameEncoding {
+		options, err := GetEncoderOptions(client, "", configToken)
+		if err != nil {
+			return err
+		}
+		if _, err := SelectEncodingOptions(options, input.Encoding); err != nil {
+			return fmt.Errorf("cannot change encoding to %s: %w", input.Encoding, err)
+		}
+	}
+
+	if client.UsesMedia2() {
+		current, err := getMedia2EncoderConfig(client, configToken)
+		if err != nil {
+			return fmt.Errorf("failed to get video encoder config: %w", err)
+		}
+		return setMedia2EncoderConfig(client, current, input)
+	}
+
+	resp, err := client.Media.GetVideoEncoderConfiguration(&media.GetVideoEncoderConfiguration{
+		ConfigurationToken: media.ReferenceToken(configToken),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get video encoder config: %w", err)
+	}
+
+	// Apply the configuration changes to the current configuration
+	cfg := resp.Configuration
+	if input.Encoding != "" {
+		cfg.Encoding = media.VideoEncoding(input.Encoding)
+	}
 	cfg.Resolution.Width = int32(input.Resolution.Width)
 	cfg.Resolution.Height = int32(input.Resolution.Height)
 	cfg.RateControl.FrameRateLimit = int32(input.FPS)
 	cfg.Quality = float32(input.Quality)
 	cfg.RateControl.BitrateLimit = int32(input.Bitrate)
+	if input.EncodingInterval > 0 {
+		cfg.RateControl.EncodingInterval = int32(input.EncodingInterval)
+	}
+	if input.Encoding == EncodingH264 {
+		if input.GOP > 0 {
+			cfg.H264.GovLength = int32(input.GOP)
+		}
+		if input.EncoderProfile != "" {
+			cfg.H264.H264Profile = media.H264Profile(input.EncoderProfile)
+		}
+	}
 
 	// Apply the configuration
 	req := &media.SetVideoEncoderConfiguration{
@@ -0,0 +1,135 @@
+package camera
+
+import (
+	"testing"
+
+	"onvif_manager/pkg/models"
+
+	"github.com/videonext/onvif/profiles/media"
+)
+
+func TestNormalizeRateControl(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{mode: "cbr", want: RateControlCBR},
+		{mode: " Constant ", want: RateControlCBR},
+		{mode: "vbr", want: RateControlVBR},
+		{mode: "variable", want: RateControlVBR},
+		{mode: "abr", want: "ABR"},
+		{mode: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeRateControl(tt.mode); got != tt.want {
+			t.Errorf("NormalizeRateControl(%q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeEncoderProfile(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{profile: "main", want: "Main"},
+		{profile: "MAIN10", want: "Main10"},
+		{profile: " high ", want: "High"},
+		{profile: "Extended", want: "Extended"},
+		{profile: "custom", want: "custom"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeEncoderProfile(tt.profile); got != tt.want {
+			t.Errorf("NormalizeEncoderProfile(%q) = %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestMergeEncoderConfig(t *testing.T) {
+	current := models.EncoderConfig{
+		Resolution:       models.Resolution{Width: 1920, Height: 1080},
+		Quality:          5,
+		FPS:              25,
+		Bitrate:          4096,
+		Encoding:         "H264",
+		GOP:              50,
+		EncoderProfile:   "High",
+		RateControl:      RateControlVBR,
+		EncodingInterval: 1,
+	}
+
+	tests := []struct {
+		name    string
+		current models.EncoderConfig
+		input   models.EncoderConfig
+		want    models.EncoderConfig
+	}{
+		{
+			name:    "unset values are kept",
+			current: current,
+			input:   models.EncoderConfig{Resolution: models.Resolution{Width: 1280, Height: 720}},
+			want: models.EncoderConfig{Resolution: models.Resolution{Width: 1280, Height: 720}, Quality: 5, FPS: 25, Bitrate: 4096,
+				Encoding: EncodingH264, GOP: 50, EncoderProfile: "High", RateControl: RateControlVBR, EncodingInterval: 1},
+		},
+		{
+			name:    "rate control and names are normalized",
+			current: current,
+			input:   models.EncoderConfig{Resolution: current.Resolution, RateControl: "constant", EncoderProfile: "main", EncodingInterval: 2},
+			want: models.EncoderConfig{Resolution: current.Resolution, Quality: 5, FPS: 25, Bitrate: 4096,
+				Encoding: EncodingH264, GOP: 50, EncoderProfile: "Main", RateControl: RateControlCBR, EncodingInterval: 2},
+		},
+		{
+			name:    "profile is dropped with a change of encoding",
+			current: current,
+			input:   models.EncoderConfig{Resolution: current.Resolution, Encoding: "hevc"},
+			want: models.EncoderConfig{Resolution: current.Resolution, Quality: 5, FPS: 25, Bitrate: 4096,
+				Encoding: EncodingH265, GOP: 50, RateControl: RateControlVBR, EncodingInterval: 1},
+		},
+		{
+			name:    "bitrate estimated from the resolution",
+			current: models.EncoderConfig{Encoding: "H264"},
+			input:   models.EncoderConfig{Resolution: models.Resolution{Width: 1280, Height: 720}},
+			want:    models.EncoderConfig{Resolution: models.Resolution{Width: 1280, Height: 720}, Bitrate: 2048, Encoding: EncodingH264},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := mergeEncoderConfig(tt.current, tt.input); got != tt.want {
+			t.Errorf("%s: mergeEncoderConfig() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMediaEncoderConfig(t *testing.T) {
+	var cfg media.VideoEncoderConfiguration
+	cfg.Encoding = "H264"
+	cfg.Resolution.Width, cfg.Resolution.Height = 1920, 1080
+	cfg.Quality = 6
+	cfg.RateControl.FrameRateLimit = 25
+	cfg.RateControl.BitrateLimit = 4096
+	cfg.RateControl.EncodingInterval = 2
+	cfg.H264.GovLength = 50
+	cfg.H264.H264Profile = "Main"
+
+	want := models.EncoderConfig{
+		Resolution:       models.Resolution{Width: 1920, Height: 1080},
+		Quality:          6,
+		FPS:              25,
+		Bitrate:          4096,
+		Encoding:         EncodingH264,
+		GOP:              50,
+		EncoderProfile:   "Main",
+		EncodingInterval: 2,
+	}
+	if got := mediaEncoderConfig(cfg); got != want {
+		t.Errorf("mediaEncoderConfig() = %+v, want %+v", got, want)
+	}
+
+	// GOP and profile are only read for H.264
+	cfg.Encoding = "JPEG"
+	if got := mediaEncoderConfig(cfg); got.GOP != 0 || got.EncoderProfile != "" || got.RateControl != "" {
+		t.Errorf("mediaEncoderConfig() of JPEG = %+v, want no GOP, profile or rate control", got)
+	}
+}
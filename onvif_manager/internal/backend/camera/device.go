@@ -0,0 +1,35 @@
+package camera
+
+import (
+	"fmt"
+
+	"onvif_manager/pkg/models"
+
+	"github.com/videonext/onvif/profiles/devicemgmt"
+)
+
+// GetDeviceInformation returns the manufacturer, model, firmware version,
+// serial number and hardware ID reported by the camera's device service.
+func (c *CameraClient) GetDeviceInformation() (*models.DeviceInfo, error) {
+	resp, err := c.Device.GetDeviceInformation(&devicemgmt.GetDeviceInformation{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device information: %w", err)
+	}
+
+	return &models.DeviceInfo{
+		Manufacturer:    resp.Manufacturer,
+		Model:           resp.Model,
+		FirmwareVersion: resp.FirmwareVersion,
+		SerialNumber:    resp.SerialNumber,
+		HardwareID:      resp.HardwareId,
+	}, nil
+}
+
+// Reboot asks the camera to restart and returns the message it reports, e.g. the expected restart time
+func (c *CameraClient) Reboot() (string, error) {
+	resp, err := c.Device.SystemReboot(&devicemgmt.SystemReboot{})
+	if err != nil {
+		return "", fmt.Errorf("failed to reboot camera: %w", err)
+	}
+	return resp.Message, nil
+}
@@ -0,0 +1,258 @@
+package camera
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// ImagingSettings are the image settings of a video source. Settings the camera
+// does not report are nil or empty and are left alone when the settings are written.
+type ImagingSettings struct {
+	VideoSourceToken      string                `json:"videoSourceToken"`
+	BacklightCompensation *ImagingMode          `json:"backlightCompensation,omitempty"`
+	Brightness            *float64              `json:"brightness,omitempty"`
+	ColorSaturation       *float64              `json:"colorSaturation,omitempty"`
+	Contrast              *float64              `json:"contrast,omitempty"`
+	Exposure              *ExposureSettings     `json:"exposure,omitempty"`
+	Focus                 *FocusSettings        `json:"focus,omitempty"`
+	IrCutFilter           string                `json:"irCutFilter,omitempty"` // ON, OFF or AUTO
+	Sharpness             *float64              `json:"sharpness,omitempty"`
+	WideDynamicRange      *ImagingMode          `json:"wideDynamicRange,omitempty"`
+	WhiteBalance          *WhiteBalanceSettings `json:"whiteBalance,omitempty"`
+}
+
+// ImagingMode is an imaging feature that is switched on or off, with an optional level
+type ImagingMode struct {
+	Mode  string   `json:"mode"`
+	Level *float64 `json:"level,omitempty"`
+}
+
+// ExposureSettings are the exposure settings of a video source
+type ExposureSettings struct {
+	Mode            string   `json:"mode"` // AUTO or MANUAL
+	Priority        string   `json:"priority,omitempty"`
+	MinExposureTime *float64 `json:"minExposureTime,omitempty"`
+	MaxExposureTime *float64 `json:"maxExposureTime,omitempty"`
+	MinGain         *float64 `json:"minGain,omitempty"`
+	MaxGain         *float64 `json:"maxGain,omitempty"`
+	MinIris         *float64 `json:"minIris,omitempty"`
+	MaxIris         *float64 `json:"maxIris,omitempty"`
+	ExposureTime    *float64 `json:"exposureTime,omitempty"`
+	Gain            *float64 `json:"gain,omitempty"`
+	Iris            *float64 `json:"iris,omitempty"`
+}
+
+// FocusSettings are the focus settings of a video source
+type FocusSettings struct {
+	AutoFocusMode string   `json:"autoFocusMode"` // AUTO or MANUAL
+	DefaultSpeed  *float64 `json:"defaultSpeed,omitempty"`
+	NearLimit     *float64 `json:"nearLimit,omitempty"`
+	FarLimit      *float64 `json:"farLimit,omitempty"`
+}
+
+// WhiteBalanceSettings are the white balance settings of a video source
+type WhiteBalanceSettings struct {
+	Mode   string   `json:"mode"` // AUTO or MANUAL
+	CrGain *float64 `json:"crGain,omitempty"`
+	CbGain *float64 `json:"cbGain,omitempty"`
+}
+
+// The imaging package sends every optional element, including empty ones that cameras
+// reject, so the imaging messages are defined here. Optional values are pointers so
+// that settings the camera does not report are left out. Responses are matched by
+// local name only.
+
+type imagingModeXML struct {
+	Mode  string   `xml:"Mode"`
+	Level *float64 `xml:"Level"`
+}
+
+type exposureXML struct {
+	Mode            string   `xml:"Mode"`
+	Priority        string   `xml:"Priority"`
+	MinExposureTime *float64 `xml:"MinExposureTime"`
+	MaxExposureTime *float64 `xml:"MaxExposureTime"`
+	MinGain         *float64 `xml:"MinGain"`
+	MaxGain         *float64 `xml:"MaxGain"`
+	MinIris         *float64 `xml:"MinIris"`
+	MaxIris         *float64 `xml:"MaxIris"`
+	ExposureTime    *float64 `xml:"ExposureTime"`
+	Gain            *float64 `xml:"Gain"`
+	Iris            *float64 `xml:"Iris"`
+}
+
+type focusXML struct {
+	AutoFocusMode string   `xml:"AutoFocusMode"`
+	DefaultSpeed  *float64 `xml:"DefaultSpeed"`
+	NearLimit     *float64 `xml:"NearLimit"`
+	FarLimit      *float64 `xml:"FarLimit"`
+}
+
+type whiteBalanceXML struct {
+	Mode   string   `xml:"Mode"`
+	CrGain *float64 `xml:"CrGain"`
+	CbGain *float64 `xml:"CbGain"`
+}
+
+type imagingSettingsResponse struct {
+	BacklightCompensation *imagingModeXML  `xml:"BacklightCompensation"`
+	Brightness            *float64         `xml:"Brightness"`
+	ColorSaturation       *float64         `xml:"ColorSaturation"`
+	Contrast              *float64         `xml:"Contrast"`
+	Exposure              *exposureXML     `xml:"Exposure"`
+	Focus                 *focusXML        `xml:"Focus"`
+	IrCutFilter           string           `xml:"IrCutFilter"`
+	Sharpness             *float64         `xml:"Sharpness"`
+	WideDynamicRange      *imagingModeXML  `xml:"WideDynamicRange"`
+	WhiteBalance          *whiteBalanceXML `xml:"WhiteBalance"`
+}
+
+type getImagingSettingsRequest struct {
+	XMLName          xml.Name `xml:"http://www.onvif.org/ver20/imaging/wsdl GetImagingSettings"`
+	VideoSourceToken string   `xml:"http://www.onvif.org/ver20/imaging/wsdl VideoSourceToken"`
+}
+
+type getImagingSettingsResponse struct {
+	XMLName         xml.Name                `xml:"GetImagingSettingsResponse"`
+	ImagingSettings imagingSettingsResponse `xml:"ImagingSettings"`
+}
+
+type imagingModeRequest struct {
+	Mode  string   `xml:"http://www.onvif.org/ver10/schema Mode"`
+	Level *float64 `xml:"http://www.onvif.org/ver10/schema Level,omitempty"`
+}
+
+type exposureRequest struct {
+	Mode            string   `xml:"http://www.onvif.org/ver10/schema Mode"`
+	Priority        string   `xml:"http://www.onvif.org/ver10/schema Priority,omitempty"`
+	MinExposureTime *float64 `xml:"http://www.onvif.org/ver10/schema MinExposureTime,omitempty"`
+	MaxExposureTime *float64 `xml:"http://www.onvif.org/ver10/schema MaxExposureTime,omitempty"`
+	MinGain         *float64 `xml:"http://www.onvif.org/ver10/schema MinGain,omitempty"`
+	MaxGain         *float64 `xml:"http://www.onvif.org/ver10/schema MaxGain,omitempty"`
+	MinIris         *float64 `xml:"http://www.onvif.org/ver10/schema MinIris,omitempty"`
+	MaxIris         *float64 `xml:"http://www.onvif.org/ver10/schema MaxIris,omitempty"`
+	ExposureTime    *float64 `xml:"http://www.onvif.org/ver10/schema ExposureTime,omitempty"`
+	Gain            *float64 `xml:"http://www.onvif.org/ver10/schema Gain,omitempty"`
+	Iris            *float64 `xml:"http://www.onvif.org/ver10/schema Iris,omitempty"`
+}
+
+type focusRequest struct {
+	AutoFocusMode string   `xml:"http://www.onvif.org/ver10/schema AutoFocusMode"`
+	DefaultSpeed  *float64 `xml:"http://www.onvif.org/ver10/schema DefaultSpeed,omitempty"`
+	NearLimit     *float64 `xml:"http://www.onvif.org/ver10/schema NearLimit,omitempty"`
+	FarLimit      *float64 `xml:"http://www.onvif.org/ver10/schema FarLimit,omitempty"`
+}
+
+type whiteBalanceRequest struct {
+	Mode   string   `xml:"http://www.onvif.org/ver10/schema Mode"`
+	CrGain *float64 `xml:"http://www.onvif.org/ver10/schema CrGain,omitempty"`
+	CbGain *float64 `xml:"http://www.onvif.org/ver10/schema CbGain,omitempty"`
+}
+
+type imagingSettingsRequest struct {
+	BacklightCompensation *imagingModeRequest  `xml:"http://www.onvif.org/ver10/schema BacklightCompensation,omitempty"`
+	Brightness            *float64             `xml:"http://www.onvif.org/ver10/schema Brightness,omitempty"`
+	ColorSaturation       *float64             `xml:"http://www.onvif.org/ver10/schema ColorSaturation,omitempty"`
+	Contrast              *float64             `xml:"http://www.onvif.org/ver10/schema Contrast,omitempty"`
+	Exposure              *exposureRequest     `xml:"http://www.onvif.org/ver10/schema Exposure,omitempty"`
+	Focus                 *focusRequest        `xml:"http://www.onvif.org/ver10/schema Focus,omitempty"`
+	IrCutFilter           string               `xml:"http://www.onvif.org/ver10/schema IrCutFilter,omitempty"`
+	Sharpness             *float64             `xml:"http://www.onvif.org/ver10/schema Sharpness,omitempty"`
+	WideDynamicRange      *imagingModeRequest  `xml:"http://www.onvif.org/ver10/schema WideDynamicRange,omitempty"`
+	WhiteBalance          *whiteBalanceRequest `xml:"http://www.onvif.org/ver10/schema WhiteBalance,omitempty"`
+}
+
+type setImagingSettingsRequest struct {
+	XMLName          xml.Name               `xml:"http://www.onvif.org/ver20/imaging/wsdl SetImagingSettings"`
+	VideoSourceToken string                 `xml:"http://www.onvif.org/ver20/imaging/wsdl VideoSourceToken"`
+	ImagingSettings  imagingSettingsRequest `xml:"http://www.onvif.org/ver20/imaging/wsdl ImagingSettings"`
+	ForcePersistence bool                   `xml:"http://www.onvif.org/ver20/imaging/wsdl ForcePersistence"`
+}
+
+type setImagingSettingsResponse struct {
+	XMLName xml.Name `xml:"SetImagingSettingsResponse"`
+}
+
+// GetImagingSettings returns the image settings of a video source through the Imaging service
+func (c *CameraClient) GetImagingSettings(videoSourceToken string) (*ImagingSettings, error) {
+	if c.Services.Imaging == "" {
+		return nil, fmt.Errorf("camera does not advertise the Imaging service")
+	}
+
+	resp := new(getImagingSettingsResponse)
+	request := &getImagingSettingsRequest{VideoSourceToken: videoSourceToken}
+	if err := c.Client.Call(c.Services.Imaging, imagingNamespace+"/GetImagingSettings", request, resp); err != nil {
+		return nil, fmt.Errorf("failed to get imaging settings of video source %s: %w", videoSourceToken, err)
+	}
+
+	s := resp.ImagingSettings
+	settings := &ImagingSettings{
+		VideoSourceToken: videoSourceToken,
+		Brightness:       s.Brightness,
+		ColorSaturation:  s.ColorSaturation,
+		Contrast:         s.Contrast,
+		IrCutFilter:      s.IrCutFilter,
+		Sharpness:        s.Sharpness,
+	}
+	if m := s.BacklightCompensation; m != nil {
+		settings.BacklightCompensation = &ImagingMode{Mode: m.Mode, Level: m.Level}
+	}
+	if m := s.WideDynamicRange; m != nil {
+		settings.WideDynamicRange = &ImagingMode{Mode: m.Mode, Level: m.Level}
+	}
+	if e := s.Exposure; e != nil {
+		exposure := ExposureSettings(*e)
+		settings.Exposure = &exposure
+	}
+	if f := s.Focus; f != nil {
+		focus := FocusSettings(*f)
+		settings.Focus = &focus
+	}
+	if wb := s.WhiteBalance; wb != nil {
+		whiteBalance := WhiteBalanceSettings(*wb)
+		settings.WhiteBalance = &whiteBalance
+	}
+	return settings, nil
+}
+
+// SetImagingSettings writes the image settings of a video source; settings that are not set are left alone
+func (c *CameraClient) SetImagingSettings(settings ImagingSettings) error {
+	if c.Services.Imaging == "" {
+		return fmt.Errorf("camera does not advertise the Imaging service")
+	}
+
+	request := &setImagingSettingsRequest{
+		VideoSourceToken: settings.VideoSourceToken,
+		ImagingSettings: imagingSettingsRequest{
+			Brightness:      settings.Brightness,
+			ColorSaturation: settings.ColorSaturation,
+			Contrast:        settings.Contrast,
+			IrCutFilter:     settings.IrCutFilter,
+			Sharpness:       settings.Sharpness,
+		},
+		ForcePersistence: true,
+	}
+	if m := settings.BacklightCompensation; m != nil {
+		request.ImagingSettings.BacklightCompensation = &imagingModeRequest{Mode: m.Mode, Level: m.Level}
+	}
+	if m := settings.WideDynamicRange; m != nil {
+		request.ImagingSettings.WideDynamicRange = &imagingModeRequest{Mode: m.Mode, Level: m.Level}
+	}
+	if e := settings.Exposure; e != nil {
+		exposure := exposureRequest(*e)
+		request.ImagingSettings.Exposure = &exposure
+	}
+	if f := settings.Focus; f != nil {
+		focus := focusRequest(*f)
+		request.ImagingSettings.Focus = &focus
+	}
+	if wb := settings.WhiteBalance; wb != nil {
+		whiteBalance := whiteBalanceRequest(*wb)
+		request.ImagingSettings.WhiteBalance = &whiteBalance
+	}
+
+	if err := c.Client.Call(c.Services.Imaging, imagingNamespace+"/SetImagingSettings", request, new(setImagingSettingsResponse)); err != nil {
+		return fmt.Errorf("failed to set imaging settings of video source %s: %w", settings.VideoSourceToken, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package camera
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"onvif_manager/pkg/models"
+)
+
+// LabelSet lists the cameras that carry a group or tag
+type LabelSet struct {
+	Name      string   `json:"name"`
+	CameraIDs []string `json:"cameraIds"`
+}
+
+// NormalizeLabel checks a group or tag name and returns it in lower case.
+// Names may not contain spaces or the characters used by selector expressions.
+func NormalizeLabel(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if strings.ContainsAny(name, " \t,=!|:") {
+		return "", fmt.Errorf("invalid name %q: spaces and the characters , = ! | : are not allowed", name)
+	}
+	return name, nil
+}
+
+// NormalizeLabels normalizes a list of group or tag names and removes duplicates
+func NormalizeLabels(names []string) ([]string, error) {
+	normalized := []string{}
+	for _, name := range names {
+		label, err := NormalizeLabel(name)
+		if err != nil {
+			return nil, err
+		}
+		if !containsLabel(normalized, label) {
+			normalized = append(normalized, label)
+		}
+	}
+	return normalized, nil
+}
+
+// AddLabels returns labels with names appended, skipping names already present
+func AddLabels(labels, names []string) []string {
+	updated := append([]string{}, labels...)
+	for _, name := range names {
+		if !containsLabel(updated, name) {
+			updated = append(updated, name)
+		}
+	}
+	return updated
+}
+
+// RemoveLabels returns labels without names
+func RemoveLabels(labels, names []string) []string {
+	updated := []string{}
+	for _, label := range labels {
+		if !containsLabel(names, label) {
+			updated = append(updated, label)
+		}
+	}
+	return updated
+}
+
+// containsLabel reports whether labels contains name, ignoring case
+func containsLabel(labels []string, name string) bool {
+	for _, label := range labels {
+		if strings.EqualFold(label, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateLabels changes the groups and tags of the given cameras and saves the inventory once.
+// update is called with a copy of each camera; only its Groups and Tags are kept.
+// No camera is changed when one of the IDs is not in the inventory.
+func (r *Registry) UpdateLabels(ids []string, update func(cam *models.Camera)) error {
+	return r.update(func(cameras []models.Camera) ([]models.Camera, error) {
+		index := make(map[string]int, len(cameras))
+		for i, cam := range cameras {
+			index[cam.ID] = i
+		}
+		for _, id := range ids {
+			if _, ok := index[id]; !ok {
+				return nil, notFound(id)
+			}
+		}
+
+		for _, id := range ids {
+			cam := cameras[index[id]]
+			update(&cam)
+			cameras[index[id]].Groups = nonEmptyLabels(cam.Groups)
+			cameras[index[id]].Tags = nonEmptyLabels(cam.Tags)
+		}
+		return cameras, nil
+	})
+}
+
+// nonEmptyLabels returns nil for an empty list so the inventory omits it
+func nonEmptyLabels(labels []string) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// Groups returns every group in use with its cameras, sorted by name
+func (r *Registry) Groups() []LabelSet {
+	return r.labelSets(func(cam models.Camera) []string { return cam.Groups })
+}
+
+// Tags returns every tag in use with its cameras, sorted by name
+func (r *Registry) Tags() []LabelSet {
+	return r.labelSets(func(cam models.Camera) []string { return cam.Tags })
+}
+
+// labelSets collects the cameras of every label returned by labels
+func (r *Registry) labelSets(labels func(cam models.Camera) []string) []LabelSet {
+	byName := make(map[string]*LabelSet)
+	var names []string
+	for _, cam := range r.Cameras() {
+		for _, label := range labels(cam) {
+			set, ok := byName[label]
+			if !ok {
+				set = &LabelSet{Name: label, CameraIDs: []string{}}
+				byName[label] = set
+				names = append(names, label)
+			}
+			set.CameraIDs = append(set.CameraIDs, cam.ID)
+		}
+	}
+
+	sort.Strings(names)
+	sets := make([]LabelSet, 0, len(names))
+	for _, name := range names {
+		sets = append(sets, *byName[name])
+	}
+	return sets
+}
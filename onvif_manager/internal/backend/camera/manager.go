@@ -1,128 +1,99 @@
 package camera
 
 import (
-	"fmt"
 	"onvif_manager/pkg/models"
-	"strconv"
-	"strings"
 )
 
-var connectedCameras map[string]*CameraClient
-var inMemoryCameras []models.Camera
+// defaultRegistry is the registry shared by the API handlers and the CLI
+var defaultRegistry = NewRegistry(&MemoryStore{})
 
-func init() {
-	connectedCameras = make(map[string]*CameraClient)
-	inMemoryCameras = []models.Camera{}
+// DefaultRegistry returns the registry used by the package-level functions
+func DefaultRegistry() *Registry {
+	return defaultRegistry
 }
 
-// InitializeAllCameras connects to all provided cameras and stores their clients.
-// It attempts to initialize all cameras and returns a single error containing details
-// of all failures, or nil if all cameras were successfully initialized.
-func InitializeAllCameras(cameras []models.Camera) error {
-	var initializationErrors []string
-
-	for _, cam := range cameras {
-		client, err := NewCameraClient(cam)
-		if err != nil {
-			initializationErrors = append(initializationErrors, fmt.Sprintf("failed to initialize camera %s: %v", cam.ID, err))
-		} else {
-			connectedCameras[cam.ID] = client
-		}
+// UseStore switches the inventory to the given store and loads the cameras saved in it.
+// Cameras are connected when first used. It should be called once at startup.
+func UseStore(store Store) error {
+	registry := NewRegistry(store)
+	if err := registry.Load(); err != nil {
+		return err
 	}
-
-	if len(initializationErrors) > 0 {
-		return fmt.Errorf("failed to initialize some cameras:\n%s", strings.Join(initializationErrors, "\n"))
-	}
-
+	defaultRegistry = registry
 	return nil
 }
 
-// GetCameraClient returns a connected camera client by its ID.
+// GetCameraClient returns the client of a camera by its ID, connecting it on first use.
 func GetCameraClient(id string) (*CameraClient, error) {
-	client, ok := connectedCameras[id]
-	if !ok {
-		return nil, fmt.Errorf("camera with ID %s not found", id)
-	}
-	return client, nil
+	return defaultRegistry.Client(id)
 }
 
-// GetAllCameras returns the list of all cameras from in-memory storage
+// GetAllCameras returns a copy of the list of all cameras in the inventory
 func GetAllCameras() []models.Camera {
-	return inMemoryCameras
+	return defaultRegistry.Cameras()
 }
 
-// AddNewCamera adds a new camera to the in-memory storage and assigns it an ID
-// that is one greater than the largest existing ID.
+// FindCameraByIP returns the inventoried camera with the given IP address
+func FindCameraByIP(ip string) (models.Camera, bool) {
+	return defaultRegistry.FindByIP(ip)
+}
+
+// AddNewCamera adds a new camera to the inventory and assigns it an ID
+// that is one greater than the largest existing ID. deviceURL is the device
+// service address the camera advertised, or empty for the default.
 // Returns the new camera ID and any error encountered.
-func AddNewCamera(ip string, port int, url string, username string, password string) (string, error) {
-	// Check if a camera with the same IP already exists
-	for _, cam := range inMemoryCameras {
-		if cam.IP == ip {
-			return "", fmt.Errorf("camera with IP address %s already exists (ID: %s)", ip, cam.ID)
-		}
-	}
+func AddNewCamera(ip string, port int, url string, deviceURL string, username string, password string) (string, error) {
+	return defaultRegistry.Add(ip, port, url, deviceURL, username, password)
+}
 
-	// Find the highest ID
-	highestID := 0
-	for _, cam := range inMemoryCameras {
-		// Try to convert the ID to an integer
-		if camID, err := strconv.Atoi(cam.ID); err == nil {
-			if camID > highestID {
-				highestID = camID
-			}
-		}
-	}
+// RemoveCamera removes a camera from the inventory by its ID.
+// It also removes the camera client from the connected cameras if it exists.
+// Returns any error encountered.
+func RemoveCamera(id string) error {
+	return defaultRegistry.Remove(id)
+}
 
-	// Create a new ID by incrementing the highest ID
-	newID := strconv.Itoa(highestID + 1)
-
-	// Create a new camera
-	newCamera := models.Camera{
-		ID:       newID,
-		IP:       ip,
-		Port:     port,
-		URL:      url,
-		Username: username,
-		Password: password,
-	} // Add the new camera to the in-memory list
-	inMemoryCameras = append(inMemoryCameras, newCamera)
-	// Initialize the camera client and add it to connectedCameras
-	client, err := NewCameraClient(newCamera)
-	if err != nil {
-		// Log the error but don't fail - we still want to add the camera to the list
-		fmt.Printf("Warning: Failed to initialize camera client for %s: %v\n", newID, err)
-	} else {
-		// Add to connected cameras
-		connectedCameras[newID] = client
-	}
+// LockCamera acquires the exclusive operation lock of a camera and returns
+// the function that releases it.
+func LockCamera(id string) func() {
+	return defaultRegistry.Lock(id)
+}
 
-	// Return the new camera ID
-	return newID, nil
+// NewOperationLocks returns an empty set of camera operation locks for an operation
+// that works on cameras in several phases
+func NewOperationLocks() *OperationLocks {
+	return defaultRegistry.OperationLocks()
 }
 
-// RemoveCamera removes a camera from the in-memory storage by its ID.
-// It also removes the camera client from the connected cameras map if it exists.
-// Returns any error encountered.
-func RemoveCamera(id string) error {
-	// Find the camera and remove it
-	found := false
-	updatedCameras := make([]models.Camera, 0, len(inMemoryCameras))
-	for _, cam := range inMemoryCameras {
-		if cam.ID != id {
-			updatedCameras = append(updatedCameras, cam)
-		} else {
-			found = true
-			// Remove the camera from the connected cameras map if it exists
-			delete(connectedCameras, id)
-		}
-	}
+// UpdateCameraLabels changes the groups and tags of the given cameras and saves the inventory
+func UpdateCameraLabels(ids []string, update func(cam *models.Camera)) error {
+	return defaultRegistry.UpdateLabels(ids, update)
+}
 
-	if !found {
-		return fmt.Errorf("camera with ID %s not found", id)
-	}
+// GetGroups returns every group in use with its cameras
+func GetGroups() []LabelSet {
+	return defaultRegistry.Groups()
+}
 
-	// Update the in-memory list
-	inMemoryCameras = updatedCameras
+// GetTags returns every tag in use with its cameras
+func GetTags() []LabelSet {
+	return defaultRegistry.Tags()
+}
 
-	return nil
+// SelectCameras returns the inventoried cameras matching the selector
+func SelectCameras(selector Selector) []models.Camera {
+	return defaultRegistry.Select(selector)
+}
+
+// SelectCameraIDs parses a selector expression and returns the IDs of the matching cameras
+func SelectCameraIDs(expr string) ([]string, error) {
+	selector, err := ParseSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, cam := range SelectCameras(selector) {
+		ids = append(ids, cam.ID)
+	}
+	return ids, nil
 }
@@ -0,0 +1,349 @@
+package camera
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"onvif_manager/pkg/models"
+)
+
+// The media2 package declares the attributes of the encoder configuration in the
+// Media2 namespace and cannot decode attribute lists such as FrameRatesSupported,
+// so the Media2 messages used here are defined locally. Responses are matched by
+// local name only.
+
+type media2Resolution struct {
+	Width  int `xml:"Width"`
+	Height int `xml:"Height"`
+}
+
+type media2Range struct {
+	Min float64 `xml:"Min"`
+	Max float64 `xml:"Max"`
+}
+
+type media2MulticastResponse struct {
+	Address struct {
+		Type        string `xml:"Type"`
+		IPv4Address string `xml:"IPv4Address"`
+		IPv6Address string `xml:"IPv6Address"`
+	} `xml:"Address"`
+	Port      int  `xml:"Port"`
+	TTL       int  `xml:"TTL"`
+	AutoStart bool `xml:"AutoStart"`
+}
+
+type media2EncoderConfigResponse struct {
+	Token       string           `xml:"token,attr"`
+	GovLength   int              `xml:"GovLength,attr"`
+	Profile     string           `xml:"Profile,attr"`
+	Name        string           `xml:"Name"`
+	UseCount    int              `xml:"UseCount"`
+	Encoding    string           `xml:"Encoding"`
+	Resolution  media2Resolution `xml:"Resolution"`
+	RateControl *struct {
+		ConstantBitRate bool    `xml:"ConstantBitRate,attr"`
+		FrameRateLimit  float64 `xml:"FrameRateLimit"`
+		BitrateLimit    int     `xml:"BitrateLimit"`
+	} `xml:"RateControl"`
+	Multicast *media2MulticastResponse `xml:"Multicast"`
+	Quality   float64                  `xml:"Quality"`
+}
+
+type media2ProfileResponse struct {
+	Token          string `xml:"token,attr"`
+	Fixed          bool   `xml:"fixed,attr"`
+	Name           string `xml:"Name"`
+	Configurations struct {
+		VideoEncoder *media2EncoderConfigResponse `xml:"VideoEncoder"`
+	} `xml:"Configurations"`
+}
+
+type media2GetProfilesResponse struct {
+	XMLName  xml.Name                `xml:"GetProfilesResponse"`
+	Profiles []media2ProfileResponse `xml:"Profiles"`
+}
+
+type media2GetEncoderConfigsResponse struct {
+	XMLName        xml.Name                      `xml:"GetVideoEncoderConfigurationsResponse"`
+	Configurations []media2EncoderConfigResponse `xml:"Configurations"`
+}
+
+type media2EncoderOptionsResponse struct {
+	Encoding                 string             `xml:"Encoding"`
+	QualityRange             media2Range        `xml:"QualityRange"`
+	ResolutionsAvailable     []media2Resolution `xml:"ResolutionsAvailable"`
+	BitrateRange             media2Range        `xml:"BitrateRange"`
+	GovLengthRange           string             `xml:"GovLengthRange,attr"`
+	FrameRatesSupported      string             `xml:"FrameRatesSupported,attr"`
+	ProfilesSupported        string             `xml:"ProfilesSupported,attr"`
+	ConstantBitRateSupported bool               `xml:"ConstantBitRateSupported,attr"`
+}
+
+type media2GetEncoderOptionsResponse struct {
+	XMLName xml.Name                       `xml:"GetVideoEncoderConfigurationOptionsResponse"`
+	Options []media2EncoderOptionsResponse `xml:"Options"`
+}
+
+type media2GetStreamURIResponse struct {
+	XMLName xml.Name `xml:"GetStreamUriResponse"`
+	URI     string   `xml:"Uri"`
+}
+
+type media2GetProfilesRequest struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver20/media/wsdl GetProfiles"`
+	Type    []string `xml:"http://www.onvif.org/ver20/media/wsdl Type"`
+}
+
+type media2ConfigurationRequest struct {
+	XMLName            xml.Name
+	ConfigurationToken string `xml:"http://www.onvif.org/ver20/media/wsdl ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"http://www.onvif.org/ver20/media/wsdl ProfileToken,omitempty"`
+}
+
+type media2GetStreamURIRequest struct {
+	XMLName      xml.Name `xml:"http://www.onvif.org/ver20/media/wsdl GetStreamUri"`
+	Protocol     string   `xml:"http://www.onvif.org/ver20/media/wsdl Protocol"`
+	ProfileToken string   `xml:"http://www.onvif.org/ver20/media/wsdl ProfileToken"`
+}
+
+type media2MulticastRequest struct {
+	Address struct {
+		Type        string `xml:"http://www.onvif.org/ver10/schema Type"`
+		IPv4Address string `xml:"http://www.onvif.org/ver10/schema IPv4Address,omitempty"`
+		IPv6Address string `xml:"http://www.onvif.org/ver10/schema IPv6Address,omitempty"`
+	} `xml:"http://www.onvif.org/ver10/schema Address"`
+	Port      int  `xml:"http://www.onvif.org/ver10/schema Port"`
+	TTL       int  `xml:"http://www.onvif.org/ver10/schema TTL"`
+	AutoStart bool `xml:"http://www.onvif.org/ver10/schema AutoStart"`
+}
+
+type media2RateControlRequest struct {
+	ConstantBitRate *bool   `xml:"ConstantBitRate,attr,omitempty"` // Left out when the mode is unknown
+	FrameRateLimit  float64 `xml:"http://www.onvif.org/ver10/schema FrameRateLimit"`
+	BitrateLimit    int     `xml:"http://www.onvif.org/ver10/schema BitrateLimit"`
+}
+
+type media2EncoderConfigRequest struct {
+	Token      string `xml:"token,attr"`
+	GovLength  int    `xml:"GovLength,attr,omitempty"`
+	Profile    string `xml:"Profile,attr,omitempty"`
+	Name       string `xml:"http://www.onvif.org/ver10/schema Name"`
+	UseCount   int    `xml:"http://www.onvif.org/ver10/schema UseCount"`
+	Encoding   string `xml:"http://www.onvif.org/ver10/schema Encoding"`
+	Resolution struct {
+		Width  int `xml:"http://www.onvif.org/ver10/schema Width"`
+		Height int `xml:"http://www.onvif.org/ver10/schema Height"`
+	} `xml:"http://www.onvif.org/ver10/schema Resolution"`
+	RateControl *media2RateControlRequest `xml:"http://www.onvif.org/ver10/schema RateControl,omitempty"`
+	Multicast   *media2MulticastRequest   `xml:"http://www.onvif.org/ver10/schema Multicast,omitempty"`
+	Quality     float64                   `xml:"http://www.onvif.org/ver10/schema Quality"`
+}
+
+type media2SetEncoderConfigRequest struct {
+	XMLName       xml.Name                   `xml:"http://www.onvif.org/ver20/media/wsdl SetVideoEncoderConfiguration"`
+	Configuration media2EncoderConfigRequest `xml:"http://www.onvif.org/ver20/media/wsdl Configuration"`
+}
+
+type media2SetEncoderConfigResponse struct {
+	XMLName xml.Name `xml:"SetVideoEncoderConfigurationResponse"`
+}
+
+// UsesMedia2 reports whether profiles and encoder configurations of the camera
+// are handled through the Media2 service, which is the case when the camera advertises it
+func (c *CameraClient) UsesMedia2() bool {
+	return c.Services.Media2 != ""
+}
+
+// callMedia2 sends a Media2 request to the camera
+func (c *CameraClient) callMedia2(operation string, request, response interface{}) error {
+	return c.Client.Call(c.Services.Media2, media2Namespace+"/"+operation, request, response)
+}
+
+// newMedia2ConfigurationRequest builds one of the Media2 requests that select configurations by token
+func newMedia2ConfigurationRequest(operation, profileToken, configToken string) *media2ConfigurationRequest {
+	return &media2ConfigurationRequest{
+		XMLName:            xml.Name{Space: media2Namespace, Local: operation},
+		ConfigurationToken: configToken,
+		ProfileToken:       profileToken,
+	}
+}
+
+// getMedia2Profiles reads the media profiles with their video encoder configuration through Media2
+func getMedia2Profiles(client *CameraClient) ([]Profile, error) {
+	resp := new(media2GetProfilesResponse)
+	if err := client.callMedia2("GetProfiles", &media2GetProfilesRequest{Type: []string{"VideoEncoder"}}, resp); err != nil {
+		return nil, fmt.Errorf("failed to get Media2 profiles: %w", err)
+	}
+
+	profiles := make([]Profile, 0, len(resp.Profiles))
+	for _, p := range resp.Profiles {
+		profile := Profile{
+			Token: p.Token,
+			Name:  p.Name,
+			Fixed: p.Fixed,
+		}
+		if cfg := p.Configurations.VideoEncoder; cfg != nil && cfg.Token != "" {
+			encoder := cfg.encoderConfig()
+			profile.ConfigToken = cfg.Token
+			profile.ConfigName = cfg.Name
+			profile.Encoder = &encoder
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// getMedia2EncoderConfig reads one video encoder configuration through Media2
+func getMedia2EncoderConfig(client *CameraClient, configToken string) (*media2EncoderConfigResponse, error) {
+	resp := new(media2GetEncoderConfigsResponse)
+	request := newMedia2ConfigurationRequest("GetVideoEncoderConfigurations", "", configToken)
+	if err := client.callMedia2("GetVideoEncoderConfigurations", request, resp); err != nil {
+		return nil, fmt.Errorf("failed to get Media2 encoder config: %w", err)
+	}
+
+	for i := range resp.Configurations {
+		if resp.Configurations[i].Token == configToken {
+			return &resp.Configurations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("encoder configuration %s not found", configToken)
+}
+
+// getMedia2EncoderOptions reads the encoder options of a configuration through Media2,
+// which reports one set of options per supported encoding
+func getMedia2EncoderOptions(client *CameraClient, profileToken, configToken string) (*models.EncoderOptions, error) {
+	resp := new(media2GetEncoderOptionsResponse)
+	request := newMedia2ConfigurationRequest("GetVideoEncoderConfigurationOptions", profileToken, configToken)
+	if err := client.callMedia2("GetVideoEncoderConfigurationOptions", request, resp); err != nil {
+		return nil, fmt.Errorf("failed to get Media2 encoder options: %w", err)
+	}
+
+	options := &models.EncoderOptions{Service: MediaServiceMedia2, Encodings: make(map[string]*models.EncodingOptions)}
+	for _, o := range resp.Options {
+		encoding := &models.EncodingOptions{
+			Encoding:        NormalizeEncoding(o.Encoding),
+			FrameRates:      parseFrameRates(o.FrameRatesSupported),
+			BitrateRange:    newRange(o.BitrateRange.Min, o.BitrateRange.Max),
+			QualityRange:    newRange(o.QualityRange.Min, o.QualityRange.Max),
+			Profiles:        strings.Fields(o.ProfilesSupported),
+			ConstantBitRate: o.ConstantBitRateSupported,
+		}
+		for _, res := range o.ResolutionsAvailable {
+			encoding.Resolutions = append(encoding.Resolutions, models.Resolution{Width: res.Width, Height: res.Height})
+		}
+		// GovLengthRange holds exactly two values, the lower and upper bound
+		if bounds := strings.Fields(o.GovLengthRange); len(bounds) == 2 {
+			low, lowErr := strconv.ParseFloat(bounds[0], 64)
+			high, highErr := strconv.ParseFloat(bounds[1], 64)
+			if lowErr == nil && highErr == nil {
+				encoding.GOPRange = newRange(low, high)
+			}
+		}
+		options.Encodings[encoding.Encoding] = encoding
+	}
+	return options, nil
+}
+
+// setMedia2EncoderConfig writes a video encoder configuration through Media2.
+// Attributes and elements that are not changed keep the values read from the camera.
+func setMedia2EncoderConfig(client *CameraClient, current *media2EncoderConfigResponse, config models.EncoderConfig) error {
+	request := &media2SetEncoderConfigRequest{Configuration: media2EncoderConfigRequest{
+		Token:     current.Token,
+		GovLength: current.GovLength,
+		Profile:   current.Profile,
+		Name:      current.Name,
+		UseCount:  current.UseCount,
+		Encoding:  config.Encoding,
+		Quality:   float64(config.Quality),
+		RateControl: &media2RateControlRequest{
+			FrameRateLimit: float64(config.FPS),
+			BitrateLimit:   config.Bitrate,
+		},
+	}}
+	cfg := &request.Configuration
+	cfg.Resolution.Width = config.Resolution.Width
+	cfg.Resolution.Height = config.Resolution.Height
+
+	if config.RateControl != "" {
+		constant := config.RateControl == RateControlCBR
+		cfg.RateControl.ConstantBitRate = &constant
+	} else if current.RateControl != nil {
+		cfg.RateControl.ConstantBitRate = &current.RateControl.ConstantBitRate
+	}
+	if config.GOP > 0 {
+		cfg.GovLength = config.GOP
+	}
+	// Encoder profiles such as Main or High belong to one encoding only
+	if config.EncoderProfile != "" {
+		cfg.Profile = config.EncoderProfile
+	} else if NormalizeEncoding(current.Encoding) != config.Encoding {
+		cfg.Profile = ""
+	}
+	if multicast := current.Multicast; multicast != nil && multicast.Address.Type != "" {
+		cfg.Multicast = &media2MulticastRequest{Port: multicast.Port, TTL: multicast.TTL, AutoStart: multicast.AutoStart}
+		cfg.Multicast.Address.Type = multicast.Address.Type
+		cfg.Multicast.Address.IPv4Address = multicast.Address.IPv4Address
+		cfg.Multicast.Address.IPv6Address = multicast.Address.IPv6Address
+	}
+
+	if err := client.callMedia2("SetVideoEncoderConfiguration", request, new(media2SetEncoderConfigResponse)); err != nil {
+		return fmt.Errorf("failed to set Media2 video encoder config: %w", err)
+	}
+	return nil
+}
+
+// getMedia2StreamURI retrieves the RTSP stream URI of a profile through Media2
+func getMedia2StreamURI(client *CameraClient, profileToken string) (string, error) {
+	resp := new(media2GetStreamURIResponse)
+	request := &media2GetStreamURIRequest{Protocol: "RTSP", ProfileToken: profileToken}
+	if err := client.callMedia2("GetStreamUri", request, resp); err != nil {
+		return "", fmt.Errorf("failed to get stream URI for profile %s: %w", profileToken, err)
+	}
+	if resp.URI == "" {
+		return "", fmt.Errorf("received empty or invalid stream URI response for profile %s", profileToken)
+	}
+	return resp.URI, nil
+}
+
+// encoderConfig converts a Media2 encoder configuration to the common model
+func (cfg *media2EncoderConfigResponse) encoderConfig() models.EncoderConfig {
+	config := models.EncoderConfig{
+		Resolution:     models.Resolution{Width: cfg.Resolution.Width, Height: cfg.Resolution.Height},
+		Quality:        int(cfg.Quality),
+		Encoding:       NormalizeEncoding(cfg.Encoding),
+		GOP:            cfg.GovLength,
+		EncoderProfile: cfg.Profile,
+	}
+	if cfg.RateControl != nil {
+		config.FPS = int(cfg.RateControl.FrameRateLimit + 0.5)
+		config.Bitrate = cfg.RateControl.BitrateLimit
+		config.RateControl = RateControlVBR
+		if cfg.RateControl.ConstantBitRate {
+			config.RateControl = RateControlCBR
+		}
+	}
+	return config
+}
+
+// parseFrameRates turns a FrameRatesSupported list such as "30 25 15 7.5" into whole frame rates, lowest first
+func parseFrameRates(list string) []int {
+	seen := make(map[int]bool)
+	var rates []int
+	for _, field := range strings.Fields(list) {
+		rate, err := strconv.ParseFloat(field, 64)
+		if err != nil || rate < 1 {
+			continue
+		}
+		fps := int(rate + 0.5)
+		if !seen[fps] {
+			seen[fps] = true
+			rates = append(rates, fps)
+		}
+	}
+	sort.Ints(rates)
+	return rates
+}
@@ -0,0 +1,144 @@
+package camera
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"onvif_manager/pkg/models"
+)
+
+func TestNormalizeEncoding(t *testing.T) {
+	tests := []struct {
+		encoding string
+		want     string
+	}{
+		{encoding: "h264", want: EncodingH264},
+		{encoding: "H.264", want: EncodingH264},
+		{encoding: "avc", want: EncodingH264},
+		{encoding: " H265 ", want: EncodingH265},
+		{encoding: "H.265", want: EncodingH265},
+		{encoding: "hevc", want: EncodingH265},
+		{encoding: "MJPEG", want: EncodingJPEG},
+		{encoding: "jpeg", want: EncodingJPEG},
+		{encoding: "mpeg4", want: "MPEG4"},
+		{encoding: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeEncoding(tt.encoding); got != tt.want {
+			t.Errorf("NormalizeEncoding(%q) = %q, want %q", tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestTargetEncoding(t *testing.T) {
+	tests := []struct {
+		requested string
+		current   string
+		want      string
+	}{
+		{requested: "hevc", current: "H264", want: EncodingH265},
+		{requested: "", current: "h264", want: EncodingH264},
+		{requested: "", current: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := TargetEncoding(tt.requested, tt.current); got != tt.want {
+			t.Errorf("TargetEncoding(%q, %q) = %q, want %q", tt.requested, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestParseFrameRates(t *testing.T) {
+	tests := []struct {
+		list string
+		want []int
+	}{
+		{list: "30 25 15 7.5", want: []int{8, 15, 25, 30}},
+		{list: "29.97 30 0.5 fast", want: []int{30}},
+		{list: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseFrameRates(tt.list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFrameRates(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestMedia2EncoderConfig(t *testing.T) {
+	const response = `<GetVideoEncoderConfigurationsResponse xmlns="http://www.onvif.org/ver20/media/wsdl" xmlns:tt="http://www.onvif.org/ver10/schema">
+  <Configurations token="enc_main" GovLength="50" Profile="Main">
+    <tt:Name>Main stream</tt:Name>
+    <tt:UseCount>1</tt:UseCount>
+    <tt:Encoding>H265</tt:Encoding>
+    <tt:Resolution><tt:Width>2560</tt:Width><tt:Height>1440</tt:Height></tt:Resolution>
+    <tt:RateControl ConstantBitRate="true">
+      <tt:FrameRateLimit>24.98</tt:FrameRateLimit>
+      <tt:BitrateLimit>6144</tt:BitrateLimit>
+    </tt:RateControl>
+    <tt:Quality>4</tt:Quality>
+  </Configurations>
+</GetVideoEncoderConfigurationsResponse>`
+
+	var resp media2GetEncoderConfigsResponse
+	if err := xml.Unmarshal([]byte(response), &resp); err != nil {
+		t.Fatalf("xml.Unmarshal() error = %v", err)
+	}
+	if len(resp.Configurations) != 1 || resp.Configurations[0].Token != "enc_main" {
+		t.Fatalf("decoded %+v, want configuration enc_main", resp.Configurations)
+	}
+
+	want := models.EncoderConfig{
+		Resolution:     models.Resolution{Width: 2560, Height: 1440},
+		Quality:        4,
+		FPS:            25,
+		Bitrate:        6144,
+		Encoding:       EncodingH265,
+		GOP:            50,
+		EncoderProfile: "Main",
+		RateControl:    RateControlCBR,
+	}
+	if got := resp.Configurations[0].encoderConfig(); got != want {
+		t.Errorf("encoderConfig() = %+v, want %+v", got, want)
+	}
+
+	// Without rate control the frame rate, bitrate and mode are unknown
+	withoutRateControl := media2EncoderConfigResponse{Encoding: "JPEG", Quality: 80}
+	if got := withoutRateControl.encoderConfig(); got.FPS != 0 || got.Bitrate != 0 || got.RateControl != "" || got.Encoding != EncodingJPEG {
+		t.Errorf("encoderConfig() without rate control = %+v", got)
+	}
+}
+
+func TestMedia2EncoderOptionsResponse(t *testing.T) {
+	const response = `<GetVideoEncoderConfigurationOptionsResponse xmlns="http://www.onvif.org/ver20/media/wsdl" xmlns:tt="http://www.onvif.org/ver10/schema">
+  <Options GovLengthRange="1 150" FrameRatesSupported="25 12.5 6" ProfilesSupported="Main Main10" ConstantBitRateSupported="true">
+    <tt:Encoding>H265</tt:Encoding>
+    <tt:QualityRange><tt:Min>0</tt:Min><tt:Max>10</tt:Max></tt:QualityRange>
+    <tt:ResolutionsAvailable><tt:Width>1920</tt:Width><tt:Height>1080</tt:Height></tt:ResolutionsAvailable>
+    <tt:ResolutionsAvailable><tt:Width>1280</tt:Width><tt:Height>720</tt:Height></tt:ResolutionsAvailable>
+    <tt:BitrateRange><tt:Min>64</tt:Min><tt:Max>16384</tt:Max></tt:BitrateRange>
+  </Options>
+</GetVideoEncoderConfigurationOptionsResponse>`
+
+	var resp media2GetEncoderOptionsResponse
+	if err := xml.Unmarshal([]byte(response), &resp); err != nil {
+		t.Fatalf("xml.Unmarshal() error = %v", err)
+	}
+	if len(resp.Options) != 1 {
+		t.Fatalf("decoded %d options, want 1", len(resp.Options))
+	}
+
+	// The attribute lists the media2 package cannot decode are read as text
+	options := resp.Options[0]
+	if options.GovLengthRange != "1 150" || options.ProfilesSupported != "Main Main10" || !options.ConstantBitRateSupported {
+		t.Errorf("decoded attributes %+v", options)
+	}
+	if got := parseFrameRates(options.FrameRatesSupported); !reflect.DeepEqual(got, []int{6, 13, 25}) {
+		t.Errorf("frame rates = %v, want [6 13 25]", got)
+	}
+	if len(options.ResolutionsAvailable) != 2 || options.BitrateRange.Max != 16384 || options.QualityRange.Max != 10 {
+		t.Errorf("decoded options %+v", options)
+	}
+}
@@ -0,0 +1,289 @@
+package camera
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// VideoSourceConfig is a video source configuration: the sensor input and the area of it that is captured
+type VideoSourceConfig struct {
+	Token       string    `json:"token"`
+	Name        string    `json:"name"`
+	SourceToken string    `json:"sourceToken"` // Physical video input
+	Bounds      Rectangle `json:"bounds"`
+}
+
+// Rectangle is an area of a video source in pixels
+type Rectangle struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// AudioEncoderConfig is an audio encoder configuration
+type AudioEncoderConfig struct {
+	Token          string           `json:"token"`
+	Name           string           `json:"name"`
+	Encoding       string           `json:"encoding"`   // G711, G726 or AAC
+	Bitrate        int              `json:"bitrate"`    // kbps
+	SampleRate     int              `json:"sampleRate"` // kHz
+	SessionTimeout string           `json:"sessionTimeout,omitempty"`
+	Multicast      *MulticastConfig `json:"multicast,omitempty"`
+}
+
+// MulticastConfig is the multicast streaming setting of a configuration
+type MulticastConfig struct {
+	Address   string `json:"address"`
+	Port      int    `json:"port"`
+	TTL       int    `json:"ttl"`
+	AutoStart bool   `json:"autoStart"`
+}
+
+// The media package declares the elements of these configurations in the Media
+// namespace instead of the schema namespace, so the messages are defined here.
+// Responses are matched by local name only.
+
+type rectangleResponse struct {
+	X      int `xml:"x,attr"`
+	Y      int `xml:"y,attr"`
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
+}
+
+type videoSourceConfigResponse struct {
+	Token       string            `xml:"token,attr"`
+	Name        string            `xml:"Name"`
+	UseCount    int               `xml:"UseCount"`
+	SourceToken string            `xml:"SourceToken"`
+	Bounds      rectangleResponse `xml:"Bounds"`
+}
+
+type getVideoSourceConfigsResponse struct {
+	XMLName        xml.Name                    `xml:"GetVideoSourceConfigurationsResponse"`
+	Configurations []videoSourceConfigResponse `xml:"Configurations"`
+}
+
+type audioEncoderConfigResponse struct {
+	Token          string                   `xml:"token,attr"`
+	Name           string                   `xml:"Name"`
+	UseCount       int                      `xml:"UseCount"`
+	Encoding       string                   `xml:"Encoding"`
+	Bitrate        int                      `xml:"Bitrate"`
+	SampleRate     int                      `xml:"SampleRate"`
+	Multicast      *media2MulticastResponse `xml:"Multicast"`
+	SessionTimeout string                   `xml:"SessionTimeout"`
+}
+
+type getAudioEncoderConfigsResponse struct {
+	XMLName        xml.Name                     `xml:"GetAudioEncoderConfigurationsResponse"`
+	Configurations []audioEncoderConfigResponse `xml:"Configurations"`
+}
+
+type mediaRequest struct {
+	XMLName xml.Name
+}
+
+type rectangleRequest struct {
+	X      int `xml:"x,attr"`
+	Y      int `xml:"y,attr"`
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
+}
+
+type videoSourceConfigRequest struct {
+	Token       string           `xml:"token,attr"`
+	Name        string           `xml:"http://www.onvif.org/ver10/schema Name"`
+	UseCount    int              `xml:"http://www.onvif.org/ver10/schema UseCount"`
+	SourceToken string           `xml:"http://www.onvif.org/ver10/schema SourceToken"`
+	Bounds      rectangleRequest `xml:"http://www.onvif.org/ver10/schema Bounds"`
+}
+
+type setVideoSourceConfigRequest struct {
+	XMLName          xml.Name                 `xml:"http://www.onvif.org/ver10/media/wsdl SetVideoSourceConfiguration"`
+	Configuration    videoSourceConfigRequest `xml:"http://www.onvif.org/ver10/media/wsdl Configuration"`
+	ForcePersistence bool                     `xml:"http://www.onvif.org/ver10/media/wsdl ForcePersistence"`
+}
+
+type setVideoSourceConfigResponse struct {
+	XMLName xml.Name `xml:"SetVideoSourceConfigurationResponse"`
+}
+
+type audioEncoderConfigRequest struct {
+	Token          string                  `xml:"token,attr"`
+	Name           string                  `xml:"http://www.onvif.org/ver10/schema Name"`
+	UseCount       int                     `xml:"http://www.onvif.org/ver10/schema UseCount"`
+	Encoding       string                  `xml:"http://www.onvif.org/ver10/schema Encoding"`
+	Bitrate        int                     `xml:"http://www.onvif.org/ver10/schema Bitrate"`
+	SampleRate     int                     `xml:"http://www.onvif.org/ver10/schema SampleRate"`
+	Multicast      *media2MulticastRequest `xml:"http://www.onvif.org/ver10/schema Multicast"`
+	SessionTimeout string                  `xml:"http://www.onvif.org/ver10/schema SessionTimeout"`
+}
+
+type setAudioEncoderConfigRequest struct {
+	XMLName          xml.Name                  `xml:"http://www.onvif.org/ver10/media/wsdl SetAudioEncoderConfiguration"`
+	Configuration    audioEncoderConfigRequest `xml:"http://www.onvif.org/ver10/media/wsdl Configuration"`
+	ForcePersistence bool                      `xml:"http://www.onvif.org/ver10/media/wsdl ForcePersistence"`
+}
+
+type setAudioEncoderConfigResponse struct {
+	XMLName xml.Name `xml:"SetAudioEncoderConfigurationResponse"`
+}
+
+// callMedia sends a request to the Media service of the camera
+func (c *CameraClient) callMedia(operation string, request, response interface{}) error {
+	return c.Client.Call(c.Services.Media, mediaNamespace+"/"+operation, request, response)
+}
+
+// GetVideoSourceConfigs returns the video source configurations of the camera
+func (c *CameraClient) GetVideoSourceConfigs() ([]VideoSourceConfig, error) {
+	resp, err := c.getVideoSourceConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]VideoSourceConfig, 0, len(resp.Configurations))
+	for _, cfg := range resp.Configurations {
+		configs = append(configs, VideoSourceConfig{
+			Token:       cfg.Token,
+			Name:        cfg.Name,
+			SourceToken: cfg.SourceToken,
+			Bounds:      Rectangle{X: cfg.Bounds.X, Y: cfg.Bounds.Y, Width: cfg.Bounds.Width, Height: cfg.Bounds.Height},
+		})
+	}
+	return configs, nil
+}
+
+// SetVideoSourceConfig writes a video source configuration. The configuration must exist on the camera.
+func (c *CameraClient) SetVideoSourceConfig(config VideoSourceConfig) error {
+	resp, err := c.getVideoSourceConfigs()
+	if err != nil {
+		return err
+	}
+
+	for _, current := range resp.Configurations {
+		if current.Token != config.Token {
+			continue
+		}
+		request := &setVideoSourceConfigRequest{
+			Configuration: videoSourceConfigRequest{
+				Token:       config.Token,
+				Name:        config.Name,
+				UseCount:    current.UseCount,
+				SourceToken: config.SourceToken,
+				Bounds:      rectangleRequest(config.Bounds),
+			},
+			ForcePersistence: true,
+		}
+		if err := c.callMedia("SetVideoSourceConfiguration", request, new(setVideoSourceConfigResponse)); err != nil {
+			return fmt.Errorf("failed to set video source configuration %s: %w", config.Token, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("camera has no video source configuration %s", config.Token)
+}
+
+// GetAudioEncoderConfigs returns the audio encoder configurations of the camera
+func (c *CameraClient) GetAudioEncoderConfigs() ([]AudioEncoderConfig, error) {
+	resp, err := c.getAudioEncoderConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]AudioEncoderConfig, 0, len(resp.Configurations))
+	for _, cfg := range resp.Configurations {
+		config := AudioEncoderConfig{
+			Token:          cfg.Token,
+			Name:           cfg.Name,
+			Encoding:       cfg.Encoding,
+			Bitrate:        cfg.Bitrate,
+			SampleRate:     cfg.SampleRate,
+			SessionTimeout: cfg.SessionTimeout,
+		}
+		if m := cfg.Multicast; m != nil {
+			config.Multicast = &MulticastConfig{Port: m.Port, TTL: m.TTL, AutoStart: m.AutoStart}
+			config.Multicast.Address = m.Address.IPv4Address
+			if config.Multicast.Address == "" {
+				config.Multicast.Address = m.Address.IPv6Address
+			}
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// SetAudioEncoderConfig writes an audio encoder configuration. The configuration must exist on the camera.
+func (c *CameraClient) SetAudioEncoderConfig(config AudioEncoderConfig) error {
+	resp, err := c.getAudioEncoderConfigs()
+	if err != nil {
+		return err
+	}
+
+	for _, current := range resp.Configurations {
+		if current.Token != config.Token {
+			continue
+		}
+		request := &setAudioEncoderConfigRequest{
+			Configuration: audioEncoderConfigRequest{
+				Token:          config.Token,
+				Name:           config.Name,
+				UseCount:       current.UseCount,
+				Encoding:       config.Encoding,
+				Bitrate:        config.Bitrate,
+				SampleRate:     config.SampleRate,
+				Multicast:      newMulticastRequest(config.Multicast),
+				SessionTimeout: config.SessionTimeout,
+			},
+			ForcePersistence: true,
+		}
+		// Both elements are required; keep the camera's values when the backup has none
+		if request.Configuration.SessionTimeout == "" {
+			request.Configuration.SessionTimeout = current.SessionTimeout
+		}
+		if request.Configuration.Multicast == nil {
+			request.Configuration.Multicast = newMulticastRequest(&MulticastConfig{})
+		}
+		if err := c.callMedia("SetAudioEncoderConfiguration", request, new(setAudioEncoderConfigResponse)); err != nil {
+			return fmt.Errorf("failed to set audio encoder configuration %s: %w", config.Token, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("camera has no audio encoder configuration %s", config.Token)
+}
+
+// getVideoSourceConfigs reads the video source configurations through the Media service
+func (c *CameraClient) getVideoSourceConfigs() (*getVideoSourceConfigsResponse, error) {
+	resp := new(getVideoSourceConfigsResponse)
+	request := &mediaRequest{XMLName: xml.Name{Space: mediaNamespace, Local: "GetVideoSourceConfigurations"}}
+	if err := c.callMedia("GetVideoSourceConfigurations", request, resp); err != nil {
+		return nil, fmt.Errorf("failed to get video source configurations: %w", err)
+	}
+	return resp, nil
+}
+
+// getAudioEncoderConfigs reads the audio encoder configurations through the Media service
+func (c *CameraClient) getAudioEncoderConfigs() (*getAudioEncoderConfigsResponse, error) {
+	resp := new(getAudioEncoderConfigsResponse)
+	request := &mediaRequest{XMLName: xml.Name{Space: mediaNamespace, Local: "GetAudioEncoderConfigurations"}}
+	if err := c.callMedia("GetAudioEncoderConfigurations", request, resp); err != nil {
+		return nil, fmt.Errorf("failed to get audio encoder configurations: %w", err)
+	}
+	return resp, nil
+}
+
+// newMulticastRequest converts a multicast setting for a request; nil stays nil
+func newMulticastRequest(config *MulticastConfig) *media2MulticastRequest {
+	if config == nil {
+		return nil
+	}
+	request := &media2MulticastRequest{Port: config.Port, TTL: config.TTL, AutoStart: config.AutoStart}
+	host := newNetworkHost(config.Address)
+	request.Address.Type = host.Type
+	request.Address.IPv4Address = host.IPv4Address
+	request.Address.IPv6Address = host.IPv6Address
+	if config.Address == "" {
+		request.Address.Type = "IPv4"
+		request.Address.IPv4Address = "0.0.0.0"
+	}
+	return request
+}
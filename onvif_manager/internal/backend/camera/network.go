@@ -0,0 +1,258 @@
+package camera
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// NetworkSettings are the network, name resolution and time settings of a camera
+type NetworkSettings struct {
+	Hostname         string             `json:"hostname"`
+	HostnameFromDHCP bool               `json:"hostnameFromDhcp"`
+	DNSFromDHCP      bool               `json:"dnsFromDhcp"`
+	SearchDomains    []string           `json:"searchDomains"`
+	DNSServers       []string           `json:"dnsServers"` // Manually configured servers
+	Interfaces       []NetworkInterface `json:"interfaces"`
+	DefaultGateways  []string           `json:"defaultGateways"`
+	Protocols        []NetworkProtocol  `json:"protocols"`
+	Time             *TimeStatus        `json:"time,omitempty"`
+}
+
+// NetworkInterface is the IPv4 configuration of a network interface
+type NetworkInterface struct {
+	Token     string   `json:"token"`
+	Name      string   `json:"name"`
+	HwAddress string   `json:"hwAddress"`
+	Enabled   bool     `json:"enabled"`
+	DHCP      bool     `json:"dhcp"`
+	Addresses []string `json:"addresses"` // Manual addresses as address/prefix length
+}
+
+// NetworkProtocol is a protocol the camera serves, such as HTTP or RTSP, with its ports
+type NetworkProtocol struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Ports   []int  `json:"ports"`
+}
+
+// Responses are matched by local name only.
+
+type getHostnameResponse struct {
+	XMLName             xml.Name `xml:"GetHostnameResponse"`
+	HostnameInformation struct {
+		FromDHCP bool   `xml:"FromDHCP"`
+		Name     string `xml:"Name"`
+	} `xml:"HostnameInformation"`
+}
+
+type ipAddressResponse struct {
+	Type        string `xml:"Type"`
+	IPv4Address string `xml:"IPv4Address"`
+	IPv6Address string `xml:"IPv6Address"`
+}
+
+type getDNSResponse struct {
+	XMLName        xml.Name `xml:"GetDNSResponse"`
+	DNSInformation struct {
+		FromDHCP     bool                `xml:"FromDHCP"`
+		SearchDomain []string            `xml:"SearchDomain"`
+		DNSManual    []ipAddressResponse `xml:"DNSManual"`
+	} `xml:"DNSInformation"`
+}
+
+type getNetworkInterfacesResponse struct {
+	XMLName           xml.Name `xml:"GetNetworkInterfacesResponse"`
+	NetworkInterfaces []struct {
+		Token   string `xml:"token,attr"`
+		Enabled bool   `xml:"Enabled"`
+		Info    struct {
+			Name      string `xml:"Name"`
+			HwAddress string `xml:"HwAddress"`
+		} `xml:"Info"`
+		IPv4 struct {
+			Config struct {
+				Manual []struct {
+					Address      string `xml:"Address"`
+					PrefixLength int    `xml:"PrefixLength"`
+				} `xml:"Manual"`
+				DHCP bool `xml:"DHCP"`
+			} `xml:"Config"`
+		} `xml:"IPv4"`
+	} `xml:"NetworkInterfaces"`
+}
+
+type getNetworkDefaultGatewayResponse struct {
+	XMLName        xml.Name `xml:"GetNetworkDefaultGatewayResponse"`
+	NetworkGateway struct {
+		IPv4Address []string `xml:"IPv4Address"`
+	} `xml:"NetworkGateway"`
+}
+
+type getNetworkProtocolsResponse struct {
+	XMLName          xml.Name `xml:"GetNetworkProtocolsResponse"`
+	NetworkProtocols []struct {
+		Name    string `xml:"Name"`
+		Enabled bool   `xml:"Enabled"`
+		Port    []int  `xml:"Port"`
+	} `xml:"NetworkProtocols"`
+}
+
+type deviceRequest struct {
+	XMLName xml.Name
+}
+
+type setHostnameRequest struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/device/wsdl SetHostname"`
+	Name    string   `xml:"http://www.onvif.org/ver10/device/wsdl Name"`
+}
+
+type setHostnameResponse struct {
+	XMLName xml.Name `xml:"SetHostnameResponse"`
+}
+
+type ipAddressRequest struct {
+	Type        string `xml:"http://www.onvif.org/ver10/schema Type"`
+	IPv4Address string `xml:"http://www.onvif.org/ver10/schema IPv4Address,omitempty"`
+	IPv6Address string `xml:"http://www.onvif.org/ver10/schema IPv6Address,omitempty"`
+}
+
+type setDNSRequest struct {
+	XMLName      xml.Name           `xml:"http://www.onvif.org/ver10/device/wsdl SetDNS"`
+	FromDHCP     bool               `xml:"http://www.onvif.org/ver10/device/wsdl FromDHCP"`
+	SearchDomain []string           `xml:"http://www.onvif.org/ver10/device/wsdl SearchDomain,omitempty"`
+	DNSManual    []ipAddressRequest `xml:"http://www.onvif.org/ver10/device/wsdl DNSManual,omitempty"`
+}
+
+type setDNSResponse struct {
+	XMLName xml.Name `xml:"SetDNSResponse"`
+}
+
+// callDevice sends a request without parameters to the device service
+func (c *CameraClient) callDevice(operation string, response interface{}) error {
+	request := &deviceRequest{XMLName: xml.Name{Space: deviceWSDLNamespace, Local: operation}}
+	return c.Client.Call(c.Services.Device, deviceWSDLNamespace+"/"+operation, request, response)
+}
+
+// GetNetworkSettings reads the host name, DNS, network interface, gateway, protocol and time settings.
+// Only the host name is required; settings the camera does not report are left empty.
+func (c *CameraClient) GetNetworkSettings() (*NetworkSettings, error) {
+	hostname := new(getHostnameResponse)
+	if err := c.callDevice("GetHostname", hostname); err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	settings := &NetworkSettings{
+		Hostname:         hostname.HostnameInformation.Name,
+		HostnameFromDHCP: hostname.HostnameInformation.FromDHCP,
+		SearchDomains:    []string{},
+		DNSServers:       []string{},
+		Interfaces:       []NetworkInterface{},
+		DefaultGateways:  []string{},
+		Protocols:        []NetworkProtocol{},
+	}
+
+	dns := new(getDNSResponse)
+	if err := c.callDevice("GetDNS", dns); err == nil {
+		settings.DNSFromDHCP = dns.DNSInformation.FromDHCP
+		settings.SearchDomains = append(settings.SearchDomains, dns.DNSInformation.SearchDomain...)
+		for _, server := range dns.DNSInformation.DNSManual {
+			if server.IPv4Address != "" {
+				settings.DNSServers = append(settings.DNSServers, server.IPv4Address)
+			} else if server.IPv6Address != "" {
+				settings.DNSServers = append(settings.DNSServers, server.IPv6Address)
+			}
+		}
+	}
+
+	interfaces := new(getNetworkInterfacesResponse)
+	if err := c.callDevice("GetNetworkInterfaces", interfaces); err == nil {
+		for _, ni := range interfaces.NetworkInterfaces {
+			networkInterface := NetworkInterface{
+				Token:     ni.Token,
+				Name:      ni.Info.Name,
+				HwAddress: ni.Info.HwAddress,
+				Enabled:   ni.Enabled,
+				DHCP:      ni.IPv4.Config.DHCP,
+				Addresses: []string{},
+			}
+			for _, address := range ni.IPv4.Config.Manual {
+				networkInterface.Addresses = append(networkInterface.Addresses, address.Address+"/"+strconv.Itoa(address.PrefixLength))
+			}
+			settings.Interfaces = append(settings.Interfaces, networkInterface)
+		}
+	}
+
+	gateway := new(getNetworkDefaultGatewayResponse)
+	if err := c.callDevice("GetNetworkDefaultGateway", gateway); err == nil {
+		settings.DefaultGateways = append(settings.DefaultGateways, gateway.NetworkGateway.IPv4Address...)
+	}
+
+	protocols := new(getNetworkProtocolsResponse)
+	if err := c.callDevice("GetNetworkProtocols", protocols); err == nil {
+		for _, protocol := range protocols.NetworkProtocols {
+			settings.Protocols = append(settings.Protocols, NetworkProtocol{Name: protocol.Name, Enabled: protocol.Enabled, Ports: protocol.Port})
+		}
+	}
+
+	if status, err := c.GetTimeStatus(); err == nil {
+		settings.Time = status
+	}
+	return settings, nil
+}
+
+// SetHostname sets the host name of the camera
+func (c *CameraClient) SetHostname(name string) error {
+	if err := c.Client.Call(c.Services.Device, deviceWSDLNamespace+"/SetHostname", &setHostnameRequest{Name: name}, new(setHostnameResponse)); err != nil {
+		return fmt.Errorf("failed to set hostname: %w", err)
+	}
+	return nil
+}
+
+// SetDNS sets the DNS servers and search domains of the camera, or makes it take them from DHCP
+func (c *CameraClient) SetDNS(fromDHCP bool, searchDomains, servers []string) error {
+	request := &setDNSRequest{FromDHCP: fromDHCP, SearchDomain: searchDomains}
+	if !fromDHCP {
+		for _, server := range servers {
+			host := newNetworkHost(server)
+			request.DNSManual = append(request.DNSManual, ipAddressRequest{Type: host.Type, IPv4Address: host.IPv4Address, IPv6Address: host.IPv6Address})
+		}
+	}
+	if err := c.Client.Call(c.Services.Device, deviceWSDLNamespace+"/SetDNS", request, new(setDNSResponse)); err != nil {
+		return fmt.Errorf("failed to set DNS: %w", err)
+	}
+	return nil
+}
+
+// SetTimeSettings restores the date/time type, time zone and NTP servers of a time status.
+// A camera set to manual time gets the local time.
+func (c *CameraClient) SetTimeSettings(status TimeStatus) error {
+	request := &setNTPRequest{FromDHCP: status.NTPFromDHCP}
+	if !status.NTPFromDHCP {
+		for _, server := range status.NTPServers {
+			request.NTPManual = append(request.NTPManual, newNetworkHost(server))
+		}
+	}
+	// Cameras without NTP configuration can still have their time zone set
+	if len(request.NTPManual) > 0 || status.NTPFromDHCP {
+		if err := c.Client.Call(c.Services.Device, deviceWSDLNamespace+"/SetNTP", request, new(setNTPResponse)); err != nil {
+			return fmt.Errorf("failed to set NTP servers: %w", err)
+		}
+	}
+
+	var now *time.Time
+	if status.DateTimeType != DateTimeTypeNTP {
+		t := time.Now()
+		now = &t
+	}
+	dateTimeType := status.DateTimeType
+	if dateTimeType == "" {
+		dateTimeType = DateTimeTypeManual
+	}
+	if err := c.setSystemDateAndTime(dateTimeType, &status, now); err != nil {
+		return err
+	}
+
+	c.MeasureClockOffset()
+	return nil
+}
@@ -0,0 +1,219 @@
+package camera
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"onvif_manager/pkg/models"
+
+	"github.com/videonext/onvif/profiles/media"
+)
+
+// Media services the encoder options can be read from
+const (
+	MediaServiceMedia  = "media"
+	MediaServiceMedia2 = "media2"
+)
+
+// GetEncoderOptions returns the options of a video encoder configuration for every encoding
+// the camera offers. Cameras that advertise Media2 are asked through Media2, others through
+// the Media service, which only knows H.264 and JPEG.
+func GetEncoderOptions(client *CameraClient, profileToken, configToken string) (*models.EncoderOptions, error) {
+	if client.UsesMedia2() {
+		return getMedia2EncoderOptions(client, profileToken, configToken)
+	}
+
+	resp, err := client.Media.GetVideoEncoderConfigurationOptions(&media.GetVideoEncoderConfigurationOptions{
+		ConfigurationToken: media.ReferenceToken(configToken),
+		ProfileToken:       media.ReferenceToken(profileToken),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encoder options: %w", err)
+	}
+
+	options := &models.EncoderOptions{Service: MediaServiceMedia, Encodings: make(map[string]*models.EncodingOptions)}
+	quality := newRange(float64(resp.Options.QualityRange.Min), float64(resp.Options.QualityRange.Max))
+
+	if h264 := resp.Options.H264; len(h264.ResolutionsAvailable) > 0 {
+		encoding := newMediaEncodingOptions(EncodingH264, h264.ResolutionsAvailable, h264.FrameRateRange, resp.Options.Extension.H264.BitrateRange)
+		encoding.QualityRange = quality
+		encoding.GOPRange = newRange(float64(h264.GovLengthRange.Min), float64(h264.GovLengthRange.Max))
+		encoding.EncodingIntervalRange = newRange(float64(h264.EncodingIntervalRange.Min), float64(h264.EncodingIntervalRange.Max))
+		for _, profile := range h264.H264ProfilesSupported {
+			encoding.Profiles = append(encoding.Profiles, string(profile))
+		}
+		options.Encodings[EncodingH264] = encoding
+	}
+	if jpeg := resp.Options.JPEG; len(jpeg.ResolutionsAvailable) > 0 {
+		encoding := newMediaEncodingOptions(EncodingJPEG, jpeg.ResolutionsAvailable, jpeg.FrameRateRange, resp.Options.Extension.JPEG.BitrateRange)
+		encoding.QualityRange = quality
+		encoding.EncodingIntervalRange = newRange(float64(jpeg.EncodingIntervalRange.Min), float64(jpeg.EncodingIntervalRange.Max))
+		options.Encodings[EncodingJPEG] = encoding
+	}
+
+	return options, nil
+}
+
+// SelectEncodingOptions returns the options of one encoding, or an error naming the encodings the camera offers
+func SelectEncodingOptions(options *models.EncoderOptions, encoding string) (*models.EncodingOptions, error) {
+	encoding = NormalizeEncoding(encoding)
+	if encoding == "" {
+		encoding = EncodingH264
+	}
+	if selected, ok := options.Encodings[encoding]; ok {
+		return selected, nil
+	}
+
+	supported := SupportedEncodings(options)
+	switch {
+	case encoding == EncodingH265 && options.Service == MediaServiceMedia:
+		return nil, fmt.Errorf("%s encoding requires the Media2 service, which the camera does not advertise", encoding)
+	case len(supported) == 0:
+		return nil, fmt.Errorf("camera does not support %s encoding", encoding)
+	default:
+		return nil, fmt.Errorf("camera does not support %s encoding (supported: %s)", encoding, strings.Join(supported, ", "))
+	}
+}
+
+// SupportedEncodings returns the encodings of the options in alphabetical order
+func SupportedEncodings(options *models.EncoderOptions) []string {
+	encodings := make([]string, 0, len(options.Encodings))
+	for encoding := range options.Encodings {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings)
+	return encodings
+}
+
+// ValidateEncoderConfig checks a config against the options of its encoding so that
+// unsupported values are rejected before the camera is touched. Zero values are not checked.
+func ValidateEncoderConfig(options *models.EncodingOptions, config models.EncoderConfig) error {
+	var problems []string
+
+	if res := config.Resolution; res.Width > 0 && res.Height > 0 && len(options.Resolutions) > 0 && !containsResolution(options.Resolutions, res) {
+		problems = append(problems, fmt.Sprintf("resolution %dx%d is not available", res.Width, res.Height))
+	}
+	if config.FPS > 0 && len(options.FrameRates) > 0 && !containsInt(options.FrameRates, config.FPS) {
+		problems = append(problems, fmt.Sprintf("frame rate %d is not supported (supported: %s)", config.FPS, formatFrameRates(options.FrameRates)))
+	}
+	if config.Bitrate > 0 && !inRange(options.BitrateRange, config.Bitrate) {
+		problems = append(problems, fmt.Sprintf("bitrate %d kbps is outside %d-%d kbps", config.Bitrate, options.BitrateRange.Min, options.BitrateRange.Max))
+	}
+	if config.Quality > 0 && !inRange(options.QualityRange, config.Quality) {
+		problems = append(problems, fmt.Sprintf("quality %d is outside %d-%d", config.Quality, options.QualityRange.Min, options.QualityRange.Max))
+	}
+	if config.GOP > 0 {
+		if options.GOPRange == nil {
+			problems = append(problems, "GOP length cannot be set")
+		} else if !inRange(options.GOPRange, config.GOP) {
+			problems = append(problems, fmt.Sprintf("GOP %d is outside %d-%d frames", config.GOP, options.GOPRange.Min, options.GOPRange.Max))
+		}
+	}
+	switch config.RateControl {
+	case "", RateControlVBR:
+	case RateControlCBR:
+		if !options.ConstantBitRate {
+			problems = append(problems, "constant bitrate (CBR) is not supported")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("rate control %s is not supported (use CBR or VBR)", config.RateControl))
+	}
+	if config.EncodingInterval > 0 {
+		if options.EncodingIntervalRange == nil {
+			problems = append(problems, "encoding interval cannot be set")
+		} else if !inRange(options.EncodingIntervalRange, config.EncodingInterval) {
+			problems = append(problems, fmt.Sprintf("encoding interval %d is outside %d-%d", config.EncodingInterval, options.EncodingIntervalRange.Min, options.EncodingIntervalRange.Max))
+		}
+	}
+	if config.EncoderProfile != "" && !containsFold(options.Profiles, config.EncoderProfile) {
+		if len(options.Profiles) == 0 {
+			problems = append(problems, "encoder profile cannot be set")
+		} else {
+			problems = append(problems, fmt.Sprintf("encoder profile %s is not supported (supported: %s)", config.EncoderProfile, strings.Join(options.Profiles, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s options: %s", options.Encoding, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// newMediaEncodingOptions converts the options of one encoding reported by the Media service
+func newMediaEncodingOptions(encoding string, available []media.VideoResolution, frameRateRange, bitrateRange media.IntRange) *models.EncodingOptions {
+	options := &models.EncodingOptions{
+		Encoding:     encoding,
+		BitrateRange: newRange(float64(bitrateRange.Min), float64(bitrateRange.Max)),
+	}
+	for _, res := range available {
+		options.Resolutions = append(options.Resolutions, models.Resolution{
+			Width:  int(res.Width),
+			Height: int(res.Height),
+		})
+	}
+	// The Media service reports a range, every whole frame rate within it is accepted
+	for fps := frameRateRange.Min; fps <= frameRateRange.Max; fps++ {
+		if fps > 0 {
+			options.FrameRates = append(options.FrameRates, int(fps))
+		}
+	}
+	return options
+}
+
+// newRange returns a range, or nil when the camera reports none
+func newRange(min, max float64) *models.Range {
+	if max <= 0 || max < min {
+		return nil
+	}
+	return &models.Range{Min: int(math.Ceil(min)), Max: int(math.Floor(max))}
+}
+
+// inRange reports whether a value is within a range; every value is when there is no range
+func inRange(r *models.Range, value int) bool {
+	return r == nil || (value >= r.Min && value <= r.Max)
+}
+
+// containsResolution reports whether a resolution is in the list
+func containsResolution(resolutions []models.Resolution, resolution models.Resolution) bool {
+	for _, res := range resolutions {
+		if res == resolution {
+			return true
+		}
+	}
+	return false
+}
+
+// containsInt reports whether a value is in the list
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether a string is in the list, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatFrameRates describes a frame rate list, as a range when it has no gaps
+func formatFrameRates(rates []int) string {
+	first, last := rates[0], rates[len(rates)-1]
+	if len(rates) > 2 && last-first == len(rates)-1 {
+		return fmt.Sprintf("%d-%d", first, last)
+	}
+	parts := make([]string, len(rates))
+	for i, rate := range rates {
+		parts[i] = fmt.Sprint(rate)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,174 @@
+package camera
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"onvif_manager/pkg/models"
+
+	"github.com/videonext/onvif/profiles/media"
+)
+
+// testEncoderOptions are the options of a camera offering H.264 and JPEG through the Media service
+func testEncoderOptions() *models.EncoderOptions {
+	return &models.EncoderOptions{
+		Service: MediaServiceMedia,
+		Encodings: map[string]*models.EncodingOptions{
+			EncodingH264: {
+				Encoding:     EncodingH264,
+				Resolutions:  []models.Resolution{{Width: 1920, Height: 1080}, {Width: 1280, Height: 720}},
+				FrameRates:   []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25},
+				BitrateRange: &models.Range{Min: 256, Max: 8192},
+				QualityRange: &models.Range{Min: 1, Max: 10},
+				GOPRange:     &models.Range{Min: 1, Max: 100},
+				Profiles:     []string{"Baseline", "Main", "High"},
+			},
+			EncodingJPEG: {
+				Encoding:    EncodingJPEG,
+				Resolutions: []models.Resolution{{Width: 1280, Height: 720}},
+				FrameRates:  []int{1, 5, 10},
+			},
+		},
+	}
+}
+
+func TestSelectEncodingOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *models.EncoderOptions
+		encoding string
+		want     string
+		wantErr  string
+	}{
+		{name: "default", options: testEncoderOptions(), encoding: "", want: EncodingH264},
+		{name: "alias", options: testEncoderOptions(), encoding: "mjpeg", want: EncodingJPEG},
+		{name: "H.265 without Media2", options: testEncoderOptions(), encoding: "hevc", wantErr: "requires the Media2 service"},
+		{name: "unsupported", options: &models.EncoderOptions{Service: MediaServiceMedia2, Encodings: testEncoderOptions().Encodings}, encoding: "H265", wantErr: "supported: H264, JPEG"},
+		{name: "no encodings", options: &models.EncoderOptions{Service: MediaServiceMedia2}, encoding: "H264", wantErr: "does not support H264"},
+	}
+
+	for _, tt := range tests {
+		got, err := SelectEncodingOptions(tt.options, tt.encoding)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: SelectEncodingOptions(%q) error = %v, want %q", tt.name, tt.encoding, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.Encoding != tt.want {
+			t.Errorf("%s: SelectEncodingOptions(%q) = %v, %v, want %s", tt.name, tt.encoding, got, err, tt.want)
+		}
+	}
+}
+
+func TestSupportedEncodings(t *testing.T) {
+	if got := SupportedEncodings(testEncoderOptions()); !reflect.DeepEqual(got, []string{"H264", "JPEG"}) {
+		t.Errorf("SupportedEncodings() = %v, want [H264 JPEG]", got)
+	}
+}
+
+func TestValidateEncoderConfig(t *testing.T) {
+	h264 := testEncoderOptions().Encodings[EncodingH264]
+	jpeg := testEncoderOptions().Encodings[EncodingJPEG]
+
+	tests := []struct {
+		name    string
+		options *models.EncodingOptions
+		config  models.EncoderConfig
+		wantErr []string // Parts of the error; none for a valid config
+	}{
+		{
+			name:    "valid",
+			options: h264,
+			config:  models.EncoderConfig{Resolution: models.Resolution{Width: 1920, Height: 1080}, FPS: 25, Bitrate: 4096, Quality: 5, GOP: 50, EncoderProfile: "high"},
+		},
+		{name: "zero values are not checked", options: jpeg, config: models.EncoderConfig{}},
+		{
+			name:    "out of range",
+			options: h264,
+			config:  models.EncoderConfig{Resolution: models.Resolution{Width: 3840, Height: 2160}, FPS: 30, Bitrate: 10000, Quality: 11, GOP: 200},
+			wantErr: []string{"H264 options", "resolution 3840x2160 is not available", "frame rate 30 is not supported (supported: 1-25)", "bitrate 10000 kbps is outside 256-8192 kbps", "quality 11 is outside 1-10", "GOP 200 is outside 1-100 frames"},
+		},
+		{name: "unsupported profile", options: h264, config: models.EncoderConfig{EncoderProfile: "Main10"}, wantErr: []string{"encoder profile Main10 is not supported (supported: Baseline, Main, High)"}},
+		{name: "frame rate list", options: jpeg, config: models.EncoderConfig{FPS: 25}, wantErr: []string{"supported: 1, 5, 10"}},
+		{name: "GOP without range", options: jpeg, config: models.EncoderConfig{GOP: 10}, wantErr: []string{"GOP length cannot be set"}},
+		{name: "profile without profiles", options: jpeg, config: models.EncoderConfig{EncoderProfile: "Main"}, wantErr: []string{"encoder profile cannot be set"}},
+		{name: "VBR", options: h264, config: models.EncoderConfig{RateControl: RateControlVBR}},
+		{name: "CBR not supported", options: h264, config: models.EncoderConfig{RateControl: RateControlCBR}, wantErr: []string{"constant bitrate (CBR) is not supported"}},
+		{name: "unknown rate control", options: h264, config: models.EncoderConfig{RateControl: "ABR"}, wantErr: []string{"rate control ABR is not supported"}},
+		{name: "CBR", options: &models.EncodingOptions{Encoding: EncodingH265, ConstantBitRate: true}, config: models.EncoderConfig{RateControl: RateControlCBR}},
+		{name: "encoding interval", options: &models.EncodingOptions{Encoding: EncodingH264, EncodingIntervalRange: &models.Range{Min: 1, Max: 4}}, config: models.EncoderConfig{EncodingInterval: 5}, wantErr: []string{"encoding interval 5 is outside 1-4"}},
+		{name: "encoding interval without range", options: jpeg, config: models.EncoderConfig{EncodingInterval: 2}, wantErr: []string{"encoding interval cannot be set"}},
+	}
+
+	for _, tt := range tests {
+		err := ValidateEncoderConfig(tt.options, tt.config)
+		if len(tt.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: ValidateEncoderConfig() error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: ValidateEncoderConfig() succeeded, want %v", tt.name, tt.wantErr)
+			continue
+		}
+		for _, part := range tt.wantErr {
+			if !strings.Contains(err.Error(), part) {
+				t.Errorf("%s: ValidateEncoderConfig() error = %v, want it to contain %q", tt.name, err, part)
+			}
+		}
+	}
+}
+
+func TestNewRange(t *testing.T) {
+	tests := []struct {
+		min, max float64
+		want     *models.Range
+	}{
+		{min: 1, max: 10, want: &models.Range{Min: 1, Max: 10}},
+		{min: 0.5, max: 99.9, want: &models.Range{Min: 1, Max: 99}},
+		{min: 0, max: 0, want: nil},
+		{min: 10, max: 5, want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := newRange(tt.min, tt.max); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("newRange(%v, %v) = %v, want %v", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestNewMediaEncodingOptions(t *testing.T) {
+	available := []media.VideoResolution{{Width: 1280, Height: 720}}
+	got := newMediaEncodingOptions(EncodingH264, available, media.IntRange{Min: 0, Max: 5}, media.IntRange{Min: 64, Max: 4096})
+
+	want := &models.EncodingOptions{
+		Encoding:     EncodingH264,
+		Resolutions:  []models.Resolution{{Width: 1280, Height: 720}},
+		FrameRates:   []int{1, 2, 3, 4, 5},
+		BitrateRange: &models.Range{Min: 64, Max: 4096},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newMediaEncodingOptions() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFormatFrameRates(t *testing.T) {
+	tests := []struct {
+		rates []int
+		want  string
+	}{
+		{rates: []int{1, 2, 3, 4}, want: "1-4"},
+		{rates: []int{5, 10, 15}, want: "5, 10, 15"},
+		{rates: []int{24, 25}, want: "24, 25"},
+		{rates: []int{30}, want: "30"},
+	}
+
+	for _, tt := range tests {
+		if got := formatFrameRates(tt.rates); got != tt.want {
+			t.Errorf("formatFrameRates(%v) = %q, want %q", tt.rates, got, tt.want)
+		}
+	}
+}
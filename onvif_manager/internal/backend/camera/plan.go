@@ -0,0 +1,78 @@
+package camera
+
+import (
+	"fmt"
+
+	"onvif_manager/pkg/models"
+)
+
+// ConfigChange is one setting that a configuration change modifies
+type ConfigChange struct {
+	Setting  string `json:"setting"`
+	Current  string `json:"current"`
+	Intended string `json:"intended"`
+}
+
+// ConfigPlan describes what applying a configuration to an encoder configuration would do,
+// without writing anything to the camera
+type ConfigPlan struct {
+	Current            models.EncoderConfig `json:"current"`
+	Intended           models.EncoderConfig `json:"intended"`
+	Changes            []ConfigChange       `json:"changes"`
+	ResolutionAdjusted bool                 `json:"resolutionAdjusted"` // The requested resolution is not offered, the closest one is used
+	Requested          models.Resolution    `json:"requestedResolution"`
+}
+
+// PlanEncoderConfig returns the configuration SetEncoderConfig would write for input and
+// the settings in which it differs from the current configuration. requested is the
+// resolution asked for before it was matched to the camera's resolutions.
+func PlanEncoderConfig(current, input models.EncoderConfig, requested models.Resolution) ConfigPlan {
+	intended := mergeEncoderConfig(current, input)
+	changes := CompareEncoderConfigs(current, intended)
+	if changes == nil {
+		changes = []ConfigChange{}
+	}
+	return ConfigPlan{
+		Current:            current,
+		Intended:           intended,
+		Changes:            changes,
+		ResolutionAdjusted: requested != intended.Resolution,
+		Requested:          requested,
+	}
+}
+
+// CompareEncoderConfigs returns the settings in which intended differs from current
+func CompareEncoderConfigs(current, intended models.EncoderConfig) []ConfigChange {
+	var changes []ConfigChange
+	add := func(setting, currentValue, intendedValue string) {
+		if currentValue != intendedValue {
+			changes = append(changes, ConfigChange{Setting: setting, Current: currentValue, Intended: intendedValue})
+		}
+	}
+	add("resolution", formatResolution(current.Resolution), formatResolution(intended.Resolution))
+	add("fps", formatSetting(current.FPS), formatSetting(intended.FPS))
+	add("bitrate", formatSetting(current.Bitrate), formatSetting(intended.Bitrate))
+	add("encoding", NormalizeEncoding(current.Encoding), NormalizeEncoding(intended.Encoding))
+	add("quality", formatSetting(current.Quality), formatSetting(intended.Quality))
+	add("gop", formatSetting(current.GOP), formatSetting(intended.GOP))
+	add("encoderProfile", current.EncoderProfile, intended.EncoderProfile)
+	add("rateControl", current.RateControl, intended.RateControl)
+	add("encodingInterval", formatSetting(current.EncodingInterval), formatSetting(intended.EncodingInterval))
+	return changes
+}
+
+// formatResolution formats a resolution as WIDTHxHEIGHT, or empty when it is unknown
+func formatResolution(res models.Resolution) string {
+	if res.Width == 0 || res.Height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", res.Width, res.Height)
+}
+
+// formatSetting formats a numeric setting, or empty when the camera does not report it
+func formatSetting(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,111 @@
+package camera
+
+import (
+	"reflect"
+	"testing"
+
+	"onvif_manager/pkg/models"
+)
+
+func TestPlanEncoderConfig(t *testing.T) {
+	current := models.EncoderConfig{
+		Resolution:  models.Resolution{Width: 1280, Height: 720},
+		Quality:     5,
+		FPS:         25,
+		Bitrate:     4096,
+		Encoding:    "H264",
+		GOP:         50,
+		RateControl: RateControlVBR,
+	}
+
+	tests := []struct {
+		name         string
+		input        models.EncoderConfig
+		requested    models.Resolution
+		wantSettings []string
+		wantAdjusted bool
+	}{
+		{
+			name:         "unchanged",
+			input:        models.EncoderConfig{Resolution: current.Resolution},
+			requested:    current.Resolution,
+			wantSettings: []string{},
+		},
+		{
+			name:         "resolution and frame rate",
+			input:        models.EncoderConfig{Resolution: models.Resolution{Width: 1920, Height: 1080}, FPS: 15},
+			requested:    models.Resolution{Width: 1920, Height: 1080},
+			wantSettings: []string{"resolution", "fps"},
+		},
+		{
+			name:         "snapped resolution",
+			input:        models.EncoderConfig{Resolution: models.Resolution{Width: 1920, Height: 1080}},
+			requested:    models.Resolution{Width: 1900, Height: 1000},
+			wantSettings: []string{"resolution"},
+			wantAdjusted: true,
+		},
+		{
+			name:         "encoding spelled differently",
+			input:        models.EncoderConfig{Resolution: current.Resolution, Encoding: "avc"},
+			requested:    current.Resolution,
+			wantSettings: []string{},
+		},
+		{
+			name:         "encoding and rate control",
+			input:        models.EncoderConfig{Resolution: current.Resolution, Encoding: "hevc", RateControl: "constant"},
+			requested:    current.Resolution,
+			wantSettings: []string{"encoding", "rateControl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := PlanEncoderConfig(current, tt.input, tt.requested)
+
+			settings := []string{}
+			for _, change := range plan.Changes {
+				settings = append(settings, change.Setting)
+			}
+			if !reflect.DeepEqual(settings, tt.wantSettings) {
+				t.Errorf("changed settings = %v, want %v", settings, tt.wantSettings)
+			}
+			if plan.ResolutionAdjusted != tt.wantAdjusted {
+				t.Errorf("ResolutionAdjusted = %v, want %v", plan.ResolutionAdjusted, tt.wantAdjusted)
+			}
+			if plan.Intended.Resolution != tt.input.Resolution {
+				t.Errorf("intended resolution = %v, want %v", plan.Intended.Resolution, tt.input.Resolution)
+			}
+			if plan.Intended.Bitrate != current.Bitrate || plan.Intended.GOP != current.GOP {
+				t.Errorf("settings that were not given changed: %+v", plan.Intended)
+			}
+		})
+	}
+}
+
+func TestFindClosestResolution(t *testing.T) {
+	offered := []models.Resolution{
+		{Width: 640, Height: 480},
+		{Width: 1280, Height: 720},
+		{Width: 1920, Height: 1080},
+		{Width: 2560, Height: 1440},
+	}
+
+	tests := []struct {
+		name      string
+		target    models.Resolution
+		available []models.Resolution
+		want      models.Resolution
+	}{
+		{name: "offered", target: models.Resolution{Width: 1920, Height: 1080}, available: offered, want: models.Resolution{Width: 1920, Height: 1080}},
+		{name: "closest area", target: models.Resolution{Width: 1900, Height: 1000}, available: offered, want: models.Resolution{Width: 1920, Height: 1080}},
+		{name: "between two", target: models.Resolution{Width: 1200, Height: 680}, available: offered, want: models.Resolution{Width: 1280, Height: 720}},
+		{name: "closest ratio when none is close", target: models.Resolution{Width: 3840, Height: 1080}, available: []models.Resolution{{Width: 1920, Height: 1080}, {Width: 1080, Height: 1920}}, want: models.Resolution{Width: 1920, Height: 1080}},
+		{name: "nothing offered", target: models.Resolution{Width: 1920, Height: 1080}, available: nil, want: models.Resolution{}},
+	}
+
+	for _, tt := range tests {
+		if got := FindClosestResolution(tt.target, tt.available); got != tt.want {
+			t.Errorf("%s: FindClosestResolution() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package camera
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"onvif_manager/pkg/models"
+
+	"github.com/videonext/onvif/profiles/media"
+)
+
+// Profile roles. The main stream is the video profile with the highest resolution,
+// the sub stream the one with the next highest resolution.
+const (
+	ProfileRoleMain = "main"
+	ProfileRoleSub  = "sub"
+)
+
+// Profile is a media profile of a camera together with its video encoder configuration
+type Profile struct {
+	Token       string                `json:"token"`
+	Name        string                `json:"name"`
+	Role        string                `json:"role,omitempty"` // main, sub or empty for further profiles
+	Fixed       bool                  `json:"fixed"`
+	ConfigToken string                `json:"configToken,omitempty"` // Video encoder configuration token
+	ConfigName  string                `json:"configName,omitempty"`
+	Encoder     *models.EncoderConfig `json:"encoder,omitempty"` // Nil for profiles without video encoder
+}
+
+// HasEncoder reports whether the profile has a video encoder configuration
+func (p Profile) HasEncoder() bool {
+	return p.ConfigToken != ""
+}
+
+// String returns the profile name with its role, e.g. "MainStream (main)"
+func (p Profile) String() string {
+	name := p.Name
+	if name == "" {
+		name = p.Token
+	}
+	if p.Role != "" {
+		return fmt.Sprintf("%s (%s)", name, p.Role)
+	}
+	return name
+}
+
+// GetProfiles returns all media profiles of the camera in the order the camera reports them,
+// read through Media2 when the camera advertises it.
+// Profiles with a video encoder are given the main and sub roles by resolution.
+func GetProfiles(client *CameraClient) ([]Profile, error) {
+	var profiles []Profile
+	var err error
+	if client.UsesMedia2() {
+		profiles, err = getMedia2Profiles(client)
+	} else {
+		profiles, err = getMediaProfiles(client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no profiles found")
+	}
+
+	assignProfileRoles(profiles)
+	return profiles, nil
+}
+
+// getMediaProfiles reads the media profiles with their video encoder configuration through the Media service
+func getMediaProfiles(client *CameraClient) ([]Profile, error) {
+	resp, err := client.Media.GetProfiles(&media.GetProfiles{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profiles: %w", err)
+	}
+
+	profiles := make([]Profile, 0, len(resp.Profiles))
+	for _, p := range resp.Profiles {
+		profile := Profile{
+			Token: string(p.Token),
+			Name:  string(p.Name),
+			Fixed: p.Fixed,
+		}
+
+		cfg := p.VideoEncoderConfiguration
+		if cfg.ConfigurationEntity != nil && cfg.Token != "" {
+			profile.ConfigToken = string(cfg.Token)
+			profile.ConfigName = string(cfg.Name)
+			encoder := mediaEncoderConfig(cfg)
+			profile.Encoder = &encoder
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// assignProfileRoles marks the video profiles with the highest and second highest
+// resolution as main and sub stream. Profiles of equal resolution keep the camera's order.
+func assignProfileRoles(profiles []Profile) {
+	var video []int
+	for i, profile := range profiles {
+		if profile.HasEncoder() {
+			video = append(video, i)
+		}
+	}
+
+	sort.SliceStable(video, func(a, b int) bool {
+		return pixels(profiles[video[a]].Encoder.Resolution) > pixels(profiles[video[b]].Encoder.Resolution)
+	})
+
+	roles := []string{ProfileRoleMain, ProfileRoleSub}
+	for i, index := range video {
+		if i >= len(roles) {
+			break
+		}
+		profiles[index].Role = roles[i]
+	}
+}
+
+// SelectProfile picks the profile matching the selector, which is a profile token,
+// a profile name or a role (main or sub). An empty selector selects the main stream.
+// Only profiles with a video encoder configuration can be selected.
+func SelectProfile(profiles []Profile, selector string) (Profile, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		selector = ProfileRoleMain
+	}
+
+	var available []string
+	for _, profile := range profiles {
+		if profile.HasEncoder() {
+			available = append(available, profile.String())
+		}
+	}
+	if len(available) == 0 {
+		return Profile{}, fmt.Errorf("no video encoder configuration found")
+	}
+
+	// Tokens are matched exactly and take precedence over names and roles
+	for _, profile := range profiles {
+		if profile.HasEncoder() && profile.Token == selector {
+			return profile, nil
+		}
+	}
+	for _, profile := range profiles {
+		if profile.HasEncoder() && strings.EqualFold(profile.Name, selector) {
+			return profile, nil
+		}
+	}
+	for _, profile := range profiles {
+		if profile.Role != "" && strings.EqualFold(profile.Role, selector) {
+			return profile, nil
+		}
+	}
+
+	return Profile{}, fmt.Errorf("profile %q not found (available: %s)", selector, strings.Join(available, ", "))
+}
+
+// ProfileSelectorKey returns the key under which rows naming a profile of the same camera are
+// compared: the selector in lower case, with an empty selector standing for the main stream
+// as in SelectProfile
+func ProfileSelectorKey(selector string) string {
+	selector = strings.ToLower(strings.TrimSpace(selector))
+	if selector == "" {
+		return ProfileRoleMain
+	}
+	return selector
+}
+
+// ResolveProfile reads the profiles of the camera and selects one as SelectProfile does
+func ResolveProfile(client *CameraClient, selector string) (Profile, error) {
+	profiles, err := GetProfiles(client)
+	if err != nil {
+		return Profile{}, err
+	}
+	return SelectProfile(profiles, selector)
+}
+
+// pixels returns the number of pixels of a resolution
+func pixels(resolution models.Resolution) int {
+	return resolution.Width * resolution.Height
+}
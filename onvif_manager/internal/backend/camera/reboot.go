@@ -0,0 +1,54 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"onvif_manager/internal/backend/pool"
+)
+
+// RebootResult is the outcome of rebooting one camera
+type RebootResult struct {
+	CameraID string `json:"cameraId"`
+	Success  bool   `json:"success"`
+	Message  string `json:"message,omitempty"` // Message reported by the camera
+	Error    string `json:"error,omitempty"`
+}
+
+// RebootCameras reboots the cameras through the bounded worker pool and returns
+// one result per camera in the order of cameraIDs
+func RebootCameras(ctx context.Context, cameraIDs []string, opts pool.Options) []RebootResult {
+	log.Printf("Rebooting %d cameras (concurrency %d, timeout %s)", len(cameraIDs), opts.Concurrency, opts.Timeout)
+
+	return pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) RebootResult {
+			result := RebootResult{CameraID: cameraID}
+
+			unlock := LockCamera(cameraID)
+			defer unlock()
+
+			client, err := GetCameraClient(cameraID)
+			if err != nil {
+				result.Error = err.Error()
+				return result
+			}
+
+			if err := pool.Abandoned(ctx); err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			message, err := client.Reboot()
+			if err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			log.Printf("Camera %s is rebooting: %s", cameraID, message)
+			result.Success = true
+			result.Message = message
+			return result
+		},
+		func(cameraID string, err error) RebootResult {
+			return RebootResult{CameraID: cameraID, Error: fmt.Sprintf("Reboot aborted: %v", err)}
+		})
+}
@@ -0,0 +1,413 @@
+package camera
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"onvif_manager/pkg/models"
+)
+
+// Registry holds the camera inventory and the connected camera clients.
+// All access is synchronized, and each camera has an exclusive operation lock
+// so that configure, validate and delete requests against one camera are
+// serialized while different cameras can be worked on in parallel.
+type Registry struct {
+	mu      sync.RWMutex
+	cameras []models.Camera
+	clients map[string]*CameraClient
+	store   Store
+	// recorder is told about the encoder configurations written through the clients; nil when none is set
+	recorder ConfigRecorder
+
+	locksMu sync.Mutex
+	opLocks map[string]*opLock
+}
+
+// opLock is the operation lock of a camera. It is kept in the registry only while
+// someone holds or waits for it, so locking unknown or removed cameras leaves nothing behind.
+type opLock struct {
+	sync.Mutex
+	refs int // Holders and waiters
+}
+
+// ErrNotFound is wrapped by the errors returned for cameras that are not in the inventory
+var ErrNotFound = errors.New("not found")
+
+// notFound returns the error for a camera that is not in the inventory
+func notFound(id string) error {
+	return fmt.Errorf("camera with ID %s %w", id, ErrNotFound)
+}
+
+// NewRegistry creates an empty registry that persists its inventory to store
+func NewRegistry(store Store) *Registry {
+	if store == nil {
+		store = &MemoryStore{}
+	}
+	return &Registry{
+		cameras: []models.Camera{},
+		clients: make(map[string]*CameraClient),
+		store:   store,
+		opLocks: make(map[string]*opLock),
+	}
+}
+
+// Load replaces the registry contents with the cameras saved in its store.
+// Cameras are connected when their client is first requested.
+func (r *Registry) Load() error {
+	cameras, err := r.store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load camera inventory: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cameras = cameras
+	r.clients = make(map[string]*CameraClient)
+	r.mu.Unlock()
+	return nil
+}
+
+// staleStore is implemented by stores that other processes can change, such as the inventory file
+type staleStore interface {
+	Stale() bool
+}
+
+// refresh reloads the inventory when another process, such as the CLI next to a
+// running server, changed the store since the registry last read or wrote it
+func (r *Registry) refresh() {
+	store, ok := r.store.(staleStore)
+	if !ok || !store.Stale() {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Another caller may have reloaded it while this one waited for the lock
+	if !store.Stale() {
+		return
+	}
+	cameras, err := r.store.Load()
+	if err != nil {
+		fmt.Printf("Warning: failed to reload camera inventory: %v\n", err)
+		return
+	}
+	r.replace(cameras)
+}
+
+// update changes the stored inventory with fn, starting from the store's current
+// contents so that changes made by other processes are kept, and takes over the result
+func (r *Registry) update(fn func(cameras []models.Camera) ([]models.Camera, error)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var changeErr error
+	updated, err := r.store.Update(func(cameras []models.Camera) ([]models.Camera, error) {
+		updated, err := fn(cameras)
+		changeErr = err
+		return updated, err
+	})
+	if changeErr != nil {
+		return changeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save camera inventory: %w", err)
+	}
+	r.replace(updated)
+	return nil
+}
+
+// replace swaps in a newer inventory and drops the clients of cameras that were removed
+// or whose connection settings changed. The caller holds r.mu.
+func (r *Registry) replace(cameras []models.Camera) {
+	byID := make(map[string]models.Camera, len(cameras))
+	for _, cam := range cameras {
+		byID[cam.ID] = cam
+	}
+	for id, client := range r.clients {
+		if cam, ok := byID[id]; !ok || !sameConnection(cam, client.Camera) {
+			delete(r.clients, id)
+		}
+	}
+	r.cameras = cameras
+}
+
+// sameConnection reports whether a client created for one camera can be used for the other
+func sameConnection(a, b models.Camera) bool {
+	return a.IP == b.IP && a.Port == b.Port && a.URL == b.URL && a.DeviceURL == b.DeviceURL &&
+		a.Username == b.Username && a.Password == b.Password && a.IsFake == b.IsFake
+}
+
+// Client returns the client of a camera, connecting it on first use. Connected
+// clients are cached; a camera that could not be connected is retried next time.
+func (r *Registry) Client(id string) (*CameraClient, error) {
+	r.refresh()
+
+	r.mu.RLock()
+	client, connected := r.clients[id]
+	cam, found := r.lookup(id)
+	r.mu.RUnlock()
+
+	if connected {
+		return client, nil
+	}
+	if !found {
+		return nil, notFound(id)
+	}
+
+	// Connecting talks to the camera, so it happens outside the registry lock
+	client, err := NewCameraClient(cam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to camera %s: %w", id, err)
+	}
+	return r.cache(cam, client), nil
+}
+
+// cache stores a client connected for cam and returns the client to use, which is
+// the one cached by a concurrent caller when there is one. A client is not cached
+// when its camera was removed or changed while connecting.
+func (r *Registry) cache(cam models.Camera, client *CameraClient) *CameraClient {
+	client.registry = r
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cached, ok := r.clients[cam.ID]; ok {
+		return cached
+	}
+	if current, ok := r.lookup(cam.ID); ok && sameConnection(current, cam) {
+		r.clients[cam.ID] = client
+	}
+	return client
+}
+
+// UseConfigRecorder sets the recorder that is told about the encoder configurations
+// written through the registry's clients. It should be called once at startup.
+func (r *Registry) UseConfigRecorder(recorder ConfigRecorder) {
+	r.mu.Lock()
+	r.recorder = recorder
+	r.mu.Unlock()
+}
+
+// configRecorder returns the recorder set with UseConfigRecorder, or nil
+func (r *Registry) configRecorder() ConfigRecorder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.recorder
+}
+
+// lookup returns the inventoried camera with the given ID. The caller holds r.mu.
+func (r *Registry) lookup(id string) (models.Camera, bool) {
+	for _, cam := range r.cameras {
+		if cam.ID == id {
+			return cam, true
+		}
+	}
+	return models.Camera{}, false
+}
+
+// Cameras returns a copy of the inventory
+func (r *Registry) Cameras() []models.Camera {
+	r.refresh()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cameras := make([]models.Camera, len(r.cameras))
+	copy(cameras, r.cameras)
+	return cameras
+}
+
+// Camera returns the inventoried camera with the given ID
+func (r *Registry) Camera(id string) (models.Camera, bool) {
+	r.refresh()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lookup(id)
+}
+
+// FindByIP returns the inventoried camera with the given IP address
+func (r *Registry) FindByIP(ip string) (models.Camera, bool) {
+	r.refresh()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, cam := range r.cameras {
+		if cam.IP == ip {
+			return cam, true
+		}
+	}
+	return models.Camera{}, false
+}
+
+// Add adds a camera to the inventory, assigning it an ID one greater than
+// the largest numeric ID in use, and connects its client. deviceURL is the
+// device service address the camera advertised, or empty for the default.
+func (r *Registry) Add(ip string, port int, url string, deviceURL string, username string, password string) (string, error) {
+	newCamera, err := r.addToInventory(ip, port, url, deviceURL, username, password)
+	if err != nil {
+		return "", err
+	}
+
+	// Connecting talks to the camera, so it happens outside the registry lock
+	client, err := NewCameraClient(newCamera)
+	if err != nil {
+		// Log the error but don't fail - we still want to add the camera to the list
+		fmt.Printf("Warning: Failed to initialize camera client for %s: %v\n", newCamera.ID, err)
+		return newCamera.ID, nil
+	}
+
+	r.cache(newCamera, client)
+	return newCamera.ID, nil
+}
+
+// addToInventory assigns the new camera its ID and saves it to the inventory
+func (r *Registry) addToInventory(ip string, port int, url string, deviceURL string, username string, password string) (models.Camera, error) {
+	var newCamera models.Camera
+	err := r.update(func(cameras []models.Camera) ([]models.Camera, error) {
+		// Check if a camera with the same IP already exists
+		highestID := 0
+		for _, cam := range cameras {
+			if cam.IP == ip {
+				return nil, fmt.Errorf("camera with IP address %s already exists (ID: %s)", ip, cam.ID)
+			}
+			if camID, err := strconv.Atoi(cam.ID); err == nil && camID > highestID {
+				highestID = camID
+			}
+		}
+
+		newCamera = models.Camera{
+			ID:        strconv.Itoa(highestID + 1),
+			IP:        ip,
+			Port:      port,
+			URL:       url,
+			DeviceURL: deviceURL,
+			Username:  username,
+			Password:  password,
+		}
+		return append(cameras, newCamera), nil
+	})
+	if err != nil {
+		return models.Camera{}, err
+	}
+	return newCamera, nil
+}
+
+// Remove deletes a camera from the inventory together with its client.
+// It waits for any operation currently running on the camera to finish; the
+// camera's operation lock is dropped once no one holds or waits for it.
+func (r *Registry) Remove(id string) error {
+	unlock := r.Lock(id)
+	defer unlock()
+
+	return r.update(func(cameras []models.Camera) ([]models.Camera, error) {
+		found := false
+		updatedCameras := make([]models.Camera, 0, len(cameras))
+		for _, cam := range cameras {
+			if cam.ID != id {
+				updatedCameras = append(updatedCameras, cam)
+			} else {
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, notFound(id)
+		}
+		return updatedCameras, nil
+	})
+}
+
+// Lock acquires the exclusive operation lock of a camera and returns the
+// function that releases it. Hold it for the duration of any operation that
+// talks to the camera, e.g.:
+//
+//	unlock := registry.Lock(id)
+//	defer unlock()
+func (r *Registry) Lock(id string) func() {
+	r.locksMu.Lock()
+	lock, ok := r.opLocks[id]
+	if !ok {
+		lock = &opLock{}
+		r.opLocks[id] = lock
+	}
+	lock.refs++
+	r.locksMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		r.locksMu.Lock()
+		defer r.locksMu.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(r.opLocks, id)
+		}
+	}
+}
+
+// OperationLocks holds the operation locks of the cameras an operation works on in
+// several phases, such as configuring cameras and then validating them, so that no
+// other request can work on a camera between the phases.
+type OperationLocks struct {
+	registry *Registry
+	mu       sync.Mutex
+	unlocks  map[string]func()
+	released bool
+}
+
+// OperationLocks returns an empty set of operation locks for one operation
+func (r *Registry) OperationLocks() *OperationLocks {
+	return &OperationLocks{registry: r, unlocks: make(map[string]func())}
+}
+
+// Lock acquires the operation lock of a camera for the operation, waiting while another
+// operation holds it; a camera the operation already holds is not locked again. It
+// reports false when the operation was released while waiting, as happens to a worker
+// abandoned after a timeout, and then leaves the camera unlocked.
+func (l *OperationLocks) Lock(id string) bool {
+	l.mu.Lock()
+	_, held := l.unlocks[id]
+	l.mu.Unlock()
+	if held {
+		return true
+	}
+
+	unlock := l.registry.Lock(id)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		unlock()
+		return false
+	}
+	l.unlocks[id] = unlock
+	return true
+}
+
+// Unlock releases the operation lock of a camera once the operation is done with it
+func (l *OperationLocks) Unlock(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if unlock, ok := l.unlocks[id]; ok {
+		unlock()
+		delete(l.unlocks, id)
+	}
+}
+
+// Release unlocks every camera still held and makes later Lock calls fail. Call it
+// when the operation ends.
+func (l *OperationLocks) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, unlock := range l.unlocks {
+		unlock()
+		delete(l.unlocks, id)
+	}
+	l.released = true
+}
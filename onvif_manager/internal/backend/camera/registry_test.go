@@ -0,0 +1,196 @@
+package camera
+
+import (
+	"testing"
+	"time"
+
+	"onvif_manager/pkg/models"
+)
+
+// lockCount returns the number of operation locks the registry keeps
+func lockCount(r *Registry) int {
+	r.locksMu.Lock()
+	defer r.locksMu.Unlock()
+	return len(r.opLocks)
+}
+
+// locked runs lock in the background and returns a channel closed once it returns
+func locked(lock func()) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		lock()
+		close(done)
+	}()
+	return done
+}
+
+// waitFor reports whether done is closed within a short time
+func waitFor(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func TestRegistryLock(t *testing.T) {
+	registry := NewRegistry(nil)
+
+	unlock := registry.Lock("1")
+	var unlockAgain func()
+	waiting := locked(func() { unlockAgain = registry.Lock("1") })
+	if waitFor(waiting) {
+		t.Fatal("a held camera was locked a second time")
+	}
+	if other := locked(func() { registry.Lock("2")() }); !waitFor(other) {
+		t.Fatal("locking another camera waited")
+	}
+
+	unlock()
+	if !waitFor(waiting) {
+		t.Fatal("a released camera could not be locked")
+	}
+	if n := lockCount(registry); n != 1 {
+		t.Errorf("registry keeps %d locks while one is held, want 1", n)
+	}
+	unlockAgain()
+	if n := lockCount(registry); n != 0 {
+		t.Errorf("registry keeps %d locks after they were released, want 0", n)
+	}
+}
+
+func TestRegistryRemoveDropsLock(t *testing.T) {
+	store := &MemoryStore{}
+	store.Save([]models.Camera{{ID: "1", IP: "10.0.0.1"}})
+	registry := NewRegistry(store)
+	if err := registry.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if err := registry.Remove("1"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := registry.Remove("1"); err == nil {
+		t.Error("Remove() of a removed camera succeeded")
+	}
+	if n := lockCount(registry); n != 0 {
+		t.Errorf("registry keeps %d locks after removing the camera, want 0", n)
+	}
+}
+
+func TestOperationLocks(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, registry *Registry)
+	}{
+		{
+			name: "same camera twice in one operation",
+			run: func(t *testing.T, registry *Registry) {
+				locks := registry.OperationLocks()
+				defer locks.Release()
+				if !locks.Lock("1") || !locks.Lock("1") {
+					t.Error("Lock() of a camera the operation holds failed")
+				}
+			},
+		},
+		{
+			name: "overlapping operations",
+			run: func(t *testing.T, registry *Registry) {
+				first, second := registry.OperationLocks(), registry.OperationLocks()
+				first.Lock("1")
+				var ok bool
+				waiting := locked(func() { ok = second.Lock("1") })
+				if waitFor(waiting) {
+					t.Fatal("two operations held one camera")
+				}
+				if !first.Lock("1") {
+					t.Error("the first operation lost its camera")
+				}
+				first.Unlock("1")
+				if !waitFor(waiting) || !ok {
+					t.Fatal("the second operation did not get the camera after the first unlocked it")
+				}
+				second.Release()
+			},
+		},
+		{
+			name: "released while waiting",
+			run: func(t *testing.T, registry *Registry) {
+				unlock := registry.Lock("1")
+				locks := registry.OperationLocks()
+				ok := true
+				waiting := locked(func() { ok = locks.Lock("1") })
+				locks.Release()
+				unlock()
+				if !waitFor(waiting) || ok {
+					t.Fatal("Lock() of a released operation succeeded")
+				}
+				if another := locked(func() { registry.Lock("1")() }); !waitFor(another) {
+					t.Error("the camera stayed locked after the released operation gave it up")
+				}
+			},
+		},
+		{
+			name: "release unlocks every camera",
+			run: func(t *testing.T, registry *Registry) {
+				locks := registry.OperationLocks()
+				locks.Lock("1")
+				locks.Lock("2")
+				locks.Release()
+				if done := locked(func() { registry.Lock("1")(); registry.Lock("2")() }); !waitFor(done) {
+					t.Error("cameras stayed locked after Release()")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(nil)
+			tt.run(t, registry)
+			if n := lockCount(registry); n != 0 {
+				t.Errorf("registry keeps %d locks after the operations ended, want 0", n)
+			}
+		})
+	}
+}
+
+// recorderFunc is a ConfigRecorder calling a function
+type recorderFunc func(cameraID, configToken string, config models.EncoderConfig)
+
+func (f recorderFunc) RecordApplied(cameraID, configToken string, config models.EncoderConfig) {
+	f(cameraID, configToken, config)
+}
+
+func TestRegistryConfigRecorder(t *testing.T) {
+	cam := models.Camera{ID: "1", IP: "10.0.0.1"}
+	store := &MemoryStore{}
+	store.Save([]models.Camera{cam})
+	registry := NewRegistry(store)
+	if err := registry.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	client := registry.cache(cam, &CameraClient{Camera: cam})
+	if client.configRecorder() != nil {
+		t.Error("a client has a recorder before one was set")
+	}
+
+	var recorded []string
+	registry.UseConfigRecorder(recorderFunc(func(cameraID, configToken string, config models.EncoderConfig) {
+		recorded = append(recorded, cameraID+"/"+configToken)
+	}))
+	recorder := client.configRecorder()
+	if recorder == nil {
+		t.Fatal("a cached client does not get the registry's recorder")
+	}
+	recorder.RecordApplied("1", "VideoEncoder_1", models.EncoderConfig{})
+	if len(recorded) != 1 || recorded[0] != "1/VideoEncoder_1" {
+		t.Errorf("recorded %v, want [1/VideoEncoder_1]", recorded)
+	}
+
+	if (&CameraClient{Camera: cam}).configRecorder() != nil {
+		t.Error("a client created outside a registry has a recorder")
+	}
+}
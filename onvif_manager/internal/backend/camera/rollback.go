@@ -0,0 +1,135 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/ffmpeg"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/pkg/models"
+)
+
+// Rollback policies deciding when a changed encoder configuration is restored after validation
+const (
+	RollbackNever                = "never"                  // Keep the new configuration
+	RollbackOnFailure            = "on-failure"             // Restore when the stream fails validation for any reason
+	RollbackOnResolutionMismatch = "on-resolution-mismatch" // Restore only when the stream has another resolution
+)
+
+// ParseRollbackPolicy normalizes a rollback policy; empty means never
+func ParseRollbackPolicy(policy string) (string, error) {
+	switch policy = strings.ToLower(strings.TrimSpace(policy)); policy {
+	case "":
+		return RollbackNever, nil
+	case RollbackNever, RollbackOnFailure, RollbackOnResolutionMismatch:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown rollback policy %q (use %s, %s or %s)", policy, RollbackNever, RollbackOnFailure, RollbackOnResolutionMismatch)
+	}
+}
+
+// NeedsRollback reports whether a policy restores the previous configuration of a camera
+// whose stream was validated with the actual resolution; a zero resolution means the
+// stream could not be read. expected is the resolution written to the camera, i.e. the
+// requested one snapped to the resolutions the camera offers.
+func NeedsRollback(policy string, expected, actual models.Resolution) bool {
+	switch policy {
+	case RollbackOnFailure:
+		return actual != expected
+	case RollbackOnResolutionMismatch:
+		return actual.Width > 0 && actual.Height > 0 && actual != expected
+	default:
+		return false
+	}
+}
+
+// RestoreEncoderConfig writes a previously read encoder configuration back to the camera
+func RestoreEncoderConfig(client *CameraClient, configToken string, previous models.EncoderConfig) error {
+	current, err := GetCurrentConfig(client, configToken)
+	if err != nil {
+		return fmt.Errorf("failed to get current encoder config: %w", err)
+	}
+	if err := SetEncoderConfig(client, configToken, current, previous); err != nil {
+		return fmt.Errorf("failed to restore encoder config: %w", err)
+	}
+	return nil
+}
+
+// rollbackSettleTime is how long a restored configuration gets to take effect before
+// the stream is validated again
+const rollbackSettleTime = 1 * time.Second
+
+// Rollback writes the configuration an encoder had before a change whose validation
+// failed for reason back to the camera, then validates the stream at streamURL again
+// against the restored configuration. Nothing is written once ctx is done. The caller
+// holds the camera's operation lock.
+func Rollback(ctx context.Context, client *CameraClient, configToken string, previous models.EncoderConfig, policy, reason, streamURL string) *models.RollbackResult {
+	cameraID := client.Camera.ID
+	rollback := &models.RollbackResult{
+		Policy:         policy,
+		Reason:         reason,
+		RestoredConfig: previous,
+	}
+	log.Printf("Rolling back camera %s to %dx%d @ %d fps (policy %s): %s",
+		cameraID, previous.Resolution.Width, previous.Resolution.Height, previous.FPS, policy, reason)
+
+	if err := pool.Abandoned(ctx); err != nil {
+		rollback.Error = err.Error()
+		return rollback
+	}
+	if err := RestoreEncoderConfig(client, configToken, previous); err != nil {
+		log.Printf("Rollback of camera %s failed: %v", cameraID, err)
+		rollback.Error = err.Error()
+		return rollback
+	}
+	rollback.Restored = true
+
+	time.Sleep(rollbackSettleTime)
+	check, err := ffmpeg.ValidateStream(streamURL, previous.Resolution.Width, previous.Resolution.Height, previous.FPS, previous.Bitrate, previous.Encoding, 0, "")
+	if err != nil {
+		log.Printf("Validation after rollback failed for camera %s: %v", cameraID, err)
+		rollback.Error = fmt.Sprintf("validation after rollback failed: %v", err)
+		return rollback
+	}
+	rollback.Valid = check.ActualWidth == previous.Resolution.Width && check.ActualHeight == previous.Resolution.Height
+	if !rollback.Valid {
+		rollback.Error = fmt.Sprintf("stream is %dx%d after rollback, expected %dx%d",
+			check.ActualWidth, check.ActualHeight, previous.Resolution.Width, previous.Resolution.Height)
+	}
+	log.Printf("Rollback of camera %s completed: valid=%v", cameraID, rollback.Valid)
+	return rollback
+}
+
+// Rollback outcomes as reported in the rollback column of the validation CSV
+const (
+	RollbackStatusRestored        = "RESTORED"
+	RollbackStatusRestoredInvalid = "RESTORED_INVALID"
+	RollbackStatusFailed          = "ROLLBACK_FAILED"
+)
+
+// RollbackStatus returns the outcome of a rollback for the validation CSV
+func RollbackStatus(restored, valid bool) string {
+	switch {
+	case !restored:
+		return RollbackStatusFailed
+	case !valid:
+		return RollbackStatusRestoredInvalid
+	default:
+		return RollbackStatusRestored
+	}
+}
+
+// RollbackSummary describes the outcome of a rollback for messages
+func RollbackSummary(rollback models.RollbackResult) string {
+	switch {
+	case !rollback.Restored:
+		return "rollback failed"
+	case !rollback.Valid:
+		return "previous configuration restored, stream still invalid"
+	default:
+		return "previous configuration restored"
+	}
+}
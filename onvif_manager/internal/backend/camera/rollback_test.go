@@ -0,0 +1,56 @@
+package camera
+
+import (
+	"testing"
+
+	"onvif_manager/pkg/models"
+)
+
+func TestParseRollbackPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    string
+		wantErr bool
+	}{
+		{policy: "", want: RollbackNever},
+		{policy: "never", want: RollbackNever},
+		{policy: " On-Failure ", want: RollbackOnFailure},
+		{policy: "on-resolution-mismatch", want: RollbackOnResolutionMismatch},
+		{policy: "always", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRollbackPolicy(tt.policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRollbackPolicy(%q) error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRollbackPolicy(%q) = %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsRollback(t *testing.T) {
+	applied := models.Resolution{Width: 1920, Height: 1080}
+	tests := []struct {
+		name   string
+		policy string
+		actual models.Resolution
+		want   bool
+	}{
+		{name: "never keeps a mismatch", policy: RollbackNever, actual: models.Resolution{Width: 1280, Height: 720}, want: false},
+		{name: "on failure keeps a match", policy: RollbackOnFailure, actual: applied, want: false},
+		{name: "on failure restores a mismatch", policy: RollbackOnFailure, actual: models.Resolution{Width: 1280, Height: 720}, want: true},
+		{name: "on failure restores an unreadable stream", policy: RollbackOnFailure, actual: models.Resolution{}, want: true},
+		{name: "on mismatch keeps a match", policy: RollbackOnResolutionMismatch, actual: applied, want: false},
+		{name: "on mismatch restores a mismatch", policy: RollbackOnResolutionMismatch, actual: models.Resolution{Width: 1280, Height: 720}, want: true},
+		{name: "on mismatch keeps an unreadable stream", policy: RollbackOnResolutionMismatch, actual: models.Resolution{}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsRollback(tt.policy, applied, tt.actual); got != tt.want {
+			t.Errorf("%s: NeedsRollback() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
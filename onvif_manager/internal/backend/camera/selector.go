@@ -0,0 +1,156 @@
+package camera
+
+import (
+	"fmt"
+	"strings"
+
+	"onvif_manager/pkg/models"
+)
+
+// Selector keys
+const (
+	SelectorGroup = "group"
+	SelectorTag   = "tag"
+	SelectorID    = "id"
+	SelectorIP    = "ip"
+)
+
+// Selector picks cameras from the inventory by group, tag, ID or IP address.
+//
+// A selector expression is a comma separated list of terms that must all match,
+// each term being key=value or key!=value with the keys group, tag, id and ip.
+// A value may list alternatives separated by |, e.g.
+//
+//	group=lobby|garage,tag=outdoor,tag!=ptz
+//
+// selects the outdoor cameras of the lobby and garage groups that are not tagged ptz.
+type Selector struct {
+	terms []selectorTerm
+}
+
+// selectorTerm is one key=value or key!=value term of a selector
+type selectorTerm struct {
+	key    string
+	values []string
+	negate bool
+}
+
+// ParseSelector parses a selector expression. An empty expression selects every camera.
+func ParseSelector(expr string) (Selector, error) {
+	var selector Selector
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		term := selectorTerm{}
+		key, value, found := strings.Cut(part, "!=")
+		if found {
+			term.negate = true
+		} else if key, value, found = strings.Cut(part, "="); !found {
+			return Selector{}, fmt.Errorf("invalid selector term %q: expected key=value or key!=value", part)
+		}
+
+		term.key = strings.ToLower(strings.TrimSpace(key))
+		switch term.key {
+		case SelectorGroup, SelectorTag:
+		case SelectorID, SelectorIP:
+		default:
+			return Selector{}, fmt.Errorf("invalid selector term %q: unknown key %q (use %s, %s, %s or %s)",
+				part, term.key, SelectorGroup, SelectorTag, SelectorID, SelectorIP)
+		}
+
+		for _, alternative := range strings.Split(value, "|") {
+			alternative = strings.TrimSpace(alternative)
+			if alternative == "" {
+				return Selector{}, fmt.Errorf("invalid selector term %q: empty value", part)
+			}
+			term.values = append(term.values, alternative)
+		}
+		selector.terms = append(selector.terms, term)
+	}
+	return selector, nil
+}
+
+// LabelSelector builds a selector matching cameras in any of the groups and with any of the tags.
+// An empty list does not restrict the selection.
+func LabelSelector(groups, tags []string) Selector {
+	var selector Selector
+	if len(groups) > 0 {
+		selector.terms = append(selector.terms, selectorTerm{key: SelectorGroup, values: groups})
+	}
+	if len(tags) > 0 {
+		selector.terms = append(selector.terms, selectorTerm{key: SelectorTag, values: tags})
+	}
+	return selector
+}
+
+// And returns a selector matching the cameras matched by both selectors
+func (s Selector) And(other Selector) Selector {
+	return Selector{terms: append(append([]selectorTerm{}, s.terms...), other.terms...)}
+}
+
+// IsEmpty reports whether the selector matches every camera
+func (s Selector) IsEmpty() bool {
+	return len(s.terms) == 0
+}
+
+// String returns the selector as an expression
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s.terms))
+	for _, term := range s.terms {
+		operator := "="
+		if term.negate {
+			operator = "!="
+		}
+		parts = append(parts, term.key+operator+strings.Join(term.values, "|"))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Matches reports whether a camera matches every term of the selector
+func (s Selector) Matches(cam models.Camera) bool {
+	for _, term := range s.terms {
+		if term.matches(cam) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the camera has any of the values of the term
+func (t selectorTerm) matches(cam models.Camera) bool {
+	for _, value := range t.values {
+		switch t.key {
+		case SelectorGroup:
+			if containsLabel(cam.Groups, value) {
+				return true
+			}
+		case SelectorTag:
+			if containsLabel(cam.Tags, value) {
+				return true
+			}
+		case SelectorID:
+			if cam.ID == value {
+				return true
+			}
+		case SelectorIP:
+			if cam.IP == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Select returns the inventoried cameras matching the selector, in inventory order
+func (r *Registry) Select(selector Selector) []models.Camera {
+	selected := []models.Camera{}
+	for _, cam := range r.Cameras() {
+		if selector.Matches(cam) {
+			selected = append(selected, cam)
+		}
+	}
+	return selected
+}
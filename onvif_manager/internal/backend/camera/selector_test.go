@@ -0,0 +1,145 @@
+package camera
+
+import (
+	"reflect"
+	"testing"
+
+	"onvif_manager/pkg/models"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string // The selector as an expression
+		wantErr bool
+	}{
+		{expr: "", want: ""},
+		{expr: " , ", want: ""},
+		{expr: "group=lobby", want: "group=lobby"},
+		{expr: "Group = lobby | garage , tag!=ptz", want: "group=lobby|garage,tag!=ptz"},
+		{expr: "id=1|2,ip=10.0.0.5", want: "id=1|2,ip=10.0.0.5"},
+		{expr: "lobby", wantErr: true},
+		{expr: "site=lobby", wantErr: true},
+		{expr: "group=", wantErr: true},
+		{expr: "group=lobby||garage", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		selector, err := ParseSelector(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSelector(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if got := selector.String(); !tt.wantErr && got != tt.want {
+			t.Errorf("ParseSelector(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	lobby := models.Camera{ID: "1", IP: "10.0.0.1", Groups: []string{"lobby"}, Tags: []string{"outdoor", "ptz"}}
+	garage := models.Camera{ID: "2", IP: "10.0.0.2", Groups: []string{"Garage"}, Tags: []string{"outdoor"}}
+	office := models.Camera{ID: "3", IP: "10.0.0.3"}
+	cameras := []models.Camera{lobby, garage, office}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{expr: "", want: []string{"1", "2", "3"}},
+		{expr: "group=lobby", want: []string{"1"}},
+		{expr: "group=garage", want: []string{"2"}},
+		{expr: "group=lobby|garage,tag!=ptz", want: []string{"2"}},
+		{expr: "tag=outdoor", want: []string{"1", "2"}},
+		{expr: "group!=lobby", want: []string{"2", "3"}},
+		{expr: "id=3", want: []string{"3"}},
+		{expr: "ip=10.0.0.2|10.0.0.3", want: []string{"2", "3"}},
+		{expr: "tag=indoor", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		selector, err := ParseSelector(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q) error = %v", tt.expr, err)
+		}
+		matched := []string{}
+		for _, cam := range cameras {
+			if selector.Matches(cam) {
+				matched = append(matched, cam.ID)
+			}
+		}
+		if !reflect.DeepEqual(matched, tt.want) {
+			t.Errorf("%q matched %v, want %v", tt.expr, matched, tt.want)
+		}
+	}
+}
+
+func TestLabelSelector(t *testing.T) {
+	tests := []struct {
+		groups, tags []string
+		extra        string
+		want         string
+	}{
+		{want: ""},
+		{groups: []string{"lobby", "garage"}, want: "group=lobby|garage"},
+		{tags: []string{"ptz"}, want: "tag=ptz"},
+		{groups: []string{"lobby"}, tags: []string{"ptz"}, extra: "id!=4", want: "group=lobby,tag=ptz,id!=4"},
+	}
+
+	for _, tt := range tests {
+		extra, err := ParseSelector(tt.extra)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q) error = %v", tt.extra, err)
+		}
+		if got := LabelSelector(tt.groups, tt.tags).And(extra).String(); got != tt.want {
+			t.Errorf("LabelSelector(%v, %v).And(%q) = %q, want %q", tt.groups, tt.tags, tt.extra, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeLabels(t *testing.T) {
+	tests := []struct {
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{names: nil, want: []string{}},
+		{names: []string{" Lobby ", "lobby", "Garage"}, want: []string{"lobby", "garage"}},
+		{names: []string{""}, wantErr: true},
+		{names: []string{"floor 1"}, wantErr: true},
+		{names: []string{"a|b"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeLabels(tt.names)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeLabels(%q) error = %v, wantErr %v", tt.names, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeLabels(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestRegistrySelect(t *testing.T) {
+	store := &MemoryStore{}
+	store.Save([]models.Camera{
+		{ID: "1", Groups: []string{"lobby"}},
+		{ID: "2"},
+		{ID: "3", Groups: []string{"lobby"}},
+	})
+	registry := NewRegistry(store)
+	if err := registry.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	selector, _ := ParseSelector("group=lobby")
+	var ids []string
+	for _, cam := range registry.Select(selector) {
+		ids = append(ids, cam.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"1", "3"}) {
+		t.Errorf("Select() = %v, want the lobby cameras in inventory order", ids)
+	}
+}
@@ -0,0 +1,205 @@
+package camera
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"onvif_manager/pkg/models"
+
+	"github.com/videonext/onvif/profiles/devicemgmt"
+	"github.com/videonext/onvif/soap"
+)
+
+// deviceServicePath is the device service path fixed by the ONVIF core specification
+const deviceServicePath = "onvif/device_service"
+
+// defaultMediaServicePath is the media service path used when the camera does not advertise one
+const defaultMediaServicePath = "onvif/media_service"
+
+// Sources of the service endpoints of a camera
+const (
+	ServiceSourceGetServices     = "GetServices"
+	ServiceSourceGetCapabilities = "GetCapabilities"
+	ServiceSourceDefault         = "default"
+)
+
+// Namespaces that identify the services in a GetServices response
+const (
+	deviceNamespace  = "http://www.onvif.org/ver10/device/wsdl"
+	mediaNamespace   = "http://www.onvif.org/ver10/media/wsdl"
+	media2Namespace  = "http://www.onvif.org/ver20/media/wsdl"
+	imagingNamespace = "http://www.onvif.org/ver20/imaging/wsdl"
+	ptzNamespace     = "http://www.onvif.org/ver20/ptz/wsdl"
+	eventsNamespace  = "http://www.onvif.org/ver10/events/wsdl"
+)
+
+// ServiceEndpoints holds the XAddrs of the ONVIF services of a camera.
+// Services the camera does not offer are left empty.
+type ServiceEndpoints struct {
+	Device  string `json:"device"`
+	Media   string `json:"media"`
+	Media2  string `json:"media2,omitempty"`
+	Imaging string `json:"imaging,omitempty"`
+	PTZ     string `json:"ptz,omitempty"`
+	Events  string `json:"events,omitempty"`
+	// Source tells how the endpoints were found: GetServices, GetCapabilities or default
+	Source string `json:"source"`
+}
+
+// resolveServiceEndpoints asks the device service of a camera where its other services
+// are. GetServices is tried first and GetCapabilities second, for cameras that only
+// implement the older call. When both fail the media endpoint falls back to the
+// conventional path. A media path configured on the camera always wins.
+func resolveServiceEndpoints(client *soap.Client, cam models.Camera) (ServiceEndpoints, error) {
+	baseURL := cameraBaseURL(cam)
+	endpoints := ServiceEndpoints{Device: deviceServiceURL(cam)}
+	device := devicemgmt.NewDevice(client, endpoints.Device)
+
+	servicesErr := endpoints.fromServices(device, baseURL)
+	var capabilitiesErr error
+	if servicesErr != nil {
+		capabilitiesErr = endpoints.fromCapabilities(device, baseURL)
+	}
+
+	var err error
+	if endpoints.Source == "" {
+		endpoints.Source = ServiceSourceDefault
+		err = &resolveError{cameraID: cam.ID, services: servicesErr, capabilities: capabilitiesErr}
+	}
+
+	if cam.URL != "" {
+		endpoints.Media = baseURL + "/" + cam.URL
+	} else if endpoints.Media == "" {
+		// Media2-only cameras still get a guess for the Media service
+		endpoints.Media = baseURL + "/" + defaultMediaServicePath
+	}
+
+	return endpoints, err
+}
+
+// resolveError describes why the service endpoints of a camera could not be read
+type resolveError struct {
+	cameraID     string
+	services     error // GetServices
+	capabilities error // GetCapabilities
+}
+
+func (e *resolveError) Error() string {
+	return fmt.Sprintf("failed to resolve services of camera %s, using default media endpoint: GetServices: %v; GetCapabilities: %v",
+		e.cameraID, e.services, e.capabilities)
+}
+
+// noResponse reports whether neither request was answered by the camera
+func (e *resolveError) noResponse() bool {
+	return noResponse(e.services) && noResponse(e.capabilities)
+}
+
+// noResponse reports whether a request failed without the camera answering it, such as
+// when the connection was refused or timed out. SOAP faults and answers that lack what
+// was asked for are responses.
+func noResponse(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// fromServices fills the endpoints from the GetServices response of the device
+func (e *ServiceEndpoints) fromServices(device devicemgmt.Device, baseURL string) error {
+	resp, err := device.GetServices(&devicemgmt.GetServices{IncludeCapability: false})
+	if err != nil {
+		return err
+	}
+
+	for _, service := range resp.Service {
+		xaddr := relocateXAddr(string(service.XAddr), baseURL)
+		switch string(service.Namespace) {
+		case deviceNamespace:
+			e.Device = xaddr
+		case mediaNamespace:
+			e.Media = xaddr
+		case media2Namespace:
+			e.Media2 = xaddr
+		case imagingNamespace:
+			e.Imaging = xaddr
+		case ptzNamespace:
+			e.PTZ = xaddr
+		case eventsNamespace:
+			e.Events = xaddr
+		}
+	}
+
+	if e.Media == "" && e.Media2 == "" {
+		return fmt.Errorf("no media service advertised")
+	}
+	e.Source = ServiceSourceGetServices
+	return nil
+}
+
+// fromCapabilities fills the endpoints from the GetCapabilities response of the device.
+// Media2 is not reported by this call.
+func (e *ServiceEndpoints) fromCapabilities(device devicemgmt.Device, baseURL string) error {
+	resp, err := device.GetCapabilities(&devicemgmt.GetCapabilities{
+		Category: []devicemgmt.CapabilityCategory{"All"},
+	})
+	if err != nil {
+		return err
+	}
+
+	capabilities := resp.Capabilities
+	if capabilities.Media.XAddr == "" {
+		return fmt.Errorf("no media service advertised")
+	}
+
+	e.Media = relocateXAddr(string(capabilities.Media.XAddr), baseURL)
+	e.Imaging = relocateXAddr(string(capabilities.Imaging.XAddr), baseURL)
+	e.PTZ = relocateXAddr(string(capabilities.PTZ.XAddr), baseURL)
+	e.Events = relocateXAddr(string(capabilities.Events.XAddr), baseURL)
+	e.Source = ServiceSourceGetCapabilities
+	return nil
+}
+
+// deviceServiceURL returns the address of the camera's device service: the one it
+// advertised when it was discovered, or the path fixed by ONVIF on its IP and port
+func deviceServiceURL(cam models.Camera) string {
+	if cam.DeviceURL != "" {
+		return cam.DeviceURL
+	}
+	return cameraBaseURL(cam) + "/" + deviceServicePath
+}
+
+// cameraBaseURL returns the scheme and address the camera is reached at
+func cameraBaseURL(cam models.Camera) string {
+	if advertised, err := url.Parse(cam.DeviceURL); err == nil && advertised.Host != "" {
+		return advertised.Scheme + "://" + advertised.Host
+	}
+
+	port := cam.Port
+	if port == 0 {
+		port = 80
+	}
+	return "http://" + net.JoinHostPort(cam.IP, strconv.Itoa(port))
+}
+
+// relocateXAddr moves an advertised XAddr to the address the device service was
+// reached at, keeping its path. Cameras behind NAT or port forwarding advertise
+// their internal address, which is not reachable from here.
+func relocateXAddr(xaddr, baseURL string) string {
+	if xaddr == "" {
+		return ""
+	}
+
+	advertised, err := url.Parse(xaddr)
+	if err != nil || advertised.Host == "" {
+		return xaddr
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return xaddr
+	}
+
+	advertised.Scheme = base.Scheme
+	advertised.Host = base.Host
+	return advertised.String()
+}
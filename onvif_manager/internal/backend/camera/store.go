@@ -0,0 +1,157 @@
+package camera
+
+import (
+	"sync"
+
+	"onvif_manager/internal/backend/jsonstore"
+	"onvif_manager/pkg/models"
+)
+
+// InventoryLocation selects the inventory file
+var InventoryLocation = jsonstore.Location{EnvVar: "ONVIF_MANAGER_INVENTORY", FileName: "inventory.json"}
+
+// inventoryFileVersion is written into every inventory file so the format can evolve
+const inventoryFileVersion = 1
+
+// Store persists the camera inventory between runs of the application.
+type Store interface {
+	// Load returns all cameras saved in the store
+	Load() ([]models.Camera, error)
+	// Save replaces the stored inventory with the given cameras
+	Save(cameras []models.Camera) error
+	// Update loads the stored inventory, applies fn and saves and returns the result.
+	// Other processes sharing the store cannot change it in between.
+	Update(fn func(cameras []models.Camera) ([]models.Camera, error)) ([]models.Camera, error)
+}
+
+// MemoryStore keeps the inventory in memory only. It is used when persistence is disabled.
+type MemoryStore struct {
+	mu      sync.Mutex
+	cameras []models.Camera
+}
+
+// Load returns the cameras saved since the store was created
+func (s *MemoryStore) Load() ([]models.Camera, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.Camera{}, s.cameras...), nil
+}
+
+// Save replaces the cameras kept in memory
+func (s *MemoryStore) Save(cameras []models.Camera) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cameras = append([]models.Camera{}, cameras...)
+	return nil
+}
+
+// Update applies fn to the cameras kept in memory
+func (s *MemoryStore) Update(fn func(cameras []models.Camera) ([]models.Camera, error)) ([]models.Camera, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated, err := fn(append([]models.Camera{}, s.cameras...))
+	if err != nil {
+		return nil, err
+	}
+	s.cameras = append([]models.Camera{}, updated...)
+	return updated, nil
+}
+
+// inventoryFile is the on-disk layout of the JSON inventory
+type inventoryFile struct {
+	Version int             `json:"version"`
+	Cameras []models.Camera `json:"cameras"`
+}
+
+// inventoryFormat describes the inventory file to the JSON store
+var inventoryFormat = jsonstore.Format[inventoryFile]{
+	Name:    "inventory",
+	Version: inventoryFileVersion,
+	Empty: func() *inventoryFile {
+		return &inventoryFile{Version: inventoryFileVersion, Cameras: []models.Camera{}}
+	},
+	Fill: func(inventory *inventoryFile) {
+		if inventory.Cameras == nil {
+			inventory.Cameras = []models.Camera{}
+		}
+	},
+}
+
+// JSONFileStore stores the inventory as a JSON document on disk, shared by the CLI
+// and the server
+type JSONFileStore struct {
+	file *jsonstore.File[inventoryFile]
+}
+
+// NewJSONFileStore creates a store backed by the JSON file at path.
+// The file and its parent directory are created on the first save.
+func NewJSONFileStore(path string) *JSONFileStore {
+	return &JSONFileStore{file: jsonstore.New(path, inventoryFormat)}
+}
+
+// Path returns the location of the inventory file
+func (s *JSONFileStore) Path() string {
+	return s.file.Path()
+}
+
+// Stale reports whether another process changed the inventory file since this store
+// last read or wrote it
+func (s *JSONFileStore) Stale() bool {
+	return s.file.Stale()
+}
+
+// Load reads the inventory file. A missing file is treated as an empty inventory.
+func (s *JSONFileStore) Load() ([]models.Camera, error) {
+	var cameras []models.Camera
+	err := s.file.Read(func(inventory *inventoryFile) error {
+		cameras = inventory.Cameras
+		return nil
+	})
+	return cameras, err
+}
+
+// Save replaces the inventory file with the given cameras
+func (s *JSONFileStore) Save(cameras []models.Camera) error {
+	return s.file.Update(func(inventory *inventoryFile) error {
+		inventory.Version = inventoryFileVersion
+		inventory.Cameras = cameras
+		return nil
+	})
+}
+
+// Update reads the inventory file, applies fn and writes the result
+func (s *JSONFileStore) Update(fn func(cameras []models.Camera) ([]models.Camera, error)) ([]models.Camera, error) {
+	var updated []models.Camera
+	err := s.file.Update(func(inventory *inventoryFile) error {
+		var err error
+		updated, err = fn(inventory.Cameras)
+		if err != nil {
+			return err
+		}
+		inventory.Version = inventoryFileVersion
+		inventory.Cameras = updated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// NewStore creates a JSON file store at path, or the non-persistent store when path is empty
+func NewStore(path string) Store {
+	if path == "" {
+		return &MemoryStore{}
+	}
+	return NewJSONFileStore(path)
+}
+
+// OpenStoreFromEnv opens the store selected by InventoryLocation
+func OpenStoreFromEnv() (Store, error) {
+	path, err := InventoryLocation.PathFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(path), nil
+}
@@ -0,0 +1,81 @@
+package camera
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"onvif_manager/pkg/models"
+)
+
+func TestJSONFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	store := NewJSONFileStore(path)
+
+	cameras, err := store.Load()
+	if err != nil || len(cameras) != 0 {
+		t.Fatalf("Load() of a missing file = %v, %v, want an empty inventory", cameras, err)
+	}
+
+	saved := []models.Camera{
+		{ID: "1", IP: "10.0.0.1", Port: 80, Username: "admin", Password: "secret", Groups: []string{"lobby"}},
+		{ID: "2", IP: "10.0.0.2", Port: 8080},
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if cameras, err := NewJSONFileStore(path).Load(); err != nil || !reflect.DeepEqual(cameras, saved) {
+		t.Errorf("Load() = %+v, %v, want %+v", cameras, err, saved)
+	}
+
+	updated, err := store.Update(func(cameras []models.Camera) ([]models.Camera, error) {
+		return append(cameras, models.Camera{ID: "3", IP: "10.0.0.3"}), nil
+	})
+	if err != nil || len(updated) != 3 {
+		t.Fatalf("Update() = %v, %v, want 3 cameras", updated, err)
+	}
+	if cameras, _ := NewJSONFileStore(path).Load(); len(cameras) != 3 || cameras[2].ID != "3" {
+		t.Errorf("Load() after Update() = %+v, want the added camera", cameras)
+	}
+}
+
+func TestRegistryReloadsChangedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	server := NewRegistry(NewJSONFileStore(path))
+	if err := server.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cameras := server.Cameras(); len(cameras) != 0 {
+		t.Fatalf("Cameras() = %v, want none", cameras)
+	}
+
+	// The CLI adds a camera to the same file while the server runs
+	cli := NewJSONFileStore(path)
+	if _, err := cli.Update(func(cameras []models.Camera) ([]models.Camera, error) {
+		return append(cameras, models.Camera{ID: "1", IP: "10.0.0.1"}), nil
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if cam, found := server.Camera("1"); !found || cam.IP != "10.0.0.1" {
+		t.Errorf("Camera(\"1\") = %+v, %v, want the camera the CLI added", cam, found)
+	}
+
+	// Changes of the server start from the file, so the CLI's camera is kept
+	if err := server.Remove("1"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if cameras, _ := cli.Load(); len(cameras) != 0 {
+		t.Errorf("inventory file holds %+v after the server removed the camera, want none", cameras)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	if _, ok := NewStore("").(*MemoryStore); !ok {
+		t.Error("NewStore(\"\") is not the in-memory store")
+	}
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	if store, ok := NewStore(path).(*JSONFileStore); !ok || store.Path() != path {
+		t.Errorf("NewStore(%q) = %T, want a JSON file store at the path", path, store)
+	}
+}
@@ -0,0 +1,273 @@
+package camera
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"time"
+)
+
+// deviceWSDLNamespace prefixes the SOAP actions of the device service
+const deviceWSDLNamespace = "http://www.onvif.org/ver10/device/wsdl"
+
+// Date/time types reported and accepted by SetSystemDateAndTime
+const (
+	DateTimeTypeManual = "Manual"
+	DateTimeTypeNTP    = "NTP"
+)
+
+// TimeStatus is the date, time and NTP configuration of a camera
+type TimeStatus struct {
+	CameraTime      time.Time `json:"cameraTime"`
+	LocalTime       time.Time `json:"localTime"`
+	DriftSeconds    float64   `json:"driftSeconds"` // Camera time minus local time
+	DateTimeType    string    `json:"dateTimeType"` // Manual or NTP
+	TimeZone        string    `json:"timeZone,omitempty"`
+	DaylightSavings bool      `json:"daylightSavings"`
+	NTPFromDHCP     bool      `json:"ntpFromDhcp"`
+	NTPServers      []string  `json:"ntpServers"`
+}
+
+// Drift returns the camera time minus the local time
+func (s *TimeStatus) Drift() time.Duration {
+	return time.Duration(s.DriftSeconds * float64(time.Second))
+}
+
+// The device management package declares several of these elements in the wrong
+// namespace, so the date/time and NTP messages are defined here. Responses are
+// matched by local name only.
+
+type onvifDateTimeResponse struct {
+	Time struct {
+		Hour   int `xml:"Hour"`
+		Minute int `xml:"Minute"`
+		Second int `xml:"Second"`
+	} `xml:"Time"`
+	Date struct {
+		Year  int `xml:"Year"`
+		Month int `xml:"Month"`
+		Day   int `xml:"Day"`
+	} `xml:"Date"`
+}
+
+type getSystemDateAndTimeResponse struct {
+	XMLName           xml.Name `xml:"GetSystemDateAndTimeResponse"`
+	SystemDateAndTime struct {
+		DateTimeType    string `xml:"DateTimeType"`
+		DaylightSavings bool   `xml:"DaylightSavings"`
+		TimeZone        struct {
+			TZ string `xml:"TZ"`
+		} `xml:"TimeZone"`
+		UTCDateTime onvifDateTimeResponse `xml:"UTCDateTime"`
+	} `xml:"SystemDateAndTime"`
+}
+
+type networkHostResponse struct {
+	Type        string `xml:"Type"`
+	IPv4Address string `xml:"IPv4Address"`
+	IPv6Address string `xml:"IPv6Address"`
+	DNSname     string `xml:"DNSname"`
+}
+
+type getNTPResponse struct {
+	XMLName        xml.Name `xml:"GetNTPResponse"`
+	NTPInformation struct {
+		FromDHCP    bool                  `xml:"FromDHCP"`
+		NTPFromDHCP []networkHostResponse `xml:"NTPFromDHCP"`
+		NTPManual   []networkHostResponse `xml:"NTPManual"`
+	} `xml:"NTPInformation"`
+}
+
+type getSystemDateAndTimeRequest struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/device/wsdl GetSystemDateAndTime"`
+}
+
+type getNTPRequest struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/device/wsdl GetNTP"`
+}
+
+type onvifDateTimeRequest struct {
+	Time struct {
+		Hour   int `xml:"http://www.onvif.org/ver10/schema Hour"`
+		Minute int `xml:"http://www.onvif.org/ver10/schema Minute"`
+		Second int `xml:"http://www.onvif.org/ver10/schema Second"`
+	} `xml:"http://www.onvif.org/ver10/schema Time"`
+	Date struct {
+		Year  int `xml:"http://www.onvif.org/ver10/schema Year"`
+		Month int `xml:"http://www.onvif.org/ver10/schema Month"`
+		Day   int `xml:"http://www.onvif.org/ver10/schema Day"`
+	} `xml:"http://www.onvif.org/ver10/schema Date"`
+}
+
+type timeZoneRequest struct {
+	TZ string `xml:"http://www.onvif.org/ver10/schema TZ"`
+}
+
+type setSystemDateAndTimeRequest struct {
+	XMLName         xml.Name              `xml:"http://www.onvif.org/ver10/device/wsdl SetSystemDateAndTime"`
+	DateTimeType    string                `xml:"http://www.onvif.org/ver10/device/wsdl DateTimeType"`
+	DaylightSavings bool                  `xml:"http://www.onvif.org/ver10/device/wsdl DaylightSavings"`
+	TimeZone        *timeZoneRequest      `xml:"http://www.onvif.org/ver10/device/wsdl TimeZone,omitempty"`
+	UTCDateTime     *onvifDateTimeRequest `xml:"http://www.onvif.org/ver10/device/wsdl UTCDateTime,omitempty"`
+}
+
+type setSystemDateAndTimeResponse struct {
+	XMLName xml.Name `xml:"SetSystemDateAndTimeResponse"`
+}
+
+type networkHostRequest struct {
+	Type        string `xml:"http://www.onvif.org/ver10/schema Type"`
+	IPv4Address string `xml:"http://www.onvif.org/ver10/schema IPv4Address,omitempty"`
+	IPv6Address string `xml:"http://www.onvif.org/ver10/schema IPv6Address,omitempty"`
+	DNSname     string `xml:"http://www.onvif.org/ver10/schema DNSname,omitempty"`
+}
+
+type setNTPRequest struct {
+	XMLName   xml.Name             `xml:"http://www.onvif.org/ver10/device/wsdl SetNTP"`
+	FromDHCP  bool                 `xml:"http://www.onvif.org/ver10/device/wsdl FromDHCP"`
+	NTPManual []networkHostRequest `xml:"http://www.onvif.org/ver10/device/wsdl NTPManual,omitempty"`
+}
+
+type setNTPResponse struct {
+	XMLName xml.Name `xml:"SetNTPResponse"`
+}
+
+// GetTimeStatus reads the camera's date and time, time zone and NTP settings
+// and compares its clock with the local clock.
+func (c *CameraClient) GetTimeStatus() (*TimeStatus, error) {
+	dateTime := new(getSystemDateAndTimeResponse)
+	sent := time.Now()
+	if err := c.Client.Call(c.Services.Device, deviceWSDLNamespace+"/GetSystemDateAndTime", &getSystemDateAndTimeRequest{}, dateTime); err != nil {
+		return nil, fmt.Errorf("failed to get system date and time: %w", err)
+	}
+	received := time.Now()
+
+	system := dateTime.SystemDateAndTime
+	utc := system.UTCDateTime
+	if utc.Date.Year == 0 {
+		return nil, fmt.Errorf("camera did not report its UTC date and time")
+	}
+
+	cameraTime := time.Date(utc.Date.Year, time.Month(utc.Date.Month), utc.Date.Day,
+		utc.Time.Hour, utc.Time.Minute, utc.Time.Second, 0, time.UTC)
+	localTime := sent.Add(received.Sub(sent) / 2).UTC()
+
+	status := &TimeStatus{
+		CameraTime:      cameraTime,
+		LocalTime:       localTime,
+		DriftSeconds:    cameraTime.Add(500 * time.Millisecond).Sub(localTime).Round(100 * time.Millisecond).Seconds(),
+		DateTimeType:    system.DateTimeType,
+		TimeZone:        system.TimeZone.TZ,
+		DaylightSavings: system.DaylightSavings,
+		NTPServers:      []string{},
+	}
+
+	// Not every camera implements NTP configuration, so the date and time alone are still reported
+	ntp := new(getNTPResponse)
+	if err := c.Client.Call(c.Services.Device, deviceWSDLNamespace+"/GetNTP", &getNTPRequest{}, ntp); err == nil {
+		status.NTPFromDHCP = ntp.NTPInformation.FromDHCP
+		hosts := ntp.NTPInformation.NTPManual
+		if status.NTPFromDHCP {
+			hosts = ntp.NTPInformation.NTPFromDHCP
+		}
+		for _, host := range hosts {
+			if address := host.address(); address != "" {
+				status.NTPServers = append(status.NTPServers, address)
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// SetNTPServers configures the camera to take its time from the given NTP servers,
+// keeping its time zone and daylight saving settings.
+func (c *CameraClient) SetNTPServers(servers []string) error {
+	if len(servers) == 0 {
+		return fmt.Errorf("no NTP servers given")
+	}
+
+	status, err := c.GetTimeStatus()
+	if err != nil {
+		return err
+	}
+
+	request := &setNTPRequest{FromDHCP: false}
+	for _, server := range servers {
+		request.NTPManual = append(request.NTPManual, newNetworkHost(server))
+	}
+	if err := c.Client.Call(c.Services.Device, deviceWSDLNamespace+"/SetNTP", request, new(setNTPResponse)); err != nil {
+		return fmt.Errorf("failed to set NTP servers: %w", err)
+	}
+
+	if err := c.setSystemDateAndTime(DateTimeTypeNTP, status, nil); err != nil {
+		return err
+	}
+
+	// The camera's clock may jump once NTP takes over
+	c.MeasureClockOffset()
+	return nil
+}
+
+// SetSystemTime sets the camera's clock manually to the given time,
+// keeping its time zone and daylight saving settings.
+func (c *CameraClient) SetSystemTime(t time.Time) error {
+	status, err := c.GetTimeStatus()
+	if err != nil {
+		return err
+	}
+
+	if err := c.setSystemDateAndTime(DateTimeTypeManual, status, &t); err != nil {
+		return err
+	}
+
+	// Requests must now be signed with the corrected camera time
+	c.MeasureClockOffset()
+	return nil
+}
+
+// setSystemDateAndTime sends SetSystemDateAndTime, preserving the time zone of status
+func (c *CameraClient) setSystemDateAndTime(dateTimeType string, status *TimeStatus, t *time.Time) error {
+	request := &setSystemDateAndTimeRequest{
+		DateTimeType:    dateTimeType,
+		DaylightSavings: status.DaylightSavings,
+	}
+	if status.TimeZone != "" {
+		request.TimeZone = &timeZoneRequest{TZ: status.TimeZone}
+	}
+	if t != nil {
+		utc := t.UTC()
+		dateTime := &onvifDateTimeRequest{}
+		dateTime.Time.Hour, dateTime.Time.Minute, dateTime.Time.Second = utc.Hour(), utc.Minute(), utc.Second()
+		dateTime.Date.Year, dateTime.Date.Month, dateTime.Date.Day = utc.Year(), int(utc.Month()), utc.Day()
+		request.UTCDateTime = dateTime
+	}
+
+	if err := c.Client.Call(c.Services.Device, deviceWSDLNamespace+"/SetSystemDateAndTime", request, new(setSystemDateAndTimeResponse)); err != nil {
+		return fmt.Errorf("failed to set system date and time: %w", err)
+	}
+	return nil
+}
+
+// address returns the address of an NTP host entry
+func (h networkHostResponse) address() string {
+	switch {
+	case h.DNSname != "":
+		return h.DNSname
+	case h.IPv4Address != "":
+		return h.IPv4Address
+	default:
+		return h.IPv6Address
+	}
+}
+
+// newNetworkHost describes an NTP server given as an IP address or a host name
+func newNetworkHost(server string) networkHostRequest {
+	if ip := net.ParseIP(server); ip != nil {
+		if ip.To4() != nil {
+			return networkHostRequest{Type: "IPv4", IPv4Address: server}
+		}
+		return networkHostRequest{Type: "IPv6", IPv6Address: server}
+	}
+	return networkHostRequest{Type: "DNS", DNSname: server}
+}
@@ -0,0 +1,59 @@
+package camera
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewNetworkHost(t *testing.T) {
+	tests := []struct {
+		server string
+		want   networkHostRequest
+	}{
+		{server: "192.168.1.10", want: networkHostRequest{Type: "IPv4", IPv4Address: "192.168.1.10"}},
+		{server: "2001:db8::1", want: networkHostRequest{Type: "IPv6", IPv6Address: "2001:db8::1"}},
+		{server: "pool.ntp.org", want: networkHostRequest{Type: "DNS", DNSname: "pool.ntp.org"}},
+	}
+
+	for _, tt := range tests {
+		if got := newNetworkHost(tt.server); got != tt.want {
+			t.Errorf("newNetworkHost(%q) = %+v, want %+v", tt.server, got, tt.want)
+		}
+	}
+}
+
+func TestNetworkHostAddress(t *testing.T) {
+	tests := []struct {
+		host networkHostResponse
+		want string
+	}{
+		{host: networkHostResponse{Type: "DNS", DNSname: "pool.ntp.org", IPv4Address: "10.0.0.1"}, want: "pool.ntp.org"},
+		{host: networkHostResponse{Type: "IPv4", IPv4Address: "10.0.0.1"}, want: "10.0.0.1"},
+		{host: networkHostResponse{Type: "IPv6", IPv6Address: "2001:db8::1"}, want: "2001:db8::1"},
+		{host: networkHostResponse{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := tt.host.address(); got != tt.want {
+			t.Errorf("%+v.address() = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestTimeStatusDrift(t *testing.T) {
+	tests := []struct {
+		driftSeconds float64
+		want         time.Duration
+	}{
+		{driftSeconds: 0, want: 0},
+		{driftSeconds: 1.5, want: 1500 * time.Millisecond},
+		{driftSeconds: -90, want: -90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		status := &TimeStatus{DriftSeconds: tt.driftSeconds}
+		if got := status.Drift(); got != tt.want {
+			t.Errorf("Drift() of %v seconds = %s, want %s", tt.driftSeconds, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package capacity
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/ffmpeg"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/internal/backend/templates"
+	"onvif_manager/pkg/models"
+)
+
+// Environment variables that set the default budgets
+const (
+	BandwidthBudgetEnvVar = "ONVIF_MANAGER_BANDWIDTH_BUDGET" // Aggregate bitrate in Mbit/s
+	StorageBudgetEnvVar   = "ONVIF_MANAGER_STORAGE_BUDGET"   // Recording storage in GB
+)
+
+// DefaultMeasureSeconds is how long each stream is sampled when bitrates are measured
+const DefaultMeasureSeconds = 5
+
+// DefaultRetentionDays are the retention periods storage is computed for when none are given
+var DefaultRetentionDays = []int{7, 30}
+
+// ProfilesAll plans every profile with a video encoder instead of selected profiles
+const ProfilesAll = "all"
+
+// Bases of the bitrate a stream is counted with
+const (
+	BasisConfigured = "configured" // Bitrate limit of the target or current configuration
+	BasisMeasured   = "measured"   // Average bitrate measured on the stream
+)
+
+// Budget is the network and storage capacity a plan is checked against; zero values are not checked
+type Budget struct {
+	BandwidthMbps float64 `json:"bandwidthMbps,omitempty"`
+	StorageGB     float64 `json:"storageGB,omitempty"` // For each retention period
+}
+
+// DefaultBudget returns the budgets set in the environment
+func DefaultBudget() Budget {
+	var budget Budget
+	if value, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(BandwidthBudgetEnvVar)), 64); err == nil && value > 0 {
+		budget.BandwidthMbps = value
+	}
+	if value, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(StorageBudgetEnvVar)), 64); err == nil && value > 0 {
+		budget.StorageGB = value
+	}
+	return budget
+}
+
+// WithOverrides returns a copy of the budget with the non-zero values applied on top
+func (b Budget) WithOverrides(bandwidthMbps, storageGB float64) Budget {
+	if bandwidthMbps > 0 {
+		b.BandwidthMbps = bandwidthMbps
+	}
+	if storageGB > 0 {
+		b.StorageGB = storageGB
+	}
+	return b
+}
+
+// Request describes what to plan. Without Streams and Template the current
+// configuration of the selected Profiles is planned.
+type Request struct {
+	Streams        []templates.Stream  // Target configuration of every camera
+	Template       *templates.Template // Target configuration resolved for the model of each camera
+	Profiles       []string            // Profiles planned with their current configuration; main when empty
+	Measure        bool                // Measure the bitrate of each stream
+	MeasureSeconds int                 // Seconds each stream is sampled; DefaultMeasureSeconds when 0
+	RetentionDays  []int               // DefaultRetentionDays when empty
+	Budget         Budget
+}
+
+// StreamLoad is the planned load of one stream of a camera
+type StreamLoad struct {
+	Profile        string `json:"profile"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	FPS            int    `json:"fps"`
+	Encoding       string `json:"encoding,omitempty"`
+	Changed        bool   `json:"changed"`                // The target changes resolution, frame rate, bitrate or encoding
+	ConfiguredKbps int    `json:"configuredKbps"`         // Bitrate limit of the target or current configuration
+	MeasuredKbps   int    `json:"measuredKbps,omitempty"` // Average bitrate of the current stream
+	BitrateKbps    int    `json:"bitrateKbps"`            // Bitrate counted in the totals
+	Basis          string `json:"basis"`
+	Warning        string `json:"warning,omitempty"`
+}
+
+// CameraLoad is the planned load of one camera
+type CameraLoad struct {
+	CameraID        string       `json:"cameraId"`
+	Model           string       `json:"model,omitempty"`   // Set when a template is planned
+	Variant         string       `json:"variant,omitempty"` // Template variant used
+	Success         bool         `json:"success"`
+	Error           string       `json:"error,omitempty"`
+	Streams         []StreamLoad `json:"streams"`
+	BitrateKbps     int          `json:"bitrateKbps"`
+	StorageGBPerDay float64      `json:"storageGBPerDay"`
+}
+
+// RetentionStorage is the recording storage needed to keep a number of days
+type RetentionStorage struct {
+	Days int     `json:"days"`
+	GB   float64 `json:"gb"`
+}
+
+// Totals is the aggregate load of a set of cameras
+type Totals struct {
+	Cameras     int                `json:"cameras"`
+	Streams     int                `json:"streams"`
+	BitrateKbps int                `json:"bitrateKbps"`
+	BitrateMbps float64            `json:"bitrateMbps"`
+	Storage     []RetentionStorage `json:"storage"`
+}
+
+// GroupLoad is the aggregate load of the planned cameras of a camera group
+type GroupLoad struct {
+	Group string `json:"group"`
+	Totals
+}
+
+// Plan is the outcome of a capacity planning run
+type Plan struct {
+	CreatedAt     time.Time              `json:"createdAt"`
+	Mode          string                 `json:"mode"` // current, target or template
+	CameraOrder   []string               `json:"cameraOrder"`
+	Cameras       map[string]*CameraLoad `json:"cameras"`
+	Groups        []GroupLoad            `json:"groups"`
+	Total         Totals                 `json:"total"`
+	RetentionDays []int                  `json:"retentionDays"`
+	Budget        Budget                 `json:"budget"`
+	Warnings      []string               `json:"warnings"`
+	FailedCams    int                    `json:"failedCams"`
+}
+
+// StorageGB returns the storage in GB needed to record a bitrate for a number of days
+func StorageGB(bitrateKbps int, days int) float64 {
+	return float64(bitrateKbps) * 1000 / 8 * 86400 * float64(days) / 1e9
+}
+
+// Run computes the network load and recording storage of the cameras through the bounded
+// worker pool. Cameras that cannot be read are reported and left out of the totals.
+func Run(ctx context.Context, cameraIDs []string, request Request, opts pool.Options) *Plan {
+	if len(request.RetentionDays) == 0 {
+		request.RetentionDays = DefaultRetentionDays
+	}
+	if request.MeasureSeconds <= 0 {
+		request.MeasureSeconds = DefaultMeasureSeconds
+	}
+
+	plan := &Plan{
+		CreatedAt:     time.Now(),
+		Mode:          "current",
+		CameraOrder:   cameraIDs,
+		Cameras:       make(map[string]*CameraLoad),
+		Groups:        []GroupLoad{},
+		RetentionDays: request.RetentionDays,
+		Budget:        request.Budget,
+		Warnings:      []string{},
+	}
+	switch {
+	case request.Template != nil:
+		plan.Mode = "template"
+	case len(request.Streams) > 0:
+		plan.Mode = "target"
+	}
+	log.Printf("Planning capacity of %d cameras (%s configuration, measure %t, concurrency %d, timeout %s)",
+		len(cameraIDs), plan.Mode, request.Measure, opts.Concurrency, opts.Timeout)
+
+	loads := pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) *CameraLoad {
+			return planCamera(cameraID, request)
+		},
+		func(cameraID string, err error) *CameraLoad {
+			return &CameraLoad{CameraID: cameraID, Error: fmt.Sprintf("Planning aborted: %v", err), Streams: []StreamLoad{}}
+		})
+
+	groups := make(map[string]*GroupLoad)
+	for i, cameraID := range cameraIDs {
+		load := loads[i]
+		plan.Cameras[cameraID] = load
+		if !load.Success {
+			plan.FailedCams++
+			continue
+		}
+
+		plan.Total.add(load)
+		cam, _ := camera.DefaultRegistry().Camera(cameraID)
+		for _, group := range cam.Groups {
+			if groups[group] == nil {
+				groups[group] = &GroupLoad{Group: group}
+			}
+			groups[group].add(load)
+		}
+	}
+
+	plan.Total.finish(request.RetentionDays)
+	for _, group := range groups {
+		group.finish(request.RetentionDays)
+		plan.Groups = append(plan.Groups, *group)
+	}
+	sort.Slice(plan.Groups, func(i, j int) bool {
+		return plan.Groups[i].Group < plan.Groups[j].Group
+	})
+
+	plan.checkBudget()
+	return plan
+}
+
+// add counts a camera in the totals
+func (t *Totals) add(load *CameraLoad) {
+	t.Cameras++
+	t.Streams += len(load.Streams)
+	t.BitrateKbps += load.BitrateKbps
+}
+
+// finish computes the bitrate in Mbit/s and the storage of every retention period
+func (t *Totals) finish(retentionDays []int) {
+	t.BitrateMbps = float64(t.BitrateKbps) / 1000
+	t.Storage = make([]RetentionStorage, 0, len(retentionDays))
+	for _, days := range retentionDays {
+		t.Storage = append(t.Storage, RetentionStorage{Days: days, GB: StorageGB(t.BitrateKbps, days)})
+	}
+}
+
+// checkBudget adds a warning for cameras that were not counted and for every exceeded budget
+func (p *Plan) checkBudget() {
+	if p.FailedCams > 0 {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%d camera(s) could not be read and are not counted", p.FailedCams))
+	}
+	for _, cameraID := range p.CameraOrder {
+		for _, stream := range p.Cameras[cameraID].Streams {
+			if stream.BitrateKbps == 0 {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("camera %s, profile %s: no bitrate known, not counted", cameraID, stream.Profile))
+			}
+		}
+	}
+
+	if budget := p.Budget.BandwidthMbps; budget > 0 && p.Total.BitrateMbps > budget {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("aggregate bitrate of %.1f Mbit/s exceeds the bandwidth budget of %.1f Mbit/s", p.Total.BitrateMbps, budget))
+	}
+	if budget := p.Budget.StorageGB; budget > 0 {
+		for _, storage := range p.Total.Storage {
+			if storage.GB > budget {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("%d days of recordings need %.0f GB, exceeding the storage budget of %.0f GB", storage.Days, storage.GB, budget))
+			}
+		}
+	}
+}
+
+// planCamera reads the profiles of a camera and computes the load of its planned streams
+func planCamera(cameraID string, request Request) *CameraLoad {
+	load := &CameraLoad{CameraID: cameraID, Streams: []StreamLoad{}}
+
+	unlock := camera.LockCamera(cameraID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		load.Error = err.Error()
+		return load
+	}
+
+	targets := request.Streams
+	if request.Template != nil {
+		deviceInfo, err := client.GetDeviceInformation()
+		if err != nil {
+			load.Error = fmt.Sprintf("failed to read the camera model: %v", err)
+			return load
+		}
+		load.Model = deviceInfo.Model
+		targets, load.Variant = request.Template.Resolve(deviceInfo.Model)
+	}
+
+	profiles, err := camera.GetProfiles(client)
+	if err != nil {
+		load.Error = err.Error()
+		return load
+	}
+
+	planned, err := plannedProfiles(profiles, targets, request.Profiles)
+	if err != nil {
+		load.Error = err.Error()
+		return load
+	}
+
+	for i, profile := range planned {
+		current, err := camera.GetCurrentConfig(client, profile.ConfigToken)
+		if err != nil {
+			load.Error = fmt.Sprintf("profile %s: %v", profile, err)
+			return load
+		}
+
+		stream := planStream(profile, current, targets, i)
+		if request.Measure {
+			measureStream(client, profile, request.MeasureSeconds, &stream)
+		}
+		load.Streams = append(load.Streams, stream)
+		load.BitrateKbps += stream.BitrateKbps
+	}
+
+	load.StorageGBPerDay = StorageGB(load.BitrateKbps, 1)
+	load.Success = true
+	return load
+}
+
+// plannedProfiles returns the profiles to plan: those of the target streams, in their order,
+// or the selected profiles when the current configuration is planned
+func plannedProfiles(profiles []camera.Profile, targets []templates.Stream, selectors []string) ([]camera.Profile, error) {
+	if len(targets) == 0 {
+		if len(selectors) == 0 {
+			selectors = []string{camera.ProfileRoleMain}
+		}
+		if len(selectors) == 1 && strings.EqualFold(selectors[0], ProfilesAll) {
+			var planned []camera.Profile
+			for _, profile := range profiles {
+				if profile.HasEncoder() {
+					planned = append(planned, profile)
+				}
+			}
+			return planned, nil
+		}
+	} else {
+		selectors = make([]string, len(targets))
+		for i, target := range targets {
+			selectors[i] = target.Profile
+		}
+	}
+
+	planned := make([]camera.Profile, 0, len(selectors))
+	for _, selector := range selectors {
+		profile, err := camera.SelectProfile(profiles, selector)
+		if err != nil {
+			return nil, err
+		}
+		if !profile.HasEncoder() {
+			return nil, fmt.Errorf("profile %s has no video encoder", profile)
+		}
+		planned = append(planned, profile)
+	}
+	return planned, nil
+}
+
+// planStream computes the configured load of a stream: the target settings over the current
+// configuration when there is a target, or the current configuration
+func planStream(profile camera.Profile, current models.EncoderConfig, targets []templates.Stream, index int) StreamLoad {
+	intended := current
+	if index < len(targets) {
+		target := targets[index]
+		if target.Width > 0 {
+			intended.Resolution = models.Resolution{Width: target.Width, Height: target.Height}
+		}
+		if target.FPS > 0 {
+			intended.FPS = target.FPS
+		}
+		if target.Bitrate > 0 {
+			intended.Bitrate = target.Bitrate
+		}
+		if target.Encoding != "" {
+			intended.Encoding = camera.NormalizeEncoding(target.Encoding)
+		}
+	}
+
+	stream := StreamLoad{
+		Profile:        profile.String(),
+		Width:          intended.Resolution.Width,
+		Height:         intended.Resolution.Height,
+		FPS:            intended.FPS,
+		Encoding:       intended.Encoding,
+		Changed:        intended.Resolution != current.Resolution || intended.FPS != current.FPS || intended.Bitrate != current.Bitrate || intended.Encoding != current.Encoding,
+		ConfiguredKbps: intended.Bitrate,
+		BitrateKbps:    intended.Bitrate,
+		Basis:          BasisConfigured,
+	}
+	return stream
+}
+
+// measureStream samples the bitrate of the current stream of a profile. The measured
+// bitrate is counted unless the target changes the stream, in which case it is only reported.
+func measureStream(client *camera.CameraClient, profile camera.Profile, seconds int, stream *StreamLoad) {
+	uri, err := client.GetAuthenticatedStreamURI(profile.Token)
+	if err != nil {
+		stream.Warning = fmt.Sprintf("bitrate not measured: %v", err)
+		return
+	}
+	info, err := ffmpeg.AnalyzeRTSPStream(uri, 0, seconds)
+	if err != nil {
+		stream.Warning = fmt.Sprintf("bitrate not measured: %v", err)
+		return
+	}
+
+	measured := info.Bitrate
+	if len(info.BitrateSamples) > 0 {
+		total := 0
+		for _, sample := range info.BitrateSamples {
+			total += sample
+		}
+		measured = total / len(info.BitrateSamples)
+	}
+	stream.MeasuredKbps = measured
+
+	if stream.Changed {
+		stream.Warning = "measured on the current configuration, which the target changes; the configured bitrate is counted"
+		return
+	}
+	stream.BitrateKbps = measured
+	stream.Basis = BasisMeasured
+}
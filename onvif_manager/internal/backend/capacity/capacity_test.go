@@ -0,0 +1,137 @@
+package capacity
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/templates"
+	"onvif_manager/pkg/models"
+)
+
+func TestStorageGB(t *testing.T) {
+	tests := []struct {
+		bitrateKbps int
+		days        int
+		want        float64
+	}{
+		{bitrateKbps: 0, days: 30, want: 0},
+		{bitrateKbps: 8000, days: 1, want: 86.4},
+		{bitrateKbps: 4096, days: 7, want: 309.6576},
+		{bitrateKbps: 4096, days: 30, want: 1327.104},
+	}
+
+	for _, tt := range tests {
+		if got := StorageGB(tt.bitrateKbps, tt.days); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("StorageGB(%d, %d) = %g, want %g", tt.bitrateKbps, tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		bitrateKbps  int
+		budget       Budget
+		wantWarnings []string
+	}{
+		{name: "no budget", bitrateKbps: 100000},
+		{name: "within budget", bitrateKbps: 20000, budget: Budget{BandwidthMbps: 25, StorageGB: 7000}},
+		{name: "bandwidth exceeded", bitrateKbps: 30000, budget: Budget{BandwidthMbps: 25}, wantWarnings: []string{"aggregate bitrate of 30.0 Mbit/s"}},
+		{name: "storage exceeded for 30 days", bitrateKbps: 20000, budget: Budget{StorageGB: 5000}, wantWarnings: []string{"30 days of recordings need 6480 GB"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &Plan{
+				CameraOrder: []string{"1"},
+				Cameras:     map[string]*CameraLoad{"1": {CameraID: "1", Success: true, Streams: []StreamLoad{{BitrateKbps: tt.bitrateKbps}}, BitrateKbps: tt.bitrateKbps}},
+				Budget:      tt.budget,
+				Warnings:    []string{},
+			}
+			plan.Total.add(plan.Cameras["1"])
+			plan.Total.finish([]int{3, 30})
+			plan.checkBudget()
+
+			if len(plan.Warnings) != len(tt.wantWarnings) {
+				t.Fatalf("warnings = %q, want %d", plan.Warnings, len(tt.wantWarnings))
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(plan.Warnings[i], want) {
+					t.Errorf("warning %d = %q, want it to mention %q", i, plan.Warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlannedProfiles(t *testing.T) {
+	profiles := []camera.Profile{
+		{Token: "p1", Name: "MainStream", Role: camera.ProfileRoleMain, ConfigToken: "v1"},
+		{Token: "p2", Name: "SubStream", Role: camera.ProfileRoleSub, ConfigToken: "v2"},
+		{Token: "p3", Name: "Audio"},
+	}
+
+	tests := []struct {
+		name      string
+		targets   []templates.Stream
+		selectors []string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "main by default", want: []string{"p1"}},
+		{name: "selected", selectors: []string{"sub", "main"}, want: []string{"p2", "p1"}},
+		{name: "all with an encoder", selectors: []string{"ALL"}, want: []string{"p1", "p2"}},
+		{name: "targets over selectors", targets: []templates.Stream{{Profile: "sub"}, {}}, selectors: []string{"all"}, want: []string{"p2", "p1"}},
+		{name: "no encoder", selectors: []string{"p3"}, wantErr: true},
+		{name: "unknown profile", selectors: []string{"third"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		planned, err := plannedProfiles(profiles, tt.targets, tt.selectors)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: plannedProfiles() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		var tokens []string
+		for _, profile := range planned {
+			tokens = append(tokens, profile.Token)
+		}
+		if !reflect.DeepEqual(tokens, tt.want) {
+			t.Errorf("%s: plannedProfiles() = %v, want %v", tt.name, tokens, tt.want)
+		}
+	}
+}
+
+func TestPlanStream(t *testing.T) {
+	profile := camera.Profile{Token: "p1", Name: "MainStream", Role: camera.ProfileRoleMain, ConfigToken: "v1"}
+	current := models.EncoderConfig{Resolution: models.Resolution{Width: 1920, Height: 1080}, FPS: 25, Bitrate: 4096, Encoding: "H264"}
+
+	tests := []struct {
+		name        string
+		targets     []templates.Stream
+		wantWidth   int
+		wantBitrate int
+		wantChanged bool
+	}{
+		{name: "current configuration", wantWidth: 1920, wantBitrate: 4096},
+		{name: "same as current", targets: []templates.Stream{{Width: 1920, Height: 1080, Encoding: "h.264"}}, wantWidth: 1920, wantBitrate: 4096},
+		{name: "lower bitrate", targets: []templates.Stream{{Bitrate: 2048}}, wantWidth: 1920, wantBitrate: 2048, wantChanged: true},
+		{name: "lower resolution", targets: []templates.Stream{{Width: 1280, Height: 720}}, wantWidth: 1280, wantBitrate: 4096, wantChanged: true},
+	}
+
+	for _, tt := range tests {
+		stream := planStream(profile, current, tt.targets, 0)
+		if stream.Width != tt.wantWidth || stream.BitrateKbps != tt.wantBitrate || stream.Changed != tt.wantChanged {
+			t.Errorf("%s: planStream() = %+v, want width %d, bitrate %d, changed %v", tt.name, stream, tt.wantWidth, tt.wantBitrate, tt.wantChanged)
+		}
+		if stream.Basis != BasisConfigured || stream.ConfiguredKbps != stream.BitrateKbps {
+			t.Errorf("%s: planStream() counts %d kbps on basis %s", tt.name, stream.BitrateKbps, stream.Basis)
+		}
+	}
+}
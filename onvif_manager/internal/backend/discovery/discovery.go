@@ -0,0 +1,325 @@
+package discovery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MulticastAddress is the WS-Discovery multicast group and port ONVIF devices listen on
+const MulticastAddress = "239.255.255.250:3702"
+
+// DefaultTimeout is how long a probe waits for devices to answer
+const DefaultTimeout = 3 * time.Second
+
+// maxMessageSize bounds a single ProbeMatches datagram
+const maxMessageSize = 64 * 1024
+
+// probeTemplate is the WS-Discovery Probe for ONVIF video transmitters.
+// The only placeholder is the message ID that answers must relate to.
+const probeTemplate = `<?xml version="1.0" encoding="UTF-8"?>` +
+	`<e:Envelope xmlns:e="http://www.w3.org/2003/05/soap-envelope"` +
+	` xmlns:w="http://schemas.xmlsoap.org/ws/2004/08/addressing"` +
+	` xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery"` +
+	` xmlns:dn="http://www.onvif.org/ver10/network/wsdl">` +
+	`<e:Header>` +
+	`<w:MessageID>%s</w:MessageID>` +
+	`<w:To e:mustUnderstand="true">urn:schemas-xmlsoap-org:ws:2005:04:discovery</w:To>` +
+	`<w:Action e:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2005/04/discovery/Probe</w:Action>` +
+	`</e:Header>` +
+	`<e:Body><d:Probe><d:Types>dn:NetworkVideoTransmitter</d:Types></d:Probe></e:Body>` +
+	`</e:Envelope>`
+
+// Options controls a discovery probe
+type Options struct {
+	// Timeout is how long to wait for answers (DefaultTimeout when zero)
+	Timeout time.Duration
+	// Address is where the probe is sent (MulticastAddress when empty).
+	// A unicast address probes a single device or a local test responder.
+	Address string
+}
+
+// Device is an ONVIF device that answered a probe
+type Device struct {
+	EndpointReference string   `json:"endpointReference"`
+	XAddrs            []string `json:"xaddrs"`
+	Types             []string `json:"types"`
+	Scopes            []string `json:"scopes"`
+	Name              string   `json:"name"`
+	Hardware          string   `json:"hardware"`
+	Location          string   `json:"location"`
+	XAddr             string   `json:"xaddr"` // Device service address IP and Port are taken from
+	IP                string   `json:"ip"`
+	Port              int      `json:"port"`
+}
+
+// probeMatchEnvelope is the part of a ProbeMatches message we read.
+// Elements are matched by local name so any namespace prefix works.
+type probeMatchEnvelope struct {
+	RelatesTo string `xml:"Header>RelatesTo"`
+	Matches   []struct {
+		Address string `xml:"EndpointReference>Address"`
+		Types   string `xml:"Types"`
+		Scopes  string `xml:"Scopes"`
+		XAddrs  string `xml:"XAddrs"`
+	} `xml:"Body>ProbeMatches>ProbeMatch"`
+}
+
+// Probe sends a WS-Discovery Probe and collects the devices that answer until
+// the timeout expires or ctx is cancelled. A multicast probe is sent from every
+// IPv4 interface so devices on all attached networks are found. Devices are
+// returned sorted by IP address, each listed once.
+func Probe(ctx context.Context, opts Options) ([]Device, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Address == "" {
+		opts.Address = MulticastAddress
+	}
+
+	target, err := net.ResolveUDPAddr("udp4", opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid discovery address %s: %w", opts.Address, err)
+	}
+
+	localAddrs := []net.IP{nil}
+	if target.IP.IsMulticast() {
+		if addrs := interfaceAddrs(); len(addrs) > 0 {
+			localAddrs = addrs
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		devices = make(map[string]Device)
+		errs    []string
+	)
+
+	for _, localAddr := range localAddrs {
+		wg.Add(1)
+		go func(localAddr net.IP) {
+			defer wg.Done()
+			found, err := probeFrom(ctx, localAddr, target)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			for _, device := range found {
+				key := device.EndpointReference
+				if key == "" && len(device.XAddrs) > 0 {
+					key = device.XAddrs[0]
+				}
+				if _, exists := devices[key]; !exists {
+					devices[key] = device
+				}
+			}
+		}(localAddr)
+	}
+	wg.Wait()
+
+	// Only fail when no interface could probe at all
+	if len(errs) == len(localAddrs) {
+		return nil, fmt.Errorf("discovery probe failed: %s", strings.Join(errs, "; "))
+	}
+
+	result := make([]Device, 0, len(devices))
+	for _, device := range devices {
+		result = append(result, device)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].IP != result[j].IP {
+			return compareIPs(result[i].IP, result[j].IP) < 0
+		}
+		return result[i].EndpointReference < result[j].EndpointReference
+	})
+
+	return result, nil
+}
+
+// probeFrom sends one probe from localAddr (any address when nil) and reads answers until ctx ends
+func probeFrom(ctx context.Context, localAddr net.IP, target *net.UDPAddr) ([]Device, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: localAddr})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open discovery socket on %v: %w", localAddr, err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed to set discovery deadline: %w", err)
+	}
+
+	// Unblock the read below as soon as the caller gives up
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	messageID := "uuid:" + newUUID()
+	if _, err := conn.WriteToUDP([]byte(fmt.Sprintf(probeTemplate, messageID)), target); err != nil {
+		return nil, fmt.Errorf("failed to send discovery probe from %v: %w", localAddr, err)
+	}
+
+	var devices []Device
+	buffer := make([]byte, maxMessageSize)
+	for {
+		n, _, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return devices, nil
+			}
+			return devices, fmt.Errorf("failed to read discovery response: %w", err)
+		}
+
+		// Ignore anything that is not an answer to this probe
+		matches, err := parseProbeMatches(buffer[:n], messageID)
+		if err != nil {
+			continue
+		}
+		devices = append(devices, matches...)
+	}
+}
+
+// parseProbeMatches decodes a ProbeMatches message sent in reply to messageID
+func parseProbeMatches(data []byte, messageID string) ([]Device, error) {
+	var envelope probeMatchEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid discovery response: %w", err)
+	}
+	if strings.TrimSpace(envelope.RelatesTo) != messageID {
+		return nil, errors.New("discovery response is not related to this probe")
+	}
+
+	devices := make([]Device, 0, len(envelope.Matches))
+	for _, match := range envelope.Matches {
+		device := Device{
+			EndpointReference: strings.TrimSpace(match.Address),
+			XAddrs:            strings.Fields(match.XAddrs),
+			Types:             strings.Fields(match.Types),
+			Scopes:            strings.Fields(match.Scopes),
+		}
+		if len(device.XAddrs) == 0 {
+			continue
+		}
+
+		for _, scope := range device.Scopes {
+			switch {
+			case strings.HasPrefix(scope, "onvif://www.onvif.org/name/"):
+				device.Name = scopeValue(scope, "onvif://www.onvif.org/name/")
+			case strings.HasPrefix(scope, "onvif://www.onvif.org/hardware/"):
+				device.Hardware = scopeValue(scope, "onvif://www.onvif.org/hardware/")
+			case strings.HasPrefix(scope, "onvif://www.onvif.org/location/"):
+				device.Location = scopeValue(scope, "onvif://www.onvif.org/location/")
+			}
+		}
+
+		device.XAddr, device.IP, device.Port = deviceXAddr(device.XAddrs)
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// scopeValue returns the readable value of an ONVIF scope URI
+func scopeValue(scope, prefix string) string {
+	value := strings.TrimPrefix(scope, prefix)
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	return strings.ReplaceAll(value, "_", " ")
+}
+
+// deviceXAddr returns the device service XAddr to use and its host and port, preferring an IPv4 XAddr
+func deviceXAddr(xaddrs []string) (string, string, int) {
+	var fallbackXAddr, fallbackIP string
+	var fallbackPort int
+
+	for _, xaddr := range xaddrs {
+		parsed, err := url.Parse(xaddr)
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+
+		port := 80
+		if parsed.Scheme == "https" {
+			port = 443
+		}
+		if p, err := strconv.Atoi(parsed.Port()); err == nil {
+			port = p
+		}
+
+		if ip := net.ParseIP(parsed.Hostname()); ip != nil && ip.To4() != nil {
+			return xaddr, parsed.Hostname(), port
+		}
+		if fallbackIP == "" {
+			fallbackXAddr, fallbackIP, fallbackPort = xaddr, parsed.Hostname(), port
+		}
+	}
+
+	return fallbackXAddr, fallbackIP, fallbackPort
+}
+
+// interfaceAddrs returns the IPv4 addresses of the interfaces that can send multicast
+func interfaceAddrs() []net.IP {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	var addrs []net.IP
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range ifaceAddrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				addrs = append(addrs, ipNet.IP)
+			}
+		}
+	}
+	return addrs
+}
+
+// compareIPs orders IPv4 addresses numerically and anything else as text
+func compareIPs(a, b string) int {
+	ipA, ipB := net.ParseIP(a).To4(), net.ParseIP(b).To4()
+	if ipA != nil && ipB != nil {
+		for i := range ipA {
+			if ipA[i] != ipB[i] {
+				return int(ipA[i]) - int(ipB[i])
+			}
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// newUUID returns a random (version 4) UUID for the probe message ID
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to the clock
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
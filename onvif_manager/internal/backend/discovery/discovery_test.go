@@ -0,0 +1,150 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// probeMatchTemplate is a ProbeMatches answer; the placeholders are the message ID it
+// relates to and the XAddrs of the device
+const probeMatchTemplate = `<?xml version="1.0" encoding="UTF-8"?>` +
+	`<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://www.w3.org/2003/05/soap-envelope"` +
+	` xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing"` +
+	` xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery">` +
+	`<SOAP-ENV:Header><wsa:RelatesTo>%s</wsa:RelatesTo></SOAP-ENV:Header>` +
+	`<SOAP-ENV:Body><d:ProbeMatches><d:ProbeMatch>` +
+	`<wsa:EndpointReference><wsa:Address>urn:uuid:%s</wsa:Address></wsa:EndpointReference>` +
+	`<d:Types>dn:NetworkVideoTransmitter</d:Types>` +
+	`<d:Scopes>onvif://www.onvif.org/name/Front_Door onvif://www.onvif.org/hardware/DS-2CD2143G2-I</d:Scopes>` +
+	`<d:XAddrs>%s</d:XAddrs>` +
+	`</d:ProbeMatch></d:ProbeMatches></SOAP-ENV:Body></SOAP-ENV:Envelope>`
+
+var messageIDPattern = regexp.MustCompile(`<w:MessageID>([^<]+)</w:MessageID>`)
+
+// startResponder answers every probe it receives on a local UDP port with one ProbeMatch
+// per entry of xaddrs, preceded by an answer to another probe that must be ignored
+func startResponder(t *testing.T, xaddrs []string) string {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buffer := make([]byte, maxMessageSize)
+		for {
+			n, sender, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+			match := messageIDPattern.FindSubmatch(buffer[:n])
+			if match == nil {
+				continue
+			}
+			conn.WriteToUDP([]byte(fmt.Sprintf(probeMatchTemplate, "uuid:another-probe", "stale", "http://10.0.0.1/onvif/device_service")), sender)
+			for i, xaddr := range xaddrs {
+				conn.WriteToUDP([]byte(fmt.Sprintf(probeMatchTemplate, match[1], fmt.Sprintf("device-%d", i), xaddr)), sender)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		xaddrs string
+		want   Device
+	}{
+		{
+			name:   "default path",
+			xaddrs: "http://192.168.1.64/onvif/device_service",
+			want:   Device{XAddr: "http://192.168.1.64/onvif/device_service", IP: "192.168.1.64", Port: 80},
+		},
+		{
+			name:   "non-default path and port",
+			xaddrs: "http://192.168.1.65:8899/onvif/device",
+			want:   Device{XAddr: "http://192.168.1.65:8899/onvif/device", IP: "192.168.1.65", Port: 8899},
+		},
+		{
+			name:   "https",
+			xaddrs: "https://192.168.1.66/onvif/device_service",
+			want:   Device{XAddr: "https://192.168.1.66/onvif/device_service", IP: "192.168.1.66", Port: 443},
+		},
+		{
+			name:   "IPv4 preferred over IPv6",
+			xaddrs: "http://[fe80::1]/onvif/device_service http://192.168.1.67:8080/onvif/device_service",
+			want:   Device{XAddr: "http://192.168.1.67:8080/onvif/device_service", IP: "192.168.1.67", Port: 8080},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := startResponder(t, []string{tt.xaddrs})
+
+			devices, err := Probe(context.Background(), Options{Address: address, Timeout: 300 * time.Millisecond})
+			if err != nil {
+				t.Fatalf("Probe() error = %v", err)
+			}
+			if len(devices) != 1 {
+				t.Fatalf("Probe() found %d devices, want 1: %+v", len(devices), devices)
+			}
+
+			device := devices[0]
+			if device.XAddr != tt.want.XAddr || device.IP != tt.want.IP || device.Port != tt.want.Port {
+				t.Errorf("Probe() device at %s %s:%d, want %s %s:%d",
+					device.XAddr, device.IP, device.Port, tt.want.XAddr, tt.want.IP, tt.want.Port)
+			}
+			if device.EndpointReference != "urn:uuid:device-0" {
+				t.Errorf("EndpointReference = %q", device.EndpointReference)
+			}
+			if device.Name != "Front Door" || device.Hardware != "DS-2CD2143G2-I" {
+				t.Errorf("Name, Hardware = %q, %q", device.Name, device.Hardware)
+			}
+		})
+	}
+}
+
+func TestProbeSortsDevices(t *testing.T) {
+	address := startResponder(t, []string{
+		"http://192.168.1.100/onvif/device_service",
+		"http://192.168.1.9/onvif/device_service",
+		"http://192.168.1.20/onvif/device_service",
+	})
+
+	devices, err := Probe(context.Background(), Options{Address: address, Timeout: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+
+	var ips []string
+	for _, device := range devices {
+		ips = append(ips, device.IP)
+	}
+	if fmt.Sprint(ips) != "[192.168.1.9 192.168.1.20 192.168.1.100]" {
+		t.Errorf("Probe() returned %v, want the devices sorted by IP", ips)
+	}
+}
+
+func TestProbeWithoutAnswers(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	devices, err := Probe(context.Background(), Options{Address: conn.LocalAddr().String(), Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("Probe() found %d devices, want none", len(devices))
+	}
+}
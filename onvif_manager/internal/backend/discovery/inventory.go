@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"onvif_manager/internal/backend/camera"
+)
+
+// Candidate is a discovered device together with its inventory status
+type Candidate struct {
+	Device
+	InInventory bool   `json:"inInventory"`
+	CameraID    string `json:"cameraId,omitempty"`
+}
+
+// AddResult reports the outcome of adding one discovered device to the inventory
+type AddResult struct {
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	CameraID string `json:"cameraId,omitempty"`
+	Success  bool   `json:"success"`
+	Existing bool   `json:"existing,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MatchInventory marks the devices whose IP address is already in the camera inventory
+func MatchInventory(devices []Device) []Candidate {
+	candidates := make([]Candidate, 0, len(devices))
+	for _, device := range devices {
+		candidate := Candidate{Device: device}
+		if cam, ok := camera.FindCameraByIP(device.IP); ok {
+			candidate.InInventory = true
+			candidate.CameraID = cam.ID
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// AddToInventory adds the discovered devices to the camera inventory with the given
+// credentials. Devices that are already inventoried are reported as existing.
+func AddToInventory(devices []Device, username, password string) []AddResult {
+	results := make([]AddResult, 0, len(devices))
+	for _, device := range devices {
+		result := AddResult{IP: device.IP, Port: device.Port}
+
+		if device.IP == "" {
+			result.Error = "device did not report a usable address"
+			results = append(results, result)
+			continue
+		}
+
+		if cam, ok := camera.FindCameraByIP(device.IP); ok {
+			result.CameraID = cam.ID
+			result.Success = true
+			result.Existing = true
+			results = append(results, result)
+			continue
+		}
+
+		// The advertised device service is kept; the media service is read from it
+		newID, err := camera.AddNewCamera(device.IP, device.Port, "", device.XAddr, username, password)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.CameraID = newID
+			result.Success = true
+		}
+		results = append(results, result)
+	}
+	return results
+}
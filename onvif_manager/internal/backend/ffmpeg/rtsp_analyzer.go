@@ -17,19 +17,28 @@ package ffmpeg
 #include <libavcodec/avcodec.h>
 #include <libavutil/avutil.h>
 
+#define MAX_BITRATE_SAMPLES 32
+
 typedef struct {
     char codec[64];
     int width;
     int height;
     double fps;
     int bitrate;
+    int gop;
+    int bitrate_samples[MAX_BITRATE_SAMPLES];
+    int bitrate_sample_count;
     int success;
     char error_msg[256];
 } StreamInfo;
 
-StreamInfo analyze_rtsp_stream(const char* rtsp_url) {
+// analyze_rtsp_stream reads the stream parameters. When max_gop_frames is positive,
+// up to that many video frames are read to measure the distance between keyframes.
+// When bitrate_seconds is positive, the video bitrate of that many one second windows is sampled.
+StreamInfo analyze_rtsp_stream(const char* rtsp_url, int max_gop_frames, int bitrate_seconds) {
     StreamInfo info = {0};
     AVFormatContext *format_ctx = NULL;
+    int video_index = -1;
     int ret;
 
     // Initialize FFmpeg
@@ -96,6 +105,7 @@ StreamInfo analyze_rtsp_stream(const char* rtsp_url) {
             }
 
             info.success = 1;
+            video_index = i;
             break;
         }
     }
@@ -104,6 +114,61 @@ StreamInfo analyze_rtsp_stream(const char* rtsp_url) {
         snprintf(info.error_msg, sizeof(info.error_msg), "No video stream found in RTSP stream");
     }
 
+    if (bitrate_seconds > MAX_BITRATE_SAMPLES) {
+        bitrate_seconds = MAX_BITRATE_SAMPLES;
+    }
+
+    // Measure the GOP as the average number of frames between the keyframes of two full GOPs,
+    // and the bitrate of consecutive one second windows from the packet sizes
+    if (info.success && (max_gop_frames > 0 || bitrate_seconds > 0)) {
+        AVPacket *packet = av_packet_alloc();
+        AVStream *stream = format_ctx->streams[video_index];
+        double fps = info.fps > 0 ? info.fps : 25.0;
+        int max_bitrate_frames = (int)(fps * (bitrate_seconds + 2)) + 10;
+        int frames = 0, last_keyframe = -1, intervals = 0, total = 0;
+        double window_start = -1;
+        int64_t window_bytes = 0;
+
+        while (packet) {
+            int gop_done = max_gop_frames <= 0 || intervals >= 2 || frames >= max_gop_frames;
+            int bitrate_done = bitrate_seconds <= 0 || info.bitrate_sample_count >= bitrate_seconds || frames >= max_bitrate_frames;
+            if ((gop_done && bitrate_done) || av_read_frame(format_ctx, packet) < 0) {
+                break;
+            }
+
+            if (packet->stream_index == video_index) {
+                if (!gop_done && (packet->flags & AV_PKT_FLAG_KEY)) {
+                    if (last_keyframe >= 0) {
+                        total += frames - last_keyframe;
+                        intervals++;
+                    }
+                    last_keyframe = frames;
+                }
+
+                if (!bitrate_done) {
+                    // Packet time in seconds, counted in frames when the packet has no timestamp
+                    int64_t ts = packet->pts != AV_NOPTS_VALUE ? packet->pts : packet->dts;
+                    double t = ts != AV_NOPTS_VALUE ? ts * av_q2d(stream->time_base) : frames / fps;
+                    if (window_start < 0) {
+                        window_start = t;
+                    } else if (t - window_start >= 1.0) {
+                        info.bitrate_samples[info.bitrate_sample_count++] = (int)(window_bytes * 8 / 1000 / (t - window_start));
+                        window_start = t;
+                        window_bytes = 0;
+                    }
+                    window_bytes += packet->size;
+                }
+                frames++;
+            }
+            av_packet_unref(packet);
+        }
+        av_packet_free(&packet);
+
+        if (intervals > 0) {
+            info.gop = (total + intervals / 2) / intervals;
+        }
+    }
+
     // Clean up
     avformat_close_input(&format_ctx);
     avformat_network_deinit();
@@ -115,23 +180,36 @@ import "C"
 
 import (
 	"fmt"
+	"math"
 	"strings"
 	"unsafe"
 )
 
+// Bitrate sampling used to check the rate control mode of a stream
+const (
+	BitrateSampleSeconds = 5    // One second windows sampled when a rate control mode is expected
+	CBRMaxVariation      = 15.0 // Largest bitrate variation, in percent, still counted as constant
+)
+
 // StreamInfo represents the information extracted from an RTSP stream
 type StreamInfo struct {
-	Codec    string  `json:"codec"`
-	Width    int     `json:"width"`
-	Height   int     `json:"height"`
-	FPS      float64 `json:"fps"`
-	Bitrate  int     `json:"bitrate"` // in kbps
-	Success  bool    `json:"success"`
-	ErrorMsg string  `json:"error_msg,omitempty"`
+	Codec   string  `json:"codec"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	FPS     float64 `json:"fps"`
+	Bitrate int     `json:"bitrate"` // in kbps
+	GOP     int     `json:"gop"`     // Measured frames between keyframes, 0 when not measured
+	Success bool    `json:"success"`
+
+	// BitrateSamples holds the measured bitrate of consecutive one second windows in kbps
+	BitrateSamples []int  `json:"bitrateSamples,omitempty"`
+	ErrorMsg       string `json:"error_msg,omitempty"`
 }
 
-// AnalyzeRTSPStream analyzes an RTSP stream and returns codec, resolution, and FPS information
-func AnalyzeRTSPStream(rtspURL string) (*StreamInfo, error) {
+// AnalyzeRTSPStream analyzes an RTSP stream and returns codec, resolution, and FPS information.
+// When maxGOPFrames is positive, up to that many frames are read to measure the GOP.
+// When bitrateSeconds is positive, the bitrate is sampled once per second for that many seconds.
+func AnalyzeRTSPStream(rtspURL string, maxGOPFrames, bitrateSeconds int) (*StreamInfo, error) {
 	if rtspURL == "" {
 		return nil, fmt.Errorf("RTSP URL cannot be empty")
 	}
@@ -141,16 +219,20 @@ func AnalyzeRTSPStream(rtspURL string) (*StreamInfo, error) {
 	defer C.free(unsafe.Pointer(cURL))
 
 	// Call the C function
-	cInfo := C.analyze_rtsp_stream(cURL) // Convert C struct to Go struct
+	cInfo := C.analyze_rtsp_stream(cURL, C.int(maxGOPFrames), C.int(bitrateSeconds)) // Convert C struct to Go struct
 	info := &StreamInfo{
 		Codec:    C.GoString(&cInfo.codec[0]),
 		Width:    int(cInfo.width),
 		Height:   int(cInfo.height),
 		FPS:      float64(cInfo.fps),
 		Bitrate:  int(cInfo.bitrate),
+		GOP:      int(cInfo.gop),
 		Success:  int(cInfo.success) == 1,
 		ErrorMsg: C.GoString(&cInfo.error_msg[0]),
 	}
+	for i := 0; i < int(cInfo.bitrate_sample_count); i++ {
+		info.BitrateSamples = append(info.BitrateSamples, int(cInfo.bitrate_samples[i]))
+	}
 
 	if !info.Success {
 		return info, fmt.Errorf("failed to analyze RTSP stream: %s", info.ErrorMsg)
@@ -209,30 +291,51 @@ func (s *StreamInfo) Is4K() bool {
 }
 
 type ValidationResult struct {
-	IsValid          bool    `json:"isValid"`
-	ExpectedWidth    int     `json:"expectedWidth"`
-	ExpectedHeight   int     `json:"expectedHeight"`
-	ExpectedFPS      int     `json:"expectedFPS"`
-	ExpectedBitrate  int     `json:"expectedBitrate"`
-	ExpectedEncoding string  `json:"expectedEncoding,omitempty"`
-	ActualWidth      int     `json:"actualWidth"`
-	ActualHeight     int     `json:"actualHeight"`
-	ActualFPS        float64 `json:"actualFPS"`
-	ActualBitrate    int     `json:"actualBitrate"`
-	ActualEncoding   string  `json:"actualEncoding,omitempty"`
-	Error            string  `json:"error,omitempty"`
+	IsValid             bool    `json:"isValid"`
+	ExpectedWidth       int     `json:"expectedWidth"`
+	ExpectedHeight      int     `json:"expectedHeight"`
+	ExpectedFPS         int     `json:"expectedFPS"`
+	ExpectedBitrate     int     `json:"expectedBitrate"`
+	ExpectedEncoding    string  `json:"expectedEncoding,omitempty"`
+	ExpectedGOP         int     `json:"expectedGOP,omitempty"`
+	ExpectedRateControl string  `json:"expectedRateControl,omitempty"`
+	ActualWidth         int     `json:"actualWidth"`
+	ActualHeight        int     `json:"actualHeight"`
+	ActualFPS           float64 `json:"actualFPS"`
+	ActualBitrate       int     `json:"actualBitrate"`
+	ActualEncoding      string  `json:"actualEncoding,omitempty"`
+	ActualGOP           int     `json:"actualGOP,omitempty"`
+	BitrateSamples      int     `json:"bitrateSamples,omitempty"`   // Number of one second windows measured
+	BitrateVariation    float64 `json:"bitrateVariation,omitempty"` // Standard deviation of the windows, in percent of their mean
+	Error               string  `json:"error,omitempty"`
 }
 
-func ValidateStream(rtspURL string, expectedWidth, expectedHeight, expectedFPS, expectedBitrate int, expectedEncoding string) (*ValidationResult, error) {
+// ValidateStream analyzes an RTSP stream and compares it with the expected settings.
+// The GOP is only measured when an expected GOP is given, and the bitrate variation
+// only when a rate control mode (CBR or VBR) is given.
+func ValidateStream(rtspURL string, expectedWidth, expectedHeight, expectedFPS, expectedBitrate int, expectedEncoding string, expectedGOP int, expectedRateControl string) (*ValidationResult, error) {
 	result := &ValidationResult{
-		ExpectedWidth:    expectedWidth,
-		ExpectedHeight:   expectedHeight,
-		ExpectedFPS:      expectedFPS,
-		ExpectedBitrate:  expectedBitrate,
-		ExpectedEncoding: expectedEncoding,
+		ExpectedWidth:       expectedWidth,
+		ExpectedHeight:      expectedHeight,
+		ExpectedFPS:         expectedFPS,
+		ExpectedBitrate:     expectedBitrate,
+		ExpectedEncoding:    expectedEncoding,
+		ExpectedGOP:         expectedGOP,
+		ExpectedRateControl: expectedRateControl,
+	}
+
+	// Reading two full GOPs and a few spare frames is enough to measure the keyframe spacing
+	maxGOPFrames := 0
+	if expectedGOP > 0 {
+		maxGOPFrames = 2*expectedGOP + 10
+	}
+
+	bitrateSeconds := 0
+	if expectedRateControl != "" {
+		bitrateSeconds = BitrateSampleSeconds
 	}
 
-	streamInfo, err := AnalyzeRTSPStream(rtspURL)
+	streamInfo, err := AnalyzeRTSPStream(rtspURL, maxGOPFrames, bitrateSeconds)
 	if err != nil {
 		result.Error = fmt.Sprintf("Failed to analyze RTSP stream: %v", err)
 		return result, nil
@@ -242,6 +345,15 @@ func ValidateStream(rtspURL string, expectedWidth, expectedHeight, expectedFPS,
 	result.ActualFPS = streamInfo.FPS
 	result.ActualBitrate = streamInfo.Bitrate
 	result.ActualEncoding = streamInfo.Codec
+	result.ActualGOP = streamInfo.GOP
+
+	// RTSP streams rarely announce their bitrate, the sampled mean is used instead
+	mean, variation := BitrateVariation(streamInfo.BitrateSamples)
+	result.BitrateSamples = len(streamInfo.BitrateSamples)
+	result.BitrateVariation = variation
+	if result.ActualBitrate == 0 {
+		result.ActualBitrate = mean
+	}
 
 	if !streamInfo.Success {
 		result.Error = streamInfo.ErrorMsg
@@ -257,10 +369,12 @@ func ValidateStream(rtspURL string, expectedWidth, expectedHeight, expectedFPS,
 	// Check for encoding match if expected encoding was provided
 	encodingMatch := true // Default to true if no expected encoding
 	if result.ExpectedEncoding != "" && result.ActualEncoding != "" {
-		// Case insensitive check for codec/encoding match
-		encodingMatch = strings.EqualFold(result.ActualEncoding, result.ExpectedEncoding)
+		encodingMatch = SameEncoding(result.ActualEncoding, result.ExpectedEncoding)
 	}
 
+	gopMatch := GOPMatches(result.ActualGOP, result.ExpectedGOP)
+	rateControlMatch := RateControlMatches(result.ExpectedRateControl, result.BitrateVariation, result.BitrateSamples)
+
 	// Only consider bitrate match if expected bitrate was provided and we have actual bitrate
 	bitrateMatch := true // Default to true if no expected bitrate
 	if result.ExpectedBitrate > 0 {
@@ -281,7 +395,7 @@ func ValidateStream(rtspURL string, expectedWidth, expectedHeight, expectedFPS,
 	// FPS, bitrate, and encoding mismatches are warnings only
 	result.IsValid = resolutionMatch // Only require resolution to match for success
 	// Generate error/warning messages with clear distinction
-	if !result.IsValid || !fpsMatch || !bitrateMatch || !encodingMatch {
+	if !result.IsValid || !fpsMatch || !bitrateMatch || !encodingMatch || !gopMatch || !rateControlMatch {
 		var errors []string
 
 		// Resolution mismatch = ERROR (causes failure)
@@ -324,6 +438,26 @@ func ValidateStream(rtspURL string, expectedWidth, expectedHeight, expectedFPS,
 			}
 		}
 
+		// GOP mismatch = WARNING (does not cause failure)
+		if !gopMatch {
+			if result.ActualGOP > 0 {
+				errors = append(errors, fmt.Sprintf("GOP DIFFERENCE (WARNING): keyframes every %d frames, expected %d",
+					result.ActualGOP, result.ExpectedGOP))
+			} else {
+				errors = append(errors, fmt.Sprintf("GOP DETECTION FAILED (WARNING): no two keyframes within %d frames", maxGOPFrames))
+			}
+		}
+
+		// Rate control mismatch = WARNING (does not cause failure)
+		if !rateControlMatch {
+			if result.BitrateSamples >= 2 {
+				errors = append(errors, fmt.Sprintf("RATE CONTROL DIFFERENCE (WARNING): bitrate varies by %.1f%% over %d s, expected %s",
+					result.BitrateVariation, result.BitrateSamples, result.ExpectedRateControl))
+			} else {
+				errors = append(errors, "RATE CONTROL DETECTION FAILED (WARNING): unable to sample the bitrate")
+			}
+		}
+
 		// Set the error message
 		if len(errors) > 0 {
 			result.Error = strings.Join(errors, "; ")
@@ -332,3 +466,61 @@ func ValidateStream(rtspURL string, expectedWidth, expectedHeight, expectedFPS,
 
 	return result, nil
 }
+
+// SameEncoding reports whether the codec reported by FFmpeg is the expected encoding.
+// FFmpeg names H.265 "hevc" and Motion JPEG "mjpeg", config files use H265 and MJPEG or JPEG.
+func SameEncoding(actual, expected string) bool {
+	canonical := func(encoding string) string {
+		switch encoding = strings.ToUpper(strings.ReplaceAll(encoding, ".", "")); encoding {
+		case "HEVC":
+			return "H265"
+		case "MJPEG":
+			return "JPEG"
+		default:
+			return encoding
+		}
+	}
+	return canonical(actual) == canonical(expected)
+}
+
+// GOPMatches reports whether the measured GOP is within one frame of the expected GOP.
+// Without an expected GOP there is nothing to compare and it always matches.
+func GOPMatches(actual, expected int) bool {
+	if expected <= 0 {
+		return true
+	}
+	return actual > 0 && actual >= expected-1 && actual <= expected+1
+}
+
+// BitrateVariation returns the mean of bitrate samples in kbps and their standard deviation
+// in percent of the mean. Fewer than two samples have no variation.
+func BitrateVariation(samples []int) (int, float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, sample := range samples {
+		sum += float64(sample)
+	}
+	mean := sum / float64(len(samples))
+	if len(samples) < 2 || mean == 0 {
+		return int(math.Round(mean)), 0
+	}
+
+	var squares float64
+	for _, sample := range samples {
+		squares += (float64(sample) - mean) * (float64(sample) - mean)
+	}
+	deviation := math.Sqrt(squares / float64(len(samples)))
+	return int(math.Round(mean)), math.Round(deviation/mean*1000) / 10
+}
+
+// RateControlMatches reports whether the measured bitrate variation is consistent with the
+// expected rate control mode. Only CBR limits the variation, a VBR stream of a still scene
+// may be as steady as a CBR stream.
+func RateControlMatches(expected string, variation float64, samples int) bool {
+	if !strings.EqualFold(expected, "CBR") {
+		return true
+	}
+	return samples >= 2 && variation <= CBRMaxVariation
+}
@@ -0,0 +1,47 @@
+package ffmpeg
+
+import "testing"
+
+func TestBitrateVariation(t *testing.T) {
+	tests := []struct {
+		samples       []int
+		wantMean      int
+		wantVariation float64
+	}{
+		{samples: nil, wantMean: 0, wantVariation: 0},
+		{samples: []int{4000}, wantMean: 4000, wantVariation: 0},
+		{samples: []int{4000, 4000, 4000}, wantMean: 4000, wantVariation: 0},
+		{samples: []int{3000, 5000}, wantMean: 4000, wantVariation: 25},
+		{samples: []int{0, 0}, wantMean: 0, wantVariation: 0},
+	}
+
+	for _, tt := range tests {
+		mean, variation := BitrateVariation(tt.samples)
+		if mean != tt.wantMean || variation != tt.wantVariation {
+			t.Errorf("BitrateVariation(%v) = %d, %v, want %d, %v", tt.samples, mean, variation, tt.wantMean, tt.wantVariation)
+		}
+	}
+}
+
+func TestRateControlMatches(t *testing.T) {
+	tests := []struct {
+		expected  string
+		variation float64
+		samples   int
+		want      bool
+	}{
+		{expected: "CBR", variation: 5, samples: 5, want: true},
+		{expected: "cbr", variation: CBRMaxVariation, samples: 5, want: true},
+		{expected: "CBR", variation: 40, samples: 5, want: false},
+		{expected: "CBR", variation: 0, samples: 1, want: false},
+		{expected: "VBR", variation: 2, samples: 5, want: true},
+		{expected: "VBR", variation: 80, samples: 5, want: true},
+		{expected: "", variation: 80, samples: 0, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := RateControlMatches(tt.expected, tt.variation, tt.samples); got != tt.want {
+			t.Errorf("RateControlMatches(%q, %v, %d) = %v, want %v", tt.expected, tt.variation, tt.samples, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/pkg/models"
+)
+
+// PollIntervalEnvVar names the environment variable that sets how often the server
+// polls the cameras; "off" disables polling
+const PollIntervalEnvVar = "ONVIF_MANAGER_POLL_INTERVAL"
+
+// DefaultPollInterval is the time between two polls in server mode
+const DefaultPollInterval = 15 * time.Minute
+
+// PollResults represents the overall results of polling the cameras
+type PollResults struct {
+	CheckedAt     time.Time              `json:"checkedAt"`
+	CameraOrder   []string               `json:"cameraOrder"` // Camera IDs in the order they were requested
+	CameraResults map[string]*PollResult `json:"cameraResults"`
+	Summary       PollSummary            `json:"summary"`
+}
+
+// PollResult represents the encoder configurations read from one camera
+type PollResult struct {
+	CameraID string         `json:"cameraId"`
+	Success  bool           `json:"success"`
+	Error    string         `json:"error,omitempty"`
+	Configs  []ConfigStatus `json:"configs"`
+}
+
+// ConfigStatus is the state of one encoder configuration compared with its history
+type ConfigStatus struct {
+	ConfigToken string                `json:"configToken"`
+	Profiles    []string              `json:"profiles"` // Profiles using the configuration
+	Config      models.EncoderConfig  `json:"config"`
+	Changed     bool                  `json:"changed"`   // Differs from the configuration seen before
+	ChangedAt   time.Time             `json:"changedAt"` // When the configuration was first seen
+	AppliedAt   *time.Time            `json:"appliedAt,omitempty"`
+	Drift       []camera.ConfigChange `json:"drift,omitempty"` // Settings that differ from the last applied configuration
+}
+
+// PollSummary represents a summary of a poll
+type PollSummary struct {
+	TotalCameras int `json:"totalCameras"`
+	ChangedCams  int `json:"changedCams"` // Cameras with a configuration that changed since the last poll
+	DriftedCams  int `json:"driftedCams"` // Cameras with a configuration that differs from the last applied one
+	FailedCams   int `json:"failedCams"`
+}
+
+// Poll reads the encoder configurations of the cameras through the bounded worker pool
+// and records them in the store
+func Poll(ctx context.Context, store *Store, cameraIDs []string, opts pool.Options) *PollResults {
+	log.Printf("Polling encoder configs of %d cameras (concurrency %d, timeout %s)", len(cameraIDs), opts.Concurrency, opts.Timeout)
+
+	results := &PollResults{
+		CheckedAt:     time.Now(),
+		CameraOrder:   cameraIDs,
+		CameraResults: make(map[string]*PollResult),
+	}
+
+	polled := pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) *PollResult {
+			return pollCamera(store, cameraID)
+		},
+		func(cameraID string, err error) *PollResult {
+			return &PollResult{CameraID: cameraID, Error: fmt.Sprintf("Poll aborted: %v", err), Configs: []ConfigStatus{}}
+		})
+
+	results.Summary.TotalCameras = len(cameraIDs)
+	for i, cameraID := range cameraIDs {
+		result := polled[i]
+		results.CameraResults[cameraID] = result
+
+		if !result.Success {
+			results.Summary.FailedCams++
+			continue
+		}
+		changed, drifted := false, false
+		for _, config := range result.Configs {
+			changed = changed || config.Changed
+			drifted = drifted || len(config.Drift) > 0
+		}
+		if changed {
+			results.Summary.ChangedCams++
+		}
+		if drifted {
+			results.Summary.DriftedCams++
+		}
+	}
+
+	return results
+}
+
+// pollCamera reads and records the encoder configurations of one camera
+func pollCamera(store *Store, cameraID string) *PollResult {
+	result := &PollResult{CameraID: cameraID, Configs: []ConfigStatus{}}
+
+	unlock := camera.LockCamera(cameraID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	profiles, err := camera.GetProfiles(client)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// Profiles may share an encoder configuration; read each one once
+	var tokens []string
+	users := make(map[string][]string)
+	for _, profile := range profiles {
+		if !profile.HasEncoder() {
+			continue
+		}
+		if _, seen := users[profile.ConfigToken]; !seen {
+			tokens = append(tokens, profile.ConfigToken)
+		}
+		users[profile.ConfigToken] = append(users[profile.ConfigToken], profile.String())
+	}
+
+	for _, token := range tokens {
+		config, err := camera.GetCurrentConfig(client, token)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+
+		now := time.Now()
+		history, err := store.Observe(cameraID, token, config, now)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to record config: %v", err)
+			return result
+		}
+
+		latest := history.Latest()
+		status := ConfigStatus{
+			ConfigToken: token,
+			Profiles:    users[token],
+			Config:      config,
+			Changed:     len(history.Entries) > 1 && latest.ObservedAt.Equal(now),
+			ChangedAt:   latest.ObservedAt,
+			AppliedAt:   history.AppliedAt,
+			Drift:       latest.Drift,
+		}
+		if status.Changed {
+			log.Printf("Encoder config %s of camera %s changed: %d setting(s), %d differ from the applied config",
+				token, cameraID, len(latest.Changes), len(latest.Drift))
+		}
+		result.Configs = append(result.Configs, status)
+	}
+
+	result.Success = true
+	return result
+}
+
+// StartPollingFromEnv polls every inventoried camera periodically, every
+// ONVIF_MANAGER_POLL_INTERVAL or DefaultPollInterval, unless it is set to "off"
+func StartPollingFromEnv(ctx context.Context, store *Store) error {
+	interval := DefaultPollInterval
+	if value := strings.TrimSpace(os.Getenv(PollIntervalEnvVar)); value != "" {
+		if strings.EqualFold(value, "off") {
+			return nil
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid %s %q", PollIntervalEnvVar, value)
+		}
+		interval = parsed
+	}
+
+	StartPolling(ctx, store, interval)
+	return nil
+}
+
+// StartPolling polls every inventoried camera right away and then every interval until ctx is done
+func StartPolling(ctx context.Context, store *Store, interval time.Duration) {
+	log.Printf("Polling camera encoder configs every %s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			var cameraIDs []string
+			for _, cam := range camera.GetAllCameras() {
+				cameraIDs = append(cameraIDs, cam.ID)
+			}
+			if len(cameraIDs) > 0 {
+				results := Poll(ctx, store, cameraIDs, pool.DefaultOptions())
+				log.Printf("Poll completed: %d changed, %d drifted, %d failed",
+					results.Summary.ChangedCams, results.Summary.DriftedCams, results.Summary.FailedCams)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RevertResult is the outcome of writing the last applied configuration back to a camera
+type RevertResult struct {
+	ConfigToken string               `json:"configToken"`
+	Config      models.EncoderConfig `json:"config"` // Configuration written back
+	Success     bool                 `json:"success"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// Revert writes the last applied configuration back to the encoder configurations of a
+// camera that drifted from it, or to the given configuration regardless of drift
+func Revert(store *Store, cameraID, configToken string) ([]RevertResult, error) {
+	histories, err := store.History(cameraID)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []*ConfigHistory
+	for _, history := range histories {
+		if configToken != "" && history.ConfigToken != configToken {
+			continue
+		}
+		if configToken == "" && !history.Drifted() {
+			continue
+		}
+		if history.Applied == nil {
+			return nil, fmt.Errorf("no applied configuration recorded for encoder config %s", history.ConfigToken)
+		}
+		targets = append(targets, history)
+	}
+	if configToken != "" && len(targets) == 0 {
+		return nil, fmt.Errorf("no history recorded for encoder config %s", configToken)
+	}
+	if len(targets) == 0 {
+		return []RevertResult{}, nil
+	}
+
+	unlock := camera.LockCamera(cameraID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []RevertResult{}
+	for _, history := range targets {
+		result := RevertResult{ConfigToken: history.ConfigToken, Config: *history.Applied}
+		log.Printf("Reverting encoder config %s of camera %s to the config applied %s", history.ConfigToken, cameraID, history.AppliedAt.Format(time.RFC3339))
+		if err := camera.RestoreEncoderConfig(client, history.ConfigToken, *history.Applied); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,200 @@
+package history
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/jsonstore"
+	"onvif_manager/pkg/models"
+)
+
+// historyFileVersion is written into every history file so the format can evolve
+const historyFileVersion = 1
+
+// MaxEntries is the number of entries kept per encoder configuration; older ones are dropped
+const MaxEntries = 200
+
+// Sources of a history entry
+const (
+	// SourceApplied marks configurations written by onvif-manager
+	SourceApplied = "applied"
+	// SourceObserved marks configurations found by polling that onvif-manager did not write
+	SourceObserved = "observed"
+)
+
+// Entry is one configuration an encoder configuration had
+type Entry struct {
+	ObservedAt time.Time             `json:"observedAt"` // When the configuration was first seen
+	LastSeenAt time.Time             `json:"lastSeenAt"` // When it was last seen unchanged
+	Source     string                `json:"source"`
+	Config     models.EncoderConfig  `json:"config"`
+	Changes    []camera.ConfigChange `json:"changes,omitempty"` // Compared with the previous entry
+	Drift      []camera.ConfigChange `json:"drift,omitempty"`   // Settings that differ from the last applied configuration, as observed → applied
+}
+
+// ConfigHistory is the history of one video encoder configuration of a camera
+type ConfigHistory struct {
+	ConfigToken string                `json:"configToken"`
+	Applied     *models.EncoderConfig `json:"applied,omitempty"` // Last configuration written by onvif-manager
+	AppliedAt   *time.Time            `json:"appliedAt,omitempty"`
+	Entries     []Entry               `json:"entries"` // Oldest first
+}
+
+// Latest returns the most recent entry, or nil when there is none
+func (h *ConfigHistory) Latest() *Entry {
+	if len(h.Entries) == 0 {
+		return nil
+	}
+	return &h.Entries[len(h.Entries)-1]
+}
+
+// Drifted reports whether the configuration last seen differs from the one last applied
+func (h *ConfigHistory) Drifted() bool {
+	latest := h.Latest()
+	return latest != nil && len(latest.Drift) > 0
+}
+
+// historyFile is the on-disk layout of the history, by camera ID and configuration token
+type historyFile struct {
+	Version int                                  `json:"version"`
+	Cameras map[string]map[string]*ConfigHistory `json:"cameras"`
+}
+
+// Store keeps the configuration history of the cameras in a JSON file, or in memory
+// when no file is given. The CLI and the server share the file.
+type Store struct {
+	file *jsonstore.File[historyFile]
+}
+
+// NewStore creates a store backed by the JSON file at path, or an in-memory store when path is empty
+func NewStore(path string) *Store {
+	return &Store{file: jsonstore.New(path, historyFormat)}
+}
+
+// Path returns the location of the history file, or empty for an in-memory store
+func (s *Store) Path() string {
+	return s.file.Path()
+}
+
+// Shared is the history store used by the API, the CLI and the poller
+var Shared = jsonstore.NewShared(jsonstore.Location{
+	EnvVar:   "ONVIF_MANAGER_HISTORY",
+	FileName: "config-history.json",
+}, NewStore)
+
+// RecordApplied records a configuration written by onvif-manager. It implements camera.ConfigRecorder.
+func (s *Store) RecordApplied(cameraID, configToken string, config models.EncoderConfig) {
+	now := time.Now()
+	err := s.file.Update(func(file *historyFile) error {
+		history := file.config(cameraID, configToken)
+		history.Applied = &config
+		history.AppliedAt = &now
+
+		if latest := history.Latest(); latest != nil && len(camera.CompareEncoderConfigs(latest.Config, config)) == 0 {
+			latest.LastSeenAt = now
+			latest.Drift = nil
+			return nil
+		}
+		history.add(Entry{Source: SourceApplied, Config: config}, now)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to record applied config %s of camera %s: %v", configToken, cameraID, err)
+	}
+}
+
+// Observe records a configuration read from a camera and returns its history.
+// A configuration that differs from the last one seen gets a new entry.
+func (s *Store) Observe(cameraID, configToken string, config models.EncoderConfig, at time.Time) (*ConfigHistory, error) {
+	var result ConfigHistory
+	err := s.file.Update(func(file *historyFile) error {
+		history := file.config(cameraID, configToken)
+		if latest := history.Latest(); latest != nil && len(camera.CompareEncoderConfigs(latest.Config, config)) == 0 {
+			latest.LastSeenAt = at
+		} else {
+			entry := Entry{Source: SourceObserved, Config: config}
+			if history.Applied != nil {
+				entry.Drift = camera.CompareEncoderConfigs(config, *history.Applied) // Observed → applied
+			}
+			history.add(entry, at)
+		}
+		result = history.copy()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// History returns the histories of the encoder configurations of a camera, ordered by token
+func (s *Store) History(cameraID string) ([]*ConfigHistory, error) {
+	histories := []*ConfigHistory{}
+	err := s.file.Read(func(file *historyFile) error {
+		for _, history := range file.Cameras[cameraID] {
+			copied := history.copy()
+			histories = append(histories, &copied)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(histories, func(i, j int) bool {
+		return histories[i].ConfigToken < histories[j].ConfigToken
+	})
+	return histories, nil
+}
+
+// copy returns a copy of the history that does not share its entries
+func (h *ConfigHistory) copy() ConfigHistory {
+	copied := *h
+	copied.Entries = append([]Entry{}, h.Entries...)
+	return copied
+}
+
+// add appends an entry, comparing it with the previous one, and drops the oldest entries beyond MaxEntries
+func (h *ConfigHistory) add(entry Entry, at time.Time) {
+	entry.ObservedAt = at
+	entry.LastSeenAt = at
+	if latest := h.Latest(); latest != nil {
+		entry.Changes = camera.CompareEncoderConfigs(latest.Config, entry.Config)
+	}
+	h.Entries = append(h.Entries, entry)
+	if len(h.Entries) > MaxEntries {
+		h.Entries = h.Entries[len(h.Entries)-MaxEntries:]
+	}
+}
+
+// config returns the history of a configuration, creating it when needed
+func (f *historyFile) config(cameraID, configToken string) *ConfigHistory {
+	configs := f.Cameras[cameraID]
+	if configs == nil {
+		configs = make(map[string]*ConfigHistory)
+		f.Cameras[cameraID] = configs
+	}
+	history := configs[configToken]
+	if history == nil {
+		history = &ConfigHistory{ConfigToken: configToken, Entries: []Entry{}}
+		configs[configToken] = history
+	}
+	return history
+}
+
+func newHistoryFile() *historyFile {
+	return &historyFile{Version: historyFileVersion, Cameras: make(map[string]map[string]*ConfigHistory)}
+}
+
+// historyFormat describes the config-history file to the JSON store
+var historyFormat = jsonstore.Format[historyFile]{
+	Name:    "history",
+	Version: historyFileVersion,
+	Empty:   newHistoryFile,
+	Fill: func(file *historyFile) {
+		if file.Cameras == nil {
+			file.Cameras = make(map[string]map[string]*ConfigHistory)
+		}
+	},
+}
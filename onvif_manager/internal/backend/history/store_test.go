@@ -0,0 +1,204 @@
+package history
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
+
+// testConfig returns an encoder configuration at the given frame rate
+func testConfig(fps int) models.EncoderConfig {
+	return models.EncoderConfig{Resolution: models.Resolution{Width: 1920, Height: 1080}, FPS: fps, Bitrate: 4096, Encoding: "H264"}
+}
+
+// step is a configuration written by onvif-manager or read from the camera
+type step struct {
+	applied bool
+	config  models.EncoderConfig
+}
+
+// settings returns the names of the settings of changes
+func settings(changes []camera.ConfigChange) []string {
+	var names []string
+	for _, change := range changes {
+		names = append(names, change.Setting)
+	}
+	return names
+}
+
+func TestObserve(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       []step
+		wantSources []string
+		wantChanges []string // Settings the latest entry changed
+		wantDrift   []string // Settings the latest entry differs from the applied config in
+	}{
+		{
+			name:        "first observation",
+			steps:       []step{{config: testConfig(25)}},
+			wantSources: []string{SourceObserved},
+		},
+		{
+			name:        "unchanged",
+			steps:       []step{{config: testConfig(25)}, {config: testConfig(25)}},
+			wantSources: []string{SourceObserved},
+		},
+		{
+			name:        "changed",
+			steps:       []step{{config: testConfig(25)}, {config: testConfig(15)}},
+			wantSources: []string{SourceObserved, SourceObserved},
+			wantChanges: []string{"fps"},
+		},
+		{
+			name:        "applied then seen unchanged",
+			steps:       []step{{applied: true, config: testConfig(25)}, {config: testConfig(25)}},
+			wantSources: []string{SourceApplied},
+		},
+		{
+			name:        "drifted from the applied config",
+			steps:       []step{{applied: true, config: testConfig(25)}, {config: testConfig(15)}},
+			wantSources: []string{SourceApplied, SourceObserved},
+			wantChanges: []string{"fps"},
+			wantDrift:   []string{"fps"},
+		},
+		{
+			name:        "changed back to the applied config",
+			steps:       []step{{applied: true, config: testConfig(25)}, {config: testConfig(15)}, {config: testConfig(25)}},
+			wantSources: []string{SourceApplied, SourceObserved, SourceObserved},
+			wantChanges: []string{"fps"},
+		},
+		{
+			name:        "drifted config applied again",
+			steps:       []step{{applied: true, config: testConfig(25)}, {config: testConfig(15)}, {applied: true, config: testConfig(15)}},
+			wantSources: []string{SourceApplied, SourceObserved},
+			wantChanges: []string{"fps"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore("")
+			start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+			for i, s := range tt.steps {
+				if s.applied {
+					store.RecordApplied("1", "enc_main", s.config)
+					continue
+				}
+				if _, err := store.Observe("1", "enc_main", s.config, start.Add(time.Duration(i)*time.Minute)); err != nil {
+					t.Fatalf("Observe() error = %v", err)
+				}
+			}
+
+			histories, err := store.History("1")
+			if err != nil || len(histories) != 1 {
+				t.Fatalf("History() = %v, %v, want one configuration", histories, err)
+			}
+			history := histories[0]
+			var sources []string
+			for _, entry := range history.Entries {
+				sources = append(sources, entry.Source)
+			}
+			if !reflect.DeepEqual(sources, tt.wantSources) {
+				t.Errorf("entry sources = %v, want %v", sources, tt.wantSources)
+			}
+			latest := history.Latest()
+			if got := settings(latest.Changes); !reflect.DeepEqual(got, tt.wantChanges) {
+				t.Errorf("latest changes = %v, want %v", got, tt.wantChanges)
+			}
+			if got := settings(latest.Drift); !reflect.DeepEqual(got, tt.wantDrift) {
+				t.Errorf("latest drift = %v, want %v", got, tt.wantDrift)
+			}
+			if history.Drifted() != (len(tt.wantDrift) > 0) {
+				t.Errorf("Drifted() = %v, want %v", history.Drifted(), len(tt.wantDrift) > 0)
+			}
+		})
+	}
+}
+
+func TestObserveLastSeen(t *testing.T) {
+	store := NewStore("")
+	first := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := store.Observe("1", "enc_main", testConfig(25), first); err != nil {
+		t.Fatal(err)
+	}
+	history, err := store.Observe("1", "enc_main", testConfig(25), first.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := history.Latest(); !latest.ObservedAt.Equal(first) || !latest.LastSeenAt.Equal(first.Add(time.Hour)) {
+		t.Errorf("latest entry observed %s, last seen %s, want first observed and seen an hour later", latest.ObservedAt, latest.LastSeenAt)
+	}
+
+	// The returned history does not share its entries with the store
+	history.Entries[0].Source = "changed"
+	if histories, _ := store.History("1"); histories[0].Entries[0].Source != SourceObserved {
+		t.Error("changing the returned history changed the store")
+	}
+}
+
+func TestObserveKeepsMaxEntries(t *testing.T) {
+	store := NewStore("")
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < MaxEntries+5; i++ {
+		if _, err := store.Observe("1", "enc_main", testConfig(10+i%2), at.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	histories, _ := store.History("1")
+	if entries := histories[0].Entries; len(entries) != MaxEntries || !entries[0].ObservedAt.Equal(at.Add(5*time.Minute)) {
+		t.Errorf("history keeps %d entries from %s, want the newest %d", len(entries), entries[0].ObservedAt, MaxEntries)
+	}
+}
+
+func TestRevertTargets(t *testing.T) {
+	if err := camera.UseStore(&camera.MemoryStore{}); err != nil {
+		t.Fatalf("UseStore() error = %v", err)
+	}
+	store := NewStore("")
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	// enc_main drifted from its applied config, enc_sub is as applied and enc_third was never applied
+	store.RecordApplied("1", "enc_main", testConfig(25))
+	store.Observe("1", "enc_main", testConfig(15), at)
+	store.RecordApplied("1", "enc_sub", testConfig(10))
+	store.Observe("1", "enc_sub", testConfig(10), at)
+	store.Observe("1", "enc_third", testConfig(5), at)
+	store.RecordApplied("2", "enc_main", testConfig(25))
+
+	tests := []struct {
+		name         string
+		cameraID     string
+		configToken  string
+		wantErr      string
+		wantNotFound bool // The revert reached the camera, which is not in the inventory
+	}{
+		{name: "drifted configurations", cameraID: "1", wantNotFound: true},
+		{name: "nothing drifted", cameraID: "2"},
+		{name: "given configuration regardless of drift", cameraID: "1", configToken: "enc_sub", wantNotFound: true},
+		{name: "configuration without history", cameraID: "1", configToken: "enc_missing", wantErr: "no history recorded for encoder config enc_missing"},
+		{name: "configuration never applied", cameraID: "1", configToken: "enc_third", wantErr: "no applied configuration recorded for encoder config enc_third"},
+	}
+
+	for _, tt := range tests {
+		results, err := Revert(store, tt.cameraID, tt.configToken)
+		switch {
+		case tt.wantNotFound:
+			if !errors.Is(err, camera.ErrNotFound) {
+				t.Errorf("%s: Revert() error = %v, want the camera not found", tt.name, err)
+			}
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: Revert() error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+		default:
+			if err != nil || results == nil || len(results) != 0 {
+				t.Errorf("%s: Revert() = %v, %v, want no results", tt.name, results, err)
+			}
+		}
+	}
+}
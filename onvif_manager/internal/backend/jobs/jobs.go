@@ -0,0 +1,318 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status is the lifecycle state of a job
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusPaused    Status = "paused" // A staged rollout waiting to be resumed or aborted
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// Phase is the step a camera is at within a job
+type Phase string
+
+const (
+	PhaseQueued      Phase = "queued"
+	PhaseConnecting  Phase = "connecting"
+	PhaseConfiguring Phase = "configuring"
+	PhaseValidating  Phase = "validating"
+	PhaseRollingBack Phase = "rolling-back"
+	PhaseDone        Phase = "done"
+	PhaseFailed      Phase = "failed"
+	PhaseSkipped     Phase = "skipped" // Not reached because a staged rollout was aborted
+)
+
+// finishedJobRetention is how long finished jobs are kept before they are pruned
+const finishedJobRetention = 24 * time.Hour
+
+// CameraProgress is the current phase of one camera in a job
+type CameraProgress struct {
+	CameraID  string    `json:"cameraId"`
+	Phase     Phase     `json:"phase"`
+	Message   string    `json:"message,omitempty"`
+	Wave      int       `json:"wave,omitempty"` // Wave of a staged rollout the camera is in
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Event is a single progress change. Camera events carry a CameraID; job status
+// changes leave it empty and set Status instead.
+type Event struct {
+	Seq      int       `json:"seq"`
+	JobID    string    `json:"jobId"`
+	CameraID string    `json:"cameraId,omitempty"`
+	Phase    Phase     `json:"phase,omitempty"`
+	Status   Status    `json:"status,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Snapshot is a point-in-time copy of a job, safe to encode as JSON
+type Snapshot struct {
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	Status     Status           `json:"status"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	StartedAt  *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt *time.Time       `json:"finishedAt,omitempty"`
+	Summary    map[Phase]int    `json:"summary"`
+	Cameras    []CameraProgress `json:"cameras"`
+	Result     interface{}      `json:"result,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// Job tracks a long-running operation over a set of cameras
+type Job struct {
+	mu         sync.Mutex
+	id         string
+	jobType    string
+	status     Status
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	order      []string
+	cameras    map[string]*CameraProgress
+	events     []Event
+	changed    chan struct{} // Closed and replaced whenever an event is recorded
+	result     interface{}
+	err        string
+}
+
+// ID returns the job identifier
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Start marks the job as running
+func (j *Job) Start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status = StatusRunning
+	j.startedAt = time.Now()
+	j.record(Event{Status: StatusRunning})
+}
+
+// SetPhase moves a camera to a new phase and notifies subscribers
+func (j *Job) SetPhase(cameraID string, phase Phase, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	progress, ok := j.cameras[cameraID]
+	if !ok {
+		progress = &CameraProgress{CameraID: cameraID}
+		j.cameras[cameraID] = progress
+		j.order = append(j.order, cameraID)
+	}
+	progress.Phase = phase
+	progress.Message = message
+	progress.UpdatedAt = time.Now()
+
+	j.record(Event{CameraID: cameraID, Phase: phase, Message: message})
+}
+
+// SetStatus changes the status of a running job, e.g. to pause it, and notifies subscribers
+func (j *Job) SetStatus(status Status, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status = status
+	j.record(Event{Status: status, Message: message})
+}
+
+// SetWave records the wave of a staged rollout a camera is in
+func (j *Job) SetWave(cameraID string, wave int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if progress, ok := j.cameras[cameraID]; ok {
+		progress.Wave = wave
+	}
+}
+
+// Complete stores the final result and marks the job as completed
+func (j *Job) Complete(result interface{}) {
+	j.finish(StatusCompleted, result, "")
+}
+
+// Fail marks the job as failed with the given error
+func (j *Job) Fail(err error) {
+	j.finish(StatusFailed, nil, err.Error())
+}
+
+func (j *Job) finish(status Status, result interface{}, errMsg string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status = status
+	j.result = result
+	j.err = errMsg
+	j.finishedAt = time.Now()
+	j.record(Event{Status: status, Message: errMsg})
+}
+
+// record appends an event and wakes up subscribers. The caller must hold j.mu.
+func (j *Job) record(event Event) {
+	event.Seq = len(j.events) + 1
+	event.JobID = j.id
+	event.Time = time.Now()
+	j.events = append(j.events, event)
+
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// EventsSince returns the events with a sequence number greater than seq, a channel
+// that is closed when the next event is recorded, and whether the job has finished.
+func (j *Job) EventsSince(seq int) ([]Event, <-chan struct{}, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if seq < 0 {
+		seq = 0
+	}
+	var events []Event
+	if seq < len(j.events) {
+		events = append(events, j.events[seq:]...)
+	}
+	return events, j.changed, j.isFinished()
+}
+
+// isFinished reports whether the job reached a final status. The caller must hold j.mu.
+func (j *Job) isFinished() bool {
+	return j.status == StatusCompleted || j.status == StatusFailed
+}
+
+// Snapshot returns a copy of the job state
+func (j *Job) Snapshot() Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	snapshot := Snapshot{
+		ID:        j.id,
+		Type:      j.jobType,
+		Status:    j.status,
+		CreatedAt: j.createdAt,
+		Summary:   make(map[Phase]int),
+		Cameras:   make([]CameraProgress, 0, len(j.order)),
+		Result:    j.result,
+		Error:     j.err,
+	}
+	if !j.startedAt.IsZero() {
+		startedAt := j.startedAt
+		snapshot.StartedAt = &startedAt
+	}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		snapshot.FinishedAt = &finishedAt
+	}
+	for _, cameraID := range j.order {
+		progress := *j.cameras[cameraID]
+		snapshot.Cameras = append(snapshot.Cameras, progress)
+		snapshot.Summary[progress.Phase]++
+	}
+
+	return snapshot
+}
+
+// Manager keeps the jobs of the running server in memory
+type Manager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager creates an empty job manager
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string]*Job)}
+}
+
+// Create registers a new pending job with every camera in the queued phase
+func (m *Manager) Create(jobType string, cameraIDs []string) *Job {
+	now := time.Now()
+	job := &Job{
+		id:        newJobID(),
+		jobType:   jobType,
+		status:    StatusPending,
+		createdAt: now,
+		cameras:   make(map[string]*CameraProgress, len(cameraIDs)),
+		changed:   make(chan struct{}),
+	}
+	for _, cameraID := range cameraIDs {
+		if _, exists := job.cameras[cameraID]; exists {
+			continue
+		}
+		job.cameras[cameraID] = &CameraProgress{CameraID: cameraID, Phase: PhaseQueued, UpdatedAt: now}
+		job.order = append(job.order, cameraID)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(now)
+	m.jobs[job.id] = job
+
+	return job
+}
+
+// Get returns a job by its ID
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	return job, nil
+}
+
+// List returns snapshots of all jobs, newest first
+func (m *Manager) List() []Snapshot {
+	m.mu.Lock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.Unlock()
+
+	snapshots := make([]Snapshot, 0, len(jobs))
+	for _, job := range jobs {
+		snapshots = append(snapshots, job.Snapshot())
+	}
+	sort.Slice(snapshots, func(i, k int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[k].CreatedAt)
+	})
+	return snapshots
+}
+
+// pruneLocked drops finished jobs older than finishedJobRetention. The caller must hold m.mu.
+func (m *Manager) pruneLocked(now time.Time) {
+	for id, job := range m.jobs {
+		job.mu.Lock()
+		expired := job.isFinished() && now.Sub(job.finishedAt) > finishedJobRetention
+		job.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random identifier for a job
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to the clock
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
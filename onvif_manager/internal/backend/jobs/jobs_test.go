@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	manager := NewManager()
+	job := manager.Create("apply-config", []string{"2", "1", "2"})
+
+	snapshot := job.Snapshot()
+	if snapshot.Status != StatusPending || snapshot.Type != "apply-config" || snapshot.StartedAt != nil {
+		t.Errorf("Snapshot() = %+v, want a pending apply-config job", snapshot)
+	}
+	var order []string
+	for _, progress := range snapshot.Cameras {
+		order = append(order, progress.CameraID)
+	}
+	if !reflect.DeepEqual(order, []string{"2", "1"}) || snapshot.Summary[PhaseQueued] != 2 {
+		t.Errorf("Snapshot() cameras = %v, summary %v, want [2 1] queued once each", order, snapshot.Summary)
+	}
+
+	if got, err := manager.Get(job.ID()); err != nil || got != job {
+		t.Errorf("Get(%q) = %v, %v, want the job", job.ID(), got, err)
+	}
+	if _, err := manager.Get("missing"); err == nil {
+		t.Error("Get() of an unknown job succeeded")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	job := NewManager().Create("apply-config", []string{"1", "2"})
+	job.Start()
+	job.SetPhase("1", PhaseConfiguring, "setting 1920x1080 @ 25 fps")
+	before := job.Snapshot()
+
+	job.SetPhase("1", PhaseDone, "valid")
+	job.SetPhase("3", PhaseFailed, "not in the request")
+	job.Complete(map[string]int{"cameras": 2})
+	after := job.Snapshot()
+
+	// A snapshot is not changed by later progress
+	if before.Cameras[0].Phase != PhaseConfiguring || before.Summary[PhaseConfiguring] != 1 || before.FinishedAt != nil {
+		t.Errorf("earlier Snapshot() changed to %+v", before)
+	}
+
+	wantSummary := map[Phase]int{PhaseDone: 1, PhaseQueued: 1, PhaseFailed: 1}
+	if !reflect.DeepEqual(after.Summary, wantSummary) {
+		t.Errorf("Snapshot() summary = %v, want %v", after.Summary, wantSummary)
+	}
+	if len(after.Cameras) != 3 || after.Cameras[2].CameraID != "3" {
+		t.Errorf("Snapshot() cameras = %+v, want a camera reported later at the end", after.Cameras)
+	}
+	if after.Status != StatusCompleted || after.StartedAt == nil || after.FinishedAt == nil || after.Result == nil {
+		t.Errorf("Snapshot() = %+v, want a completed job with its times and result", after)
+	}
+
+	failed := NewManager().Create("apply-config", nil)
+	failed.Fail(errors.New("no cameras"))
+	if snapshot := failed.Snapshot(); snapshot.Status != StatusFailed || snapshot.Error != "no cameras" {
+		t.Errorf("Snapshot() of a failed job = %+v", snapshot)
+	}
+}
+
+func TestEventsSince(t *testing.T) {
+	job := NewManager().Create("apply-config", []string{"1"})
+	job.Start()
+	job.SetPhase("1", PhaseConnecting, "connecting")
+
+	events, changed, finished := job.EventsSince(0)
+	if len(events) != 2 || events[0].Seq != 1 || events[0].Status != StatusRunning || events[1].Seq != 2 || events[1].CameraID != "1" {
+		t.Fatalf("EventsSince(0) = %+v, want the status and the phase event", events)
+	}
+	if finished {
+		t.Error("EventsSince() reports a running job as finished")
+	}
+	select {
+	case <-changed:
+		t.Fatal("change channel closed before the next event")
+	default:
+	}
+
+	job.SetPhase("1", PhaseDone, "valid")
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("change channel not closed by the next event")
+	}
+
+	job.Complete(nil)
+	tests := []struct {
+		seq      int
+		wantSeqs []int
+	}{
+		{seq: -1, wantSeqs: []int{1, 2, 3, 4}},
+		{seq: 2, wantSeqs: []int{3, 4}},
+		{seq: 4, wantSeqs: nil},
+		{seq: 10, wantSeqs: nil},
+	}
+	for _, tt := range tests {
+		events, _, finished := job.EventsSince(tt.seq)
+		var seqs []int
+		for _, event := range events {
+			seqs = append(seqs, event.Seq)
+		}
+		if !reflect.DeepEqual(seqs, tt.wantSeqs) || !finished {
+			t.Errorf("EventsSince(%d) = %v, finished %v, want %v, finished", tt.seq, seqs, finished, tt.wantSeqs)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	manager := NewManager()
+	expired := manager.Create("apply-config", nil)
+	expired.Complete(nil)
+	recent := manager.Create("apply-config", nil)
+	recent.Complete(nil)
+	running := manager.Create("apply-config", nil)
+	running.Start()
+
+	// Finished and started long ago
+	for _, job := range []*Job{expired, running} {
+		job.mu.Lock()
+		job.createdAt = time.Now().Add(-2 * finishedJobRetention)
+		job.startedAt = job.createdAt
+		job.mu.Unlock()
+	}
+	expired.mu.Lock()
+	expired.finishedAt = time.Now().Add(-finishedJobRetention - time.Minute)
+	expired.mu.Unlock()
+
+	newest := manager.Create("apply-config", nil)
+
+	if _, err := manager.Get(expired.ID()); err == nil {
+		t.Error("a job finished longer ago than the retention was kept")
+	}
+	for _, job := range []*Job{recent, running, newest} {
+		if _, err := manager.Get(job.ID()); err != nil {
+			t.Errorf("job %s was pruned: %v", job.ID(), err)
+		}
+	}
+
+	list := manager.List()
+	if len(list) != 3 || list[0].ID != newest.ID() || list[2].ID != running.ID() {
+		t.Errorf("List() = %d jobs starting with %s, want 3 newest first", len(list), list[0].ID)
+	}
+}
@@ -0,0 +1,260 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemorySpec is the store spec that selects a non-persistent in-memory store
+const MemorySpec = "memory"
+
+// Lock file timing; variables so that tests can shorten them
+var (
+	lockRetryInterval = 20 * time.Millisecond
+	lockTimeout       = 10 * time.Second // How long a change waits for another process
+	staleLockAge      = 30 * time.Second // Lock files older than this were left behind by a crash
+)
+
+// Format describes the document kept in a file
+type Format[T any] struct {
+	Name    string       // What the document holds, used in errors and temporary file names
+	Version int          // Newest version of the format that can be read
+	Empty   func() *T    // Returns an empty document of the current version
+	Fill    func(doc *T) // Sets the fields a file left out after reading; may be nil
+}
+
+// File keeps a versioned JSON document in a file, or in memory when it has no path.
+// The CLI and the server share the file: changes are made under a lock file and
+// always start from the file's current contents, and Stale reports changes made by
+// other processes.
+type File[T any] struct {
+	path   string
+	format Format[T]
+	mu     sync.Mutex
+	memory *T
+	stamp  fileStamp // The file as last read or written through this File
+}
+
+// New creates the document kept in the JSON file at path, or in memory when path is empty.
+// The file and its parent directory are created on the first change.
+func New[T any](path string, format Format[T]) *File[T] {
+	file := &File[T]{path: path, format: format}
+	if path == "" {
+		file.memory = format.Empty()
+	}
+	return file
+}
+
+// DefaultPath returns the location of the named file in the user's configuration
+// directory, where the CLI and the server both find it
+func DefaultPath(name string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "onvif-manager", name), nil
+}
+
+// Path returns the location of the file, or empty for a document kept in memory
+func (f *File[T]) Path() string {
+	return f.path
+}
+
+// Stale reports whether another process changed the file since it was last read or
+// written through f
+func (f *File[T]) Stale() bool {
+	if f.memory != nil {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, err := statFile(f.path)
+	return err == nil && !current.equal(f.stamp)
+}
+
+// Read calls fn with the document as currently stored. A missing file is read as an
+// empty document. fn must not keep the document or change it.
+func (f *File[T]) Read(fn func(doc *T) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	doc, err := f.load()
+	if err != nil {
+		return err
+	}
+	return fn(doc)
+}
+
+// Update reads the document, applies fn and writes the result, holding the lock file
+// throughout so that changes made by the CLI and the server are never lost. Nothing
+// is written when fn fails.
+func (f *File[T]) Update(fn func(doc *T) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.memory != nil {
+		return fn(f.memory)
+	}
+
+	unlock, err := lockFile(f.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	doc, err := f.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(doc); err != nil {
+		return err
+	}
+	return f.save(doc)
+}
+
+// load reads the file and remembers its stamp. The caller holds f.mu.
+func (f *File[T]) load() (*T, error) {
+	if f.memory != nil {
+		return f.memory, nil
+	}
+
+	// Stat first: a change made while reading is then picked up by the next Stale
+	stamp, err := statFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file %s: %w", f.format.Name, f.path, err)
+	}
+
+	doc := f.format.Empty()
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		f.stamp = stamp
+		return doc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file %s: %w", f.format.Name, f.path, err)
+	}
+
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse %s file %s: %w", f.format.Name, f.path, err)
+	}
+	if header.Version > f.format.Version {
+		return nil, fmt.Errorf("%s file %s has unsupported version %d", f.format.Name, f.path, header.Version)
+	}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s file %s: %w", f.format.Name, f.path, err)
+	}
+	if f.format.Fill != nil {
+		f.format.Fill(doc)
+	}
+
+	f.stamp = stamp
+	return doc, nil
+}
+
+// save writes the document to a temporary file and renames it into place, so a crash
+// mid-write never leaves a truncated file behind. The caller holds f.mu and the lock file.
+func (f *File[T]) save(doc *T) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", f.format.Name, err)
+	}
+
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s directory %s: %w", f.format.Name, dir, err)
+	}
+
+	// CreateTemp makes the file readable by its owner only, which keeps camera passwords private
+	base := filepath.Base(f.path)
+	ext := filepath.Ext(base)
+	tmp, err := os.CreateTemp(dir, "."+strings.TrimSuffix(base, ext)+"-*"+ext)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary %s file: %w", f.format.Name, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once the rename succeeded
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s file: %w", f.format.Name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s file: %w", f.format.Name, err)
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		return fmt.Errorf("failed to replace %s file %s: %w", f.format.Name, f.path, err)
+	}
+
+	if stamp, err := statFile(f.path); err == nil {
+		f.stamp = stamp
+	}
+	return nil
+}
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// statFile returns the stamp of the file at path
+func statFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fileStamp{}, nil
+	}
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// equal reports whether two stamps describe the same version of the file
+func (a fileStamp) equal(b fileStamp) bool {
+	return a.exists == b.exists && a.size == b.size && a.modTime.Equal(b.modTime)
+}
+
+// lockFile creates the lock file of the file at path, waiting while another process
+// holds it, and returns the function that removes it. A lock file is used rather
+// than an OS file lock so that it works the same on every supported platform.
+func lockFile(path string) (func(), error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			fmt.Fprintf(file, "%d\n", os.Getpid())
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", lockPath, err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(lockPath) // Left behind by a process that exited while holding it
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock file %s, remove it if no other onvif-manager is running", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
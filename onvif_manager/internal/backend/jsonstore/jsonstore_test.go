@@ -0,0 +1,229 @@
+package jsonstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDoc is the document kept by the files of the tests
+type testDoc struct {
+	Version int      `json:"version"`
+	Items   []string `json:"items"`
+}
+
+var testFormat = Format[testDoc]{
+	Name:    "test",
+	Version: 1,
+	Empty:   func() *testDoc { return &testDoc{Version: 1, Items: []string{}} },
+	Fill: func(doc *testDoc) {
+		if doc.Items == nil {
+			doc.Items = []string{}
+		}
+	},
+}
+
+// add returns an update that appends item to the document
+func add(item string) func(doc *testDoc) error {
+	return func(doc *testDoc) error {
+		doc.Items = append(doc.Items, item)
+		return nil
+	}
+}
+
+// items reads the items of the document kept in f
+func items(t *testing.T, f *File[testDoc]) []string {
+	t.Helper()
+	var got []string
+	if err := f.Read(func(doc *testDoc) error {
+		got = append([]string{}, doc.Items...)
+		return nil
+	}); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	return got
+}
+
+// shortenLockTiming makes lock files time out and turn stale quickly for one test
+func shortenLockTiming(t *testing.T) {
+	retry, timeout, stale := lockRetryInterval, lockTimeout, staleLockAge
+	lockRetryInterval, lockTimeout, staleLockAge = time.Millisecond, 50*time.Millisecond, time.Minute
+	t.Cleanup(func() {
+		lockRetryInterval, lockTimeout, staleLockAge = retry, timeout, stale
+	})
+}
+
+func TestFileUpdate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config")
+	path := filepath.Join(dir, "test.json")
+	file := New(path, testFormat)
+
+	if got := items(t, file); len(got) != 0 {
+		t.Errorf("Read() of a missing file = %v, want an empty document", got)
+	}
+	if err := file.Update(add("a")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := file.Update(add("b")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got := items(t, file); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Read() = %v, want [a b]", got)
+	}
+
+	// The temporary file was renamed into place and the lock file removed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "test.json" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory holds %v, want only test.json", names)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("file mode = %o, want 600", mode)
+	}
+
+	var saved testDoc
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Version != 1 {
+		t.Errorf("file holds %s, want a document of version 1", data)
+	}
+
+	// A failed change writes nothing
+	before, _ := os.ReadFile(path)
+	errRejected := errors.New("rejected")
+	err = file.Update(func(doc *testDoc) error {
+		doc.Items = nil
+		return errRejected
+	})
+	if !errors.Is(err, errRejected) {
+		t.Errorf("Update() error = %v, want the error of the change", err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("a failed Update() changed the file to %s", after)
+	}
+	if _, err := os.Stat(path + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file left behind after a failed Update(): %v", err)
+	}
+}
+
+func TestFileMemory(t *testing.T) {
+	file := New("", testFormat)
+	if err := file.Update(add("a")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got := items(t, file); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Read() = %v, want [a]", got)
+	}
+	if file.Path() != "" || file.Stale() {
+		t.Errorf("in-memory file has path %q and Stale() = %v", file.Path(), file.Stale())
+	}
+}
+
+func TestFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	server, cli := New(path, testFormat), New(path, testFormat)
+
+	if err := server.Update(add("server")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if server.Stale() {
+		t.Error("Stale() after the file's own change = true")
+	}
+
+	// Another process changes the file, starting from what the server wrote
+	if err := cli.Update(add("cli")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if !server.Stale() {
+		t.Error("Stale() after another process changed the file = false")
+	}
+	if got := items(t, server); !reflect.DeepEqual(got, []string{"server", "cli"}) {
+		t.Errorf("Read() = %v, want both changes", got)
+	}
+	if server.Stale() {
+		t.Error("Stale() after reading the change = true")
+	}
+}
+
+func TestFileVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2, "items": ["a"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(path, testFormat).Read(func(doc *testDoc) error { return nil }); err == nil || !strings.Contains(err.Error(), "unsupported version 2") {
+		t.Errorf("Read() of a newer version error = %v, want unsupported version", err)
+	}
+
+	// Fields a file leaves out are filled in
+	if err := os.WriteFile(path, []byte(`{"version": 1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := items(t, New(path, testFormat)); got == nil || len(got) != 0 {
+		t.Errorf("Read() = %#v, want empty items", got)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	shortenLockTiming(t)
+
+	tests := []struct {
+		name    string
+		lockAge time.Duration // Age of a lock file left by another process; 0 for none
+		wantErr bool
+	}{
+		{name: "no lock"},
+		{name: "held by another process", lockAge: time.Second, wantErr: true},
+		{name: "left behind by a crash", lockAge: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.json")
+			lockPath := path + ".lock"
+			if tt.lockAge > 0 {
+				if err := os.WriteFile(lockPath, []byte("1\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				modTime := time.Now().Add(-tt.lockAge)
+				if err := os.Chtimes(lockPath, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			file := New(path, testFormat)
+			err := file.Update(add("a"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("Update() without the lock wrote the file: %v", err)
+				}
+				if _, err := os.Stat(lockPath); err != nil {
+					t.Errorf("the lock of another process was removed: %v", err)
+				}
+				return
+			}
+			if got := items(t, file); !reflect.DeepEqual(got, []string{"a"}) {
+				t.Errorf("Read() = %v, want [a]", got)
+			}
+			if _, err := os.Stat(lockPath); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("lock file left behind: %v", err)
+			}
+		})
+	}
+}
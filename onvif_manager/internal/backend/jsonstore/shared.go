@@ -0,0 +1,77 @@
+package jsonstore
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// Location selects the file a store is kept in. The environment variable EnvVar holds
+// either a path to a JSON file or "memory" to disable persistence; when it is unset,
+// the file is FileName in the user's configuration directory, where the CLI and the
+// server both find it.
+type Location struct {
+	EnvVar   string
+	FileName string
+}
+
+// Path returns the file described by spec, a value of the environment variable:
+// empty for "memory", the default location when spec is empty, or the path spec holds
+func (l Location) Path(spec string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.EqualFold(spec, MemorySpec) {
+		return "", nil
+	}
+	if spec == "" {
+		return DefaultPath(l.FileName)
+	}
+	return spec, nil
+}
+
+// PathFromEnv returns the file selected by the environment variable, or empty for memory
+func (l Location) PathFromEnv() (string, error) {
+	return l.Path(os.Getenv(l.EnvVar))
+}
+
+// Shared is the store of a package that its API handlers, CLI commands and background
+// tasks use. It starts out in memory and is switched to the store selected by its
+// location once at startup.
+type Shared[S any] struct {
+	Location Location
+	newStore func(path string) S // Creates the store kept at path, or in memory when path is empty
+	mu       sync.RWMutex
+	store    S
+}
+
+// NewShared creates a shared in-memory store that OpenFromEnv replaces with the one
+// newStore creates at location
+func NewShared[S any](location Location, newStore func(path string) S) *Shared[S] {
+	return &Shared[S]{Location: location, newStore: newStore, store: newStore("")}
+}
+
+// OpenFromEnv opens the store selected by the location's environment variable, uses it
+// from now on and returns it
+func (s *Shared[S]) OpenFromEnv() (S, error) {
+	path, err := s.Location.PathFromEnv()
+	if err != nil {
+		var none S
+		return none, err
+	}
+	store := s.newStore(path)
+	s.Use(store)
+	return store, nil
+}
+
+// Use switches to the given store
+func (s *Shared[S]) Use(store S) {
+	s.mu.Lock()
+	s.store = store
+	s.mu.Unlock()
+}
+
+// Get returns the store in use
+func (s *Shared[S]) Get() S {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
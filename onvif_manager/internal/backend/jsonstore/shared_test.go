@@ -0,0 +1,55 @@
+package jsonstore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLocationPath(t *testing.T) {
+	location := Location{EnvVar: "ONVIF_MANAGER_TEST_STORE", FileName: "test.json"}
+	defaultPath, err := DefaultPath("test.json")
+	if err != nil {
+		t.Skipf("no user config directory: %v", err)
+	}
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{spec: "", want: defaultPath},
+		{spec: "memory", want: ""},
+		{spec: " Memory ", want: ""},
+		{spec: "/var/lib/onvif/test.json", want: "/var/lib/onvif/test.json"},
+	}
+
+	for _, tt := range tests {
+		got, err := location.Path(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("Path(%q) = %q, %v, want %q", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestSharedOpenFromEnv(t *testing.T) {
+	var created []string
+	shared := NewShared(Location{EnvVar: "ONVIF_MANAGER_TEST_STORE", FileName: "test.json"}, func(path string) string {
+		created = append(created, path)
+		return "store at " + path
+	})
+	if got := shared.Get(); got != "store at " {
+		t.Errorf("Get() before OpenFromEnv() = %q, want the in-memory store", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.json")
+	t.Setenv("ONVIF_MANAGER_TEST_STORE", path)
+	store, err := shared.OpenFromEnv()
+	if err != nil {
+		t.Fatalf("OpenFromEnv() error = %v", err)
+	}
+	if want := "store at " + path; store != want || shared.Get() != want {
+		t.Errorf("OpenFromEnv() = %q, Get() = %q, want %q", store, shared.Get(), want)
+	}
+	if len(created) != 2 {
+		t.Errorf("OpenFromEnv() created %d stores, want 2", len(created))
+	}
+}
@@ -0,0 +1,184 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Environment variables that override the default pool settings
+const (
+	ConcurrencyEnvVar = "ONVIF_MANAGER_CONCURRENCY"
+	TimeoutEnvVar     = "ONVIF_MANAGER_CAMERA_TIMEOUT"
+)
+
+// Default pool settings used when neither the request nor the environment set them
+const (
+	DefaultConcurrency = 8
+	DefaultTimeout     = 2 * time.Minute
+)
+
+// Options controls how many cameras are worked on at once and how long
+// a single camera may take before it is reported as timed out.
+type Options struct {
+	Concurrency int           `json:"concurrency"`
+	Timeout     time.Duration `json:"timeout"`
+	// StartCheck, when set, is asked before the work for an ID starts. When it fails the
+	// work is not started and the result for the ID comes from onError with its error.
+	// Work that has already started is not affected.
+	StartCheck func(id string) error `json:"-"`
+}
+
+// DefaultOptions returns the pool settings from the environment, falling back
+// to DefaultConcurrency and DefaultTimeout. The timeout accepts Go duration
+// syntax ("90s", "2m") or a plain number of seconds.
+func DefaultOptions() Options {
+	opts := Options{
+		Concurrency: DefaultConcurrency,
+		Timeout:     DefaultTimeout,
+	}
+
+	if value := os.Getenv(ConcurrencyEnvVar); value != "" {
+		if concurrency, err := strconv.Atoi(value); err == nil && concurrency > 0 {
+			opts.Concurrency = concurrency
+		}
+	}
+
+	if value := os.Getenv(TimeoutEnvVar); value != "" {
+		if timeout, err := ParseTimeout(value); err == nil {
+			opts.Timeout = timeout
+		}
+	}
+
+	return opts
+}
+
+// ParseTimeout parses a per-camera timeout given as a Go duration or a number of seconds
+func ParseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0, fmt.Errorf("timeout must be greater than 0")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %w", value, err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be greater than 0")
+	}
+	return timeout, nil
+}
+
+// WithOverrides returns a copy of opts with the non-zero values of concurrency
+// and timeout applied on top.
+func (o Options) WithOverrides(concurrency int, timeout time.Duration) Options {
+	if concurrency > 0 {
+		o.Concurrency = concurrency
+	}
+	if timeout > 0 {
+		o.Timeout = timeout
+	}
+	return o
+}
+
+// TimeoutError is returned for a camera whose work did not finish within the per-camera timeout
+type TimeoutError struct {
+	ID      string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("camera %s did not respond within %s", e.ID, e.Timeout)
+}
+
+// Run calls work for every ID using at most opts.Concurrency goroutines and
+// returns the results in the same order as ids.
+//
+// If work for an ID takes longer than opts.Timeout, or ctx is cancelled, the
+// result for that ID comes from onError instead. The ONVIF and FFmpeg calls
+// cannot be interrupted, so the abandoned call keeps running in the background
+// and its result is discarded; its worker slot is released immediately. The
+// context passed to work is cancelled when it is abandoned, so work that changes
+// a camera must check Abandoned before every write.
+func Run[T any](ctx context.Context, ids []string, opts Options, work func(ctx context.Context, id string) T, onError func(id string, err error) T) []T {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	if concurrency > len(ids) {
+		concurrency = len(ids)
+	}
+
+	results := make([]T, len(ids))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = runOne(ctx, ids[i], opts, work, onError)
+			}
+		}()
+	}
+
+	for i := range ids {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// Abandoned returns an error once the work given ctx was abandoned because it timed out
+// or the run was cancelled. Work that changes a camera checks it before every write,
+// so that an abandoned call never changes a camera after its result was reported.
+func Abandoned(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("abandoned before changing the camera: %w", err)
+	}
+	return nil
+}
+
+// runOne runs work for a single ID, enforcing the start check, the timeout and cancellation
+func runOne[T any](ctx context.Context, id string, opts Options, work func(ctx context.Context, id string) T, onError func(id string, err error) T) T {
+	if err := ctx.Err(); err != nil {
+		return onError(id, err)
+	}
+	if opts.StartCheck != nil {
+		if err := opts.StartCheck(id); err != nil {
+			return onError(id, err)
+		}
+	}
+
+	timeout := opts.Timeout
+	itemCtx := ctx
+	cancel := func() {}
+	if timeout > 0 {
+		itemCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	done := make(chan T, 1) // Buffered so an abandoned call can still finish
+	go func() {
+		done <- work(itemCtx, id)
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-itemCtx.Done():
+		if ctx.Err() != nil {
+			return onError(id, ctx.Err())
+		}
+		return onError(id, &TimeoutError{ID: id, Timeout: timeout})
+	}
+}
@@ -0,0 +1,209 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		ids         []string
+		concurrency int
+	}{
+		{name: "no ids", ids: nil, concurrency: 4},
+		{name: "fewer ids than workers", ids: []string{"1", "2"}, concurrency: 8},
+		{name: "more ids than workers", ids: []string{"1", "2", "3", "4", "5", "6", "7"}, concurrency: 3},
+		{name: "default concurrency", ids: []string{"1", "2", "3"}, concurrency: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, peak int32
+			results := Run(context.Background(), tt.ids, Options{Concurrency: tt.concurrency, Timeout: time.Second},
+				func(ctx context.Context, id string) string {
+					n := atomic.AddInt32(&running, 1)
+					for {
+						p := atomic.LoadInt32(&peak)
+						if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return "done " + id
+				},
+				func(id string, err error) string {
+					return "failed " + id
+				})
+
+			if len(results) != len(tt.ids) {
+				t.Fatalf("Run() returned %d results, want %d", len(results), len(tt.ids))
+			}
+			for i, id := range tt.ids {
+				if results[i] != "done "+id {
+					t.Errorf("result %d = %q, want %q", i, results[i], "done "+id)
+				}
+			}
+			limit := tt.concurrency
+			if limit <= 0 {
+				limit = DefaultConcurrency
+			}
+			if int(peak) > limit {
+				t.Errorf("%d calls ran at once, want at most %d", peak, limit)
+			}
+		})
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	abandoned := make(chan error, 1)
+
+	results := Run(context.Background(), []string{"fast", "slow"}, Options{Concurrency: 2, Timeout: 50 * time.Millisecond},
+		func(ctx context.Context, id string) error {
+			if id == "slow" {
+				time.Sleep(150 * time.Millisecond)
+				abandoned <- Abandoned(ctx)
+			}
+			return nil
+		},
+		func(id string, err error) error {
+			return err
+		})
+
+	if results[0] != nil {
+		t.Errorf("fast camera failed: %v", results[0])
+	}
+	var timeout *TimeoutError
+	if !errors.As(results[1], &timeout) || timeout.ID != "slow" {
+		t.Errorf("slow camera result = %v, want a TimeoutError", results[1])
+	}
+
+	// The abandoned call keeps running and must see that it may no longer write
+	select {
+	case err := <-abandoned:
+		if err == nil {
+			t.Errorf("Abandoned() = nil in a call that timed out")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the abandoned call did not finish")
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls int32
+	results := Run(ctx, []string{"1", "2", "3"}, Options{Concurrency: 2},
+		func(ctx context.Context, id string) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		},
+		func(id string, err error) error {
+			return fmt.Errorf("camera %s: %w", id, err)
+		})
+
+	if calls != 0 {
+		t.Errorf("work was called %d times after the context was cancelled", calls)
+	}
+	for i, err := range results {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("result %d = %v, want context.Canceled", i, err)
+		}
+	}
+}
+
+func TestAbandoned(t *testing.T) {
+	if err := Abandoned(context.Background()); err != nil {
+		t.Errorf("Abandoned() = %v for a live context", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Abandoned(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Abandoned() = %v, want it to wrap context.Canceled", err)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "90", want: 90 * time.Second},
+		{value: "90s", want: 90 * time.Second},
+		{value: "2m", want: 2 * time.Minute},
+		{value: "0", wantErr: true},
+		{value: "-5s", wantErr: true},
+		{value: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimeout(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTimeout(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTimeout(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWithOverrides(t *testing.T) {
+	base := Options{Concurrency: 8, Timeout: time.Minute}
+	tests := []struct {
+		name        string
+		concurrency int
+		timeout     time.Duration
+		want        Options
+	}{
+		{name: "none", want: base},
+		{name: "concurrency", concurrency: 2, want: Options{Concurrency: 2, Timeout: time.Minute}},
+		{name: "timeout", timeout: time.Second, want: Options{Concurrency: 8, Timeout: time.Second}},
+		{name: "negative values are ignored", concurrency: -1, timeout: -time.Second, want: base},
+	}
+
+	for _, tt := range tests {
+		if got := base.WithOverrides(tt.concurrency, tt.timeout); got.Concurrency != tt.want.Concurrency || got.Timeout != tt.want.Timeout {
+			t.Errorf("%s: WithOverrides() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStartCheck(t *testing.T) {
+	var started []string
+	var mu sync.Mutex
+	opts := Options{Concurrency: 1, Timeout: time.Second, StartCheck: func(id string) error {
+		if id == "2" {
+			return errors.New("not now")
+		}
+		return nil
+	}}
+
+	results := Run(context.Background(), []string{"1", "2", "3"}, opts,
+		func(ctx context.Context, id string) error {
+			mu.Lock()
+			started = append(started, id)
+			mu.Unlock()
+			return nil
+		},
+		func(id string, err error) error {
+			return err
+		})
+
+	if !reflect.DeepEqual(started, []string{"1", "3"}) {
+		t.Errorf("started %v, want the cameras that passed the check", started)
+	}
+	if results[0] != nil || results[2] != nil || results[1] == nil || results[1].Error() != "not now" {
+		t.Errorf("Run() = %v, want the check's error for camera 2 only", results)
+	}
+}
@@ -0,0 +1,114 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"onvif_manager/internal/backend/pool"
+)
+
+// Environment variables that enable periodic reconciling in server mode
+const (
+	DesiredStateEnvVar = "ONVIF_MANAGER_DESIRED_STATE"
+	IntervalEnvVar     = "ONVIF_MANAGER_RECONCILE_INTERVAL"
+)
+
+// DefaultInterval is the time between two reconcile runs in server mode
+const DefaultInterval = 10 * time.Minute
+
+// Status is the state of the periodic reconciler and the results of its last run
+type Status struct {
+	Enabled          bool       `json:"enabled"`
+	DesiredStateFile string     `json:"desiredStateFile,omitempty"`
+	Interval         string     `json:"interval,omitempty"`
+	LastError        string     `json:"lastError,omitempty"` // Why the desired state could not be read in the last run
+	LastRun          *time.Time `json:"lastRun,omitempty"`
+	Results          *Results   `json:"results,omitempty"`
+}
+
+var (
+	statusMu sync.RWMutex
+	status   Status
+)
+
+// StartFromEnv starts the periodic reconciler when ONVIF_MANAGER_DESIRED_STATE names a
+// desired-state file. The file is checked once up front and read again on every run,
+// so that edits take effect without a restart.
+func StartFromEnv(ctx context.Context) error {
+	path := os.Getenv(DesiredStateEnvVar)
+	if path == "" {
+		return nil
+	}
+
+	interval := DefaultInterval
+	if value := os.Getenv(IntervalEnvVar); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid %s %q", IntervalEnvVar, value)
+		}
+		interval = parsed
+	}
+
+	if _, err := LoadFile(path); err != nil {
+		return err
+	}
+
+	Start(ctx, path, interval)
+	return nil
+}
+
+// Start reconciles the desired-state file right away and then every interval until ctx is done
+func Start(ctx context.Context, path string, interval time.Duration) {
+	statusMu.Lock()
+	status = Status{Enabled: true, DesiredStateFile: path, Interval: interval.String()}
+	statusMu.Unlock()
+
+	log.Printf("Reconciling %s every %s", path, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			runOnce(ctx, path)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Latest returns the state of the periodic reconciler
+func Latest() Status {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	return status
+}
+
+// runOnce reads the desired-state file and reconciles the cameras in it
+func runOnce(ctx context.Context, path string) {
+	state, err := LoadFile(path)
+	if err != nil {
+		log.Printf("Reconcile skipped: %v", err)
+		statusMu.Lock()
+		now := time.Now()
+		status.LastError = err.Error()
+		status.LastRun = &now
+		statusMu.Unlock()
+		return
+	}
+
+	results := Run(ctx, state, true, pool.DefaultOptions())
+	log.Printf("Reconcile completed: %d in sync, %d reconciled, %d failed",
+		results.Summary.InSyncCams, results.Summary.ReconciledCams, results.Summary.FailedCams)
+
+	statusMu.Lock()
+	status.LastError = ""
+	status.LastRun = &results.CheckedAt
+	status.Results = results
+	statusMu.Unlock()
+}
@@ -0,0 +1,241 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/ffmpeg"
+	"onvif_manager/internal/backend/pool"
+)
+
+// Reconcile states of a profile and of a camera, from best to worst
+const (
+	StatusInSync     = "in-sync"    // The camera has the desired configuration
+	StatusReconciled = "reconciled" // The configuration drifted and was corrected
+	StatusDrifted    = "drifted"    // The configuration differs and was not changed
+	StatusFailed     = "failed"     // The camera could not be read, changed or validated
+)
+
+// Results represents the overall results of a reconcile run
+type Results struct {
+	CheckedAt     time.Time                `json:"checkedAt"`
+	Applied       bool                     `json:"applied"`     // Whether drifted configurations were corrected
+	CameraOrder   []string                 `json:"cameraOrder"` // Camera IDs in the order of the desired state
+	CameraResults map[string]*CameraResult `json:"cameraResults"`
+	Summary       Summary                  `json:"summary"`
+}
+
+// CameraResult represents the reconcile result of a single camera
+type CameraResult struct {
+	CameraID string           `json:"cameraId"`
+	Status   string           `json:"status"`
+	Error    string           `json:"error,omitempty"`
+	Profiles []*ProfileResult `json:"profiles"`
+}
+
+// ProfileResult represents the reconcile result of one desired profile
+type ProfileResult struct {
+	Profile            string                   `json:"profile"` // Profile selector from the desired state
+	ProfileToken       string                   `json:"profileToken,omitempty"`
+	ConfigToken        string                   `json:"configToken,omitempty"`
+	Status             string                   `json:"status"`
+	Changes            []camera.ConfigChange    `json:"changes"` // Settings that differ from the desired state
+	ResolutionAdjusted bool                     `json:"resolutionAdjusted"`
+	Validation         *ffmpeg.ValidationResult `json:"validation,omitempty"`
+	Error              string                   `json:"error,omitempty"`
+}
+
+// Summary represents a summary of a reconcile run
+type Summary struct {
+	TotalCameras   int `json:"totalCameras"`
+	InSyncCams     int `json:"inSyncCams"`
+	DriftedCams    int `json:"driftedCams"`
+	ReconciledCams int `json:"reconciledCams"`
+	FailedCams     int `json:"failedCams"`
+	Changes        int `json:"changes"` // Differing settings over all cameras
+}
+
+// Run compares the cameras of the desired state with their actual configuration through
+// the bounded worker pool. With apply the settings that differ are written and the stream
+// is validated; without it the drift is only reported.
+func Run(ctx context.Context, state *DesiredState, apply bool, opts pool.Options) *Results {
+	log.Printf("Reconciling %d cameras (apply %v, concurrency %d, timeout %s)", len(state.Cameras), apply, opts.Concurrency, opts.Timeout)
+
+	results := &Results{
+		CheckedAt:     time.Now(),
+		Applied:       apply,
+		CameraResults: make(map[string]*CameraResult),
+	}
+	desired := make(map[string]DesiredCamera)
+	for _, cam := range state.Cameras {
+		results.CameraOrder = append(results.CameraOrder, cam.ID)
+		desired[cam.ID] = cam
+	}
+
+	reconciled := pool.Run(ctx, results.CameraOrder, opts,
+		func(ctx context.Context, cameraID string) *CameraResult {
+			return reconcileCamera(ctx, desired[cameraID], apply)
+		},
+		func(cameraID string, err error) *CameraResult {
+			return &CameraResult{CameraID: cameraID, Status: StatusFailed, Error: fmt.Sprintf("Reconcile aborted: %v", err)}
+		})
+
+	results.Summary.TotalCameras = len(results.CameraOrder)
+	for i, cameraID := range results.CameraOrder {
+		result := reconciled[i]
+		results.CameraResults[cameraID] = result
+
+		switch result.Status {
+		case StatusInSync:
+			results.Summary.InSyncCams++
+		case StatusDrifted:
+			results.Summary.DriftedCams++
+		case StatusReconciled:
+			results.Summary.ReconciledCams++
+		default:
+			results.Summary.FailedCams++
+		}
+		for _, profile := range result.Profiles {
+			results.Summary.Changes += len(profile.Changes)
+		}
+	}
+
+	return results
+}
+
+// reconcileCamera reconciles the desired profiles of one camera. Nothing is written once ctx is done.
+func reconcileCamera(ctx context.Context, desired DesiredCamera, apply bool) *CameraResult {
+	result := &CameraResult{CameraID: desired.ID, Status: StatusInSync, Profiles: []*ProfileResult{}}
+
+	unlock := camera.LockCamera(desired.ID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(desired.ID)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	profiles, err := camera.GetProfiles(client)
+	if err != nil {
+		log.Printf("Failed to get profiles of camera %s: %v", desired.ID, err)
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	for _, desiredProfile := range desired.Profiles {
+		profileResult := reconcileProfile(ctx, client, profiles, desiredProfile, apply)
+		result.Profiles = append(result.Profiles, profileResult)
+		if statusRank(profileResult.Status) > statusRank(result.Status) {
+			result.Status = profileResult.Status
+		}
+	}
+	return result
+}
+
+// reconcileProfile compares one profile with its desired configuration and corrects it when apply is set
+func reconcileProfile(ctx context.Context, client *camera.CameraClient, profiles []camera.Profile, desired DesiredProfile, apply bool) *ProfileResult {
+	cameraID := client.Camera.ID
+	result := &ProfileResult{Profile: desired.Profile, Status: StatusFailed, Changes: []camera.ConfigChange{}}
+
+	profile, err := camera.SelectProfile(profiles, desired.Profile)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.ProfileToken = profile.Token
+	result.ConfigToken = profile.ConfigToken
+
+	current, err := camera.GetCurrentConfig(client, profile.ConfigToken)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	options, err := camera.GetEncoderOptions(client, profile.Token, profile.ConfigToken)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get encoder options: %v", err)
+		return result
+	}
+	encodingOptions, err := camera.SelectEncodingOptions(options, camera.TargetEncoding(desired.Encoding, current.Encoding))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// The desired resolution is matched to the closest one the camera offers, as config apply does
+	requested := desired.Resolution()
+	resolution := camera.FindClosestResolution(requested, encodingOptions.Resolutions)
+	config := desired.EncoderConfig(resolution)
+	plan := camera.PlanEncoderConfig(current, config, requested)
+	result.Changes = plan.Changes
+	result.ResolutionAdjusted = plan.ResolutionAdjusted
+
+	if len(plan.Changes) == 0 {
+		result.Status = StatusInSync
+		return result
+	}
+	log.Printf("Camera %s profile %s drifted in %d setting(s)", cameraID, profile, len(plan.Changes))
+	if !apply {
+		result.Status = StatusDrifted
+		return result
+	}
+
+	if err := camera.ValidateEncoderConfig(encodingOptions, config); err != nil {
+		result.Error = fmt.Sprintf("desired config not supported: %v", err)
+		return result
+	}
+	if err := pool.Abandoned(ctx); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if err := camera.SetEncoderConfig(client, profile.ConfigToken, current, config); err != nil {
+		result.Error = fmt.Sprintf("failed to set encoder config: %v", err)
+		return result
+	}
+
+	streamURL, err := client.GetAuthenticatedStreamURI(profile.Token)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// Wait for the new configuration to stabilize before checking the stream
+	time.Sleep(1 * time.Second)
+	validation, err := ffmpeg.ValidateStream(streamURL, resolution.Width, resolution.Height, desired.FPS, desired.Bitrate, desired.Encoding, desired.GOP, desired.RateControl)
+	if err != nil {
+		result.Error = fmt.Sprintf("validation failed: %v", err)
+		return result
+	}
+	result.Validation = validation
+
+	// Only a resolution mismatch fails validation; frame rate and bitrate differences are warnings
+	if validation.ActualWidth != resolution.Width || validation.ActualHeight != resolution.Height {
+		result.Error = fmt.Sprintf("stream is %dx%d after reconcile, expected %dx%d",
+			validation.ActualWidth, validation.ActualHeight, resolution.Width, resolution.Height)
+		return result
+	}
+
+	log.Printf("Reconciled camera %s profile %s", cameraID, profile)
+	result.Status = StatusReconciled
+	return result
+}
+
+// statusRank orders the statuses from best to worst
+func statusRank(status string) int {
+	switch status {
+	case StatusInSync:
+		return 0
+	case StatusReconciled:
+		return 1
+	case StatusDrifted:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,190 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+
+	"github.com/videonext/onvif/soap"
+)
+
+// Media2 responses of a camera whose main stream is 1920x1080 H.264 at 25 fps
+const (
+	encoderConfigResponse = `<GetVideoEncoderConfigurationsResponse xmlns:tt="http://www.onvif.org/ver10/schema">
+  <Configurations token="enc_main" GovLength="50" Profile="Main">
+    <tt:Name>Main stream</tt:Name>
+    <tt:Encoding>H264</tt:Encoding>
+    <tt:Resolution><tt:Width>1920</tt:Width><tt:Height>1080</tt:Height></tt:Resolution>
+    <tt:RateControl ConstantBitRate="false">
+      <tt:FrameRateLimit>25</tt:FrameRateLimit>
+      <tt:BitrateLimit>4096</tt:BitrateLimit>
+    </tt:RateControl>
+    <tt:Quality>5</tt:Quality>
+  </Configurations>
+</GetVideoEncoderConfigurationsResponse>`
+
+	encoderOptionsResponse = `<GetVideoEncoderConfigurationOptionsResponse xmlns:tt="http://www.onvif.org/ver10/schema">
+  <Options GovLengthRange="1 100" FrameRatesSupported="25 15" ProfilesSupported="Baseline Main High" ConstantBitRateSupported="true">
+    <tt:Encoding>H264</tt:Encoding>
+    <tt:QualityRange><tt:Min>1</tt:Min><tt:Max>10</tt:Max></tt:QualityRange>
+    <tt:ResolutionsAvailable><tt:Width>1920</tt:Width><tt:Height>1080</tt:Height></tt:ResolutionsAvailable>
+    <tt:ResolutionsAvailable><tt:Width>1280</tt:Width><tt:Height>720</tt:Height></tt:ResolutionsAvailable>
+    <tt:BitrateRange><tt:Min>256</tt:Min><tt:Max>8192</tt:Max></tt:BitrateRange>
+  </Options>
+</GetVideoEncoderConfigurationOptionsResponse>`
+)
+
+// fakeMedia2 answers the Media2 requests of reconcileProfile and records the operations called
+type fakeMedia2 struct {
+	mu         sync.Mutex
+	operations []string
+}
+
+func (f *fakeMedia2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	action := strings.Trim(r.Header.Get("Soapaction"), `"`)
+	operation := action[strings.LastIndex(action, "/")+1:]
+
+	f.mu.Lock()
+	f.operations = append(f.operations, operation)
+	f.mu.Unlock()
+
+	var body string
+	switch operation {
+	case "GetVideoEncoderConfigurations":
+		body = encoderConfigResponse
+	case "GetVideoEncoderConfigurationOptions":
+		body = encoderOptionsResponse
+	default:
+		http.Error(w, "unexpected operation "+operation, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/soap+xml")
+	fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body>%s</s:Body></s:Envelope>`, body)
+}
+
+// called returns the operations the fake camera was asked for
+func (f *fakeMedia2) called() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.operations...)
+}
+
+func TestReconcileProfile(t *testing.T) {
+	profiles := []camera.Profile{
+		{Token: "prof_main", Name: "MainStream", Role: camera.ProfileRoleMain, ConfigToken: "enc_main"},
+	}
+
+	tests := []struct {
+		name         string
+		desired      DesiredProfile
+		apply        bool
+		wantStatus   string
+		wantChanges  []string // Settings that differ
+		wantAdjusted bool
+		wantErr      string
+	}{
+		{
+			name:       "in sync",
+			desired:    DesiredProfile{Profile: "main", Width: 1920, Height: 1080, FPS: 25},
+			wantStatus: StatusInSync,
+		},
+		{
+			name:       "in sync with every setting",
+			desired:    DesiredProfile{Profile: "MainStream", Width: 1920, Height: 1080, FPS: 25, Bitrate: 4096, Encoding: "H264", GOP: 50, EncoderProfile: "main", RateControl: camera.RateControlVBR, Quality: 5},
+			wantStatus: StatusInSync,
+		},
+		{
+			name:        "drifted",
+			desired:     DesiredProfile{Profile: "prof_main", Width: 1280, Height: 720, FPS: 15, Bitrate: 2048, RateControl: camera.RateControlCBR},
+			wantStatus:  StatusDrifted,
+			wantChanges: []string{"resolution", "fps", "bitrate", "rateControl"},
+		},
+		{
+			name:         "closest resolution",
+			desired:      DesiredProfile{Profile: "main", Width: 1920, Height: 1088, FPS: 25},
+			wantStatus:   StatusInSync,
+			wantAdjusted: true,
+		},
+		{
+			name:       "unknown profile",
+			desired:    DesiredProfile{Profile: "thermal", Width: 640, Height: 480, FPS: 9},
+			wantStatus: StatusFailed,
+			wantErr:    "thermal",
+		},
+		{
+			name:       "unsupported encoding",
+			desired:    DesiredProfile{Profile: "main", Width: 1920, Height: 1080, FPS: 25, Encoding: camera.EncodingH265},
+			wantStatus: StatusFailed,
+			wantErr:    "does not support H265",
+		},
+		{
+			name:        "unsupported setting is not applied",
+			desired:     DesiredProfile{Profile: "main", Width: 1920, Height: 1080, FPS: 30},
+			apply:       true,
+			wantStatus:  StatusFailed,
+			wantChanges: []string{"fps"},
+			wantErr:     "desired config not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeMedia2{}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+			client := &camera.CameraClient{
+				Camera:   models.Camera{ID: "1"},
+				Client:   soap.NewClient(),
+				Services: camera.ServiceEndpoints{Media2: server.URL},
+			}
+
+			result := reconcileProfile(context.Background(), client, profiles, tt.desired, tt.apply)
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("status = %s (error %q), want %s", result.Status, result.Error, tt.wantStatus)
+			}
+			var settings []string
+			for _, change := range result.Changes {
+				settings = append(settings, change.Setting)
+			}
+			if !reflect.DeepEqual(settings, tt.wantChanges) {
+				t.Errorf("changes = %v, want %v", settings, tt.wantChanges)
+			}
+			if result.ResolutionAdjusted != tt.wantAdjusted {
+				t.Errorf("ResolutionAdjusted = %v, want %v", result.ResolutionAdjusted, tt.wantAdjusted)
+			}
+			if tt.wantErr != "" && !strings.Contains(result.Error, tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", result.Error, tt.wantErr)
+			}
+			if tt.wantStatus != StatusFailed || tt.wantChanges != nil {
+				if result.ProfileToken != "prof_main" || result.ConfigToken != "enc_main" {
+					t.Errorf("tokens = %s, %s, want prof_main, enc_main", result.ProfileToken, result.ConfigToken)
+				}
+			}
+			for _, operation := range fake.called() {
+				if strings.HasPrefix(operation, "Set") {
+					t.Errorf("camera was changed with %s", operation)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusRank(t *testing.T) {
+	ordered := []string{StatusInSync, StatusReconciled, StatusDrifted, StatusFailed}
+	for i := 1; i < len(ordered); i++ {
+		if statusRank(ordered[i-1]) >= statusRank(ordered[i]) {
+			t.Errorf("statusRank(%s) >= statusRank(%s), want %s better", ordered[i-1], ordered[i], ordered[i-1])
+		}
+	}
+}
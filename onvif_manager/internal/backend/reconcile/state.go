@@ -0,0 +1,141 @@
+package reconcile
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DesiredState is the encoder configuration the cameras should have, as read from a desired-state file
+type DesiredState struct {
+	Cameras []DesiredCamera `yaml:"cameras" json:"cameras"`
+}
+
+// DesiredCamera lists the desired encoder settings of the profiles of one inventoried camera
+type DesiredCamera struct {
+	ID       string           `yaml:"id" json:"id"`
+	Profiles []DesiredProfile `yaml:"profiles" json:"profiles"`
+}
+
+// DesiredProfile is the desired encoder configuration of one profile. Width, height and
+// frame rate are required; the other settings are only enforced when they are set.
+type DesiredProfile struct {
+	Profile          string `yaml:"profile" json:"profile"` // Profile token, name or role; the main stream when empty
+	Width            int    `yaml:"width" json:"width"`
+	Height           int    `yaml:"height" json:"height"`
+	FPS              int    `yaml:"fps" json:"fps"`
+	Bitrate          int    `yaml:"bitrate,omitempty" json:"bitrate,omitempty"`
+	Encoding         string `yaml:"encoding,omitempty" json:"encoding,omitempty"`
+	GOP              int    `yaml:"gop,omitempty" json:"gop,omitempty"`
+	EncoderProfile   string `yaml:"encoderProfile,omitempty" json:"encoderProfile,omitempty"`
+	RateControl      string `yaml:"rateControl,omitempty" json:"rateControl,omitempty"`
+	Quality          int    `yaml:"quality,omitempty" json:"quality,omitempty"`
+	EncodingInterval int    `yaml:"encodingInterval,omitempty" json:"encodingInterval,omitempty"`
+}
+
+// LoadFile reads and validates a desired-state file
+func LoadFile(path string) (*DesiredState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read desired state: %w", err)
+	}
+
+	state := new(DesiredState)
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse desired state %s: %w", path, err)
+	}
+	if err := state.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid desired state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// Validate checks the desired state and normalizes encoding and rate control names
+func (s *DesiredState) Validate() error {
+	if len(s.Cameras) == 0 {
+		return fmt.Errorf("no cameras listed")
+	}
+
+	cameras := make(map[string]bool)
+	for i := range s.Cameras {
+		cam := &s.Cameras[i]
+		cam.ID = strings.TrimSpace(cam.ID)
+		if cam.ID == "" {
+			return fmt.Errorf("camera %d has no id", i+1)
+		}
+		if cameras[cam.ID] {
+			return fmt.Errorf("camera %s is listed more than once", cam.ID)
+		}
+		cameras[cam.ID] = true
+
+		if len(cam.Profiles) == 0 {
+			return fmt.Errorf("camera %s has no profiles", cam.ID)
+		}
+		profiles := make(map[string]bool)
+		for j := range cam.Profiles {
+			profile := &cam.Profiles[j]
+			profile.Profile = strings.TrimSpace(profile.Profile)
+			if profile.Profile == "" {
+				profile.Profile = camera.ProfileRoleMain
+			}
+			key := strings.ToLower(profile.Profile)
+			if profiles[key] {
+				return fmt.Errorf("camera %s: profile %s is listed more than once", cam.ID, profile.Profile)
+			}
+			profiles[key] = true
+
+			if err := profile.validate(); err != nil {
+				return fmt.Errorf("camera %s, profile %s: %w", cam.ID, profile.Profile, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks the settings of a desired profile
+func (p *DesiredProfile) validate() error {
+	if p.Width <= 0 || p.Height <= 0 {
+		return fmt.Errorf("width and height are required")
+	}
+	if p.FPS <= 0 {
+		return fmt.Errorf("fps is required")
+	}
+	if p.Bitrate < 0 || p.GOP < 0 || p.Quality < 0 || p.EncodingInterval < 0 {
+		return fmt.Errorf("settings must not be negative")
+	}
+	if p.Encoding != "" {
+		p.Encoding = camera.NormalizeEncoding(p.Encoding)
+	}
+	if p.RateControl != "" {
+		p.RateControl = camera.NormalizeRateControl(p.RateControl)
+		if p.RateControl != camera.RateControlCBR && p.RateControl != camera.RateControlVBR {
+			return fmt.Errorf("invalid rate control %q (use %s or %s)", p.RateControl, camera.RateControlCBR, camera.RateControlVBR)
+		}
+	}
+	return nil
+}
+
+// Resolution returns the desired resolution
+func (p DesiredProfile) Resolution() models.Resolution {
+	return models.Resolution{Width: p.Width, Height: p.Height}
+}
+
+// EncoderConfig returns the desired encoder settings at the given resolution
+func (p DesiredProfile) EncoderConfig(resolution models.Resolution) models.EncoderConfig {
+	return models.EncoderConfig{
+		Resolution:       resolution,
+		Quality:          p.Quality,
+		FPS:              p.FPS,
+		Bitrate:          p.Bitrate,
+		Encoding:         p.Encoding,
+		GOP:              p.GOP,
+		EncoderProfile:   p.EncoderProfile,
+		RateControl:      p.RateControl,
+		EncodingInterval: p.EncodingInterval,
+	}
+}
@@ -0,0 +1,132 @@
+package reconcile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"onvif_manager/internal/backend/camera"
+)
+
+// validProfile returns a desired profile that passes validation
+func validProfile(profile string) DesiredProfile {
+	return DesiredProfile{Profile: profile, Width: 1920, Height: 1080, FPS: 25}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   DesiredState
+		wantErr string
+	}{
+		{name: "valid", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{validProfile("main"), validProfile("sub")}},
+			{ID: "2", Profiles: []DesiredProfile{validProfile("")}},
+		}}},
+		{name: "no cameras", state: DesiredState{}, wantErr: "no cameras listed"},
+		{name: "camera without id", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{validProfile("")}},
+			{ID: " ", Profiles: []DesiredProfile{validProfile("")}},
+		}}, wantErr: "camera 2 has no id"},
+		{name: "duplicate camera", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{validProfile("")}},
+			{ID: " 1 ", Profiles: []DesiredProfile{validProfile("sub")}},
+		}}, wantErr: "camera 1 is listed more than once"},
+		{name: "camera without profiles", state: DesiredState{Cameras: []DesiredCamera{{ID: "1"}}}, wantErr: "camera 1 has no profiles"},
+		{name: "duplicate profile", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{validProfile("MainStream"), validProfile("mainstream")}},
+		}}, wantErr: "profile mainstream is listed more than once"},
+		{name: "empty profile is the main stream", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{validProfile(""), validProfile("Main")}},
+		}}, wantErr: "profile Main is listed more than once"},
+		{name: "missing resolution", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{{Profile: "main", Width: 1920, FPS: 25}}},
+		}}, wantErr: "camera 1, profile main: width and height are required"},
+		{name: "missing fps", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{{Width: 1920, Height: 1080}}},
+		}}, wantErr: "fps is required"},
+		{name: "negative setting", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{{Width: 1920, Height: 1080, FPS: 25, GOP: -1}}},
+		}}, wantErr: "settings must not be negative"},
+		{name: "invalid rate control", state: DesiredState{Cameras: []DesiredCamera{
+			{ID: "1", Profiles: []DesiredProfile{{Width: 1920, Height: 1080, FPS: 25, RateControl: "abr"}}},
+		}}, wantErr: `invalid rate control "ABR"`},
+	}
+
+	for _, tt := range tests {
+		err := tt.state.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Validate() error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Validate() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateNormalizes(t *testing.T) {
+	state := DesiredState{Cameras: []DesiredCamera{{
+		ID: " 7 ",
+		Profiles: []DesiredProfile{
+			{Profile: " ", Width: 1920, Height: 1080, FPS: 25, Encoding: "hevc", RateControl: "constant"},
+			{Profile: " sub ", Width: 640, Height: 360, FPS: 10, Encoding: "mjpeg", RateControl: "vbr"},
+		},
+	}}}
+	if err := state.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cam := state.Cameras[0]
+	if cam.ID != "7" {
+		t.Errorf("camera id = %q, want 7", cam.ID)
+	}
+	want := []DesiredProfile{
+		{Profile: camera.ProfileRoleMain, Width: 1920, Height: 1080, FPS: 25, Encoding: camera.EncodingH265, RateControl: camera.RateControlCBR},
+		{Profile: "sub", Width: 640, Height: 360, FPS: 10, Encoding: camera.EncodingJPEG, RateControl: camera.RateControlVBR},
+	}
+	for i, profile := range cam.Profiles {
+		if profile != want[i] {
+			t.Errorf("profile %d = %+v, want %+v", i+1, profile, want[i])
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "desired.yaml")
+	if err := os.WriteFile(valid, []byte(`cameras:
+  - id: "1"
+    profiles:
+      - profile: main
+        width: 1920
+        height: 1080
+        fps: 25
+        encoding: h265
+        rateControl: cbr
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := LoadFile(valid)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if profile := state.Cameras[0].Profiles[0]; profile.Encoding != camera.EncodingH265 || profile.RateControl != camera.RateControlCBR {
+		t.Errorf("LoadFile() profile = %+v, want normalized names", profile)
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("cameras:\n  - id: \"1\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(invalid); err == nil || !strings.Contains(err.Error(), "has no profiles") {
+		t.Errorf("LoadFile() of an invalid state error = %v, want a validation error", err)
+	}
+	if _, err := LoadFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("LoadFile() of a missing file succeeded")
+	}
+}
@@ -0,0 +1,279 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Defaults of a staged rollout
+const (
+	DefaultCanary   = "1"   // One canary camera
+	DefaultWaveSize = "25%" // A quarter of the cameras per following wave
+)
+
+// Outcomes of a rollout
+const (
+	StatusCompleted = "completed"
+	StatusAborted   = "aborted"
+)
+
+// Options describes the waves of a staged rollout. Sizes are a number of cameras such
+// as 5, or a percentage of all cameras such as 10%.
+type Options struct {
+	Canary           string  `json:"canary"`           // Size of the first wave; DefaultCanary when empty
+	WaveSize         string  `json:"waveSize"`         // Size of each following wave; DefaultWaveSize when empty
+	MaxFailureRate   float64 `json:"maxFailureRate"`   // Highest tolerated percentage of failed cameras so far; 0 pauses on the first failure
+	PauseAfterCanary bool    `json:"pauseAfterCanary"` // Wait to be resumed after the canary wave
+}
+
+// parseSize returns the number of cameras a size stands for out of total, at least one
+func parseSize(size string, total int) (int, error) {
+	size = strings.TrimSpace(size)
+	if percent, ok := strings.CutSuffix(size, "%"); ok {
+		value, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || value <= 0 || value > 100 {
+			return 0, fmt.Errorf("invalid wave size %q: use a percentage between 0 and 100", size)
+		}
+		return max(1, int(math.Ceil(float64(total)*value/100))), nil
+	}
+
+	value, err := strconv.Atoi(size)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid wave size %q: use a number of cameras or a percentage such as 10%%", size)
+	}
+	return value, nil
+}
+
+// Waves splits the cameras into the canary wave and the following waves, in order
+func Waves(cameraIDs []string, opts Options) ([][]string, error) {
+	if opts.Canary == "" {
+		opts.Canary = DefaultCanary
+	}
+	if opts.WaveSize == "" {
+		opts.WaveSize = DefaultWaveSize
+	}
+	if opts.MaxFailureRate < 0 || opts.MaxFailureRate > 100 {
+		return nil, fmt.Errorf("invalid maximum failure rate %g: use a percentage between 0 and 100", opts.MaxFailureRate)
+	}
+	canary, err := parseSize(opts.Canary, len(cameraIDs))
+	if err != nil {
+		return nil, err
+	}
+	waveSize, err := parseSize(opts.WaveSize, len(cameraIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	var waves [][]string
+	for start, size := 0, canary; start < len(cameraIDs); start, size = start+size, waveSize {
+		end := min(start+size, len(cameraIDs))
+		waves = append(waves, cameraIDs[start:end])
+	}
+	return waves, nil
+}
+
+// State is the state of a rollout as set through its Control
+type State string
+
+const (
+	StateRunning State = "running"
+	StatePaused  State = "paused"
+	StateAborted State = "aborted"
+)
+
+// ErrAborted is returned when a rollout that was aborted is paused or resumed
+var ErrAborted = errors.New("rollout was aborted")
+
+// Control pauses, resumes and aborts a running rollout. Changes take effect between
+// waves: the cameras of a wave that has started are always finished.
+type Control struct {
+	mu       sync.Mutex
+	state    State
+	reason   string
+	changed  chan struct{}                    // Closed and replaced whenever the state changes
+	decide   func(reason string) bool         // Asked instead of waiting while paused, when set
+	onChange func(state State, reason string) // Called after every state change
+}
+
+// NewControl returns the control of a running rollout that waits while it is paused
+func NewControl() *Control {
+	return &Control{state: StateRunning, changed: make(chan struct{})}
+}
+
+// NewPromptControl returns a control that calls decide instead of waiting while the
+// rollout is paused; the rollout continues when it returns true and is aborted otherwise
+func NewPromptControl(decide func(reason string) bool) *Control {
+	control := NewControl()
+	control.decide = decide
+	return control
+}
+
+// OnChange registers a function that is called after every state change
+func (c *Control) OnChange(fn func(state State, reason string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = fn
+}
+
+// State returns the current state and the reason it was entered
+func (c *Control) State() (State, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state, c.reason
+}
+
+// Pause stops the rollout before its next wave
+func (c *Control) Pause(reason string) error {
+	return c.set(StatePaused, reason)
+}
+
+// Resume continues a paused rollout
+func (c *Control) Resume() error {
+	return c.set(StateRunning, "")
+}
+
+// Abort ends the rollout before its next wave; the remaining cameras are skipped
+func (c *Control) Abort(reason string) error {
+	return c.set(StateAborted, reason)
+}
+
+func (c *Control) set(state State, reason string) error {
+	c.mu.Lock()
+	if c.state == StateAborted {
+		c.mu.Unlock()
+		if state == StateAborted {
+			return nil
+		}
+		return ErrAborted
+	}
+	c.state = state
+	c.reason = reason
+	close(c.changed)
+	c.changed = make(chan struct{})
+	onChange := c.onChange
+	c.mu.Unlock()
+
+	if onChange != nil {
+		onChange(state, reason)
+	}
+	return nil
+}
+
+// wait blocks while the rollout is paused and reports whether it may continue
+func (c *Control) wait(ctx context.Context) bool {
+	for {
+		c.mu.Lock()
+		state, reason, changed := c.state, c.reason, c.changed
+		c.mu.Unlock()
+
+		switch state {
+		case StateRunning:
+			return true
+		case StateAborted:
+			return false
+		}
+
+		if c.decide != nil {
+			if c.decide(reason) {
+				c.Resume()
+			} else {
+				c.Abort("aborted while paused")
+			}
+			continue
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			c.Abort(fmt.Sprintf("cancelled while paused: %v", ctx.Err()))
+		}
+	}
+}
+
+// Wave is the outcome of one wave of a rollout; wave 1 is the canary
+type Wave struct {
+	Number      int      `json:"wave"`
+	CameraIDs   []string `json:"cameraIds"`
+	Failed      []string `json:"failed"`
+	FailureRate float64  `json:"failureRate"` // Percentage of the cameras of the wave that failed
+}
+
+// Result is the outcome of a rollout
+type Result struct {
+	Status      string         `json:"status"` // completed or aborted
+	Reason      string         `json:"reason,omitempty"`
+	Waves       []Wave         `json:"waves"`
+	CameraWaves map[string]int `json:"cameraWaves"` // Wave each camera is planned in
+	Applied     int            `json:"applied"`     // Cameras of the waves that ran
+	Failed      int            `json:"failed"`
+	FailureRate float64        `json:"failureRate"` // Percentage of the applied cameras that failed
+	Skipped     []string       `json:"skipped"`     // Cameras of the waves that did not run
+}
+
+// ApplyFunc configures the cameras of a wave and returns the ones that failed
+type ApplyFunc func(ctx context.Context, wave int, cameraIDs []string) []string
+
+// Run rolls out to the waves one after the other. After each wave the failure rate of all
+// cameras so far is checked; when it exceeds the maximum the rollout pauses until it is
+// resumed or aborted through control.
+func Run(ctx context.Context, waves [][]string, opts Options, control *Control, apply ApplyFunc) *Result {
+	result := &Result{
+		Status:      StatusCompleted,
+		Waves:       []Wave{},
+		CameraWaves: make(map[string]int),
+		Skipped:     []string{},
+	}
+	for i, wave := range waves {
+		for _, cameraID := range wave {
+			result.CameraWaves[cameraID] = i + 1
+		}
+	}
+
+	for i, cameraIDs := range waves {
+		if !control.wait(ctx) {
+			_, result.Reason = control.State()
+			result.Status = StatusAborted
+			for _, skipped := range waves[i:] {
+				result.Skipped = append(result.Skipped, skipped...)
+			}
+			log.Printf("Rollout aborted before wave %d of %d: %s", i+1, len(waves), result.Reason)
+			break
+		}
+
+		log.Printf("Rollout wave %d of %d: %d camera(s)", i+1, len(waves), len(cameraIDs))
+		failed := apply(ctx, i+1, cameraIDs)
+		if failed == nil {
+			failed = []string{}
+		}
+		wave := Wave{Number: i + 1, CameraIDs: cameraIDs, Failed: failed, FailureRate: percent(len(failed), len(cameraIDs))}
+		result.Waves = append(result.Waves, wave)
+		result.Applied += len(cameraIDs)
+		result.Failed += len(failed)
+		result.FailureRate = percent(result.Failed, result.Applied)
+		log.Printf("Rollout wave %d of %d finished: %d of %d camera(s) failed, %.1f%% of all so far", i+1, len(waves), len(failed), len(cameraIDs), result.FailureRate)
+
+		if i == len(waves)-1 {
+			break
+		}
+		switch {
+		case result.FailureRate > opts.MaxFailureRate:
+			control.Pause(fmt.Sprintf("failure rate of %.1f%% after wave %d exceeds the maximum of %g%%", result.FailureRate, i+1, opts.MaxFailureRate))
+		case i == 0 && opts.PauseAfterCanary:
+			control.Pause("canary wave finished")
+		}
+	}
+	return result
+}
+
+// percent returns part as a percentage of total
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
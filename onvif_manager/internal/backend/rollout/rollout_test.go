@@ -0,0 +1,198 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWaves(t *testing.T) {
+	ten := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+
+	tests := []struct {
+		name    string
+		ids     []string
+		opts    Options
+		want    []int // Size of each wave
+		wantErr bool
+	}{
+		{name: "defaults", ids: ten, want: []int{1, 3, 3, 3}},
+		{name: "counts", ids: ten, opts: Options{Canary: "2", WaveSize: "4"}, want: []int{2, 4, 4}},
+		{name: "percentages round up", ids: ten, opts: Options{Canary: "5%", WaveSize: " 45 %"}, want: []int{1, 5, 4}},
+		{name: "canary larger than the cameras", ids: []string{"1", "2"}, opts: Options{Canary: "5"}, want: []int{2}},
+		{name: "one wave", ids: ten, opts: Options{Canary: "100%"}, want: []int{10}},
+		{name: "no cameras", ids: nil, want: []int{}},
+		{name: "zero cameras", ids: ten, opts: Options{Canary: "0"}, wantErr: true},
+		{name: "percentage over 100", ids: ten, opts: Options{WaveSize: "150%"}, wantErr: true},
+		{name: "not a size", ids: ten, opts: Options{WaveSize: "half"}, wantErr: true},
+		{name: "negative failure rate", ids: ten, opts: Options{MaxFailureRate: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waves, err := Waves(tt.ids, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Waves() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			sizes := []int{}
+			var order []string
+			for _, wave := range waves {
+				sizes = append(sizes, len(wave))
+				order = append(order, wave...)
+			}
+			if !reflect.DeepEqual(sizes, tt.want) {
+				t.Errorf("wave sizes = %v, want %v", sizes, tt.want)
+			}
+			if !reflect.DeepEqual(order, tt.ids) {
+				t.Errorf("waves = %v, want the cameras in order", waves)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	waves := [][]string{{"1"}, {"2", "3"}, {"4", "5"}}
+
+	tests := []struct {
+		name        string
+		opts        Options
+		failed      map[string]bool
+		decide      bool // Answer when the rollout pauses
+		wantStatus  string
+		wantWaves   int
+		wantPauses  int
+		wantSkipped []string
+	}{
+		{name: "no failures", wantStatus: StatusCompleted, wantWaves: 3, wantSkipped: []string{}},
+		{name: "failed canary aborted", failed: map[string]bool{"1": true}, wantStatus: StatusAborted, wantWaves: 1, wantPauses: 1, wantSkipped: []string{"2", "3", "4", "5"}},
+		{name: "failed canary resumed", failed: map[string]bool{"1": true}, decide: true, wantStatus: StatusCompleted, wantWaves: 3, wantPauses: 2, wantSkipped: []string{}},
+		{name: "failure rate tolerated", opts: Options{MaxFailureRate: 40}, failed: map[string]bool{"3": true}, wantStatus: StatusCompleted, wantWaves: 3, wantSkipped: []string{}},
+		{name: "failure rate exceeded", opts: Options{MaxFailureRate: 20}, failed: map[string]bool{"3": true}, wantStatus: StatusAborted, wantWaves: 2, wantPauses: 1, wantSkipped: []string{"4", "5"}},
+		{name: "failure in the last wave", failed: map[string]bool{"5": true}, wantStatus: StatusCompleted, wantWaves: 3, wantSkipped: []string{}},
+		{name: "pause after canary", opts: Options{PauseAfterCanary: true}, decide: true, wantStatus: StatusCompleted, wantWaves: 3, wantPauses: 1, wantSkipped: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pauses := 0
+			control := NewPromptControl(func(reason string) bool {
+				pauses++
+				return tt.decide
+			})
+
+			result := Run(context.Background(), waves, tt.opts, control, func(ctx context.Context, wave int, cameraIDs []string) []string {
+				var failed []string
+				for _, cameraID := range cameraIDs {
+					if tt.failed[cameraID] {
+						failed = append(failed, cameraID)
+					}
+				}
+				return failed
+			})
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", result.Status, tt.wantStatus)
+			}
+			if len(result.Waves) != tt.wantWaves {
+				t.Errorf("%d waves ran, want %d", len(result.Waves), tt.wantWaves)
+			}
+			if pauses != tt.wantPauses {
+				t.Errorf("paused %d times, want %d", pauses, tt.wantPauses)
+			}
+			if !reflect.DeepEqual(result.Skipped, tt.wantSkipped) {
+				t.Errorf("Skipped = %v, want %v", result.Skipped, tt.wantSkipped)
+			}
+			if result.CameraWaves["4"] != 3 {
+				t.Errorf("camera 4 planned in wave %d, want 3", result.CameraWaves["4"])
+			}
+		})
+	}
+}
+
+func TestRunResumedWhilePaused(t *testing.T) {
+	control := NewControl()
+	control.Pause("paused by the operator")
+
+	done := make(chan *Result)
+	go func() {
+		done <- Run(context.Background(), [][]string{{"1"}, {"2"}}, Options{}, control, func(ctx context.Context, wave int, cameraIDs []string) []string {
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("a paused rollout ran")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := control.Resume(); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	select {
+	case result := <-done:
+		if result.Status != StatusCompleted || result.Applied != 2 {
+			t.Errorf("Run() = %+v, want both waves applied", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the resumed rollout did not finish")
+	}
+}
+
+func TestRunCancelledWhilePaused(t *testing.T) {
+	control := NewControl()
+	control.Pause("paused by the operator")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := Run(ctx, [][]string{{"1"}, {"2"}}, Options{}, control, func(ctx context.Context, wave int, cameraIDs []string) []string {
+		t.Errorf("wave %d ran after the rollout was cancelled", wave)
+		return nil
+	})
+	if result.Status != StatusAborted || len(result.Skipped) != 2 {
+		t.Errorf("Run() = %+v, want an aborted rollout that skipped both cameras", result)
+	}
+}
+
+func TestControl(t *testing.T) {
+	control := NewControl()
+	var changes []State
+	control.OnChange(func(state State, reason string) {
+		changes = append(changes, state)
+	})
+
+	if err := control.Pause("checking"); err != nil {
+		t.Errorf("Pause() error = %v", err)
+	}
+	if state, reason := control.State(); state != StatePaused || reason != "checking" {
+		t.Errorf("State() = %s, %q after Pause", state, reason)
+	}
+	if err := control.Resume(); err != nil {
+		t.Errorf("Resume() error = %v", err)
+	}
+	if err := control.Abort("bad firmware"); err != nil {
+		t.Errorf("Abort() error = %v", err)
+	}
+	if err := control.Abort("again"); err != nil {
+		t.Errorf("second Abort() error = %v", err)
+	}
+	if err := control.Resume(); !errors.Is(err, ErrAborted) {
+		t.Errorf("Resume() after Abort error = %v, want ErrAborted", err)
+	}
+	if err := control.Pause("later"); !errors.Is(err, ErrAborted) {
+		t.Errorf("Pause() after Abort error = %v, want ErrAborted", err)
+	}
+	if state, reason := control.State(); state != StateAborted || reason != "bad firmware" {
+		t.Errorf("State() = %s, %q, want the first abort", state, reason)
+	}
+
+	want := []State{StatePaused, StateRunning, StateAborted}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("state changes = %v, want %v", changes, want)
+	}
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "* * * * *"},
+		{expr: "0 2 * * 1-5"},
+		{expr: "*/15 0-6/2 1,15 */3 7"},
+		{expr: " @Daily "},
+		{expr: "@yearly", wantErr: true},
+		{expr: "0 2 * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "* * * 13 *", wantErr: true},
+		{expr: "* * * * 8", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Monday 12 October 2026, 10:30:20
+	from := time.Date(2026, 10, 12, 10, 30, 20, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "* * * * *", want: time.Date(2026, 10, 12, 10, 31, 0, 0, time.UTC)},
+		{expr: "30 10 * * *", want: time.Date(2026, 10, 13, 10, 30, 0, 0, time.UTC)},
+		{expr: "*/20 * * * *", want: time.Date(2026, 10, 12, 10, 40, 0, 0, time.UTC)},
+		{expr: "0 2 * * 1-5", want: time.Date(2026, 10, 13, 2, 0, 0, 0, time.UTC)},
+		{expr: "0 2 * * 0", want: time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)},
+		{expr: "0 2 * * 7", want: time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)},
+		{expr: "@monthly", want: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 13 * 5", want: time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)}, // The 13th or a Friday
+		{expr: "0 0 30 2 *", want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+		}
+		if got := cron.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next(%s) = %s, want %s", tt.expr, from, got, tt.want)
+		}
+	}
+}
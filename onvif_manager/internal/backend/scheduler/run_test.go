@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
+
+// waitForRun returns the newest run of a schedule once it has finished
+func waitForRun(t *testing.T, store *Store, name string) *Run {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		runs, err := store.Runs(name)
+		if err != nil {
+			t.Fatalf("Runs() error = %v", err)
+		}
+		if len(runs) > 0 && runs[0].Status != RunRunning {
+			return runs[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("run of schedule %s did not finish", name)
+	return nil
+}
+
+func TestRunNow(t *testing.T) {
+	inventory := &camera.MemoryStore{}
+	inventory.Save([]models.Camera{{ID: "1"}, {ID: "2"}})
+	if err := camera.UseStore(inventory); err != nil {
+		t.Fatalf("UseStore() error = %v", err)
+	}
+
+	var (
+		mu            sync.Mutex
+		rejectParams  bool
+		operated      []string
+		hadStartCheck bool
+	)
+	release := make(chan struct{})
+	RegisterOperation(OperationValidate, Operation{
+		Validate: func(params map[string]interface{}) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if rejectParams {
+				return errors.New("invalid profile")
+			}
+			return nil
+		},
+		Run: func(ctx context.Context, cameraIDs []string, params map[string]interface{}, startCheck func(cameraID string) error) []CameraResult {
+			<-release
+			mu.Lock()
+			operated = cameraIDs
+			hadStartCheck = startCheck != nil
+			mu.Unlock()
+			results := make([]CameraResult, 0, len(cameraIDs))
+			for _, cameraID := range cameraIDs {
+				results = append(results, CameraResult{CameraID: cameraID, Status: CameraSucceeded})
+			}
+			return results
+		},
+	})
+	t.Cleanup(func() {
+		operationsMu.Lock()
+		delete(operations, OperationValidate)
+		operationsMu.Unlock()
+	})
+
+	store := NewStore("")
+	schedule := &Schedule{Name: "nightly", Operation: OperationValidate, Cron: "0 2 * * *", CameraIDs: []string{"2", "1", "2"}, AllowWithoutWindow: true}
+	if _, err := store.Put(schedule); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	if _, err := RunNow(context.Background(), store, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RunNow() of a missing schedule error = %v, want ErrNotFound", err)
+	}
+
+	mu.Lock()
+	rejectParams = true
+	mu.Unlock()
+	if _, err := RunNow(context.Background(), store, "nightly"); err == nil || errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("RunNow() with invalid parameters error = %v, want a validation error", err)
+	}
+	if runs, _ := store.Runs("nightly"); len(runs) != 0 {
+		t.Errorf("RunNow() with invalid parameters recorded %d run(s), want none", len(runs))
+	}
+	mu.Lock()
+	rejectParams = false
+	mu.Unlock()
+
+	run, err := RunNow(context.Background(), store, "nightly")
+	if err != nil {
+		t.Fatalf("RunNow() error = %v", err)
+	}
+	if _, err := RunNow(context.Background(), store, "nightly"); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("RunNow() of a running schedule error = %v, want ErrAlreadyRunning", err)
+	}
+	close(release)
+
+	finished := waitForRun(t, store, "nightly")
+	// The returned run is the one that started and is not changed by the run itself
+	if run.Status != RunRunning || len(run.Cameras) != 0 || len(run.Summary) != 0 || run.FinishedAt != nil {
+		t.Errorf("RunNow() = %+v, want the run as it started", run)
+	}
+	if finished.ID != run.ID || finished.Status != RunCompleted || finished.Summary[CameraSucceeded] != 2 {
+		t.Errorf("finished run = %+v, want run %s completed with 2 cameras", finished, run.ID)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"2", "1"}; !reflect.DeepEqual(operated, want) {
+		t.Errorf("operation ran on %v, want %v", operated, want)
+	}
+	if !hadStartCheck {
+		t.Error("operation was not given the maintenance window start check")
+	}
+}
+
+func TestScheduleCameraIDs(t *testing.T) {
+	inventory := &camera.MemoryStore{}
+	inventory.Save([]models.Camera{
+		{ID: "10", Groups: []string{"lobby"}},
+		{ID: "2"},
+		{ID: "1", Groups: []string{"lobby"}},
+	})
+	if err := camera.UseStore(inventory); err != nil {
+		t.Fatalf("UseStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     []string
+	}{
+		{name: "whole inventory", schedule: Schedule{}, want: []string{"10", "2", "1"}},
+		{name: "listed", schedule: Schedule{CameraIDs: []string{"2", "1", "2"}}, want: []string{"2", "1"}},
+		{name: "selector", schedule: Schedule{Selector: "group=lobby"}, want: []string{"10", "1"}},
+		{name: "listed and selector", schedule: Schedule{CameraIDs: []string{"1", "2", "1"}, Selector: "group=lobby"}, want: []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		got, err := scheduleCameraIDs(&tt.schedule)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: scheduleCameraIDs() = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,559 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
+
+// Operations a schedule can run
+const (
+	OperationApplyConfig = "apply-config" // Apply an /apply-config request
+	OperationValidate    = "validate"     // Validate the streams
+	OperationReboot      = "reboot"       // Reboot the cameras
+	OperationTimeSync    = "time-sync"    // Check or set the camera clocks
+)
+
+// operationNames are the operations a schedule may name, in the order they are documented
+var operationNames = []string{OperationApplyConfig, OperationValidate, OperationReboot, OperationTimeSync}
+
+// Outcomes of a camera in a run
+const (
+	CameraSucceeded     = "succeeded"
+	CameraFailed        = "failed"
+	CameraSkipped       = "skipped"        // The operation had nothing to do for the camera
+	CameraOutsideWindow = "outside-window" // Not run because none of its maintenance windows was open when it was due to start
+)
+
+// Outcomes of a run
+const (
+	RunRunning       = "running"
+	RunCompleted     = "completed"      // Every camera that ran succeeded
+	RunFailed        = "failed"         // At least one camera failed, or the run could not start
+	RunOutsideWindow = "outside-window" // No camera was inside a maintenance window
+	RunMissed        = "missed"         // The server was not running when the schedule was due
+)
+
+// Timing of the scheduler loop
+const (
+	tickInterval = 30 * time.Second
+	missedGrace  = time.Hour // Runs that were due longer ago than this are recorded as missed
+)
+
+// maxReportedCameras is the number of cameras an error lists by name
+const maxReportedCameras = 5
+
+// namePattern is the form of schedule and window names, which are used in URLs and on the command line
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// NormalizeName checks a schedule or window name and returns it in lower case
+func NormalizeName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return name, nil
+}
+
+// CameraResult is the outcome of an operation on one camera
+type CameraResult struct {
+	CameraID string      `json:"cameraId"`
+	Status   string      `json:"status"` // succeeded, failed, skipped or outside-window
+	Message  string      `json:"message,omitempty"`
+	Details  interface{} `json:"details,omitempty"` // Operation-specific result of the camera
+}
+
+// Operation runs a scheduled operation. Validate checks the parameters of a schedule
+// before it is saved; Run operates the cameras and returns the result of each of them.
+// Run must set startCheck as the pool.Options StartCheck of the phase that changes the
+// cameras, so that cameras whose maintenance windows closed during the run are held
+// back; later phases, such as validating a camera that was changed, must not be held back.
+type Operation struct {
+	Validate func(params map[string]interface{}) error
+	Run      func(ctx context.Context, cameraIDs []string, params map[string]interface{}, startCheck func(cameraID string) error) []CameraResult
+}
+
+var (
+	operationsMu sync.RWMutex
+	operations   = make(map[string]Operation)
+)
+
+// RegisterOperation makes an operation available to schedules. The server registers
+// its operations at startup.
+func RegisterOperation(name string, op Operation) {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+	operations[name] = op
+}
+
+// lookupOperation returns a registered operation
+func lookupOperation(name string) (Operation, bool) {
+	operationsMu.RLock()
+	defer operationsMu.RUnlock()
+	op, found := operations[name]
+	return op, found
+}
+
+// Schedule runs an operation on a set of cameras, repeatedly on a cron expression or
+// once at a given time. Cameras are those of CameraIDs and Selector, as in the API;
+// every camera of the inventory when both are empty. Cameras are only operated while
+// one of their maintenance windows is open, and cameras without a window only with
+// AllowWithoutWindow.
+type Schedule struct {
+	Name               string                 `yaml:"name" json:"name"`
+	Description        string                 `yaml:"description,omitempty" json:"description,omitempty"`
+	Operation          string                 `yaml:"operation" json:"operation"`
+	Params             map[string]interface{} `yaml:"params,omitempty" json:"params,omitempty"`
+	CameraIDs          []string               `yaml:"cameraIds,omitempty" json:"cameraIds,omitempty"`
+	Selector           string                 `yaml:"selector,omitempty" json:"selector,omitempty"`
+	Cron               string                 `yaml:"cron,omitempty" json:"cron,omitempty"` // Recurring schedule, e.g. "0 2 * * 1-5"
+	At                 *time.Time             `yaml:"at,omitempty" json:"at,omitempty"`     // One-off run time
+	Disabled           bool                   `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	AllowWithoutWindow bool                   `yaml:"allowWithoutWindow,omitempty" json:"allowWithoutWindow,omitempty"` // Operate cameras that no window applies to at any time
+	NextRun            *time.Time             `yaml:"-" json:"nextRun,omitempty"`
+	LastRun            *time.Time             `yaml:"-" json:"lastRun,omitempty"`
+	CreatedAt          time.Time              `yaml:"-" json:"createdAt"`
+	UpdatedAt          time.Time              `yaml:"-" json:"updatedAt"`
+}
+
+// Validate checks the schedule and normalizes its name and operation. The parameters
+// are checked by the operation when it is registered, i.e. in the server.
+func (s *Schedule) Validate() error {
+	name, err := NormalizeName(s.Name)
+	if err != nil {
+		return err
+	}
+	s.Name = name
+
+	s.Operation = strings.ToLower(strings.TrimSpace(s.Operation))
+	known := false
+	for _, operation := range operationNames {
+		known = known || operation == s.Operation
+	}
+	if !known {
+		return fmt.Errorf("schedule %s: unknown operation %q, use one of %s", s.Name, s.Operation, strings.Join(operationNames, ", "))
+	}
+	if op, found := lookupOperation(s.Operation); found && op.Validate != nil {
+		if err := op.Validate(s.Params); err != nil {
+			return fmt.Errorf("schedule %s: invalid %s parameters: %w", s.Name, s.Operation, err)
+		}
+	}
+	if _, err := camera.ParseSelector(s.Selector); err != nil {
+		return fmt.Errorf("schedule %s: %w", s.Name, err)
+	}
+
+	s.Cron = strings.TrimSpace(s.Cron)
+	switch {
+	case s.Cron == "" && s.At == nil:
+		return fmt.Errorf("schedule %s: set either cron or at", s.Name)
+	case s.Cron != "" && s.At != nil:
+		return fmt.Errorf("schedule %s: set either cron or at, not both", s.Name)
+	case s.Cron != "":
+		if _, err := ParseCron(s.Cron); err != nil {
+			return fmt.Errorf("schedule %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// next returns when the schedule runs next after t, or nil when it does not run again
+func (s *Schedule) next(t time.Time) *time.Time {
+	if s.Disabled {
+		return nil
+	}
+	if s.At != nil {
+		if s.At.After(t) {
+			at := *s.At
+			return &at
+		}
+		return nil
+	}
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil
+	}
+	next := cron.Next(t.Local())
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// Run is one run of a schedule with the result of each of its cameras
+type Run struct {
+	ID          string         `json:"id"`
+	Schedule    string         `json:"schedule"`
+	Operation   string         `json:"operation"`
+	Manual      bool           `json:"manual,omitempty"` // Started through RunNow instead of being due
+	ScheduledAt time.Time      `json:"scheduledAt"`
+	StartedAt   *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time     `json:"finishedAt,omitempty"`
+	Status      string         `json:"status"`
+	Error       string         `json:"error,omitempty"`
+	Cameras     []CameraResult `json:"cameras"`
+	Summary     map[string]int `json:"summary"` // Number of cameras by status
+}
+
+// newRunID returns a random run ID
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// running holds the names of the schedules with a run in progress, so runs do not overlap
+var (
+	runningMu sync.Mutex
+	running   = make(map[string]bool)
+)
+
+// Start checks the schedules of store every 30 seconds until ctx is done and runs the
+// ones that are due in the background
+func Start(ctx context.Context, store *Store) {
+	log.Printf("Scheduler started (%s)", storeLabel(store))
+	go func() {
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+		for {
+			runDue(ctx, store, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// storeLabel describes where the schedules are kept
+func storeLabel(store *Store) string {
+	if store.Path() == "" {
+		return "in memory"
+	}
+	return store.Path()
+}
+
+// runDue starts every schedule that is due at now. The next run time is saved before a
+// run starts, so a restart during the run does not repeat it.
+func runDue(ctx context.Context, store *Store, now time.Time) {
+	due, err := store.claimDue(now)
+	if err != nil {
+		log.Printf("Scheduler skipped: %v", err)
+		return
+	}
+
+	for _, claim := range due {
+		if now.Sub(claim.scheduledAt) > missedGrace {
+			log.Printf("Schedule %s missed its run at %s", claim.schedule.Name, claim.scheduledAt.Format(time.RFC3339))
+			finished := now
+			recordRun(store, &Run{
+				ID:          newRunID(),
+				Schedule:    claim.schedule.Name,
+				Operation:   claim.schedule.Operation,
+				ScheduledAt: claim.scheduledAt,
+				FinishedAt:  &finished,
+				Status:      RunMissed,
+				Error:       "the server was not running when the schedule was due",
+				Cameras:     []CameraResult{},
+				Summary:     map[string]int{},
+			})
+			continue
+		}
+		start(ctx, store, claim.schedule, claim.scheduledAt, false)
+	}
+}
+
+// ErrAlreadyRunning is returned by RunNow for a schedule whose previous run has not finished
+var ErrAlreadyRunning = errors.New("already running")
+
+// RunNow starts a run of the schedule with the given name in the background and returns it.
+// Maintenance windows apply as for runs that are due. A schedule whose operation, parameters
+// or cameras are invalid is not started.
+func RunNow(ctx context.Context, store *Store, name string) (*Run, error) {
+	schedule, err := store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := prepare(schedule); err != nil {
+		return nil, err
+	}
+	run := start(ctx, store, schedule, time.Now(), true)
+	if run == nil {
+		return nil, fmt.Errorf("schedule %s is %w", schedule.Name, ErrAlreadyRunning)
+	}
+	return run, nil
+}
+
+// start runs a schedule in the background unless it is already running. It returns the
+// run as saved when it started, or nil when the schedule is already running.
+func start(ctx context.Context, store *Store, schedule *Schedule, scheduledAt time.Time, manual bool) *Run {
+	runningMu.Lock()
+	if running[schedule.Name] {
+		runningMu.Unlock()
+		log.Printf("Schedule %s is still running, skipping its run at %s", schedule.Name, scheduledAt.Format(time.RFC3339))
+		return nil
+	}
+	running[schedule.Name] = true
+	runningMu.Unlock()
+
+	started := time.Now()
+	run := &Run{
+		ID:          newRunID(),
+		Schedule:    schedule.Name,
+		Operation:   schedule.Operation,
+		Manual:      manual,
+		ScheduledAt: scheduledAt,
+		StartedAt:   &started,
+		Status:      RunRunning,
+		Cameras:     []CameraResult{},
+		Summary:     map[string]int{},
+	}
+	recordRun(store, run)
+	// The goroutine fills in the run, so the caller gets a copy of it as it started
+	snapshot := run.copy()
+
+	go func() {
+		defer func() {
+			runningMu.Lock()
+			delete(running, schedule.Name)
+			runningMu.Unlock()
+		}()
+		execute(ctx, store, schedule, run)
+		recordRun(store, run)
+		log.Printf("Schedule %s run %s %s: %s", schedule.Name, run.ID, run.Status, summaryLabel(run.Summary))
+	}()
+	return snapshot
+}
+
+// recordRun saves a run, logging failures since runs happen in the background
+func recordRun(store *Store, run *Run) {
+	if err := store.AddRun(run); err != nil {
+		log.Printf("Failed to record run %s of schedule %s: %v", run.ID, run.Schedule, err)
+	}
+}
+
+// execute runs the operation of a schedule on those of its cameras that are inside a
+// maintenance window, and fills in the run. Cameras whose windows close before the
+// operation gets to them are not started.
+func execute(ctx context.Context, store *Store, schedule *Schedule, run *Run) {
+	defer func() {
+		finished := time.Now()
+		run.FinishedAt = &finished
+		for _, result := range run.Cameras {
+			run.Summary[result.Status]++
+		}
+	}()
+
+	fail := func(err error) {
+		run.Status = RunFailed
+		run.Error = err.Error()
+	}
+	op, cameraIDs, err := prepare(schedule)
+	if err != nil {
+		fail(err)
+		return
+	}
+	windows, err := store.Windows()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	gate := &windowGate{
+		windows:       windows,
+		withoutWindow: schedule.AllowWithoutWindow,
+		cameras:       make(map[string]models.Camera),
+		started:       make(map[string]bool),
+		refused:       make(map[string]time.Time),
+	}
+	now := time.Now()
+	var allowed []string
+	for _, cameraID := range cameraIDs {
+		cam, found := camera.DefaultRegistry().Camera(cameraID)
+		if !found {
+			run.Cameras = append(run.Cameras, CameraResult{CameraID: cameraID, Status: CameraFailed, Message: "camera not found in the inventory"})
+			continue
+		}
+		ok, opens := Allowed(windows, cam, now, schedule.AllowWithoutWindow)
+		if !ok {
+			run.Cameras = append(run.Cameras, CameraResult{CameraID: cameraID, Status: CameraOutsideWindow, Message: outsideWindowMessage(opens)})
+			continue
+		}
+		gate.cameras[cameraID] = cam
+		allowed = append(allowed, cameraID)
+	}
+
+	if len(allowed) > 0 {
+		log.Printf("Schedule %s: running %s on %d camera(s), %d outside their maintenance windows", schedule.Name, schedule.Operation, len(allowed), len(cameraIDs)-len(allowed))
+		for _, result := range op.Run(ctx, allowed, schedule.Params, gate.check) {
+			if opens, refused := gate.refused[result.CameraID]; refused {
+				result = CameraResult{CameraID: result.CameraID, Status: CameraOutsideWindow, Message: "maintenance window closed before the camera was started"}
+				if !opens.IsZero() {
+					result.Message += ", next window opens " + opens.Format(time.RFC3339)
+				}
+			}
+			run.Cameras = append(run.Cameras, result)
+		}
+	}
+
+	run.Status = RunCompleted
+	for _, result := range run.Cameras {
+		if result.Status == CameraFailed {
+			run.Status = RunFailed
+			break
+		}
+	}
+	if run.Status == RunCompleted && len(allowed) == 0 && len(run.Cameras) > 0 {
+		run.Status = RunOutsideWindow
+	}
+}
+
+// prepare looks up the operation of a schedule, checks its parameters and resolves its cameras
+func prepare(schedule *Schedule) (Operation, []string, error) {
+	op, found := lookupOperation(schedule.Operation)
+	if !found {
+		return op, nil, fmt.Errorf("operation %s is not available", schedule.Operation)
+	}
+	if op.Validate != nil {
+		if err := op.Validate(schedule.Params); err != nil {
+			return op, nil, fmt.Errorf("invalid %s parameters: %w", schedule.Operation, err)
+		}
+	}
+	cameraIDs, err := scheduleCameraIDs(schedule)
+	if err != nil {
+		return op, nil, err
+	}
+	return op, cameraIDs, nil
+}
+
+// windowGate lets the operation of a run start a camera only while one of its maintenance
+// windows is open. A camera that has started is let through for the rest of the run, so
+// it is validated and rolled back even when its window closed in between.
+type windowGate struct {
+	windows       []*Window
+	withoutWindow bool
+	cameras       map[string]models.Camera // The cameras the run operates
+
+	mu      sync.Mutex
+	started map[string]bool
+	refused map[string]time.Time // Cameras held back, with when their next window opens
+}
+
+// check is the start check of the worker pool
+func (g *windowGate) check(cameraID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cam, found := g.cameras[cameraID]
+	if !found || g.started[cameraID] {
+		return nil
+	}
+	if ok, opens := Allowed(g.windows, cam, time.Now(), g.withoutWindow); !ok {
+		g.refused[cameraID] = opens
+		return fmt.Errorf("maintenance window of camera %s closed before it was started", cameraID)
+	}
+	g.started[cameraID] = true
+	return nil
+}
+
+// checkAt returns an error when the one-off time of a schedule is outside the maintenance
+// windows of one of its cameras, since the run would then not operate that camera.
+// Cameras that are not in the inventory are reported when the schedule runs.
+func checkAt(schedule *Schedule, windows map[string]*Window) error {
+	cameraIDs, err := scheduleCameraIDs(schedule)
+	if err != nil {
+		return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+	}
+	list := make([]*Window, 0, len(windows))
+	for _, window := range windows {
+		list = append(list, window)
+	}
+
+	var outside []string
+	for _, cameraID := range cameraIDs {
+		cam, found := camera.DefaultRegistry().Camera(cameraID)
+		if !found {
+			continue
+		}
+		if ok, opens := Allowed(list, cam, *schedule.At, schedule.AllowWithoutWindow); !ok {
+			outside = append(outside, fmt.Sprintf("camera %s: %s", cameraID, outsideWindowMessage(opens)))
+		}
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+	if len(outside) > maxReportedCameras {
+		outside = append(outside[:maxReportedCameras], fmt.Sprintf("and %d more", len(outside)-maxReportedCameras))
+	}
+	return fmt.Errorf("schedule %s: at %s is outside the maintenance windows of its cameras (%s)",
+		schedule.Name, schedule.At.Format(time.RFC3339), strings.Join(outside, "; "))
+}
+
+// scheduleCameraIDs returns the cameras of a schedule: those it lists, in order and each
+// once, or the inventory in its own order when it lists none
+func scheduleCameraIDs(schedule *Schedule) ([]string, error) {
+	if len(schedule.CameraIDs) == 0 && schedule.Selector == "" {
+		var cameraIDs []string
+		for _, cam := range camera.GetAllCameras() {
+			cameraIDs = append(cameraIDs, cam.ID)
+		}
+		return cameraIDs, nil
+	}
+	listed := make([]string, 0, len(schedule.CameraIDs))
+	seen := make(map[string]bool, len(schedule.CameraIDs))
+	for _, cameraID := range schedule.CameraIDs {
+		if !seen[cameraID] {
+			seen[cameraID] = true
+			listed = append(listed, cameraID)
+		}
+	}
+	if schedule.Selector == "" {
+		return listed, nil
+	}
+
+	selected, err := camera.SelectCameraIDs(schedule.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedule.CameraIDs) == 0 {
+		return selected, nil
+	}
+	matched := make(map[string]bool, len(selected))
+	for _, cameraID := range selected {
+		matched[cameraID] = true
+	}
+	var cameraIDs []string
+	for _, cameraID := range listed {
+		if matched[cameraID] {
+			cameraIDs = append(cameraIDs, cameraID)
+		}
+	}
+	return cameraIDs, nil
+}
+
+// summaryLabel formats the number of cameras by status, e.g. "2 succeeded, 1 outside-window"
+func summaryLabel(summary map[string]int) string {
+	var parts []string
+	for _, status := range []string{CameraSucceeded, CameraFailed, CameraSkipped, CameraOutsideWindow} {
+		if summary[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", summary[status], status))
+		}
+	}
+	if len(parts) == 0 {
+		return "no cameras"
+	}
+	return strings.Join(parts, ", ")
+}
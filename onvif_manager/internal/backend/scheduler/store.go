@@ -0,0 +1,326 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"onvif_manager/internal/backend/jsonstore"
+)
+
+// schedulesFileVersion is written into every schedules file so the format can evolve
+const schedulesFileVersion = 1
+
+// maxRuns is the number of runs kept per schedule
+const maxRuns = 20
+
+// ErrNotFound is returned for schedules and windows that do not exist
+var ErrNotFound = errors.New("not found")
+
+// schedulesFile is the on-disk layout of the schedules, the maintenance windows and the
+// recent runs of each schedule, by name
+type schedulesFile struct {
+	Version   int                  `json:"version"`
+	Schedules map[string]*Schedule `json:"schedules"`
+	Windows   map[string]*Window   `json:"windows"`
+	Runs      map[string][]*Run    `json:"runs"` // Oldest first
+}
+
+// Store keeps the schedules, maintenance windows and runs in a JSON file, or in memory
+// when no file is given. The CLI and the server share the file.
+type Store struct {
+	file *jsonstore.File[schedulesFile]
+}
+
+// NewStore creates a store backed by the JSON file at path, or an in-memory store when path is empty
+func NewStore(path string) *Store {
+	return &Store{file: jsonstore.New(path, schedulesFormat)}
+}
+
+// Path returns the location of the schedules file, or empty for an in-memory store
+func (s *Store) Path() string {
+	return s.file.Path()
+}
+
+// Shared is the schedules store used by the API, the CLI and the scheduler
+var Shared = jsonstore.NewShared(jsonstore.Location{
+	EnvVar:   "ONVIF_MANAGER_SCHEDULES",
+	FileName: "schedules.json",
+}, NewStore)
+
+// List returns every schedule, ordered by name
+func (s *Store) List() ([]*Schedule, error) {
+	schedules := []*Schedule{}
+	err := s.file.Read(func(file *schedulesFile) error {
+		for _, schedule := range file.Schedules {
+			schedules = append(schedules, schedule.copy())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].Name < schedules[j].Name
+	})
+	return schedules, nil
+}
+
+// Get returns the schedule with the given name, or ErrNotFound
+func (s *Store) Get(name string) (*Schedule, error) {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule *Schedule
+	err = s.file.Read(func(file *schedulesFile) error {
+		saved, found := file.Schedules[name]
+		if !found {
+			return fmt.Errorf("schedule %w: %s", ErrNotFound, name)
+		}
+		schedule = saved.copy()
+		return nil
+	})
+	return schedule, err
+}
+
+// Put validates a schedule and creates it, or replaces the schedule with the same name,
+// and sets its next run. A one-off time must be in the future unless it is unchanged,
+// and inside the maintenance windows of the schedule's cameras while it is ahead.
+// It reports whether the schedule was created.
+func (s *Store) Put(schedule *Schedule) (bool, error) {
+	if err := schedule.Validate(); err != nil {
+		return false, err
+	}
+
+	created := false
+	err := s.file.Update(func(file *schedulesFile) error {
+		now := time.Now()
+		existing, exists := file.Schedules[schedule.Name]
+		created = !exists
+		unchanged := exists && existing.At != nil && schedule.At != nil && existing.At.Equal(*schedule.At)
+		if schedule.At != nil && !schedule.At.After(now) && !unchanged {
+			return fmt.Errorf("schedule %s: at %s is not in the future", schedule.Name, schedule.At.Format(time.RFC3339))
+		}
+		if schedule.At != nil && schedule.At.After(now) {
+			if err := checkAt(schedule, file.Windows); err != nil {
+				return err
+			}
+		}
+
+		schedule.CreatedAt = now.UTC()
+		schedule.LastRun = nil
+		if exists {
+			schedule.CreatedAt = existing.CreatedAt
+			schedule.LastRun = existing.LastRun
+		}
+		schedule.UpdatedAt = now.UTC()
+		schedule.NextRun = schedule.next(now)
+		file.Schedules[schedule.Name] = schedule.copy()
+		return nil
+	})
+	return created, err
+}
+
+// Delete removes the schedule with the given name and its runs, or returns ErrNotFound
+func (s *Store) Delete(name string) error {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return err
+	}
+	return s.file.Update(func(file *schedulesFile) error {
+		if _, found := file.Schedules[name]; !found {
+			return fmt.Errorf("schedule %w: %s", ErrNotFound, name)
+		}
+		delete(file.Schedules, name)
+		delete(file.Runs, name)
+		return nil
+	})
+}
+
+// Windows returns every maintenance window, ordered by name
+func (s *Store) Windows() ([]*Window, error) {
+	windows := []*Window{}
+	err := s.file.Read(func(file *schedulesFile) error {
+		for _, window := range file.Windows {
+			copied := *window
+			copied.Days = append([]string{}, window.Days...)
+			windows = append(windows, &copied)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Name < windows[j].Name
+	})
+	return windows, nil
+}
+
+// PutWindow validates a maintenance window and creates it, or replaces the window with
+// the same name. It reports whether the window was created.
+func (s *Store) PutWindow(window *Window) (bool, error) {
+	if err := window.Validate(); err != nil {
+		return false, err
+	}
+	window.UpdatedAt = time.Now().UTC()
+
+	created := false
+	err := s.file.Update(func(file *schedulesFile) error {
+		_, exists := file.Windows[window.Name]
+		created = !exists
+		copied := *window
+		copied.Days = append([]string{}, window.Days...)
+		file.Windows[window.Name] = &copied
+		return nil
+	})
+	return created, err
+}
+
+// DeleteWindow removes the maintenance window with the given name, or returns ErrNotFound
+func (s *Store) DeleteWindow(name string) error {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return err
+	}
+	return s.file.Update(func(file *schedulesFile) error {
+		if _, found := file.Windows[name]; !found {
+			return fmt.Errorf("maintenance window %w: %s", ErrNotFound, name)
+		}
+		delete(file.Windows, name)
+		return nil
+	})
+}
+
+// Runs returns the recent runs of a schedule, newest first
+func (s *Store) Runs(name string) ([]*Run, error) {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []*Run{}
+	err = s.file.Read(func(file *schedulesFile) error {
+		if _, found := file.Schedules[name]; !found {
+			return fmt.Errorf("schedule %w: %s", ErrNotFound, name)
+		}
+		for i := len(file.Runs[name]) - 1; i >= 0; i-- {
+			runs = append(runs, file.Runs[name][i].copy())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// AddRun saves a run, replacing an earlier save of the same run, and drops the oldest
+// runs of the schedule beyond the last 20. Runs of deleted schedules are dropped.
+func (s *Store) AddRun(run *Run) error {
+	return s.file.Update(func(file *schedulesFile) error {
+		if _, found := file.Schedules[run.Schedule]; !found {
+			return nil
+		}
+		runs := file.Runs[run.Schedule]
+		for i, saved := range runs {
+			if saved.ID == run.ID {
+				runs[i] = run.copy()
+				return nil
+			}
+		}
+		runs = append(runs, run.copy())
+		if len(runs) > maxRuns {
+			runs = runs[len(runs)-maxRuns:]
+		}
+		file.Runs[run.Schedule] = runs
+		return nil
+	})
+}
+
+// due is a schedule whose run time has come
+type due struct {
+	schedule    *Schedule
+	scheduledAt time.Time
+}
+
+// claimDue returns the schedules that are due at now and moves each of them on to its
+// next run, so that no other tick or restart runs them again
+func (s *Store) claimDue(now time.Time) ([]due, error) {
+	var claimed []due
+	err := s.file.Update(func(file *schedulesFile) error {
+		for _, schedule := range file.Schedules {
+			if schedule.Disabled || schedule.NextRun == nil || schedule.NextRun.After(now) {
+				continue
+			}
+			scheduledAt := *schedule.NextRun
+			schedule.LastRun = &scheduledAt
+			schedule.NextRun = schedule.next(now)
+			claimed = append(claimed, due{schedule: schedule.copy(), scheduledAt: scheduledAt})
+		}
+		return nil
+	})
+	sort.Slice(claimed, func(i, j int) bool {
+		return claimed[i].schedule.Name < claimed[j].schedule.Name
+	})
+	return claimed, err
+}
+
+// copy returns a copy of the schedule that does not share its camera IDs and times
+func (s *Schedule) copy() *Schedule {
+	copied := *s
+	copied.CameraIDs = append([]string(nil), s.CameraIDs...)
+	copied.At = copyTime(s.At)
+	copied.NextRun = copyTime(s.NextRun)
+	copied.LastRun = copyTime(s.LastRun)
+	return &copied
+}
+
+// copy returns a copy of the run that does not share its camera results and summary
+func (r *Run) copy() *Run {
+	copied := *r
+	copied.Cameras = append([]CameraResult{}, r.Cameras...)
+	copied.Summary = make(map[string]int, len(r.Summary))
+	for status, count := range r.Summary {
+		copied.Summary[status] = count
+	}
+	return &copied
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
+
+func newSchedulesFile() *schedulesFile {
+	return &schedulesFile{
+		Version:   schedulesFileVersion,
+		Schedules: make(map[string]*Schedule),
+		Windows:   make(map[string]*Window),
+		Runs:      make(map[string][]*Run),
+	}
+}
+
+// schedulesFormat describes the schedules file to the JSON store
+var schedulesFormat = jsonstore.Format[schedulesFile]{
+	Name:    "schedules",
+	Version: schedulesFileVersion,
+	Empty:   newSchedulesFile,
+	Fill: func(file *schedulesFile) {
+		if file.Schedules == nil {
+			file.Schedules = make(map[string]*Schedule)
+		}
+		if file.Windows == nil {
+			file.Windows = make(map[string]*Window)
+		}
+		if file.Runs == nil {
+			file.Runs = make(map[string][]*Run)
+		}
+	},
+}
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
+
+// Window is a recurring maintenance window during which the cameras it selects may be
+// changed. Times are in the server's local time; a window whose end is not after its
+// start runs past midnight into the next day.
+type Window struct {
+	Name        string    `yaml:"name" json:"name"`
+	Description string    `yaml:"description,omitempty" json:"description,omitempty"`
+	Selector    string    `yaml:"selector,omitempty" json:"selector,omitempty"` // Cameras the window applies to; every camera when empty
+	Days        []string  `yaml:"days,omitempty" json:"days,omitempty"`         // Days the window opens, mon to sun; every day when empty
+	Start       string    `yaml:"start" json:"start"`                           // Opening time, HH:MM
+	End         string    `yaml:"end" json:"end"`                               // Closing time, HH:MM
+	UpdatedAt   time.Time `yaml:"-" json:"updatedAt"`
+}
+
+// weekdays are the day names of windows, indexed by time.Weekday; full names such as
+// friday are accepted too
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// clockFormat is the layout of window opening and closing times
+const clockFormat = "15:04"
+
+// Validate checks the window and normalizes its name and days
+func (w *Window) Validate() error {
+	name, err := NormalizeName(w.Name)
+	if err != nil {
+		return err
+	}
+	w.Name = name
+
+	if _, err := camera.ParseSelector(w.Selector); err != nil {
+		return fmt.Errorf("window %s: %w", w.Name, err)
+	}
+	start, err := time.Parse(clockFormat, strings.TrimSpace(w.Start))
+	if err != nil {
+		return fmt.Errorf("window %s: invalid start %q, use HH:MM", w.Name, w.Start)
+	}
+	end, err := time.Parse(clockFormat, strings.TrimSpace(w.End))
+	if err != nil {
+		return fmt.Errorf("window %s: invalid end %q, use HH:MM", w.Name, w.End)
+	}
+	if start.Equal(end) {
+		return fmt.Errorf("window %s: start and end must differ", w.Name)
+	}
+	w.Start, w.End = start.Format(clockFormat), end.Format(clockFormat)
+
+	days := make([]string, 0, len(w.Days))
+	for _, day := range w.Days {
+		name := strings.ToLower(strings.TrimSpace(day))
+		for i, short := range weekdays {
+			if name == strings.ToLower(time.Weekday(i).String()) {
+				name = short
+			}
+		}
+		if weekday(name) < 0 {
+			return fmt.Errorf("window %s: invalid day %q, use mon to sun", w.Name, day)
+		}
+		days = append(days, name)
+	}
+	w.Days = days
+	return nil
+}
+
+// weekday returns the time.Weekday of a day name, or -1
+func weekday(day string) time.Weekday {
+	for i, name := range weekdays {
+		if name == day {
+			return time.Weekday(i)
+		}
+	}
+	return -1
+}
+
+// opensOn reports whether the window opens on a day of the week
+func (w *Window) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekday(name) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// bounds returns the opening and closing time of the window that opens on the day of t
+func (w *Window) bounds(t time.Time) (time.Time, time.Time) {
+	start, _ := time.Parse(clockFormat, w.Start)
+	end, _ := time.Parse(clockFormat, w.End)
+	opens := time.Date(t.Year(), t.Month(), t.Day(), start.Hour(), start.Minute(), 0, 0, t.Location())
+	closes := time.Date(t.Year(), t.Month(), t.Day(), end.Hour(), end.Minute(), 0, 0, t.Location())
+	if !closes.After(opens) {
+		closes = closes.AddDate(0, 0, 1)
+	}
+	return opens, closes
+}
+
+// OpenAt reports whether the window is open at t, including a window that opened the day before
+func (w *Window) OpenAt(t time.Time) bool {
+	for _, day := range []time.Time{t, t.AddDate(0, 0, -1)} {
+		if !w.opensOn(day.Weekday()) {
+			continue
+		}
+		opens, closes := w.bounds(day)
+		if !t.Before(opens) && t.Before(closes) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns when the window opens next after t
+func (w *Window) NextOpen(t time.Time) time.Time {
+	for i := 0; i <= 7; i++ {
+		day := t.AddDate(0, 0, i)
+		if !w.opensOn(day.Weekday()) {
+			continue
+		}
+		if opens, _ := w.bounds(day); opens.After(t) {
+			return opens
+		}
+	}
+	return time.Time{}
+}
+
+// Applies reports whether the window applies to a camera
+func (w *Window) Applies(cam models.Camera) bool {
+	selector, err := camera.ParseSelector(w.Selector)
+	return err == nil && selector.Matches(cam)
+}
+
+// Allowed reports whether a camera may be operated at t: when one of the windows that
+// apply to it is open. A camera that no window applies to is only allowed with
+// withoutWindow. Otherwise it returns when the first of its windows opens next, which
+// is the zero time only when no window applies to the camera.
+func Allowed(windows []*Window, cam models.Camera, t time.Time, withoutWindow bool) (bool, time.Time) {
+	var next time.Time
+	restricted := false
+	for _, window := range windows {
+		if !window.Applies(cam) {
+			continue
+		}
+		restricted = true
+		if window.OpenAt(t) {
+			return true, time.Time{}
+		}
+		if opens := window.NextOpen(t); !opens.IsZero() && (next.IsZero() || opens.Before(next)) {
+			next = opens
+		}
+	}
+	return !restricted && withoutWindow, next
+}
+
+// outsideWindowMessage explains why a camera is not operated; opens is when its next window opens
+func outsideWindowMessage(opens time.Time) string {
+	if opens.IsZero() {
+		return "no maintenance window applies to the camera"
+	}
+	return "outside its maintenance windows, next window opens " + opens.Format(time.RFC3339)
+}
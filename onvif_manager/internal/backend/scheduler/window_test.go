@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
+
+// at returns a time of the week of Monday 12 October 2026, day 0 being the Monday
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 10, 12+day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestWindowValidate(t *testing.T) {
+	tests := []struct {
+		window   Window
+		wantDays []string
+		wantErr  bool
+	}{
+		{window: Window{Name: "Nightly", Start: "1:00", End: "04:00"}, wantDays: []string{}},
+		{window: Window{Name: "weekend", Start: "22:00", End: "02:00", Days: []string{"Saturday", " sun "}}, wantDays: []string{"sat", "sun"}},
+		{window: Window{Name: "nightly", Start: "01:00", End: "01:00"}, wantErr: true},
+		{window: Window{Name: "nightly", Start: "25:00", End: "04:00"}, wantErr: true},
+		{window: Window{Name: "nightly", Start: "01:00"}, wantErr: true},
+		{window: Window{Name: "nightly", Start: "01:00", End: "04:00", Days: []string{"someday"}}, wantErr: true},
+		{window: Window{Name: "nightly", Start: "01:00", End: "04:00", Selector: "site=lobby"}, wantErr: true},
+		{window: Window{Name: "night shift", Start: "01:00", End: "04:00"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		window := tt.window
+		err := window.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.window, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if window.Name != strings.ToLower(tt.window.Name) || len(window.Start) != len(clockFormat) {
+			t.Errorf("Validate(%+v) normalized to %+v", tt.window, window)
+		}
+		if !reflect.DeepEqual(window.Days, tt.wantDays) {
+			t.Errorf("Validate(%+v) days = %v, want %v", tt.window, window.Days, tt.wantDays)
+		}
+	}
+}
+
+func TestWindowOpenAt(t *testing.T) {
+	nightly := &Window{Name: "nightly", Start: "01:00", End: "04:00"}
+	friday := &Window{Name: "friday", Start: "22:00", End: "02:00", Days: []string{"fri"}}
+
+	tests := []struct {
+		name     string
+		window   *Window
+		t        time.Time
+		want     bool
+		wantNext time.Time
+	}{
+		{name: "before opening", window: nightly, t: at(0, 0, 59), want: false, wantNext: at(0, 1, 0)},
+		{name: "at opening", window: nightly, t: at(0, 1, 0), want: true, wantNext: at(1, 1, 0)},
+		{name: "at closing", window: nightly, t: at(0, 4, 0), want: false, wantNext: at(1, 1, 0)},
+		{name: "overnight before midnight", window: friday, t: at(4, 23, 0), want: true, wantNext: at(11, 22, 0)},
+		{name: "overnight after midnight", window: friday, t: at(5, 1, 30), want: true, wantNext: at(11, 22, 0)},
+		{name: "after closing", window: friday, t: at(5, 2, 0), want: false, wantNext: at(11, 22, 0)},
+		{name: "other day", window: friday, t: at(5, 23, 0), want: false, wantNext: at(11, 22, 0)},
+		{name: "earlier that day", window: friday, t: at(4, 21, 0), want: false, wantNext: at(4, 22, 0)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.window.OpenAt(tt.t); got != tt.want {
+			t.Errorf("%s: OpenAt(%s) = %v, want %v", tt.name, tt.t, got, tt.want)
+		}
+		if got := tt.window.NextOpen(tt.t); !got.Equal(tt.wantNext) {
+			t.Errorf("%s: NextOpen(%s) = %s, want %s", tt.name, tt.t, got, tt.wantNext)
+		}
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	windows := []*Window{
+		{Name: "lobby", Selector: "group=lobby", Start: "01:00", End: "04:00"},
+		{Name: "lobby-weekend", Selector: "group=lobby", Start: "10:00", End: "12:00", Days: []string{"sat", "sun"}},
+		{Name: "garage", Selector: "group=garage", Start: "02:00", End: "03:00"},
+	}
+	lobby := models.Camera{ID: "1", Groups: []string{"lobby"}}
+	office := models.Camera{ID: "2"}
+
+	tests := []struct {
+		name          string
+		cam           models.Camera
+		t             time.Time
+		withoutWindow bool
+		want          bool
+		wantNext      time.Time
+	}{
+		{name: "one window open", cam: lobby, t: at(0, 2, 0), want: true},
+		{name: "other window open", cam: lobby, t: at(5, 11, 0), want: true},
+		{name: "closed, earliest next opening", cam: lobby, t: at(5, 5, 0), want: false, wantNext: at(5, 10, 0)},
+		{name: "closed on a weekday", cam: lobby, t: at(0, 5, 0), want: false, wantNext: at(1, 1, 0)},
+		{name: "no window", cam: office, t: at(0, 2, 0), want: false},
+		{name: "no window allowed", cam: office, t: at(0, 2, 0), withoutWindow: true, want: true},
+		{name: "closed and allowed without window", cam: lobby, t: at(0, 5, 0), withoutWindow: true, want: false, wantNext: at(1, 1, 0)},
+	}
+
+	for _, tt := range tests {
+		ok, next := Allowed(windows, tt.cam, tt.t, tt.withoutWindow)
+		if ok != tt.want || !next.Equal(tt.wantNext) {
+			t.Errorf("%s: Allowed() = %v, %s, want %v, %s", tt.name, ok, next, tt.want, tt.wantNext)
+		}
+	}
+}
+
+// clockWindow returns a window of every day from the given offsets to now
+func clockWindow(name, selector string, from, to time.Duration) *Window {
+	now := time.Now()
+	return &Window{Name: name, Selector: selector, Start: now.Add(from).Format(clockFormat), End: now.Add(to).Format(clockFormat)}
+}
+
+func TestWindowGate(t *testing.T) {
+	open := models.Camera{ID: "1", Groups: []string{"open"}}
+	closed := models.Camera{ID: "2", Groups: []string{"closed"}}
+	gate := &windowGate{
+		windows: []*Window{
+			clockWindow("open", "group=open", -time.Hour, time.Hour),
+			clockWindow("closed", "group=closed", 2*time.Hour, 3*time.Hour),
+		},
+		cameras: map[string]models.Camera{"1": open, "2": closed},
+		started: make(map[string]bool),
+		refused: make(map[string]time.Time),
+	}
+
+	if err := gate.check("1"); err != nil {
+		t.Errorf("check() of a camera inside its window = %v", err)
+	}
+	if err := gate.check("2"); err == nil {
+		t.Error("check() of a camera outside its window = nil")
+	}
+	if _, refused := gate.refused["2"]; !refused || len(gate.refused) != 1 {
+		t.Errorf("refused = %v, want camera 2", gate.refused)
+	}
+	if err := gate.check("3"); err != nil {
+		t.Errorf("check() of a camera the run does not operate = %v", err)
+	}
+
+	// A camera that started keeps going after its window closed
+	gate.windows = gate.windows[1:]
+	if err := gate.check("1"); err != nil {
+		t.Errorf("check() of a started camera after its window closed = %v", err)
+	}
+}
+
+func TestCheckAt(t *testing.T) {
+	var cameras []models.Camera
+	for _, id := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		cameras = append(cameras, models.Camera{ID: id, Groups: []string{"lobby"}})
+	}
+	cameras = append(cameras, models.Camera{ID: "8"})
+	store := &camera.MemoryStore{}
+	store.Save(cameras)
+	if err := camera.UseStore(store); err != nil {
+		t.Fatalf("UseStore() error = %v", err)
+	}
+	windows := map[string]*Window{
+		"lobby": {Name: "lobby", Selector: "group=lobby", Start: "01:00", End: "04:00"},
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		at       time.Time
+		wantErr  []string // Parts of the error, none when the time is allowed
+	}{
+		{name: "inside the window", schedule: Schedule{Selector: "group=lobby"}, at: at(1, 2, 0)},
+		{name: "camera without a window allowed", schedule: Schedule{AllowWithoutWindow: true}, at: at(1, 2, 0)},
+		{name: "camera without a window", schedule: Schedule{CameraIDs: []string{"1", "8"}}, at: at(1, 2, 0), wantErr: []string{"camera 8: no maintenance window applies"}},
+		{name: "unknown cameras are left to the run", schedule: Schedule{CameraIDs: []string{"1", "99"}}, at: at(1, 2, 0)},
+		{
+			name:     "outside the window",
+			schedule: Schedule{Selector: "group=lobby"},
+			at:       at(1, 5, 0),
+			wantErr:  []string{"camera 1: outside its maintenance windows, next window opens " + at(2, 1, 0).Format(time.RFC3339), "camera 5:", "and 2 more"},
+		},
+	}
+
+	for _, tt := range tests {
+		schedule := tt.schedule
+		schedule.Name = "nightly"
+		schedule.At = &tt.at
+
+		err := checkAt(&schedule, windows)
+		if len(tt.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: checkAt() error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: checkAt() error = nil, want one", tt.name)
+			continue
+		}
+		for _, part := range tt.wantErr {
+			if !strings.Contains(err.Error(), part) {
+				t.Errorf("%s: checkAt() error = %v, want it to mention %q", tt.name, err, part)
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"onvif_manager/internal/backend/jsonstore"
+)
+
+// templatesFileVersion is written into every templates file so the format can evolve
+const templatesFileVersion = 1
+
+// ErrNotFound is returned for templates that do not exist
+var ErrNotFound = errors.New("template not found")
+
+// templatesFile is the on-disk layout of the templates, by name
+type templatesFile struct {
+	Version   int                  `json:"version"`
+	Templates map[string]*Template `json:"templates"`
+}
+
+// Store keeps the configuration templates in a JSON file, or in memory when no file
+// is given. The CLI and the server share the file.
+type Store struct {
+	file *jsonstore.File[templatesFile]
+}
+
+// NewStore creates a store backed by the JSON file at path, or an in-memory store when path is empty
+func NewStore(path string) *Store {
+	return &Store{file: jsonstore.New(path, templatesFormat)}
+}
+
+// Path returns the location of the templates file, or empty for an in-memory store
+func (s *Store) Path() string {
+	return s.file.Path()
+}
+
+// Shared is the templates store used by the API and the CLI
+var Shared = jsonstore.NewShared(jsonstore.Location{
+	EnvVar:   "ONVIF_MANAGER_TEMPLATES",
+	FileName: "templates.json",
+}, NewStore)
+
+// List returns every template, ordered by name
+func (s *Store) List() ([]*Template, error) {
+	templates := []*Template{}
+	err := s.file.Read(func(file *templatesFile) error {
+		for _, template := range file.Templates {
+			templates = append(templates, template.copy())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// Get returns the template with the given name, or ErrNotFound
+func (s *Store) Get(name string) (*Template, error) {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var template *Template
+	err = s.file.Read(func(file *templatesFile) error {
+		saved, found := file.Templates[name]
+		if !found {
+			return fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		template = saved.copy()
+		return nil
+	})
+	return template, err
+}
+
+// Put validates a template and creates it, or replaces the template with the same name.
+// It reports whether the template was created.
+func (s *Store) Put(template *Template) (bool, error) {
+	if err := template.Validate(); err != nil {
+		return false, err
+	}
+	template.UpdatedAt = time.Now().UTC()
+
+	created := false
+	err := s.file.Update(func(file *templatesFile) error {
+		_, exists := file.Templates[template.Name]
+		created = !exists
+		file.Templates[template.Name] = template.copy()
+		return nil
+	})
+	return created, err
+}
+
+// Delete removes the template with the given name, or returns ErrNotFound
+func (s *Store) Delete(name string) error {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return err
+	}
+	return s.file.Update(func(file *templatesFile) error {
+		if _, found := file.Templates[name]; !found {
+			return fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		delete(file.Templates, name)
+		return nil
+	})
+}
+
+// copy returns a copy of the template that does not share its streams and variants
+func (t *Template) copy() *Template {
+	copied := *t
+	copied.Streams = append([]Stream{}, t.Streams...)
+	copied.Models = make([]Variant, len(t.Models))
+	for i, variant := range t.Models {
+		copied.Models[i] = Variant{Model: variant.Model, Streams: append([]Stream{}, variant.Streams...)}
+	}
+	return &copied
+}
+
+func newTemplatesFile() *templatesFile {
+	return &templatesFile{Version: templatesFileVersion, Templates: make(map[string]*Template)}
+}
+
+// templatesFormat describes the templates file to the JSON store
+var templatesFormat = jsonstore.Format[templatesFile]{
+	Name:    "templates",
+	Version: templatesFileVersion,
+	Empty:   newTemplatesFile,
+	Fill: func(file *templatesFile) {
+		if file.Templates == nil {
+			file.Templates = make(map[string]*Template)
+		}
+	},
+}
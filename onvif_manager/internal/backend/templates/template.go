@@ -0,0 +1,287 @@
+package templates
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+)
+
+// Template is a named, reusable stream configuration. Streams is the configuration for
+// any camera; Models overrides it for cameras whose device service reports a given model.
+type Template struct {
+	Name        string    `yaml:"name" json:"name"`
+	Description string    `yaml:"description,omitempty" json:"description,omitempty"`
+	Streams     []Stream  `yaml:"streams" json:"streams"`
+	Models      []Variant `yaml:"models,omitempty" json:"models,omitempty"`
+	UpdatedAt   time.Time `yaml:"-" json:"updatedAt"`
+}
+
+// Variant overrides the streams of a template for one camera model. Model matches the
+// model reported by the camera case-insensitively; a trailing * matches any model
+// starting with the text before it.
+type Variant struct {
+	Model string `yaml:"model" json:"model"`
+	// Streams override the settings that are set of the template stream with the same
+	// profile; a stream for a profile the template does not configure is added
+	Streams []Stream `yaml:"streams" json:"streams"`
+}
+
+// Stream is the encoder configuration of one profile. Width, height and frame rate are
+// required in the streams of a template; the other settings keep the camera's current
+// values when they are not set.
+type Stream struct {
+	Profile          string `yaml:"profile" json:"profile"` // Profile token, name or role; the main stream when empty
+	Width            int    `yaml:"width,omitempty" json:"width,omitempty"`
+	Height           int    `yaml:"height,omitempty" json:"height,omitempty"`
+	FPS              int    `yaml:"fps,omitempty" json:"fps,omitempty"`
+	Bitrate          int    `yaml:"bitrate,omitempty" json:"bitrate,omitempty"`
+	Encoding         string `yaml:"encoding,omitempty" json:"encoding,omitempty"`
+	GOP              int    `yaml:"gop,omitempty" json:"gop,omitempty"`
+	EncoderProfile   string `yaml:"encoderProfile,omitempty" json:"encoderProfile,omitempty"`
+	RateControl      string `yaml:"rateControl,omitempty" json:"rateControl,omitempty"`
+	Quality          int    `yaml:"quality,omitempty" json:"quality,omitempty"`
+	EncodingInterval int    `yaml:"encodingInterval,omitempty" json:"encodingInterval,omitempty"`
+}
+
+// namePattern is the form of template names, which are used in URLs and on the command line
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// NormalizeName checks a template name and returns it in lower case
+func NormalizeName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("template name is required")
+	}
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid template name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return name, nil
+}
+
+// Validate checks the template and normalizes its name, profiles, encodings and rate control modes
+func (t *Template) Validate() error {
+	name, err := NormalizeName(t.Name)
+	if err != nil {
+		return err
+	}
+	t.Name = name
+
+	if len(t.Streams) == 0 {
+		return fmt.Errorf("template %s has no streams", t.Name)
+	}
+	if err := validateStreams(t.Streams, true); err != nil {
+		return fmt.Errorf("template %s: %w", t.Name, err)
+	}
+
+	models := make(map[string]bool)
+	for i := range t.Models {
+		variant := &t.Models[i]
+		variant.Model = strings.TrimSpace(variant.Model)
+		if variant.Model == "" {
+			return fmt.Errorf("template %s: model %d has no name", t.Name, i+1)
+		}
+		key := strings.ToLower(variant.Model)
+		if models[key] {
+			return fmt.Errorf("template %s: model %s is listed more than once", t.Name, variant.Model)
+		}
+		models[key] = true
+
+		if len(variant.Streams) == 0 {
+			return fmt.Errorf("template %s, model %s: no streams", t.Name, variant.Model)
+		}
+		if err := validateStreams(variant.Streams, false); err != nil {
+			return fmt.Errorf("template %s, model %s: %w", t.Name, variant.Model, err)
+		}
+		// Streams the template does not configure must be complete
+		for _, stream := range variant.Streams {
+			if t.stream(stream.Profile) == nil && (stream.Width <= 0 || stream.Height <= 0 || stream.FPS <= 0) {
+				return fmt.Errorf("template %s, model %s: profile %s is not in the template, so width, height and fps are required",
+					t.Name, variant.Model, stream.Profile)
+			}
+		}
+	}
+	return nil
+}
+
+// validateStreams checks a list of streams; complete streams need width, height and frame rate
+func validateStreams(streams []Stream, complete bool) error {
+	profiles := make(map[string]bool)
+	for i := range streams {
+		stream := &streams[i]
+		stream.Profile = strings.TrimSpace(stream.Profile)
+		if stream.Profile == "" {
+			stream.Profile = camera.ProfileRoleMain
+		}
+		key := strings.ToLower(stream.Profile)
+		if profiles[key] {
+			return fmt.Errorf("profile %s is listed more than once", stream.Profile)
+		}
+		profiles[key] = true
+
+		if complete && (stream.Width <= 0 || stream.Height <= 0) {
+			return fmt.Errorf("profile %s: width and height are required", stream.Profile)
+		}
+		if complete && stream.FPS <= 0 {
+			return fmt.Errorf("profile %s: fps is required", stream.Profile)
+		}
+		if (stream.Width > 0) != (stream.Height > 0) {
+			return fmt.Errorf("profile %s: width and height must be given together", stream.Profile)
+		}
+		if stream.Width < 0 || stream.Height < 0 || stream.FPS < 0 || stream.Bitrate < 0 ||
+			stream.GOP < 0 || stream.Quality < 0 || stream.EncodingInterval < 0 {
+			return fmt.Errorf("profile %s: settings must not be negative", stream.Profile)
+		}
+		if stream.Encoding != "" {
+			stream.Encoding = camera.NormalizeEncoding(stream.Encoding)
+		}
+		stream.EncoderProfile = camera.NormalizeEncoderProfile(stream.EncoderProfile)
+		if stream.RateControl != "" {
+			stream.RateControl = camera.NormalizeRateControl(stream.RateControl)
+			if stream.RateControl != camera.RateControlCBR && stream.RateControl != camera.RateControlVBR {
+				return fmt.Errorf("profile %s: invalid rate control %q (use %s or %s)",
+					stream.Profile, stream.RateControl, camera.RateControlCBR, camera.RateControlVBR)
+			}
+		}
+	}
+	return nil
+}
+
+// stream returns the template stream of a profile, or nil
+func (t *Template) stream(profile string) *Stream {
+	for i := range t.Streams {
+		if strings.EqualFold(t.Streams[i].Profile, profile) {
+			return &t.Streams[i]
+		}
+	}
+	return nil
+}
+
+// Variant returns the variant for a camera model, or nil when the template has none.
+// An exact match wins over a wildcard; among wildcards the longest one wins.
+func (t *Template) Variant(model string) *Variant {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if model == "" {
+		return nil
+	}
+
+	var best *Variant
+	bestLength := -1
+	for i := range t.Models {
+		pattern := strings.ToLower(t.Models[i].Model)
+		if pattern == model {
+			return &t.Models[i]
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(model, prefix) && len(prefix) > bestLength {
+			best = &t.Models[i]
+			bestLength = len(prefix)
+		}
+	}
+	return best
+}
+
+// Resolve returns the streams to configure on a camera of the given model and the model
+// pattern of the variant that was used, empty when the template's streams apply unchanged
+func (t *Template) Resolve(model string) ([]Stream, string) {
+	streams := append([]Stream{}, t.Streams...)
+	variant := t.Variant(model)
+	if variant == nil {
+		return streams, ""
+	}
+
+	for _, override := range variant.Streams {
+		index := -1
+		for i := range streams {
+			if strings.EqualFold(streams[i].Profile, override.Profile) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			streams = append(streams, override)
+			continue
+		}
+		streams[index] = streams[index].merge(override)
+	}
+	return streams, variant.Model
+}
+
+// merge returns the stream with the settings that are set in override replaced
+func (s Stream) merge(override Stream) Stream {
+	if override.Width > 0 {
+		s.Width = override.Width
+		s.Height = override.Height
+	}
+	if override.FPS > 0 {
+		s.FPS = override.FPS
+	}
+	if override.Bitrate > 0 {
+		s.Bitrate = override.Bitrate
+	}
+	if override.Encoding != "" {
+		s.Encoding = override.Encoding
+	}
+	if override.GOP > 0 {
+		s.GOP = override.GOP
+	}
+	if override.EncoderProfile != "" {
+		s.EncoderProfile = override.EncoderProfile
+	}
+	if override.RateControl != "" {
+		s.RateControl = override.RateControl
+	}
+	if override.Quality > 0 {
+		s.Quality = override.Quality
+	}
+	if override.EncodingInterval > 0 {
+		s.EncodingInterval = override.EncodingInterval
+	}
+	return s
+}
+
+// CameraStreams is a template resolved for one camera
+type CameraStreams struct {
+	CameraID string   `json:"cameraId"`
+	Model    string   `json:"model,omitempty"`   // Model reported by the camera
+	Variant  string   `json:"variant,omitempty"` // Model pattern of the variant used; empty for the template's streams
+	Streams  []Stream `json:"streams,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// ResolveForCameras reads the model of every camera from its device service and resolves
+// the template for it, through the bounded worker pool. Results are in the order of cameraIDs.
+func ResolveForCameras(ctx context.Context, t *Template, cameraIDs []string, opts pool.Options) []CameraStreams {
+	log.Printf("Resolving template %s for %d cameras", t.Name, len(cameraIDs))
+
+	return pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) CameraStreams {
+			result := CameraStreams{CameraID: cameraID}
+
+			unlock := camera.LockCamera(cameraID)
+			defer unlock()
+
+			client, err := camera.GetCameraClient(cameraID)
+			if err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			deviceInfo, err := client.GetDeviceInformation()
+			if err != nil {
+				result.Error = fmt.Sprintf("failed to read the camera model: %v", err)
+				return result
+			}
+
+			result.Model = deviceInfo.Model
+			result.Streams, result.Variant = t.Resolve(deviceInfo.Model)
+			log.Printf("Template %s for camera %s (model %q): variant %q", t.Name, cameraID, result.Model, result.Variant)
+			return result
+		},
+		func(cameraID string, err error) CameraStreams {
+			return CameraStreams{CameraID: cameraID, Error: fmt.Sprintf("Template resolution aborted: %v", err)}
+		})
+}
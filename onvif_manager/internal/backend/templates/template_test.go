@@ -0,0 +1,167 @@
+package templates
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVariant(t *testing.T) {
+	template := &Template{Models: []Variant{
+		{Model: "IPC-HDW*"},
+		{Model: "IPC-HDW2431T"},
+		{Model: "IPC-HDW24*"},
+		{Model: "DS-2CD2143G2-I"},
+	}}
+
+	tests := []struct {
+		model string
+		want  string // Model of the variant, empty for none
+	}{
+		{model: "IPC-HDW2431T", want: "IPC-HDW2431T"},
+		{model: "ipc-hdw2431t", want: "IPC-HDW2431T"},
+		{model: " IPC-HDW2431T ", want: "IPC-HDW2431T"},
+		{model: "IPC-HDW2431TM", want: "IPC-HDW24*"},
+		{model: "IPC-HDW5231R", want: "IPC-HDW*"},
+		{model: "IPC-HDW", want: "IPC-HDW*"},
+		{model: "IPC-HFW2431S", want: ""},
+		{model: "DS-2CD2143G2-IS", want: ""},
+		{model: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if variant := template.Variant(tt.model); variant != nil {
+			got = variant.Model
+		}
+		if got != tt.want {
+			t.Errorf("Variant(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	template := &Template{
+		Name: "lobby",
+		Streams: []Stream{
+			{Profile: "main", Width: 1920, Height: 1080, FPS: 25, Bitrate: 4096, Encoding: "H264"},
+			{Profile: "sub", Width: 640, Height: 360, FPS: 15},
+		},
+		Models: []Variant{
+			{Model: "IPC-HDW2431T", Streams: []Stream{
+				{Profile: "MAIN", Width: 2688, Height: 1520, Encoding: "H265"},
+				{Profile: "third", Width: 1280, Height: 720, FPS: 10},
+			}},
+			{Model: "DS-2CD*", Streams: []Stream{{Profile: "sub", FPS: 10, RateControl: "VBR"}}},
+		},
+	}
+
+	tests := []struct {
+		model       string
+		want        []Stream
+		wantVariant string
+	}{
+		{
+			model: "IPC-HFW2431S",
+			want:  template.Streams,
+		},
+		{
+			model: "IPC-HDW2431T",
+			want: []Stream{
+				{Profile: "main", Width: 2688, Height: 1520, FPS: 25, Bitrate: 4096, Encoding: "H265"},
+				{Profile: "sub", Width: 640, Height: 360, FPS: 15},
+				{Profile: "third", Width: 1280, Height: 720, FPS: 10},
+			},
+			wantVariant: "IPC-HDW2431T",
+		},
+		{
+			model: "DS-2CD2143G2-I",
+			want: []Stream{
+				{Profile: "main", Width: 1920, Height: 1080, FPS: 25, Bitrate: 4096, Encoding: "H264"},
+				{Profile: "sub", Width: 640, Height: 360, FPS: 10, RateControl: "VBR"},
+			},
+			wantVariant: "DS-2CD*",
+		},
+	}
+
+	for _, tt := range tests {
+		got, variant := template.Resolve(tt.model)
+		if !reflect.DeepEqual(got, tt.want) || variant != tt.wantVariant {
+			t.Errorf("Resolve(%q) = %+v, %q, want %+v, %q", tt.model, got, variant, tt.want, tt.wantVariant)
+		}
+	}
+
+	// Resolving must not change the template
+	if template.Streams[0].Width != 1920 || template.Streams[1].FPS != 15 {
+		t.Errorf("Resolve() changed the template streams to %+v", template.Streams)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template Template
+		wantErr  bool
+	}{
+		{
+			name:     "complete",
+			template: Template{Name: "Lobby", Streams: []Stream{{Width: 1920, Height: 1080, FPS: 25}}},
+		},
+		{
+			name:     "invalid name",
+			template: Template{Name: "lobby cams", Streams: []Stream{{Width: 1920, Height: 1080, FPS: 25}}},
+			wantErr:  true,
+		},
+		{
+			name:     "no streams",
+			template: Template{Name: "lobby"},
+			wantErr:  true,
+		},
+		{
+			name:     "stream without fps",
+			template: Template{Name: "lobby", Streams: []Stream{{Width: 1920, Height: 1080}}},
+			wantErr:  true,
+		},
+		{
+			name:     "main stream twice",
+			template: Template{Name: "lobby", Streams: []Stream{{Width: 1920, Height: 1080, FPS: 25}, {Profile: "Main", Width: 1280, Height: 720, FPS: 25}}},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid rate control",
+			template: Template{Name: "lobby", Streams: []Stream{{Width: 1920, Height: 1080, FPS: 25, RateControl: "ABR"}}},
+			wantErr:  true,
+		},
+		{
+			name: "partial variant stream",
+			template: Template{Name: "lobby", Streams: []Stream{{Width: 1920, Height: 1080, FPS: 25}},
+				Models: []Variant{{Model: "IPC-*", Streams: []Stream{{Bitrate: 2048}}}}},
+		},
+		{
+			name: "partial variant stream for another profile",
+			template: Template{Name: "lobby", Streams: []Stream{{Width: 1920, Height: 1080, FPS: 25}},
+				Models: []Variant{{Model: "IPC-*", Streams: []Stream{{Profile: "sub", Bitrate: 512}}}}},
+			wantErr: true,
+		},
+		{
+			name: "model listed twice",
+			template: Template{Name: "lobby", Streams: []Stream{{Width: 1920, Height: 1080, FPS: 25}},
+				Models: []Variant{{Model: "IPC-*", Streams: []Stream{{FPS: 15}}}, {Model: "ipc-*", Streams: []Stream{{FPS: 10}}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		if err := tt.template.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	template := Template{Name: " Lobby ", Streams: []Stream{{Width: 1920, Height: 1080, FPS: 25, Encoding: "h265", RateControl: "cbr"}}}
+	if err := template.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := Stream{Profile: "main", Width: 1920, Height: 1080, FPS: 25, Encoding: "H265", RateControl: "CBR"}
+	if template.Name != "lobby" || template.Streams[0] != want {
+		t.Errorf("Validate() normalized the template to %q %+v, want %q %+v", template.Name, template.Streams[0], "lobby", want)
+	}
+}
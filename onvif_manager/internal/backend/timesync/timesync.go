@@ -0,0 +1,192 @@
+package timesync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+)
+
+// Modes of a time sync run
+const (
+	// ModeReport only reads the date, time and NTP settings of the cameras
+	ModeReport = "report"
+	// ModeNTP points the cameras at the given NTP servers
+	ModeNTP = "ntp"
+	// ModeManual sets the clock of drifted cameras to the local time
+	ModeManual = "manual"
+)
+
+// DefaultDriftTolerance is the clock difference up to which a camera is considered in sync
+const DefaultDriftTolerance = 2 * time.Second
+
+// Request describes what a time sync run does
+type Request struct {
+	Mode       string   `json:"mode"`
+	NTPServers []string `json:"ntpServers,omitempty"`
+	// DriftTolerance is the drift up to which a camera counts as in sync and,
+	// in manual mode, is left alone. DefaultDriftTolerance when zero.
+	DriftTolerance time.Duration `json:"-"`
+}
+
+// Validate checks that the request can be run
+func (r *Request) Validate() error {
+	if r.Mode == "" {
+		r.Mode = ModeReport
+	}
+	if r.DriftTolerance <= 0 {
+		r.DriftTolerance = DefaultDriftTolerance
+	}
+
+	switch r.Mode {
+	case ModeReport, ModeManual:
+		return nil
+	case ModeNTP:
+		if len(r.NTPServers) == 0 {
+			return fmt.Errorf("at least one NTP server is required in %s mode", ModeNTP)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown time sync mode %q (use %s, %s or %s)", r.Mode, ModeReport, ModeNTP, ModeManual)
+	}
+}
+
+// Results represents the overall results of a time sync run
+type Results struct {
+	Mode          string                   `json:"mode"`
+	CameraOrder   []string                 `json:"cameraOrder"` // Camera IDs in the order they were requested
+	CameraResults map[string]*CameraResult `json:"cameraResults"`
+	Summary       Summary                  `json:"summary"`
+}
+
+// CameraResult represents the time sync result of a single camera
+type CameraResult struct {
+	CameraID string             `json:"cameraId"`
+	Success  bool               `json:"success"`
+	Error    string             `json:"error,omitempty"`
+	Action   string             `json:"action"` // none, ntp or manual
+	Before   *camera.TimeStatus `json:"before,omitempty"`
+	After    *camera.TimeStatus `json:"after,omitempty"`
+}
+
+// Summary represents a summary of a time sync run
+type Summary struct {
+	TotalCameras    int     `json:"totalCameras"`
+	SuccessfulCams  int     `json:"successfulCams"`
+	FailedCams      int     `json:"failedCams"`
+	DriftedCams     int     `json:"driftedCams"` // Cameras beyond the drift tolerance before the run
+	UpdatedCams     int     `json:"updatedCams"` // Cameras whose time settings were changed
+	MaxDriftSeconds float64 `json:"maxDriftSeconds"`
+}
+
+// Run reads the clocks of the cameras and applies the requested change through
+// the bounded worker pool. The request must have been validated.
+func Run(ctx context.Context, cameraIDs []string, request Request, opts pool.Options) *Results {
+	log.Printf("Running time sync (%s) on %d cameras (concurrency %d, timeout %s)", request.Mode, len(cameraIDs), opts.Concurrency, opts.Timeout)
+
+	results := &Results{
+		Mode:          request.Mode,
+		CameraOrder:   cameraIDs,
+		CameraResults: make(map[string]*CameraResult),
+	}
+
+	synced := pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) *CameraResult {
+			return syncCamera(ctx, cameraID, request)
+		},
+		func(cameraID string, err error) *CameraResult {
+			return &CameraResult{CameraID: cameraID, Action: "none", Error: fmt.Sprintf("Time sync aborted: %v", err)}
+		})
+
+	results.Summary.TotalCameras = len(cameraIDs)
+	for i, cameraID := range cameraIDs {
+		result := synced[i]
+		results.CameraResults[cameraID] = result
+
+		if result.Success {
+			results.Summary.SuccessfulCams++
+		} else {
+			results.Summary.FailedCams++
+		}
+		if result.Action != "none" && result.Success {
+			results.Summary.UpdatedCams++
+		}
+		if result.Before != nil {
+			drift := math.Abs(result.Before.DriftSeconds)
+			if drift > request.DriftTolerance.Seconds() {
+				results.Summary.DriftedCams++
+			}
+			if drift > math.Abs(results.Summary.MaxDriftSeconds) {
+				results.Summary.MaxDriftSeconds = result.Before.DriftSeconds
+			}
+		}
+	}
+
+	return results
+}
+
+// syncCamera reads and, depending on the mode, updates the time settings of one camera.
+// Nothing is written once ctx is done.
+func syncCamera(ctx context.Context, cameraID string, request Request) *CameraResult {
+	result := &CameraResult{CameraID: cameraID, Action: "none"}
+
+	unlock := camera.LockCamera(cameraID)
+	defer unlock()
+
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	before, err := client.GetTimeStatus()
+	if err != nil {
+		log.Printf("Failed to read time of camera %s: %v", cameraID, err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Before = before
+
+	switch {
+	case request.Mode == ModeReport:
+		result.Success = true
+		return result
+	case request.Mode == ModeManual && before.Drift().Abs() <= request.DriftTolerance:
+		// Leave cameras that are already in sync alone
+		result.Success = true
+		return result
+	}
+	if err := pool.Abandoned(ctx); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Action = request.Mode
+	if request.Mode == ModeNTP {
+		err = client.SetNTPServers(request.NTPServers)
+	} else {
+		err = client.SetSystemTime(time.Now())
+	}
+
+	if err != nil {
+		log.Printf("Failed to update time of camera %s: %v", cameraID, err)
+		result.Error = err.Error()
+		return result
+	}
+
+	// Read the settings back so the result shows what the camera actually uses now
+	after, err := client.GetTimeStatus()
+	if err != nil {
+		result.Error = fmt.Sprintf("time updated but could not be read back: %v", err)
+		return result
+	}
+	result.After = after
+	result.Success = true
+	log.Printf("Updated time of camera %s (%s): drift %.1fs -> %.1fs", cameraID, result.Action, before.DriftSeconds, after.DriftSeconds)
+
+	return result
+}
@@ -0,0 +1,63 @@
+package timesync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+)
+
+func TestRequestValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		request       Request
+		wantErr       bool
+		wantMode      string
+		wantTolerance time.Duration
+	}{
+		{name: "defaults", request: Request{}, wantMode: ModeReport, wantTolerance: DefaultDriftTolerance},
+		{name: "manual with tolerance", request: Request{Mode: ModeManual, DriftTolerance: 5 * time.Second}, wantMode: ModeManual, wantTolerance: 5 * time.Second},
+		{name: "ntp", request: Request{Mode: ModeNTP, NTPServers: []string{"pool.ntp.org"}}, wantMode: ModeNTP, wantTolerance: DefaultDriftTolerance},
+		{name: "ntp without servers", request: Request{Mode: ModeNTP}, wantErr: true},
+		{name: "unknown mode", request: Request{Mode: "ptp"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := tt.request.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (tt.request.Mode != tt.wantMode || tt.request.DriftTolerance != tt.wantTolerance) {
+			t.Errorf("%s: Validate() left mode %q, tolerance %s, want %q, %s", tt.name, tt.request.Mode, tt.request.DriftTolerance, tt.wantMode, tt.wantTolerance)
+		}
+	}
+}
+
+func TestRunUnknownCameras(t *testing.T) {
+	if err := camera.UseStore(&camera.MemoryStore{}); err != nil {
+		t.Fatalf("UseStore() error = %v", err)
+	}
+
+	request := Request{Mode: ModeManual}
+	if err := request.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	results := Run(context.Background(), []string{"2", "1"}, request, pool.Options{Concurrency: 2, Timeout: time.Second})
+
+	if results.Mode != ModeManual || len(results.CameraOrder) != 2 || results.CameraOrder[0] != "2" {
+		t.Errorf("Run() = mode %q, order %v, want manual in the requested order", results.Mode, results.CameraOrder)
+	}
+	want := Summary{TotalCameras: 2, FailedCams: 2}
+	if results.Summary != want {
+		t.Errorf("Run() summary = %+v, want %+v", results.Summary, want)
+	}
+	for _, cameraID := range []string{"1", "2"} {
+		result := results.CameraResults[cameraID]
+		if result == nil || result.Success || result.Error == "" || result.Action != "none" || result.Before != nil {
+			t.Errorf("result of unknown camera %s = %+v, want a failure without action", cameraID, result)
+		}
+	}
+}
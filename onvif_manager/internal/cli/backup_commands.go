@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/backup"
+	"onvif_manager/internal/backend/pool"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags of the backup and restore commands
+var (
+	backupDir         string
+	backupConcurrency int
+	backupTimeout     time.Duration
+	restoreForce      bool
+	restoreYes        bool
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup [camera-id...]",
+	Short: "Save the complete configuration of cameras to backup files",
+	Long: `Read the profiles, video source, video encoder, audio encoder, imaging, network and
+time settings of the given cameras, or of every camera in the inventory when no IDs are
+given, and write each camera's settings to a versioned JSON file in the backup directory.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBackup(args)
+	},
+}
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [camera-id] [backup-file]",
+	Short: "Write a backup file back to a camera",
+	Long: `Write the settings of a backup file to a camera, which may be the camera the backup was
+taken from or a replacement of the same manufacturer and model. Network addressing is not
+restored so that the camera stays reachable.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRestore(args[0], args[1])
+	},
+}
+
+func init() {
+	backupCmd.Flags().StringVar(&backupDir, "dir", "backups", "directory the backup files are written to")
+	backupCmd.Flags().IntVar(&backupConcurrency, "concurrency", 0, fmt.Sprintf("number of cameras worked on in parallel (default %d, env %s)", pool.DefaultConcurrency, pool.ConcurrencyEnvVar))
+	backupCmd.Flags().DurationVar(&backupTimeout, "timeout", 0, fmt.Sprintf("maximum time per camera (default %s, env %s)", pool.DefaultTimeout, pool.TimeoutEnvVar))
+
+	cameraSelection.register(backupCmd)
+
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "restore even if the camera is a different manufacturer or model")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "do not ask for confirmation")
+
+	RootCmd.AddCommand(backupCmd)
+	RootCmd.AddCommand(restoreCmd)
+}
+
+// runBackup writes backup files for the selected cameras
+func runBackup(cameraIDs []string) error {
+	cameraIDs, err := inventoryCameraIDs(cameraIDs)
+	if err != nil {
+		return err
+	}
+	if len(cameraIDs) == 0 {
+		fmt.Println("No cameras found in the inventory.")
+		return nil
+	}
+
+	fmt.Printf("💾 Backing up %d camera(s) to %s...\n", len(cameraIDs), backupDir)
+
+	opts := pool.DefaultOptions().WithOverrides(backupConcurrency, backupTimeout)
+	results := backup.Run(context.Background(), cameraIDs, backupDir, opts)
+
+	fmt.Println()
+	for _, cameraID := range results.CameraOrder {
+		result := results.CameraResults[cameraID]
+		if !result.Success {
+			fmt.Printf("❌ Camera %s: %s\n", cameraID, result.Error)
+			continue
+		}
+		fmt.Printf("✅ Camera %s: %s\n", cameraID, result.File)
+		for _, unavailable := range result.Unavailable {
+			fmt.Printf("   ⚠️  Not backed up: %s\n", unavailable)
+		}
+	}
+
+	summary := results.Summary
+	fmt.Printf("\n📊 Summary:\n")
+	fmt.Printf("   • Cameras: %d\n", summary.TotalCameras)
+	fmt.Printf("   • Backed up: %d\n", summary.SuccessfulCams)
+	fmt.Printf("   • With unavailable settings: %d\n", summary.PartialCams)
+	fmt.Printf("   • Failed: %d\n", summary.FailedCams)
+
+	if summary.FailedCams > 0 {
+		return fmt.Errorf("%d camera(s) could not be backed up", summary.FailedCams)
+	}
+	return nil
+}
+
+// runRestore writes a backup file to a camera
+func runRestore(cameraID, path string) error {
+	archive, err := backup.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	model := "unknown model"
+	if archive.DeviceInfo != nil {
+		model = strings.TrimSpace(archive.DeviceInfo.Manufacturer + " " + archive.DeviceInfo.Model)
+	}
+	fmt.Printf("📦 Backup of camera %s (%s), taken %s\n", archive.CameraID, model, archive.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+
+	if !restoreYes && !askForConfirmation(fmt.Sprintf("Overwrite the configuration of camera %s with this backup?", cameraID)) {
+		fmt.Println("Restore cancelled.")
+		return nil
+	}
+
+	result, err := backup.Restore(cameraID, archive, restoreForce)
+	if err != nil {
+		return fmt.Errorf("failed to restore camera %s: %w", cameraID, err)
+	}
+
+	for _, step := range result.Steps {
+		name := step.Section
+		if step.Token != "" {
+			name += " " + step.Token
+		}
+		if step.Success {
+			fmt.Printf("✅ %s\n", name)
+		} else {
+			fmt.Printf("❌ %s: %s\n", name, step.Error)
+		}
+	}
+
+	if !result.Success {
+		return fmt.Errorf("%s", result.Error)
+	}
+	fmt.Printf("\n✅ Camera %s restored\n", cameraID)
+	return nil
+}
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/pkg/models"
+
+	"github.com/spf13/cobra"
+)
+
+// cameraCmd groups the commands that inspect cameras in the inventory
+var cameraCmd = &cobra.Command{
+	Use:   "camera",
+	Short: "Inspect cameras in the inventory",
+	Long:  `Query information from the cameras saved in the camera inventory.`,
+}
+
+// cameraInfoCmd represents the camera info command
+var cameraInfoCmd = &cobra.Command{
+	Use:   "info [camera-id...]",
+	Short: "Show manufacturer, model, firmware and serial number of cameras",
+	Long:  `Query the device information of the given cameras, or of every camera in the inventory when no IDs are given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCameraInfo(args)
+	},
+}
+
+// cameraProfilesCmd represents the camera profiles command
+var cameraProfilesCmd = &cobra.Command{
+	Use:   "profiles [camera-id...]",
+	Short: "List the media profiles of cameras with their encoder settings",
+	Long: `List the media profiles of the given cameras, or of every camera in the inventory when no IDs
+are given, with the main/sub role and the current video encoder settings of each profile.
+The token, name or role can be used in the profile column of a config CSV.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCameraProfiles(args)
+	},
+}
+
+// cameraRebootCmd represents the camera reboot command
+var cameraRebootCmd = &cobra.Command{
+	Use:   "reboot [camera-id...]",
+	Short: "Reboot cameras",
+	Long:  `Reboot the given cameras, or the cameras selected with --group, --tag or --selector.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCameraReboot(args)
+	},
+}
+
+// Flags of the camera reboot command
+var (
+	cameraRebootYes         bool
+	cameraRebootConcurrency int
+	cameraRebootTimeout     time.Duration
+)
+
+func init() {
+	cameraRebootCmd.Flags().BoolVarP(&cameraRebootYes, "yes", "y", false, "do not ask for confirmation")
+	cameraRebootCmd.Flags().IntVar(&cameraRebootConcurrency, "concurrency", 0, fmt.Sprintf("number of cameras worked on in parallel (default %d, env %s)", pool.DefaultConcurrency, pool.ConcurrencyEnvVar))
+	cameraRebootCmd.Flags().DurationVar(&cameraRebootTimeout, "timeout", 0, fmt.Sprintf("maximum time per camera (default %s, env %s)", pool.DefaultTimeout, pool.TimeoutEnvVar))
+	for _, cmd := range []*cobra.Command{cameraInfoCmd, cameraProfilesCmd, cameraRebootCmd} {
+		cameraSelection.register(cmd)
+	}
+
+	cameraCmd.AddCommand(cameraInfoCmd)
+	cameraCmd.AddCommand(cameraProfilesCmd)
+	cameraCmd.AddCommand(cameraRebootCmd)
+	RootCmd.AddCommand(cameraCmd)
+}
+
+// cameraInfoResult is the device information of one camera or the error that prevented reading it
+type cameraInfoResult struct {
+	camera     models.Camera
+	deviceInfo *models.DeviceInfo
+	err        error
+}
+
+// cameraProfilesResult is the profile list of one camera or the error that prevented reading it
+type cameraProfilesResult struct {
+	camera   models.Camera
+	profiles []camera.Profile
+	err      error
+}
+
+// runCameraInfo prints the device information of the selected cameras
+func runCameraInfo(cameraIDs []string) error {
+	cameraIDs, err := inventoryCameraIDs(cameraIDs)
+	if err != nil {
+		return err
+	}
+	if len(cameraIDs) == 0 {
+		fmt.Println("No cameras found in the inventory.")
+		return nil
+	}
+
+	fmt.Printf("🔍 Querying device information from %d camera(s)...\n", len(cameraIDs))
+
+	results := pool.Run(context.Background(), cameraIDs, pool.DefaultOptions(),
+		func(ctx context.Context, cameraID string) cameraInfoResult {
+			unlock := camera.LockCamera(cameraID)
+			defer unlock()
+
+			client, err := camera.GetCameraClient(cameraID)
+			if err != nil {
+				return cameraInfoResult{camera: models.Camera{ID: cameraID}, err: err}
+			}
+
+			deviceInfo, err := client.GetDeviceInformation()
+			return cameraInfoResult{camera: client.Camera, deviceInfo: deviceInfo, err: err}
+		},
+		func(cameraID string, err error) cameraInfoResult {
+			return cameraInfoResult{camera: models.Camera{ID: cameraID}, err: err}
+		})
+
+	fmt.Printf("\n%-6s %-15s %-15s %-20s %-20s %-20s %s\n", "ID", "IP", "Manufacturer", "Model", "Firmware", "Serial", "Hardware ID")
+	fmt.Println(strings.Repeat("-", 110))
+
+	var failed []cameraInfoResult
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result)
+			continue
+		}
+		info := result.deviceInfo
+		fmt.Printf("%-6s %-15s %-15s %-20s %-20s %-20s %s\n",
+			result.camera.ID, result.camera.IP, info.Manufacturer, info.Model, info.FirmwareVersion, info.SerialNumber, info.HardwareID)
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("\n⚠️  Could not read device information:\n")
+		for _, result := range failed {
+			fmt.Printf("   • Camera %s: %v\n", result.camera.ID, result.err)
+		}
+	}
+
+	return nil
+}
+
+// runCameraProfiles prints the media profiles of the selected cameras
+func runCameraProfiles(cameraIDs []string) error {
+	cameraIDs, err := inventoryCameraIDs(cameraIDs)
+	if err != nil {
+		return err
+	}
+	if len(cameraIDs) == 0 {
+		fmt.Println("No cameras found in the inventory.")
+		return nil
+	}
+
+	fmt.Printf("🔍 Querying media profiles from %d camera(s)...\n", len(cameraIDs))
+
+	results := pool.Run(context.Background(), cameraIDs, pool.DefaultOptions(),
+		func(ctx context.Context, cameraID string) cameraProfilesResult {
+			unlock := camera.LockCamera(cameraID)
+			defer unlock()
+
+			client, err := camera.GetCameraClient(cameraID)
+			if err != nil {
+				return cameraProfilesResult{camera: models.Camera{ID: cameraID}, err: err}
+			}
+
+			profiles, err := camera.GetProfiles(client)
+			return cameraProfilesResult{camera: client.Camera, profiles: profiles, err: err}
+		},
+		func(cameraID string, err error) cameraProfilesResult {
+			return cameraProfilesResult{camera: models.Camera{ID: cameraID}, err: err}
+		})
+
+	fmt.Printf("\n%-6s %-15s %-6s %-20s %-20s %-10s %-6s %-8s %s\n", "ID", "IP", "Role", "Profile", "Token", "Resolution", "FPS", "Bitrate", "Encoding")
+	fmt.Println(strings.Repeat("-", 110))
+
+	var failed []cameraProfilesResult
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result)
+			continue
+		}
+		for _, profile := range result.profiles {
+			resolution, fps, bitrate, encoding := "-", "-", "-", "no video encoder"
+			if encoder := profile.Encoder; encoder != nil {
+				resolution = fmt.Sprintf("%dx%d", encoder.Resolution.Width, encoder.Resolution.Height)
+				fps = fmt.Sprintf("%d", encoder.FPS)
+				bitrate = fmt.Sprintf("%d", encoder.Bitrate)
+				encoding = encoder.Encoding
+			}
+			fmt.Printf("%-6s %-15s %-6s %-20s %-20s %-10s %-6s %-8s %s\n",
+				result.camera.ID, result.camera.IP, profile.Role, profile.Name, profile.Token, resolution, fps, bitrate, encoding)
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("\n⚠️  Could not read media profiles:\n")
+		for _, result := range failed {
+			fmt.Printf("   • Camera %s: %v\n", result.camera.ID, result.err)
+		}
+	}
+
+	return nil
+}
+
+// runCameraReboot reboots the selected cameras
+func runCameraReboot(cameraIDs []string) error {
+	// Rebooting the whole inventory by accident is costly, so cameras must be named or selected
+	if len(cameraIDs) == 0 && !cameraSelection.isSet() {
+		return fmt.Errorf("give camera IDs or select cameras with --group, --tag or --selector")
+	}
+	cameraIDs, err := inventoryCameraIDs(cameraIDs)
+	if err != nil {
+		return err
+	}
+
+	if !cameraRebootYes && !askForConfirmation(fmt.Sprintf("Reboot %d camera(s): %s?", len(cameraIDs), strings.Join(cameraIDs, ", "))) {
+		fmt.Println("Reboot cancelled.")
+		return nil
+	}
+
+	fmt.Printf("🔄 Rebooting %d camera(s)...\n\n", len(cameraIDs))
+	opts := pool.DefaultOptions().WithOverrides(cameraRebootConcurrency, cameraRebootTimeout)
+	results := camera.RebootCameras(context.Background(), cameraIDs, opts)
+
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+			fmt.Printf("❌ Camera %s: %s\n", result.CameraID, result.Error)
+			continue
+		}
+		if result.Message != "" {
+			fmt.Printf("✅ Camera %s is rebooting: %s\n", result.CameraID, result.Message)
+		} else {
+			fmt.Printf("✅ Camera %s is rebooting\n", result.CameraID)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d camera(s) could not be rebooted", failed)
+	}
+	return nil
+}
+
+// inventoryCameraIDs returns the given camera IDs, the cameras matching the --group, --tag and
+// --selector flags, or every camera in the inventory when neither is given
+func inventoryCameraIDs(cameraIDs []string) ([]string, error) {
+	if !cameraSelection.isSet() {
+		if len(cameraIDs) > 0 {
+			return cameraIDs, nil
+		}
+		return inventoryIDs(), nil
+	}
+
+	if len(cameraIDs) > 0 {
+		return nil, fmt.Errorf("give either camera IDs or --group, --tag and --selector, not both")
+	}
+	selected, err := cameraSelection.cameraIDs()
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no cameras in the inventory match the selection")
+	}
+	return selected, nil
+}
@@ -1,10 +1,10 @@
 package cli
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 	"sort"
 	"strconv"
@@ -13,6 +13,7 @@ import (
 
 	"onvif_manager/internal/backend/camera"
 	"onvif_manager/internal/backend/ffmpeg"
+	"onvif_manager/internal/backend/pool"
 	"onvif_manager/pkg/models"
 )
 
@@ -26,8 +27,8 @@ func NewCameraService() *CameraService {
 
 // EnsureCamerasInitialized ensures that all cameras are initialized and connected
 func (cs *CameraService) EnsureCamerasInitialized() error {
-	// With in-memory camera storage, we don't need to load from files
-	// Camera initialization happens when cameras are added
+	// The inventory is loaded from the configured store at startup
+	// and camera initialization happens when cameras are added
 
 	// We'll keep this method for API compatibility, but it's now a no-op
 	return nil
@@ -35,7 +36,6 @@ func (cs *CameraService) EnsureCamerasInitialized() error {
 
 // GetCameraList returns all cameras in the system
 func (cs *CameraService) GetCameraList() ([]models.Camera, error) {
-	// Use in-memory camera list instead of loading from file
 	return camera.GetAllCameras(), nil
 }
 
@@ -85,8 +85,8 @@ func (cs *CameraService) SelectCamerasFromCSV(csvFilePath string) (*SelectionRes
 	return cs.processCameraSelection(records)
 }
 
-// ImportConfigFromCSV imports configuration from CSV file
-func (cs *CameraService) ImportConfigFromCSV(csvFilePath string) (*ConfigData, error) {
+// ImportConfigFromCSV imports configuration from CSV file, one entry per configured stream
+func (cs *CameraService) ImportConfigFromCSV(csvFilePath string) ([]*ConfigData, error) {
 	file, err := os.Open(csvFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open CSV file: %w", err)
@@ -108,8 +108,8 @@ func (cs *CameraService) ImportConfigFromCSV(csvFilePath string) (*ConfigData, e
 	return cs.processConfigData(records)
 }
 
-// ImportConfigFromCSVAndSave imports configuration from CSV file and saves it as saved config
-func (cs *CameraService) ImportConfigFromCSVAndSave(csvFilePath string) (*ConfigData, error) {
+// ImportConfigFromCSVAndSave imports configuration from CSV file and saves its first row as saved config
+func (cs *CameraService) ImportConfigFromCSVAndSave(csvFilePath string) ([]*ConfigData, error) {
 	configData, err := cs.ImportConfigFromCSV(csvFilePath)
 	if err != nil {
 		return nil, err
@@ -117,7 +117,7 @@ func (cs *CameraService) ImportConfigFromCSVAndSave(csvFilePath string) (*Config
 
 	// Save to saved config
 	configService := NewConfigService()
-	if err := configService.ImportFromConfigData(configData, "csv"); err != nil {
+	if err := configService.ImportFromConfigData(configData[0], "csv"); err != nil {
 		log.Printf("Warning: Failed to save config to saved config file: %v", err)
 		// Don't fail the entire operation if saving fails
 	}
@@ -125,33 +125,127 @@ func (cs *CameraService) ImportConfigFromCSVAndSave(csvFilePath string) (*Config
 	return configData, nil
 }
 
-// ApplyConfigToCameras applies configuration to selected cameras
+// ApplyConfigToCameras applies configuration to selected cameras using the default worker pool settings
 func (cs *CameraService) ApplyConfigToCameras(cameraIDs []string, config *ConfigData) (*ValidationResults, error) {
-	log.Printf("Applying configuration to %d cameras", len(cameraIDs))
+	return cs.ApplyConfigToCamerasWithOptions(cameraIDs, config, pool.DefaultOptions(), camera.RollbackNever)
+}
+
+// ApplyConfigToCamerasWithOptions applies configuration to selected cameras, configuring
+// and then validating them through a bounded worker pool. Cameras whose validation fails
+// get their previous configuration back as the rollback policy decides.
+func (cs *CameraService) ApplyConfigToCamerasWithOptions(cameraIDs []string, config *ConfigData, opts pool.Options, rollbackPolicy string) (*ValidationResults, error) {
+	log.Printf("Applying configuration to %d cameras (concurrency %d, timeout %s, rollback %s)", len(cameraIDs), opts.Concurrency, opts.Timeout, rollbackPolicy)
+	return cs.applyConfigs(cameraIDs, sameConfig(cameraIDs, config), config.Profile, opts, rollbackPolicy), nil
+}
 
+// applyConfigs configures and then validates each camera with its own configuration.
+// Each camera stays locked from its configuration until it has been validated and,
+// if needed, rolled back.
+func (cs *CameraService) applyConfigs(cameraIDs []string, configs map[string]*ConfigData, profile string, opts pool.Options, rollbackPolicy string) *ValidationResults {
 	results := &ValidationResults{
+		Profile:           profile,
+		CameraOrder:       cameraIDs,
 		CameraResults:     make(map[string]*CameraResult),
 		ValidationResults: make(map[string]*ValidationResult),
 	}
+	ctx := context.Background()
+	locks := camera.NewOperationLocks()
+	defer locks.Release()
 
 	// Phase 1: Apply configuration
-	for _, cameraID := range cameraIDs {
-		result := cs.applyCameraConfig(cameraID, config)
-		results.CameraResults[cameraID] = result
+	configured := pool.Run(ctx, cameraIDs, opts,
+		func(ctx context.Context, cameraID string) *CameraResult {
+			if !locks.Lock(cameraID) {
+				return &CameraResult{CameraID: cameraID, Error: fmt.Errorf("operation ended before camera %s was free", cameraID)}
+			}
+			return cs.applyCameraConfig(ctx, cameraID, configs[cameraID], false)
+		},
+		func(cameraID string, err error) *CameraResult {
+			return &CameraResult{CameraID: cameraID, Error: err}
+		})
+	for i, cameraID := range cameraIDs {
+		results.CameraResults[cameraID] = configured[i]
+		if !configured[i].Success {
+			locks.Unlock(cameraID) // Nothing to validate
+		}
 	}
 
 	// Wait for configurations to stabilize
 	time.Sleep(1 * time.Second)
 
 	// Phase 2: Validate configurations
+	var validateIDs []string
 	for _, cameraID := range cameraIDs {
 		if result, exists := results.CameraResults[cameraID]; exists && result.Success {
-			validation := cs.validateCameraStream(result.StreamURL, config)
-			results.ValidationResults[cameraID] = validation
+			validateIDs = append(validateIDs, cameraID)
 		}
 	}
+	validated := pool.Run(ctx, validateIDs, opts,
+		func(ctx context.Context, cameraID string) *ValidationResult {
+			defer locks.Unlock(cameraID)
+			config := configs[cameraID]
+			validation := cs.validateCameraStream(results.CameraResults[cameraID], config)
+			validation.Rollback = cs.rollbackCamera(ctx, results.CameraResults[cameraID], validation, rollbackPolicy)
+			return validation
+		},
+		func(cameraID string, err error) *ValidationResult {
+			config := configs[cameraID]
+			return &ValidationResult{
+				IsValid:             false,
+				Error:               fmt.Sprintf("Validation aborted: %v", err),
+				ExpectedWidth:       config.Width,
+				ExpectedHeight:      config.Height,
+				ExpectedFPS:         config.FPS,
+				ExpectedBitrate:     config.Bitrate,
+				ExpectedEncoding:    config.Encoding,
+				ExpectedGOP:         config.GOP,
+				ExpectedRateControl: config.RateControl,
+			}
+		})
+	for i, cameraID := range validateIDs {
+		results.ValidationResults[cameraID] = validated[i]
+	}
+
+	return results
+}
+
+// PlanConfigForCameras reads the selected cameras and reports per camera what applying
+// the configuration would change, without writing anything. Only CameraResults is filled.
+func (cs *CameraService) PlanConfigForCameras(cameraIDs []string, config *ConfigData, opts pool.Options) *ValidationResults {
+	log.Printf("Planning configuration for %d cameras (concurrency %d, timeout %s)", len(cameraIDs), opts.Concurrency, opts.Timeout)
+	return cs.planConfigs(cameraIDs, sameConfig(cameraIDs, config), config.Profile, opts)
+}
+
+// planConfigs reads each camera and reports what applying its own configuration would change
+func (cs *CameraService) planConfigs(cameraIDs []string, configs map[string]*ConfigData, profile string, opts pool.Options) *ValidationResults {
+	results := &ValidationResults{
+		Profile:           profile,
+		CameraOrder:       cameraIDs,
+		CameraResults:     make(map[string]*CameraResult),
+		ValidationResults: make(map[string]*ValidationResult),
+	}
+	planned := pool.Run(context.Background(), cameraIDs, opts,
+		func(ctx context.Context, cameraID string) *CameraResult {
+			unlock := camera.LockCamera(cameraID)
+			defer unlock()
+			return cs.applyCameraConfig(ctx, cameraID, configs[cameraID], true)
+		},
+		func(cameraID string, err error) *CameraResult {
+			return &CameraResult{CameraID: cameraID, Error: err}
+		})
+	for i, cameraID := range cameraIDs {
+		results.CameraResults[cameraID] = planned[i]
+	}
+	return results
+}
 
-	return results, nil
+// sameConfig maps every camera to the same configuration
+func sameConfig(cameraIDs []string, config *ConfigData) map[string]*ConfigData {
+	configs := make(map[string]*ConfigData, len(cameraIDs))
+	for _, cameraID := range cameraIDs {
+		configs[cameraID] = config
+	}
+	return configs
 }
 
 // ApplyConfigToCamerasFromSaved applies saved configuration to selected cameras
@@ -166,8 +260,9 @@ func (cs *CameraService) ApplyConfigToCamerasFromSaved(cameraIDs []string) (*Val
 	return cs.ApplyConfigToCameras(cameraIDs, configData)
 }
 
-// ExportValidationToCSV exports validation results to CSV file
-func (cs *CameraService) ExportValidationToCSV(validation *ValidationResults, outputPath string) error {
+// ExportValidationToCSV exports validation results to CSV file. Each entry of validations
+// holds the results of one configured stream; cameras get one row per stream.
+func (cs *CameraService) ExportValidationToCSV(validations []*ValidationResults, outputPath string) error {
 	cameras, err := cs.GetCameraList()
 	if err != nil {
 		return fmt.Errorf("failed to load camera list: %w", err)
@@ -189,7 +284,7 @@ func (cs *CameraService) ExportValidationToCSV(validation *ValidationResults, ou
 	defer writer.Flush()
 
 	// Write header with notes column
-	header := []string{"cam_id", "cam_ip", "result", "reso_expected", "reso_actual", "fps_expected", "fps_actual", "encoding_expected", "encoding_actual", "notes"}
+	header := []string{"cam_id", "cam_ip", "result", "reso_expected", "reso_actual", "fps_expected", "fps_actual", "encoding_expected", "encoding_actual", "notes", "manufacturer", "model", "firmware_version", "serial_number", "profile", "gop_expected", "gop_actual", "rate_control_expected", "bitrate_variation", "rollback", "rollback_error"}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
@@ -197,17 +292,19 @@ func (cs *CameraService) ExportValidationToCSV(validation *ValidationResults, ou
 	// Collect all camera IDs for sorting
 	allCamIDs := make(map[string]bool)
 
-	// Add IDs from configuration results
-	for cameraID, configResult := range validation.CameraResults {
-		// Only add failed configurations as successful ones are in validation results
-		if !configResult.Success {
-			allCamIDs[cameraID] = true
+	for _, validation := range validations {
+		// Add IDs from configuration results
+		for cameraID, configResult := range validation.CameraResults {
+			// Only add failed configurations as successful ones are in validation results
+			if !configResult.Success {
+				allCamIDs[cameraID] = true
+			}
 		}
-	}
 
-	// Add IDs from validation results
-	for cameraID := range validation.ValidationResults {
-		allCamIDs[cameraID] = true
+		// Add IDs from validation results
+		for cameraID := range validation.ValidationResults {
+			allCamIDs[cameraID] = true
+		}
 	}
 	// Convert to sorted slice
 	sortedCameraIDs := make([]string, 0, len(allCamIDs))
@@ -229,131 +326,183 @@ func (cs *CameraService) ExportValidationToCSV(validation *ValidationResults, ou
 		return sortedCameraIDs[i] < sortedCameraIDs[j]
 	})
 
-	// Process each camera in sorted order
+	// Process each camera in sorted order, with one row per configured stream
 	for _, cameraID := range sortedCameraIDs {
-		// Check if this camera had a configuration error
-		if configResult, exists := validation.CameraResults[cameraID]; exists && !configResult.Success {
-			cameraIP := "Unknown"
-			if camera, exists := cameraMap[cameraID]; exists {
-				cameraIP = camera.IP
-			}
+		cameraIP := "Unknown"
+		if camera, exists := cameraMap[cameraID]; exists {
+			cameraIP = camera.IP
+		}
 
-			// Error message from configuration stage
-			errorMsg := ""
-			if configResult.Error != nil {
-				errorMsg = configResult.Error.Error()
-			} else {
-				errorMsg = "Configuration failed"
+		for _, validation := range validations {
+			row := validationCSVRow(validation, cameraID, cameraIP)
+			if row == nil {
+				continue
 			}
-
-			// Write row for configuration error
-			row := []string{cameraID, cameraIP, "CONFIG_ERROR", "", "", "", "", "", "", fmt.Sprintf("Configuration Error: %s", errorMsg)}
 			if err := writer.Write(row); err != nil {
 				return fmt.Errorf("failed to write CSV row: %w", err)
 			}
-
-			// Skip to next camera since we've handled this one
-			continue
 		}
+	}
 
-		// Process validation results if available
-		validationResult, exists := validation.ValidationResults[cameraID]
-		if !exists {
-			continue
+	return nil
+}
+
+// validationCSVRow builds the validation CSV row of a camera for one configured stream,
+// or returns nil when the camera has no result for that stream
+func validationCSVRow(validation *ValidationResults, cameraID, cameraIP string) []string {
+	profile := validation.Profile
+	if configResult, exists := validation.CameraResults[cameraID]; exists && configResult.Profile != nil {
+		profile = configResult.Profile.String()
+	}
+
+	// Check if this camera had a configuration error
+	if configResult, exists := validation.CameraResults[cameraID]; exists && !configResult.Success {
+		// Error message from configuration stage
+		errorMsg := ""
+		if configResult.Error != nil {
+			errorMsg = configResult.Error.Error()
+		} else {
+			errorMsg = "Configuration failed"
 		}
-		cameraIP := "Unknown"
-		if camera, exists := cameraMap[cameraID]; exists {
-			cameraIP = camera.IP
+
+		// Row for configuration error
+		row := []string{cameraID, cameraIP, "CONFIG_ERROR", "", "", "", "", "", "", fmt.Sprintf("Configuration Error: %s", errorMsg)}
+		row = append(row, deviceInfoColumns(configResult.DeviceInfo)...)
+		return append(row, profile, "", "", "", "", "", "")
+	}
+
+	// Process validation results if available
+	validationResult, exists := validation.ValidationResults[cameraID]
+	if !exists {
+		return nil
+	}
+	result := "FAIL"
+	notes := ""
+
+	if validationResult.IsValid {
+		// Check each parameter individually like the web version
+
+		// Check resolution match
+		resolutionMatches := true
+		if validationResult.ActualWidth > 0 && validationResult.ActualHeight > 0 {
+			resolutionMatches = (validationResult.ActualWidth == validationResult.ExpectedWidth &&
+				validationResult.ActualHeight == validationResult.ExpectedHeight)
+		} else {
+			resolutionMatches = false
 		}
-		result := "FAIL"
-		notes := ""
 
-		if validationResult.IsValid {
-			// Check each parameter individually like the web version
+		// Check FPS match
+		fpsMatches := true
+		if validationResult.ActualFPS > 0 {
+			fpsMatches = (int(validationResult.ActualFPS+0.5) == validationResult.ExpectedFPS)
+		}
 
-			// Check resolution match
-			resolutionMatches := true
-			if validationResult.ActualWidth > 0 && validationResult.ActualHeight > 0 {
-				resolutionMatches = (validationResult.ActualWidth == validationResult.ExpectedWidth &&
-					validationResult.ActualHeight == validationResult.ExpectedHeight)
-			} else {
-				resolutionMatches = false
+		// Check bitrate match (with 10% tolerance like web version)
+		bitrateMatches := true
+		if validationResult.ExpectedBitrate > 0 && validationResult.ActualBitrate > 0 {
+			tolerance := float64(validationResult.ExpectedBitrate) * 0.1
+			diff := float64(validationResult.ActualBitrate - validationResult.ExpectedBitrate)
+			if diff < 0 {
+				diff = -diff
 			}
+			bitrateMatches = (diff <= tolerance)
+		}
 
-			// Check FPS match
-			fpsMatches := true
-			if validationResult.ActualFPS > 0 {
-				fpsMatches = (int(validationResult.ActualFPS+0.5) == validationResult.ExpectedFPS)
-			}
+		// Check encoding match
+		encodingMatches := true
+		if validationResult.ExpectedEncoding != "" && validationResult.ActualEncoding != "" {
+			encodingMatches = ffmpeg.SameEncoding(validationResult.ActualEncoding, validationResult.ExpectedEncoding)
+		}
 
-			// Check bitrate match (with 10% tolerance like web version)
-			bitrateMatches := true
-			if validationResult.ExpectedBitrate > 0 && validationResult.ActualBitrate > 0 {
-				tolerance := float64(validationResult.ExpectedBitrate) * 0.1
-				diff := float64(validationResult.ActualBitrate - validationResult.ExpectedBitrate)
-				if diff < 0 {
-					diff = -diff
-				}
-				bitrateMatches = (diff <= tolerance)
+		// Check GOP match, only when a GOP was requested
+		gopMatches := ffmpeg.GOPMatches(validationResult.ActualGOP, validationResult.ExpectedGOP)
+
+		// Check the bitrate variation against the rate control mode, only when one was requested
+		rateControlMatches := ffmpeg.RateControlMatches(validationResult.ExpectedRateControl, validationResult.BitrateVariation, validationResult.BitrateSamples)
+
+		// Determine final result and notes based on matches
+		if resolutionMatches && fpsMatches && bitrateMatches && encodingMatches && gopMatches && rateControlMatches {
+			result = "PASS"
+			notes = "All parameters match expected values"
+		} else if resolutionMatches {
+			// Resolution matches but other parameters don't = WARNING
+			result = "WARNING"
+			var notesParts []string
+			if !fpsMatches {
+				notesParts = append(notesParts, "FPS mismatch")
 			}
-
-			// Check encoding match
-			encodingMatches := true
-			if validationResult.ExpectedEncoding != "" && validationResult.ActualEncoding != "" {
-				encodingMatches = strings.EqualFold(validationResult.ActualEncoding, validationResult.ExpectedEncoding)
+			if !bitrateMatches {
+				notesParts = append(notesParts, "Bitrate mismatch")
 			}
-
-			// Determine final result and notes based on matches
-			if resolutionMatches && fpsMatches && bitrateMatches && encodingMatches {
-				result = "PASS"
-				notes = "All parameters match expected values"
-			} else if resolutionMatches {
-				// Resolution matches but other parameters don't = WARNING
-				result = "WARNING"
-				var notesParts []string
-				if !fpsMatches {
-					notesParts = append(notesParts, "FPS mismatch")
-				}
-				if !bitrateMatches {
-					notesParts = append(notesParts, "Bitrate mismatch")
-				}
-				if !encodingMatches {
-					notesParts = append(notesParts, "Encoding mismatch")
-				}
-				notes = strings.Join(notesParts, "; ")
-			} else {
-				// Resolution doesn't match = FAIL
-				result = "FAIL"
-				notes = "Resolution mismatch"
+			if !encodingMatches {
+				notesParts = append(notesParts, "Encoding mismatch")
 			}
-		} else if validationResult.Error != "" {
-			notes = validationResult.Error
+			if !gopMatches {
+				notesParts = append(notesParts, "GOP mismatch")
+			}
+			if !rateControlMatches {
+				notesParts = append(notesParts, "Bitrate variation exceeds rate control")
+			}
+			notes = strings.Join(notesParts, "; ")
 		} else {
-			notes = "Validation failed without detailed error"
+			// Resolution doesn't match = FAIL
+			result = "FAIL"
+			notes = "Resolution mismatch"
 		}
+	} else if validationResult.Error != "" {
+		notes = validationResult.Error
+	} else {
+		notes = "Validation failed without detailed error"
+	}
 
-		resoExpected := fmt.Sprintf("%dx%d", validationResult.ExpectedWidth, validationResult.ExpectedHeight)
-		resoActual := ""
-		if validationResult.ActualWidth > 0 && validationResult.ActualHeight > 0 {
-			resoActual = fmt.Sprintf("%dx%d", validationResult.ActualWidth, validationResult.ActualHeight)
-		}
+	resoExpected := fmt.Sprintf("%dx%d", validationResult.ExpectedWidth, validationResult.ExpectedHeight)
+	resoActual := ""
+	if validationResult.ActualWidth > 0 && validationResult.ActualHeight > 0 {
+		resoActual = fmt.Sprintf("%dx%d", validationResult.ActualWidth, validationResult.ActualHeight)
+	}
 
-		fpsExpected := strconv.Itoa(validationResult.ExpectedFPS)
-		fpsActual := ""
-		if validationResult.ActualFPS > 0 {
-			fpsActual = fmt.Sprintf("%.2f", validationResult.ActualFPS)
-		}
+	fpsExpected := strconv.Itoa(validationResult.ExpectedFPS)
+	fpsActual := ""
+	if validationResult.ActualFPS > 0 {
+		fpsActual = fmt.Sprintf("%.2f", validationResult.ActualFPS)
+	}
 
-		encodingExpected := validationResult.ExpectedEncoding
-		encodingActual := validationResult.ActualEncoding
+	encodingExpected := validationResult.ExpectedEncoding
+	encodingActual := validationResult.ActualEncoding
 
-		row := []string{cameraID, cameraIP, result, resoExpected, resoActual, fpsExpected, fpsActual, encodingExpected, encodingActual, notes}
-		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("failed to write CSV row: %w", err)
-		}
+	gopExpected, gopActual := "", ""
+	if validationResult.ExpectedGOP > 0 {
+		gopExpected = strconv.Itoa(validationResult.ExpectedGOP)
+	}
+	if validationResult.ActualGOP > 0 {
+		gopActual = strconv.Itoa(validationResult.ActualGOP)
+	}
+	bitrateVariation := ""
+	if validationResult.BitrateSamples >= 2 {
+		bitrateVariation = fmt.Sprintf("%.1f", validationResult.BitrateVariation)
+	}
+	rollbackStatus, rollbackError := "", ""
+	if rollback := validationResult.Rollback; rollback != nil {
+		rollbackStatus = camera.RollbackStatus(rollback.Restored, rollback.Valid)
+		rollbackError = rollback.Error
 	}
 
-	return nil
+	row := []string{cameraID, cameraIP, result, resoExpected, resoActual, fpsExpected, fpsActual, encodingExpected, encodingActual, notes}
+	if configResult, exists := validation.CameraResults[cameraID]; exists {
+		row = append(row, deviceInfoColumns(configResult.DeviceInfo)...)
+	} else {
+		row = append(row, deviceInfoColumns(nil)...)
+	}
+	return append(row, profile, gopExpected, gopActual, validationResult.ExpectedRateControl, bitrateVariation, rollbackStatus, rollbackError)
+}
+
+// deviceInfoColumns returns the manufacturer, model, firmware version and serial number
+// columns of the validation CSV, left empty when the camera did not report them
+func deviceInfoColumns(deviceInfo *models.DeviceInfo) []string {
+	if deviceInfo == nil {
+		return []string{"", "", "", ""}
+	}
+	return []string{deviceInfo.Manufacturer, deviceInfo.Model, deviceInfo.FirmwareVersion, deviceInfo.SerialNumber}
 }
 
 // Helper methods for processing data
@@ -441,8 +590,22 @@ func (cs *CameraService) processCameraRecords(records [][]string) (*ImportResult
 			cameraData.Password = strings.TrimSpace(record[passwordIndex])
 		}
 
+		// Cameras persist in the inventory between runs, so reuse a camera that was imported before
+		if existing, found := camera.FindCameraByIP(cameraData.IP); found {
+			existingCamera := existing
+			results = append(results, ImportRowResult{
+				Row:      rowNum,
+				Success:  true,
+				Existing: true,
+				CameraID: existing.ID,
+				Camera:   &existingCamera,
+			})
+			successCount++
+			continue
+		}
+
 		// Attempt to add the camera
-		newID, err := camera.AddNewCamera(cameraData.IP, cameraData.Port, cameraData.URL, cameraData.Username, cameraData.Password)
+		newID, err := camera.AddNewCamera(cameraData.IP, cameraData.Port, cameraData.URL, "", cameraData.Username, cameraData.Password)
 		if err != nil {
 			results = append(results, ImportRowResult{
 				Row:     rowNum,
@@ -506,6 +669,7 @@ func (cs *CameraService) processCameraSelection(records [][]string) (*SelectionR
 
 	// Process each data row to extract IPs and find matching cameras
 	var selectedCameraIDs []string
+	selected := make(map[string]bool)
 	var matchedCameras []models.Camera
 	var unmatchedIPs []string
 	var invalidRows []InvalidRowInfo
@@ -533,8 +697,12 @@ func (cs *CameraService) processCameraSelection(records [][]string) (*SelectionR
 			continue
 		}
 
-		// Check if this IP exists in our camera list
+		// Check if this IP exists in our camera list; a camera listed twice is selected once
 		if cameraID, exists := ipToCameraMap[ip]; exists {
+			if selected[cameraID] {
+				continue
+			}
+			selected[cameraID] = true
 			// Find the full camera object
 			for _, camera := range cameras {
 				if camera.ID == cameraID {
@@ -561,7 +729,8 @@ func (cs *CameraService) processCameraSelection(records [][]string) (*SelectionR
 	}, nil
 }
 
-func (cs *CameraService) processConfigData(records [][]string) (*ConfigData, error) {
+// processConfigData parses the config CSV into one configuration per stream
+func (cs *CameraService) processConfigData(records [][]string) ([]*ConfigData, error) {
 	// Parse header to determine column indices
 	headerRow := records[0]
 	columnIndices := make(map[string]int)
@@ -579,9 +748,70 @@ func (cs *CameraService) processConfigData(records [][]string) (*ConfigData, err
 		}
 	}
 
-	// Process the first data row (should only be 1 row)
-	dataRow := records[1]
+	// Each data row configures one stream; several rows need a profile column
+	// naming the profile (main, sub, name or token) each row is meant for
+	_, hasProfile := columnIndices["profile"]
+	if !hasProfile {
+		if index, exists := columnIndices["stream"]; exists {
+			columnIndices["profile"] = index
+			hasProfile = true
+		}
+	}
+
+	// A per-camera config CSV names the camera of each row in an ip or cam_id column
+	if _, exists := columnIndices["ip"]; !exists {
+		if index, exists := columnIndices["cam_ip"]; exists {
+			columnIndices["ip"] = index
+		}
+	}
+	_, hasIP := columnIndices["ip"]
+	_, hasCameraID := columnIndices["cam_id"]
+	perCamera := hasIP || hasCameraID
+
+	var configs []*ConfigData
+	seenProfiles := make(map[string]int)
+	for i, dataRow := range records[1:] {
+		rowNum := i + 2 // +2 because we skip header and arrays are 0-indexed
+		if isBlankRow(dataRow) {
+			continue
+		}
+
+		configData, err := cs.parseConfigRow(columnIndices, dataRow)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", rowNum, err)
+		}
+
+		key := camera.ProfileSelectorKey(configData.Profile)
+		if perCamera {
+			configData.Row = rowNum
+			if !configData.HasTarget() {
+				return nil, fmt.Errorf("row %d: ip or cam_id value is required", rowNum)
+			}
+			key = strings.ToLower(configData.CameraID+"/"+configData.IP) + "/" + key
+		}
+		// Without a profile column every row is for the main stream; that is reported below
+		if previous, exists := seenProfiles[key]; exists && (hasProfile || perCamera) {
+			if perCamera {
+				return nil, fmt.Errorf("rows %d and %d target the same camera and profile", previous, rowNum)
+			}
+			return nil, fmt.Errorf("rows %d and %d target the same profile %q", previous, rowNum, configData.Profile)
+		}
+		seenProfiles[key] = rowNum
+		configs = append(configs, configData)
+	}
+
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("CSV file must contain header and configuration data")
+	}
+	if len(configs) > 1 && !hasProfile && !perCamera {
+		return nil, fmt.Errorf("a profile column is required when the CSV contains more than one configuration row")
+	}
+
+	return configs, nil
+}
 
+// parseConfigRow reads the configuration of one config CSV row
+func (cs *CameraService) parseConfigRow(columnIndices map[string]int, dataRow []string) (*ConfigData, error) {
 	// Parse configuration values
 	configData := &ConfigData{
 		Bitrate:  0,  // Default value for optional bitrate
@@ -665,198 +895,145 @@ func (cs *CameraService) processConfigData(records [][]string) (*ConfigData, err
 		}
 	}
 
-	return configData, nil
-}
-
-func (cs *CameraService) applyCameraConfig(cameraID string, config *ConfigData) *CameraResult {
-	result := &CameraResult{
-		CameraID: cameraID,
-		Success:  false,
+	// Extract Profile (optional, the main stream when empty)
+	if profileIndex, exists := columnIndices["profile"]; exists && profileIndex < len(dataRow) {
+		configData.Profile = strings.TrimSpace(dataRow[profileIndex])
 	}
 
-	// Get the camera client
-	client, err := camera.GetCameraClient(cameraID)
-	if err != nil {
-		result.Error = fmt.Errorf("camera not found: %w", err)
-		return result
+	// Extract the target camera of a per-camera config CSV
+	if cameraIDIndex, exists := columnIndices["cam_id"]; exists && cameraIDIndex < len(dataRow) {
+		configData.CameraID = strings.TrimSpace(dataRow[cameraIDIndex])
 	}
-
-	// Proceed with config application
-	log.Printf("Getting profiles and configs for camera %s (IP: %s:%d)", cameraID, client.Camera.IP, client.Camera.Port)
-	profileTokens, configTokens, err := camera.GetProfilesAndConfigs(client)
-	if err != nil {
-		log.Printf("Failed to get camera profiles and configs for %s (IP: %s:%d): %v", cameraID, client.Camera.IP, client.Camera.Port, err)
-		// Add more specific error information for network issues
-		errorMsg := err.Error()
-		if strings.Contains(errorMsg, "i/o timeout") || strings.Contains(errorMsg, "dial tcp") {
-			result.Error = fmt.Errorf("network timeout: camera at %s:%d is not responding. Please check: 1) Camera is powered on and connected to network, 2) IP address %s is correct, 3) Port %d is the correct ONVIF port, 4) Camera supports ONVIF protocol", client.Camera.IP, client.Camera.Port, client.Camera.IP, client.Camera.Port)
-		} else if strings.Contains(errorMsg, "connection refused") {
-			result.Error = fmt.Errorf("connection refused: camera at %s:%d refused connection. Please check: 1) Correct ONVIF port (common ports: 80, 8080, 554), 2) ONVIF service is enabled on camera, 3) Firewall settings", client.Camera.IP, client.Camera.Port)
-		} else if strings.Contains(errorMsg, "no route to host") {
-			result.Error = fmt.Errorf("no route to host: cannot reach camera at %s:%d. Please check: 1) Camera and server are on same network, 2) IP address is correct, 3) Network routing", client.Camera.IP, client.Camera.Port)
-		} else {
-			result.Error = fmt.Errorf("failed to get camera profiles and configs: %w", err)
-		}
-		return result
+	if ipIndex, exists := columnIndices["ip"]; exists && ipIndex < len(dataRow) {
+		configData.IP = strings.TrimSpace(dataRow[ipIndex])
 	}
 
-	if len(profileTokens) == 0 {
-		log.Printf("No profiles found for camera %s", cameraID)
-		result.Error = fmt.Errorf("no profiles found")
-		return result
+	// Extract GOP (optional, the camera keeps its current GOP when empty)
+	if gopIndex, exists := columnIndices["gop"]; exists && gopIndex < len(dataRow) {
+		gopStr := strings.TrimSpace(dataRow[gopIndex])
+		if gopStr != "" {
+			gop, err := strconv.Atoi(gopStr)
+			if err != nil || gop <= 0 {
+				return nil, fmt.Errorf("invalid gop value: %s", gopStr)
+			}
+			configData.GOP = gop
+		}
 	}
 
-	if len(configTokens) == 0 {
-		log.Printf("No video encoder configuration found for camera %s", cameraID)
-		result.Error = fmt.Errorf("no video encoder configuration found")
-		return result
+	// Extract Encoder Profile (optional, e.g. Main or High)
+	if encoderProfileIndex, exists := columnIndices["encoder_profile"]; exists && encoderProfileIndex < len(dataRow) {
+		configData.EncoderProfile = camera.NormalizeEncoderProfile(dataRow[encoderProfileIndex])
 	}
 
-	// Use the first token found
-	profileToken := profileTokens[0]
-	configToken := configTokens[0]
-	result.ProfileToken = profileToken
-
-	log.Printf("Using profile token %s and config token %s for camera %s", profileToken, configToken, cameraID)
-
-	// Get current encoder config
-	log.Printf("Getting current encoder config for camera %s", cameraID)
-	currentConfig, err := camera.GetCurrentConfig(client, configToken)
-	if err != nil {
-		log.Printf("Failed to get current encoder config for %s: %v", cameraID, err)
-		result.Error = fmt.Errorf("failed to get current encoder config: %w", err)
-		return result
+	// Extract Rate Control (optional, CBR or VBR)
+	if rateControlIndex, exists := columnIndices["rate_control"]; exists && rateControlIndex < len(dataRow) {
+		rateControl := camera.NormalizeRateControl(dataRow[rateControlIndex])
+		if rateControl != "" && rateControl != camera.RateControlCBR && rateControl != camera.RateControlVBR {
+			return nil, fmt.Errorf("invalid rate_control value: %s (use CBR or VBR)", strings.TrimSpace(dataRow[rateControlIndex]))
+		}
+		configData.RateControl = rateControl
 	}
 
-	// Get available encoder options
-	log.Printf("Getting available encoder options for camera %s", cameraID)
-	encoderOptions, err := camera.GetCurrentEncoderOptions(client, profileToken, configToken)
-	if err != nil {
-		log.Printf("Failed to get encoder options for %s: %v", cameraID, err)
-		result.Error = fmt.Errorf("failed to get encoder options: %w", err)
-		return result
-	}
-
-	// Create target resolution object
-	targetResolution := models.Resolution{Width: config.Width, Height: config.Height}
-
-	// Find closest matching resolution
-	log.Printf("Finding closest matching resolution for camera %s", cameraID)
-	closestResolution := camera.FindClosestResolution(targetResolution, encoderOptions.Resolutions)
-	log.Printf("Closest resolution found for camera %s: %dx%d", cameraID, closestResolution.Width, closestResolution.Height)
-
-	// Check if current configuration already matches the requested configuration
-	currentMatches := currentConfig.Resolution.Width == closestResolution.Width &&
-		currentConfig.Resolution.Height == closestResolution.Height &&
-		currentConfig.FPS == config.FPS &&
-		(config.Bitrate == 0 || currentConfig.Bitrate == config.Bitrate) &&
-		(config.Encoding == "" || currentConfig.Encoding == config.Encoding)
-
-	if currentMatches {
-		log.Printf("Camera %s already has the requested configuration (Resolution: %dx%d, FPS: %d, Bitrate: %d, Encoding: %s), skipping config change",
-			cameraID, closestResolution.Width, closestResolution.Height, config.FPS, currentConfig.Bitrate, currentConfig.Encoding)
-		// Mark as successful but indicate no change was needed
-		result.Success = true
-		result.AppliedConfig = map[string]interface{}{
-			"resolution": map[string]int{
-				"width":  closestResolution.Width,
-				"height": closestResolution.Height,
-			},
-			"fps":       config.FPS,
-			"bitrate":   currentConfig.Bitrate,
-			"encoding":  currentConfig.Encoding,
-			"unchanged": true, // Indicate no change was needed
-		}
-		result.ResolutionAdjusted = config.Width != closestResolution.Width || config.Height != closestResolution.Height
-
-		// Still get stream URI for validation
-		streamURI, err := client.GetStreamURI(profileToken)
-		if err != nil {
-			log.Printf("Failed to get stream URI for %s: %v", cameraID, err)
-			result.Error = fmt.Errorf("failed to get stream URI: %w", err)
-			return result
+	// Extract Quality (optional, the camera keeps its current quality when empty)
+	if qualityIndex, exists := columnIndices["quality"]; exists && qualityIndex < len(dataRow) {
+		qualityStr := strings.TrimSpace(dataRow[qualityIndex])
+		if qualityStr != "" {
+			quality, err := strconv.Atoi(qualityStr)
+			if err != nil || quality <= 0 {
+				return nil, fmt.Errorf("invalid quality value: %s", qualityStr)
+			}
+			configData.Quality = quality
 		}
+	}
 
-		// Parse and construct the URL with embedded credentials
-		parsedURI, err := url.Parse(streamURI)
-		if err != nil {
-			log.Printf("Failed to parse stream URI for %s: %v", cameraID, err)
-			result.Error = fmt.Errorf("failed to parse stream URI: %w", err)
-			return result
+	// Extract Encoding Interval (optional, the camera keeps its current interval when empty)
+	if intervalIndex, exists := columnIndices["encoding_interval"]; exists && intervalIndex < len(dataRow) {
+		intervalStr := strings.TrimSpace(dataRow[intervalIndex])
+		if intervalStr != "" {
+			interval, err := strconv.Atoi(intervalStr)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("invalid encoding_interval value: %s", intervalStr)
+			}
+			configData.EncodingInterval = interval
 		}
+	}
 
-		fullStreamURL := fmt.Sprintf("%s://%s:%s@%s%s", parsedURI.Scheme, client.Camera.Username, client.Camera.Password, parsedURI.Host, parsedURI.RequestURI())
-		result.StreamURL = fullStreamURL
+	return configData, nil
+}
 
-		return result
+// isBlankRow reports whether every field of a CSV row is empty
+func isBlankRow(row []string) bool {
+	for _, field := range row {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
 	}
+	return true
+}
 
-	// Prepare the new configuration
-	newConfig := models.EncoderConfig{
-		Resolution: closestResolution,
-		Quality:    currentConfig.Quality, // Keep the current quality
-		FPS:        config.FPS,
-		Bitrate:    config.Bitrate,
-		Encoding:   config.Encoding,
+// encoderConfig returns the encoder settings of a config at the given resolution
+func (config *ConfigData) encoderConfig(resolution models.Resolution) models.EncoderConfig {
+	return models.EncoderConfig{
+		Resolution:       resolution,
+		Quality:          config.Quality, // 0 keeps the current quality
+		FPS:              config.FPS,
+		Bitrate:          config.Bitrate,
+		Encoding:         config.Encoding,
+		GOP:              config.GOP,
+		EncoderProfile:   config.EncoderProfile,
+		RateControl:      config.RateControl,
+		EncodingInterval: config.EncodingInterval,
 	}
-	log.Printf("Prepared new config for camera %s: %+v", cameraID, newConfig)
+}
 
-	// Set the new encoder config
-	log.Printf("Setting new encoder config for camera %s", cameraID)
-	if err := camera.SetEncoderConfig(client, configToken, currentConfig, newConfig); err != nil {
-		log.Printf("Failed to set encoder config for %s: %v", cameraID, err)
-		result.Error = fmt.Errorf("failed to set encoder config: %w", err)
-		return result
-	}
-	log.Printf("Successfully applied config for camera %s", cameraID)
+// applyCameraConfig applies a config to one camera.
+// With dryRun the camera is only read and the result holds the planned changes.
+// Nothing is written once ctx is done. The caller holds the camera's operation lock.
+func (cs *CameraService) applyCameraConfig(ctx context.Context, cameraID string, config *ConfigData, dryRun bool) *CameraResult {
+	requested := config.encoderConfig(models.Resolution{Width: config.Width, Height: config.Height})
+	result := camera.ApplyStreamConfig(ctx, cameraID, config.Profile, requested, dryRun, nil)
+	return &result
+}
 
-	// Get stream URI for later validation
-	streamURI, err := client.GetStreamURI(profileToken)
-	if err != nil {
-		log.Printf("Failed to get stream URI for %s: %v", cameraID, err)
-		result.Error = fmt.Errorf("failed to get stream URI: %w", err)
-		return result
+// rollbackCamera restores the configuration a camera had before the change when its
+// validation failed and the policy asks for it, then validates the stream again against
+// the restored configuration. It returns nil when no rollback was needed. Nothing is
+// written once ctx is done. The caller holds the camera's operation lock.
+func (cs *CameraService) rollbackCamera(ctx context.Context, result *CameraResult, validation *ValidationResult, policy string) *models.RollbackResult {
+	if result.PreviousConfig == nil {
+		return nil
 	}
-
-	// The ONVIF GetStreamUri typically doesn't include credentials. Embed them for FFmpeg validation.
-	parsedURI, err := url.Parse(streamURI)
-	if err != nil {
-		log.Printf("Failed to parse stream URI for %s: %v", cameraID, err)
-		result.Error = fmt.Errorf("failed to parse stream URI: %w", err)
-		return result
+	actual := models.Resolution{Width: validation.ActualWidth, Height: validation.ActualHeight}
+	if !camera.NeedsRollback(policy, result.Resolution, actual) {
+		return nil
 	}
 
-	// Construct the URL with embedded credentials
-	fullStreamURL := fmt.Sprintf("%s://%s:%s@%s%s", parsedURI.Scheme, client.Camera.Username, client.Camera.Password, parsedURI.Host, parsedURI.RequestURI())
+	cameraID := result.CameraID
+	previous := *result.PreviousConfig
 
-	// Mark this camera as successfully configured
-	result.Success = true
-	result.AppliedConfig = map[string]interface{}{
-		"resolution": map[string]int{
-			"width":  closestResolution.Width,
-			"height": closestResolution.Height,
-		},
-		"fps":      config.FPS,
-		"bitrate":  config.Bitrate,
-		"encoding": config.Encoding,
+	client, err := camera.GetCameraClient(cameraID)
+	if err != nil {
+		return &models.RollbackResult{Policy: policy, Reason: validation.Error, RestoredConfig: previous, Error: err.Error()}
 	}
-	result.ResolutionAdjusted = config.Width != closestResolution.Width || config.Height != closestResolution.Height
-	result.StreamURL = fullStreamURL
-
-	return result
+	return camera.Rollback(ctx, client, result.ConfigToken, previous, policy, validation.Error, result.StreamURL)
 }
 
-func (cs *CameraService) validateCameraStream(streamURL string, config *ConfigData) *ValidationResult {
-	validationResult, err := ffmpeg.ValidateStream(streamURL, config.Width, config.Height, config.FPS, config.Bitrate, config.Encoding)
+// validateCameraStream validates the stream of a configured camera against the
+// resolution written to it and the other settings of config. The caller holds the
+// camera's operation lock.
+func (cs *CameraService) validateCameraStream(result *CameraResult, config *ConfigData) *ValidationResult {
+	validationResult, err := ffmpeg.ValidateStream(result.StreamURL, result.Resolution.Width, result.Resolution.Height, config.FPS, config.Bitrate, config.Encoding, config.GOP, config.RateControl)
 	if err != nil {
 		return &ValidationResult{
-			IsValid:          false,
-			Error:            err.Error(),
-			ExpectedWidth:    config.Width,
-			ExpectedHeight:   config.Height,
-			ExpectedFPS:      config.FPS,
-			ExpectedBitrate:  config.Bitrate,
-			ExpectedEncoding: config.Encoding,
+			IsValid:             false,
+			Error:               err.Error(),
+			ExpectedWidth:       result.Resolution.Width,
+			ExpectedHeight:      result.Resolution.Height,
+			ExpectedFPS:         config.FPS,
+			ExpectedBitrate:     config.Bitrate,
+			ExpectedEncoding:    config.Encoding,
+			ExpectedGOP:         config.GOP,
+			ExpectedRateControl: config.RateControl,
 		}
 	}
 
@@ -870,17 +1047,22 @@ func (cs *CameraService) validateCameraStream(streamURL string, config *ConfigDa
 	overrideIsValid := resolutionMatches // Only consider valid if resolution matches
 
 	return &ValidationResult{
-		IsValid:          overrideIsValid, // Use our override logic
-		ExpectedWidth:    validationResult.ExpectedWidth,
-		ExpectedHeight:   validationResult.ExpectedHeight,
-		ExpectedFPS:      validationResult.ExpectedFPS,
-		ExpectedBitrate:  validationResult.ExpectedBitrate,
-		ExpectedEncoding: validationResult.ExpectedEncoding,
-		ActualWidth:      validationResult.ActualWidth,
-		ActualHeight:     validationResult.ActualHeight,
-		ActualFPS:        validationResult.ActualFPS,
-		ActualBitrate:    validationResult.ActualBitrate,
-		ActualEncoding:   validationResult.ActualEncoding,
-		Error:            validationResult.Error,
+		IsValid:             overrideIsValid, // Use our override logic
+		ExpectedWidth:       validationResult.ExpectedWidth,
+		ExpectedHeight:      validationResult.ExpectedHeight,
+		ExpectedFPS:         validationResult.ExpectedFPS,
+		ExpectedBitrate:     validationResult.ExpectedBitrate,
+		ExpectedEncoding:    validationResult.ExpectedEncoding,
+		ExpectedGOP:         validationResult.ExpectedGOP,
+		ExpectedRateControl: validationResult.ExpectedRateControl,
+		ActualWidth:         validationResult.ActualWidth,
+		ActualHeight:        validationResult.ActualHeight,
+		ActualFPS:           validationResult.ActualFPS,
+		ActualBitrate:       validationResult.ActualBitrate,
+		ActualEncoding:      validationResult.ActualEncoding,
+		ActualGOP:           validationResult.ActualGOP,
+		BitrateSamples:      validationResult.BitrateSamples,
+		BitrateVariation:    validationResult.BitrateVariation,
+		Error:               validationResult.Error,
 	}
 }
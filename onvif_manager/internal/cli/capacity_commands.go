@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/capacity"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/internal/backend/templates"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags of the capacity command
+var (
+	capacityConfigCSV      string
+	capacityTemplate       string
+	capacityProfiles       []string
+	capacityMeasure        bool
+	capacityMeasureSeconds int
+	capacityRetention      []int
+	capacityBudgetMbps     float64
+	capacityBudgetGB       float64
+	capacityConcurrency    int
+	capacityTimeout        time.Duration
+)
+
+// capacityCmd represents the capacity command
+var capacityCmd = &cobra.Command{
+	Use:   "capacity [camera-id...]",
+	Short: "Plan the network load and recording storage of a configuration",
+	Long: `Compute the aggregate bitrate and the NVR storage per retention period of the selected cameras,
+per camera group and in total, without changing anything.
+
+With --config the streams of a config CSV are planned, with --template the template variant for
+the model of each camera; otherwise the current configuration of the --profile streams is planned.
+With --measure the bitrate of each stream is measured with FFmpeg and counted instead of the
+configured limit, unless the target changes the stream.
+
+Without camera IDs or selector flags every inventoried camera is planned. Budgets default to
+` + capacity.BandwidthBudgetEnvVar + ` (Mbit/s) and ` + capacity.StorageBudgetEnvVar + ` (GB).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCapacityPlan(args)
+	},
+}
+
+func init() {
+	capacityCmd.Flags().StringVar(&capacityConfigCSV, "config", "", "plan the streams of a config CSV")
+	capacityCmd.Flags().StringVar(&capacityTemplate, "template", "", "plan a configuration template, resolved for the model of each camera")
+	capacityCmd.Flags().StringSliceVar(&capacityProfiles, "profile", nil, fmt.Sprintf("profiles planned with their current configuration, or %s (default main)", capacity.ProfilesAll))
+	capacityCmd.Flags().BoolVar(&capacityMeasure, "measure", false, "measure the bitrate of each stream with FFmpeg")
+	capacityCmd.Flags().IntVar(&capacityMeasureSeconds, "measure-seconds", capacity.DefaultMeasureSeconds, "seconds each stream is measured")
+	capacityCmd.Flags().IntSliceVar(&capacityRetention, "retention", capacity.DefaultRetentionDays, "retention periods in days to compute storage for")
+	capacityCmd.Flags().Float64Var(&capacityBudgetMbps, "budget-mbps", 0, fmt.Sprintf("bandwidth budget in Mbit/s (env %s)", capacity.BandwidthBudgetEnvVar))
+	capacityCmd.Flags().Float64Var(&capacityBudgetGB, "budget-storage-gb", 0, fmt.Sprintf("storage budget in GB for each retention period (env %s)", capacity.StorageBudgetEnvVar))
+	capacityCmd.Flags().IntVar(&capacityConcurrency, "concurrency", 0, fmt.Sprintf("number of cameras read in parallel (default %d, env %s)", pool.DefaultConcurrency, pool.ConcurrencyEnvVar))
+	capacityCmd.Flags().DurationVar(&capacityTimeout, "timeout", 0, fmt.Sprintf("maximum time per camera (default %s, env %s)", pool.DefaultTimeout, pool.TimeoutEnvVar))
+	cameraSelection.register(capacityCmd)
+
+	RootCmd.AddCommand(capacityCmd)
+}
+
+// runCapacityPlan plans the selected cameras and prints the load per camera, group and in total
+func runCapacityPlan(args []string) error {
+	if capacityConfigCSV != "" && capacityTemplate != "" {
+		return fmt.Errorf("give either --config or --template, not both")
+	}
+	for _, days := range capacityRetention {
+		if days <= 0 {
+			return fmt.Errorf("retention periods must be positive, got %d", days)
+		}
+	}
+	cameraIDs, err := inventoryCameraIDs(args)
+	if err != nil {
+		return err
+	}
+	if len(cameraIDs) == 0 {
+		return fmt.Errorf("no cameras to plan")
+	}
+
+	request := capacity.Request{
+		Profiles:       capacityProfiles,
+		Measure:        capacityMeasure,
+		MeasureSeconds: capacityMeasureSeconds,
+		RetentionDays:  capacityRetention,
+		Budget:         capacity.DefaultBudget().WithOverrides(capacityBudgetMbps, capacityBudgetGB),
+	}
+	switch {
+	case capacityTemplate != "":
+		request.Template, err = templates.Shared.Get().Get(capacityTemplate)
+		if err != nil {
+			return err
+		}
+	case capacityConfigCSV != "":
+		request.Streams, err = capacityStreams(capacityConfigCSV)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("📊 Planning capacity of %d camera(s)...\n", len(cameraIDs))
+	if capacityMeasure {
+		fmt.Printf("⏱️  Measuring each stream for %d seconds\n", capacityMeasureSeconds)
+	}
+	opts := pool.DefaultOptions().WithOverrides(capacityConcurrency, capacityTimeout)
+	printCapacityPlan(capacity.Run(context.Background(), cameraIDs, request, opts))
+	return nil
+}
+
+// capacityStreams reads the streams of a config CSV. Per-camera rows are not supported,
+// as the plan applies one configuration to every camera.
+func capacityStreams(csvFile string) ([]templates.Stream, error) {
+	configs, err := cameraService.ImportConfigFromCSV(csvFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import config: %w", err)
+	}
+
+	streams := make([]templates.Stream, 0, len(configs))
+	for _, config := range configs {
+		if config.HasTarget() {
+			return nil, fmt.Errorf("per-camera config CSVs cannot be planned; use a config CSV without camera columns")
+		}
+		streams = append(streams, templates.Stream{
+			Profile:  config.Profile,
+			Width:    config.Width,
+			Height:   config.Height,
+			FPS:      config.FPS,
+			Bitrate:  config.Bitrate,
+			Encoding: config.Encoding,
+		})
+	}
+	return streams, nil
+}
+
+// printCapacityPlan prints the load of every camera, the group and overall totals and the warnings
+func printCapacityPlan(plan *capacity.Plan) {
+	fmt.Printf("\n%-15s %-16s %-12s %-6s %-10s %-10s %-10s %s\n", "Camera", "Profile", "Resolution", "FPS", "Config", "Measured", "Counted", "Basis")
+	fmt.Println(strings.Repeat("-", 92))
+	for _, cameraID := range plan.CameraOrder {
+		load := plan.Cameras[cameraID]
+		if !load.Success {
+			fmt.Printf("%-15s ❌ %s\n", cameraID, load.Error)
+			continue
+		}
+		for _, stream := range load.Streams {
+			measured := "-"
+			if stream.MeasuredKbps > 0 {
+				measured = fmt.Sprintf("%d", stream.MeasuredKbps)
+			}
+			basis := stream.Basis
+			if stream.Changed {
+				basis += ", changed"
+			}
+			fmt.Printf("%-15s %-16s %-12s %-6d %-10d %-10s %-10d %s\n", cameraID, stream.Profile,
+				fmt.Sprintf("%dx%d", stream.Width, stream.Height), stream.FPS, stream.ConfiguredKbps, measured, stream.BitrateKbps, basis)
+			if stream.Warning != "" {
+				fmt.Printf("%-15s    ⚠️  %s\n", "", stream.Warning)
+			}
+		}
+	}
+	fmt.Println("Bitrates in kbps")
+
+	if len(plan.Groups) > 0 {
+		fmt.Printf("\n🏷️  Groups:\n")
+		for _, group := range plan.Groups {
+			fmt.Printf("   • %s: %s\n", group.Group, capacityTotalsLabel(group.Totals))
+		}
+	}
+
+	fmt.Printf("\n📊 Total: %s\n", capacityTotalsLabel(plan.Total))
+	if plan.Budget.BandwidthMbps > 0 {
+		fmt.Printf("   Bandwidth budget: %.1f Mbit/s\n", plan.Budget.BandwidthMbps)
+	}
+	if plan.Budget.StorageGB > 0 {
+		fmt.Printf("   Storage budget: %.0f GB\n", plan.Budget.StorageGB)
+	}
+
+	if len(plan.Warnings) == 0 {
+		fmt.Printf("\n✅ Within budget\n")
+		return
+	}
+	fmt.Printf("\n⚠️  Warnings:\n")
+	for _, warning := range plan.Warnings {
+		fmt.Printf("   • %s\n", warning)
+	}
+}
+
+// capacityTotalsLabel describes the bitrate and storage of a set of cameras
+func capacityTotalsLabel(totals capacity.Totals) string {
+	label := fmt.Sprintf("%d camera(s), %d stream(s), %.1f Mbit/s", totals.Cameras, totals.Streams, totals.BitrateMbps)
+	for _, storage := range totals.Storage {
+		label += fmt.Sprintf(", %.0f GB for %d days", storage.GB, storage.Days)
+	}
+	return label
+}
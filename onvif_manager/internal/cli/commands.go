@@ -2,12 +2,16 @@ package cli
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+
 	"github.com/spf13/cobra"
 )
 
@@ -116,10 +120,29 @@ var configCmd = &cobra.Command{
 var applyConfigCmd = &cobra.Command{
 	Use:   "apply [camera-csv] [config-csv]",
 	Short: "Import cameras and apply configuration",
-	Long:  `Import cameras from first CSV file and apply configuration from second CSV file in a single operation.`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Import cameras from first CSV file and apply configuration from second CSV file in a single operation.
+With --group, --tag or --selector only the config CSV is given and it is applied to the selected cameras of the inventory.
+A config CSV with an ip or cam_id column configures each row on the inventoried camera it names; it can be
+given alone, after the camera CSV or with the selector flags, which then limit the rows that are applied.
+With --template the config CSV is left out and each camera gets the template variant for its model.`,
+	Args: cobra.RangeArgs(0, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runApplyConfig(args)
+	},
+}
+
+// configPlanCmd shows what config apply would change without changing anything
+var configPlanCmd = &cobra.Command{
+	Use:   "plan [camera-csv] [config-csv]",
+	Short: "Show the changes a configuration would make",
+	Long: `Import cameras from first CSV file and show, per camera, how the configuration from the second CSV file
+differs from the current settings, including resolution adjustments and unsupported values. Nothing is written to the cameras.
+With --group, --tag or --selector only the config CSV is given and the selected cameras of the inventory are planned.
+A config CSV with an ip or cam_id column is planned row by row on the cameras it names.
+With --template the config CSV is left out and each camera is planned with the template variant for its model.`,
+	Args: cobra.RangeArgs(0, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runApplyConfig(args[0], args[1])
+		return runPlanConfig(args)
 	},
 }
 
@@ -148,14 +171,23 @@ var configShowCmd = &cobra.Command{
 var configSetCmd = &cobra.Command{
 	Use:   "set [width] [height] [fps] [bitrate] [encoding]",
 	Short: "Set saved configuration manually",
-	Long:  `Set the saved configuration values manually by providing width, height, fps, bitrate, and optionally encoding (H264, H265, HEVC, MJPEG).`,
-	Args:  cobra.RangeArgs(4, 5),
+	Long: `Set the saved configuration values manually by providing width, height, fps, bitrate, and optionally encoding (H264, H265, HEVC, MJPEG).
+Use --gop and --encoder-profile to also set the keyframe interval and the H.264/H.265 profile, and
+--rate-control (CBR or VBR), --quality and --encoding-interval to control the bitrate.`,
+	Args: cobra.RangeArgs(4, 5),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		encoding := "H264" // Default encoding
 		if len(args) == 5 {
 			encoding = args[4]
 		}
-		return runSetConfigWithEncoding(args[0], args[1], args[2], args[3], encoding)
+		settings := &ConfigData{
+			GOP:              configSetGOP,
+			EncoderProfile:   configSetEncoderProfile,
+			RateControl:      configSetRateControl,
+			Quality:          configSetQuality,
+			EncodingInterval: configSetEncodingInterval,
+		}
+		return runSetConfigWithEncoding(args[0], args[1], args[2], args[3], encoding, settings)
 	},
 }
 
@@ -181,9 +213,52 @@ var applyToSelectedCmd = &cobra.Command{
 	},
 }
 
+// Optional encoder settings for config set
+var (
+	configSetGOP              int
+	configSetEncoderProfile   string
+	configSetRateControl      string
+	configSetQuality          int
+	configSetEncodingInterval int
+)
+
+// Worker pool overrides for the apply commands
+var (
+	applyConcurrency int
+	applyTimeout     time.Duration
+)
+
+// Rollback policy of the apply commands
+var applyRollback string
+
+// Configuration template applied by config apply and config plan instead of a config CSV
+var applyTemplate string
+
 func init() {
+	configSetCmd.Flags().IntVar(&configSetGOP, "gop", 0, "frames between keyframes (0 keeps the camera's current GOP)")
+	configSetCmd.Flags().StringVar(&configSetEncoderProfile, "encoder-profile", "", "H.264/H.265 profile, e.g. Baseline, Main or High (empty keeps the current profile)")
+	configSetCmd.Flags().StringVar(&configSetRateControl, "rate-control", "", "rate control mode, CBR or VBR (empty keeps the current mode)")
+	configSetCmd.Flags().IntVar(&configSetQuality, "quality", 0, "quality level within the camera's quality range (0 keeps the current quality)")
+	configSetCmd.Flags().IntVar(&configSetEncodingInterval, "encoding-interval", 0, "encode only every n-th frame (0 keeps the current interval)")
+
+	// Worker pool flags shared by the apply commands; zero keeps the environment/default value
+	for _, cmd := range []*cobra.Command{applyConfigCmd, applyToSelectedCmd, configPlanCmd} {
+		cmd.Flags().IntVar(&applyConcurrency, "concurrency", 0, fmt.Sprintf("number of cameras configured and validated in parallel (default %d, env %s)", pool.DefaultConcurrency, pool.ConcurrencyEnvVar))
+		cmd.Flags().DurationVar(&applyTimeout, "timeout", 0, fmt.Sprintf("maximum time per camera and phase (default %s, env %s)", pool.DefaultTimeout, pool.TimeoutEnvVar))
+	}
+	cameraSelection.register(applyConfigCmd)
+	cameraSelection.register(configPlanCmd)
+	for _, cmd := range []*cobra.Command{applyConfigCmd, configPlanCmd} {
+		cmd.Flags().StringVar(&applyTemplate, "template", "", "apply a configuration template instead of a config CSV, resolved for the model of each camera")
+	}
+	for _, cmd := range []*cobra.Command{applyConfigCmd, applyToSelectedCmd} {
+		cmd.Flags().StringVar(&applyRollback, "rollback", camera.RollbackNever, fmt.Sprintf("restore the previous configuration of cameras that fail validation: %s, %s or %s",
+			camera.RollbackNever, camera.RollbackOnFailure, camera.RollbackOnResolutionMismatch))
+	}
+
 	// Only add the apply command for the simplified workflow
 	configCmd.AddCommand(applyConfigCmd)
+	configCmd.AddCommand(configPlanCmd)
 
 	// These commands are no longer exposed in the simplified workflow
 	// but kept in the code for compatibility with existing scripts
@@ -193,8 +268,8 @@ func init() {
 	// configCmd.AddCommand(applyToSelectedCmd)
 }
 
-// Global variable to store last validation results
-var lastValidationResults *ValidationResults
+// Global variable to store last validation results, one entry per configured stream
+var lastValidationResults []*ValidationResults
 
 // runListCameras lists all cameras in the system
 func runListCameras() error {
@@ -263,38 +338,52 @@ func runSelectCameras(csvFile string) error {
 	return nil
 }
 
-// runApplyConfig imports cameras and applies configuration in one workflow
-func runApplyConfig(cameraCSV, configCSV string) error {
-	// Step 1: Import cameras from the first CSV file
-	fmt.Printf("📂 Importing cameras from: %s\n", cameraCSV)
-	importResult, err := cameraService.ImportCamerasFromCSV(cameraCSV)
+// runApplyConfig imports cameras, or selects them from the inventory, and applies configuration in one workflow
+func runApplyConfig(args []string) error {
+	rollbackPolicy, err := camera.ParseRollbackPolicy(applyRollback)
 	if err != nil {
-		return fmt.Errorf("failed to import cameras: %w", err)
+		return err
 	}
 
-	if importResult.SuccessCount == 0 {
-		return fmt.Errorf("no cameras were successfully imported from CSV file")
+	// Step 1: Import cameras from the first CSV file or select them
+	cameraIDs, configCSV, err := configTargets(args)
+	if err != nil {
+		return err
 	}
-
-	fmt.Printf("✅ Imported %d cameras\n", importResult.SuccessCount)
-
-	// Extract camera IDs from import result
-	var cameraIDs []string
-	for _, result := range importResult.Results {
-		if result.Success {
-			cameraIDs = append(cameraIDs, result.CameraID)
+	opts := pool.DefaultOptions().WithOverrides(applyConcurrency, applyTimeout)
+	if applyTemplate != "" {
+		targets, err := templateConfigRows(applyTemplate, cameraIDs, opts)
+		if err != nil {
+			return err
 		}
+		return runApplyConfigRows(targets, rollbackPolicy)
 	}
 
 	// Step 2: Load configuration
 	fmt.Printf("📂 Loading configuration from: %s\n", configCSV)
-	config, err := cameraService.ImportConfigFromCSV(configCSV)
+	configs, err := cameraService.ImportConfigFromCSV(configCSV)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
+	if configs[0].HasTarget() {
+		targets := cameraService.ResolveConfigRows(configs, cameraIDs)
+		listConfigRows(targets)
+		return runApplyConfigRows(targets, rollbackPolicy)
+	}
+	if cameraIDs == nil {
+		return errNoConfigTargets
+	}
 
-	fmt.Printf("⚙️  Configuration loaded: %dx%d, %d FPS, %d kbps\n",
-		config.Width, config.Height, config.FPS, config.Bitrate)
+	for _, config := range configs {
+		fmt.Printf("⚙️  Configuration loaded%s: %dx%d, %d FPS, %d kbps\n",
+			profileLabel(config.Profile), config.Width, config.Height, config.FPS, config.Bitrate)
+	}
+	var waves [][]string
+	if stagedRollout() {
+		if waves, err = rolloutWaves(cameraIDs); err != nil {
+			return err
+		}
+	}
 	// Step 3: Confirm with user
 	fmt.Printf("\n🤔 Do you want to apply this configuration to %d cameras? (y/N): ", len(cameraIDs))
 	scanner := bufio.NewScanner(os.Stdin)
@@ -305,81 +394,312 @@ func runApplyConfig(cameraCSV, configCSV string) error {
 		fmt.Println("❌ Configuration application cancelled.")
 		return nil
 	}
-	// Step 4: Apply configuration
-	fmt.Printf("\n🔧 Applying configuration to cameras...\n")
-
+	// Step 4: Apply configuration, one stream after the other
 	// Note: No need to call EnsureCamerasInitialized as cameras are already initialized during import
+	applyStreams := func(cameraIDs []string) []*ValidationResults {
+		var results []*ValidationResults
+		for _, config := range configs {
+			fmt.Printf("\n🔧 Applying configuration%s to cameras...\n", profileLabel(config.Profile))
+
+			validation, err := cameraService.ApplyConfigToCamerasWithOptions(cameraIDs, config, opts, rollbackPolicy)
+			if err != nil {
+				fmt.Printf("❌ Failed to apply configuration: %v\n", err)
+				continue
+			}
+			results = append(results, validation)
 
-	validation, err := cameraService.ApplyConfigToCameras(cameraIDs, config)
-	if err != nil {
-		return fmt.Errorf("failed to apply configuration: %w", err)
+			// Step 5: Display results
+			printApplyResults(validation)
+		}
+		return results
 	}
 
 	// Store results for potential export
-	lastValidationResults = validation
+	if waves != nil {
+		lastValidationResults = runRolloutWaves(scanner, waves, applyStreams)
+	} else {
+		lastValidationResults = applyStreams(cameraIDs)
+	}
 
-	// Step 5: Display results
-	fmt.Printf("\n📊 Configuration Results:\n")
+	// Step 6: Offer to export results
+	return offerResultsExport(scanner)
+}
 
-	successCount := 0
-	failureCount := 0
-	validationPassCount := 0
-	validationFailCount := 0
+// listConfigRows prints the rows of a per-camera config CSV with the camera each one targets
+func listConfigRows(targets []*ConfigRowTarget) {
+	for _, target := range targets {
+		if target.Error != nil {
+			fmt.Printf("⚠️  %s: %v\n", configRowLabel(target), target.Error)
+			continue
+		}
+		fmt.Printf("⚙️  %s\n", configRowLabel(target))
+	}
+}
 
-	for cameraID, result := range validation.CameraResults {
-		status := "❌ FAILED"
-		if result.Success {
-			status = "✅ SUCCESS"
-			successCount++
-		} else {
-			failureCount++
+// runApplyConfigRows applies per-camera config rows, each to the camera it targets, as one batch
+func runApplyConfigRows(targets []*ConfigRowTarget, rollbackPolicy string) error {
+	cameras := make(map[string]bool)
+	for _, target := range targets {
+		if target.Error == nil {
+			cameras[target.CameraID] = true
+		}
+	}
+	if len(cameras) == 0 {
+		return fmt.Errorf("no row of the config CSV targets a camera that can be configured")
+	}
+	var waves [][]string
+	if stagedRollout() {
+		var err error
+		if waves, err = rolloutWaves(rowCameraIDs(targets)); err != nil {
+			return err
 		}
+	}
 
-		fmt.Printf("   • Camera %s: %s", cameraID, status)
-		if !result.Success && result.Error != nil {
-			fmt.Printf(" - %s", result.Error.Error())
+	fmt.Printf("\n🤔 Do you want to apply %d rows to %d cameras? (y/N): ", len(targets), len(cameras))
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Scan()
+	response := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	if response != "y" && response != "yes" {
+		fmt.Println("❌ Configuration application cancelled.")
+		return nil
+	}
+
+	opts := pool.DefaultOptions().WithOverrides(applyConcurrency, applyTimeout)
+	if waves != nil {
+		lastValidationResults = runRolloutWaves(scanner, waves, func(cameraIDs []string) []*ValidationResults {
+			waveTargets := rowTargetsOf(targets, cameraIDs)
+			fmt.Printf("\n🔧 Applying configuration rows to cameras...\n")
+			passes := cameraService.ApplyConfigRows(waveTargets, opts, rollbackPolicy)
+			printConfigRowResults(waveTargets, passes)
+			return passes
+		})
+		return offerResultsExport(scanner)
+	}
+
+	fmt.Printf("\n🔧 Applying configuration rows to cameras...\n")
+	passes := cameraService.ApplyConfigRows(targets, opts, rollbackPolicy)
+	lastValidationResults = passes
+	printConfigRowResults(targets, passes)
+
+	return offerResultsExport(scanner)
+}
+
+// offerResultsExport asks whether to export the validation results of the last apply to CSV
+func offerResultsExport(scanner *bufio.Scanner) error {
+	if len(lastValidationResults) == 0 {
+		return nil
+	}
+
+	fmt.Printf("\n💾 Do you want to export validation results to CSV? (y/N): ")
+	scanner.Scan()
+	response := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	if response != "y" && response != "yes" {
+		return nil
+	}
+	defaultFilename := generateTimestampedFilename("validation_results.csv")
+	fmt.Printf("📄 Enter output filename (default: %s): ", defaultFilename)
+	scanner.Scan()
+	filename := strings.TrimSpace(scanner.Text())
+	if filename == "" {
+		filename = defaultFilename
+	}
+
+	return runExportResults(filename)
+}
+
+// runPlanConfig imports or selects cameras and shows the changes the configuration would make
+func runPlanConfig(args []string) error {
+	cameraIDs, configCSV, err := configTargets(args)
+	if err != nil {
+		return err
+	}
+	opts := pool.DefaultOptions().WithOverrides(applyConcurrency, applyTimeout)
+	if applyTemplate != "" {
+		targets, err := templateConfigRows(applyTemplate, cameraIDs, opts)
+		if err != nil {
+			return err
 		}
-		fmt.Println()
+		printConfigRowPlans(targets, cameraService.PlanConfigRows(targets, opts))
+		fmt.Println("\nℹ️  Dry run only, no camera was changed. Use 'config apply' to apply the configuration.")
+		return nil
+	}
 
-		if validationResult, exists := validation.ValidationResults[cameraID]; exists {
-			if validationResult.IsValid {
-				fmt.Printf("      Validation: ✅ PASSED\n")
-				validationPassCount++
-			} else {
-				fmt.Printf("      Validation: ❌ FAILED - %s\n", validationResult.Error)
-				validationFailCount++
-			}
+	fmt.Printf("📂 Loading configuration from: %s\n", configCSV)
+	configs, err := cameraService.ImportConfigFromCSV(configCSV)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	switch {
+	case configs[0].HasTarget():
+		targets := cameraService.ResolveConfigRows(configs, cameraIDs)
+		printConfigRowPlans(targets, cameraService.PlanConfigRows(targets, opts))
+	case cameraIDs == nil:
+		return errNoConfigTargets
+	default:
+		for _, config := range configs {
+			fmt.Printf("\n🔍 Planning configuration%s: %dx%d, %d FPS, %d kbps\n",
+				profileLabel(config.Profile), config.Width, config.Height, config.FPS, config.Bitrate)
+
+			printPlanResults(cameraService.PlanConfigForCameras(cameraIDs, config, opts))
 		}
 	}
 
-	fmt.Printf("\n📈 Summary:\n")
-	fmt.Printf("   • Configuration: %d success, %d failed\n", successCount, failureCount)
-	fmt.Printf("   • Validation: %d passed, %d failed\n", validationPassCount, validationFailCount)
+	fmt.Println("\nℹ️  Dry run only, no camera was changed. Use 'config apply' to apply the configuration.")
+	return nil
+}
 
-	// Step 6: Offer to export results
-	if lastValidationResults != nil {
-		fmt.Printf("\n💾 Do you want to export validation results to CSV? (y/N): ")
-		scanner.Scan()
-		response = strings.ToLower(strings.TrimSpace(scanner.Text()))
-		if response == "y" || response == "yes" {
-			defaultFilename := generateTimestampedFilename("validation_results.csv")
-			fmt.Printf("📄 Enter output filename (default: %s): ", defaultFilename)
-			scanner.Scan()
-			filename := strings.TrimSpace(scanner.Text())
-			if filename == "" {
-				filename = defaultFilename
-			}
+// errNoConfigTargets is returned when a config CSV without ip or cam_id column is given without cameras
+var errNoConfigTargets = errors.New("the config CSV has no ip or cam_id column: give the camera CSV too, or select cameras with --group, --tag or --selector")
 
-			return runExportResults(filename)
+// configTargets returns the cameras and the config CSV of config apply and config plan: the
+// cameras imported from the camera CSV, or the inventoried cameras selected by the selector flags.
+// With only a config CSV and no selector flags the cameras are nil and the rows of the per-camera
+// config CSV name their cameras. With --template no config CSV is given.
+func configTargets(args []string) ([]string, string, error) {
+	if applyTemplate != "" {
+		return templateTargets(args)
+	}
+	if len(args) == 0 {
+		return nil, "", fmt.Errorf("give the config CSV, or use --template")
+	}
+	if cameraSelection.isSet() {
+		if len(args) != 1 {
+			return nil, "", fmt.Errorf("with --group, --tag or --selector give only the config CSV")
 		}
+		cameraIDs, err := inventoryCameraIDs(nil)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Printf("🎯 Selected %d camera(s): %s\n", len(cameraIDs), strings.Join(cameraIDs, ", "))
+		return cameraIDs, args[0], nil
+	}
+	if len(args) == 1 {
+		return nil, args[0], nil
 	}
 
-	return nil
+	cameraIDs, err := importConfigCameras(args[0])
+	return cameraIDs, args[1], err
+}
+
+// importConfigCameras imports the cameras of a camera CSV and returns the IDs of those imported
+func importConfigCameras(cameraCSV string) ([]string, error) {
+	fmt.Printf("📂 Importing cameras from: %s\n", cameraCSV)
+	importResult, err := cameraService.ImportCamerasFromCSV(cameraCSV)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import cameras: %w", err)
+	}
+
+	if importResult.SuccessCount == 0 {
+		return nil, fmt.Errorf("no cameras were successfully imported from CSV file")
+	}
+
+	fmt.Printf("✅ Imported %d cameras\n", importResult.SuccessCount)
+
+	// Extract camera IDs from import result
+	var cameraIDs []string
+	for _, result := range importResult.Results {
+		if result.Success {
+			cameraIDs = append(cameraIDs, result.CameraID)
+		}
+	}
+	return cameraIDs, nil
+}
+
+// templateTargets returns the cameras a template is applied to: the cameras selected by the
+// selector flags, or the cameras imported from the only argument, the camera CSV
+func templateTargets(args []string) ([]string, string, error) {
+	if cameraSelection.isSet() {
+		if len(args) != 0 {
+			return nil, "", fmt.Errorf("with --template and --group, --tag or --selector give no CSV file")
+		}
+		cameraIDs, err := inventoryCameraIDs(nil)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Printf("🎯 Selected %d camera(s): %s\n", len(cameraIDs), strings.Join(cameraIDs, ", "))
+		return cameraIDs, "", nil
+	}
+	if len(args) != 1 {
+		return nil, "", fmt.Errorf("with --template give only the camera CSV, or select cameras with --group, --tag or --selector")
+	}
+	cameraIDs, err := importConfigCameras(args[0])
+	return cameraIDs, "", err
+}
+
+// printPlanResults prints the planned changes of every camera and a summary
+func printPlanResults(plan *ValidationResults) {
+	var outcomes []string
+	for _, cameraID := range plan.CameraOrder {
+		outcomes = append(outcomes, printPlanResult(cameraID, plan.CameraResults[cameraID]))
+	}
+	printPlanSummary(outcomes)
+}
+
+// Outcomes of planning one camera
+const (
+	planChange    = "change"
+	planUnchanged = "unchanged"
+	planFailed    = "failed"
+)
+
+// printPlanResult prints the planned changes of one camera and returns the outcome
+func printPlanResult(cameraID string, result *CameraResult) string {
+	profile := ""
+	if result.Profile != nil {
+		profile = fmt.Sprintf(" [%s]", result.Profile)
+	}
+
+	switch {
+	case result.Plan == nil:
+		fmt.Printf("   ❌ Camera %s%s: %v\n", cameraID, profile, result.Error)
+		return planFailed
+	case len(result.Plan.Changes) == 0:
+		fmt.Printf("   ✅ Camera %s%s: no changes\n", cameraID, profile)
+	default:
+		fmt.Printf("   📋 Camera %s%s: %d change(s)\n", cameraID, profile, len(result.Plan.Changes))
+	}
+
+	for _, change := range result.Plan.Changes {
+		fmt.Printf("      • %s: %s → %s\n", change.Setting, planValue(change.Current), planValue(change.Intended))
+	}
+	if result.Plan.ResolutionAdjusted {
+		fmt.Printf("      ⚠️  %dx%d is not available, using closest resolution %dx%d\n",
+			result.Plan.Requested.Width, result.Plan.Requested.Height,
+			result.Plan.Intended.Resolution.Width, result.Plan.Intended.Resolution.Height)
+	}
+	switch {
+	case result.Error != nil:
+		fmt.Printf("      ❌ %v\n", result.Error)
+		return planFailed
+	case len(result.Plan.Changes) == 0:
+		return planUnchanged
+	default:
+		return planChange
+	}
+}
+
+// printPlanSummary prints how many cameras would change
+func printPlanSummary(outcomes []string) {
+	counts := make(map[string]int)
+	for _, outcome := range outcomes {
+		counts[outcome]++
+	}
+	fmt.Printf("\n📈 Summary:\n")
+	fmt.Printf("   • %d to change, %d unchanged, %d failed\n", counts[planChange], counts[planUnchanged], counts[planFailed])
+}
+
+// planValue shows settings the camera does not report as a dash
+func planValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
 }
 
 // runExportResults exports validation results to CSV
 func runExportResults(outputFile string) error {
-	if lastValidationResults == nil {
+	if len(lastValidationResults) == 0 {
 		return fmt.Errorf("no validation results available to export. Run 'config apply' first")
 	}
 
@@ -407,6 +727,7 @@ func runShowConfig() error {
 	fmt.Printf("   • Frame Rate: %d FPS\n", config.FPS)
 	fmt.Printf("   • Bitrate: %d kbps\n", config.Bitrate)
 	fmt.Printf("   • Encoding: %s\n", config.Encoding)
+	printEncoderSettings(config.ToConfigData())
 	fmt.Printf("   • Last Updated: %s\n", config.LastUpdated)
 	fmt.Printf("   • Source: %s\n", config.Source)
 
@@ -415,11 +736,13 @@ func runShowConfig() error {
 
 // runSetConfig sets the saved configuration manually
 func runSetConfig(widthStr, heightStr, fpsStr, bitrateStr string) error {
-	return runSetConfigWithEncoding(widthStr, heightStr, fpsStr, bitrateStr, "H264")
+	return runSetConfigWithEncoding(widthStr, heightStr, fpsStr, bitrateStr, "H264", &ConfigData{})
 }
 
-// runSetConfigWithEncoding sets the saved configuration manually with encoding
-func runSetConfigWithEncoding(widthStr, heightStr, fpsStr, bitrateStr, encoding string) error {
+// runSetConfigWithEncoding sets the saved configuration manually with encoding. The optional
+// encoder settings (GOP, encoder profile, rate control, quality, encoding interval) are taken
+// from settings; zero values keep the camera's current values.
+func runSetConfigWithEncoding(widthStr, heightStr, fpsStr, bitrateStr, encoding string, settings *ConfigData) error {
 	width, err := strconv.Atoi(widthStr)
 	if err != nil {
 		return fmt.Errorf("invalid width value: %w", err)
@@ -449,8 +772,17 @@ func runSetConfigWithEncoding(widthStr, heightStr, fpsStr, bitrateStr, encoding
 	if err := configService.ValidateConfig(width, height, fps, bitrate, encoding); err != nil {
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
+	if settings.GOP < 0 || settings.Quality < 0 || settings.EncodingInterval < 0 {
+		return fmt.Errorf("invalid configuration: gop, quality and encoding interval must be 0 or greater")
+	}
+	settings.EncoderProfile = camera.NormalizeEncoderProfile(settings.EncoderProfile)
+	settings.RateControl = camera.NormalizeRateControl(settings.RateControl)
+	if settings.RateControl != "" && settings.RateControl != camera.RateControlCBR && settings.RateControl != camera.RateControlVBR {
+		return fmt.Errorf("invalid configuration: rate control '%s' is not supported. Valid options: CBR, VBR", settings.RateControl)
+	}
 
-	err = configService.UpdateManually(width, height, fps, bitrate, encoding)
+	settings.Width, settings.Height, settings.FPS, settings.Bitrate, settings.Encoding = width, height, fps, bitrate, encoding
+	err = configService.UpdateManually(settings)
 	if err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
@@ -459,21 +791,46 @@ func runSetConfigWithEncoding(widthStr, heightStr, fpsStr, bitrateStr, encoding
 	fmt.Printf("   • Frame Rate: %d FPS\n", fps)
 	fmt.Printf("   • Bitrate: %d kbps\n", bitrate)
 	fmt.Printf("   • Encoding: %s\n", encoding)
+	printEncoderSettings(settings)
 	return nil
 }
 
+// printEncoderSettings prints the optional encoder settings of a configuration that are set
+func printEncoderSettings(config *ConfigData) {
+	if config.GOP > 0 {
+		fmt.Printf("   • GOP: %d frames\n", config.GOP)
+	}
+	if config.EncoderProfile != "" {
+		fmt.Printf("   • Encoder Profile: %s\n", config.EncoderProfile)
+	}
+	if config.RateControl != "" {
+		fmt.Printf("   • Rate Control: %s\n", config.RateControl)
+	}
+	if config.Quality > 0 {
+		fmt.Printf("   • Quality: %d\n", config.Quality)
+	}
+	if config.EncodingInterval > 0 {
+		fmt.Printf("   • Encoding Interval: %d\n", config.EncodingInterval)
+	}
+}
+
 // runImportConfig imports configuration from CSV and saves it
 func runImportConfig(configCSV string) error {
 	fmt.Printf("📂 Importing configuration from: %s\n", configCSV)
 
 	configService := NewConfigService()
-	configData, err := cameraService.ImportConfigFromCSV(configCSV)
+	configs, err := cameraService.ImportConfigFromCSV(configCSV)
 	if err != nil {
 		return fmt.Errorf("failed to import configuration: %w", err)
 	}
 
-	fmt.Printf("⚙️  Configuration imported: %dx%d, %d FPS, %d kbps\n",
-		configData.Width, configData.Height, configData.FPS, configData.Bitrate)
+	// The saved configuration holds a single stream
+	configData := configs[0]
+	fmt.Printf("⚙️  Configuration imported%s: %dx%d, %d FPS, %d kbps\n",
+		profileLabel(configData.Profile), configData.Width, configData.Height, configData.FPS, configData.Bitrate)
+	if len(configs) > 1 {
+		fmt.Printf("⚠️  Only the first of %d rows is saved; use 'config apply' to apply every stream\n", len(configs))
+	}
 
 	// Save as current configuration
 	err = configService.ImportFromConfigData(configData, "csv")
@@ -487,6 +844,11 @@ func runImportConfig(configCSV string) error {
 
 // runApplyToSelected applies saved config to selected cameras
 func runApplyToSelected(cameraCSV string) error {
+	rollbackPolicy, err := camera.ParseRollbackPolicy(applyRollback)
+	if err != nil {
+		return err
+	}
+
 	// Step 1: Select cameras
 	fmt.Printf("📂 Loading camera selection from: %s\n", cameraCSV)
 	selection, err := cameraService.SelectCamerasFromCSV(cameraCSV)
@@ -528,22 +890,51 @@ func runApplyToSelected(cameraCSV string) error {
 		return fmt.Errorf("failed to initialize cameras: %w", err)
 	}
 
-	validation, err := cameraService.ApplyConfigToCameras(selection.SelectedCameraIDs, savedConfig.ToConfigData())
+	opts := pool.DefaultOptions().WithOverrides(applyConcurrency, applyTimeout)
+	validation, err := cameraService.ApplyConfigToCamerasWithOptions(selection.SelectedCameraIDs, savedConfig.ToConfigData(), opts, rollbackPolicy)
 	if err != nil {
 		return fmt.Errorf("failed to apply configuration: %w", err)
 	}
 
 	// Store results for potential export
-	lastValidationResults = validation
+	lastValidationResults = []*ValidationResults{validation}
 
 	// Step 5: Display results
-	fmt.Printf("\n📊 Configuration Results:\n")
+	printApplyResults(validation)
+
+	// Step 6: Offer to export results
+	if len(lastValidationResults) > 0 {
+		fmt.Printf("\n💾 Do you want to export validation results to CSV? (y/N): ")
+		scanner.Scan()
+		response = strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if response == "y" || response == "yes" {
+			defaultFilename := generateTimestampedFilename("validation_results.csv")
+			fmt.Printf("📄 Enter output filename (default: %s): ", defaultFilename)
+			scanner.Scan()
+			filename := strings.TrimSpace(scanner.Text())
+			if filename == "" {
+				filename = defaultFilename
+			}
+
+			return runExportResults(filename)
+		}
+	}
+
+	return nil
+}
+
+// printApplyResults prints the configuration and validation outcome of every camera and a summary
+func printApplyResults(validation *ValidationResults) {
+	fmt.Printf("\n📊 Configuration Results%s:\n", profileLabel(validation.Profile))
 
 	successCount := 0
 	failureCount := 0
 	validationPassCount := 0
 	validationFailCount := 0
-	for cameraID, result := range validation.CameraResults {
+	rollbackCount := 0
+
+	for _, cameraID := range validation.CameraOrder {
+		result := validation.CameraResults[cameraID]
 		status := "❌ FAILED"
 		if result.Success {
 			status = "✅ SUCCESS"
@@ -553,6 +944,9 @@ func runApplyToSelected(cameraCSV string) error {
 		}
 
 		fmt.Printf("   • Camera %s: %s", cameraID, status)
+		if result.Profile != nil {
+			fmt.Printf(" [%s]", result.Profile)
+		}
 		if !result.Success && result.Error != nil {
 			fmt.Printf(" - %s", result.Error.Error())
 		}
@@ -566,32 +960,35 @@ func runApplyToSelected(cameraCSV string) error {
 				fmt.Printf("      Validation: ❌ FAILED - %s\n", validationResult.Error)
 				validationFailCount++
 			}
+
+			if rollback := validationResult.Rollback; rollback != nil {
+				rollbackCount++
+				switch {
+				case rollback.Restored && rollback.Valid:
+					fmt.Printf("      Rollback: ↩️  %s\n", camera.RollbackSummary(*rollback))
+				case rollback.Restored:
+					fmt.Printf("      Rollback: ⚠️  %s - %s\n", camera.RollbackSummary(*rollback), rollback.Error)
+				default:
+					fmt.Printf("      Rollback: ❌ FAILED - %s\n", rollback.Error)
+				}
+			}
 		}
 	}
 
 	fmt.Printf("\n📈 Summary:\n")
 	fmt.Printf("   • Configuration: %d success, %d failed\n", successCount, failureCount)
 	fmt.Printf("   • Validation: %d passed, %d failed\n", validationPassCount, validationFailCount)
-
-	// Step 6: Offer to export results
-	if lastValidationResults != nil {
-		fmt.Printf("\n💾 Do you want to export validation results to CSV? (y/N): ")
-		scanner.Scan()
-		response = strings.ToLower(strings.TrimSpace(scanner.Text()))
-		if response == "y" || response == "yes" {
-			defaultFilename := generateTimestampedFilename("validation_results.csv")
-			fmt.Printf("📄 Enter output filename (default: %s): ", defaultFilename)
-			scanner.Scan()
-			filename := strings.TrimSpace(scanner.Text())
-			if filename == "" {
-				filename = defaultFilename
-			}
-
-			return runExportResults(filename)
-		}
+	if rollbackCount > 0 {
+		fmt.Printf("   • Rolled back: %d\n", rollbackCount)
 	}
+}
 
-	return nil
+// profileLabel returns " for profile X" for messages, or nothing for the default main stream
+func profileLabel(profile string) string {
+	if profile == "" {
+		return ""
+	}
+	return fmt.Sprintf(" for profile %s", profile)
 }
 
 // generateTimestampedFilename creates a filename with timestamp
@@ -633,7 +1030,9 @@ func runImportCameras(csvFile string) error {
 	if result.SuccessCount > 0 {
 		fmt.Printf("\n✨ Successfully added cameras:\n")
 		for _, rowResult := range result.Results {
-			if rowResult.Success {
+			if rowResult.Success && rowResult.Existing {
+				fmt.Printf("   • Camera ID: %s - %s (already in inventory)\n", rowResult.CameraID, rowResult.Camera.IP)
+			} else if rowResult.Success {
 				fmt.Printf("   • Camera ID: %s - %s\n", rowResult.CameraID, rowResult.Camera.IP)
 			}
 		}
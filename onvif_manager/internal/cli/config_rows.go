@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/pool"
+)
+
+// ConfigRowTarget is a row of a per-camera config CSV resolved to its inventoried camera
+type ConfigRowTarget struct {
+	Config   *ConfigData
+	CameraID string
+	Pass     int   // Index of the pass the row is applied in
+	Error    error // Set when the row cannot be applied
+}
+
+// ResolveConfigRows finds the inventoried camera of every per-camera config row. Rows are
+// only resolved to cameras in allowedIDs unless it is nil. Rows of one camera are spread over
+// passes so that every pass configures each camera at most once.
+func (cs *CameraService) ResolveConfigRows(configs []*ConfigData, allowedIDs []string) []*ConfigRowTarget {
+	var allowed map[string]bool
+	if allowedIDs != nil {
+		allowed = make(map[string]bool, len(allowedIDs))
+		for _, cameraID := range allowedIDs {
+			allowed[cameraID] = true
+		}
+	}
+
+	targets := make([]*ConfigRowTarget, 0, len(configs))
+	rowsPerCamera := make(map[string]int)
+	seen := make(map[string]int)
+	for _, config := range configs {
+		target := &ConfigRowTarget{Config: config}
+		targets = append(targets, target)
+
+		cameraID, err := resolveConfigRowCamera(config)
+		switch {
+		case err != nil:
+			target.Error = err
+			continue
+		case allowed != nil && !allowed[cameraID]:
+			target.Error = fmt.Errorf("camera %s is not among the selected cameras", cameraID)
+			continue
+		}
+
+		key := cameraID + "/" + camera.ProfileSelectorKey(config.Profile)
+		if previous, exists := seen[key]; exists {
+			target.Error = fmt.Errorf("row %d already targets camera %s and this profile", previous, cameraID)
+			continue
+		}
+		seen[key] = config.Row
+
+		target.CameraID = cameraID
+		target.Pass = rowsPerCamera[cameraID]
+		rowsPerCamera[cameraID]++
+	}
+	return targets
+}
+
+// resolveConfigRowCamera returns the inventoried camera a config row names by cam_id or ip
+func resolveConfigRowCamera(config *ConfigData) (string, error) {
+	if config.CameraID != "" {
+		cam, found := camera.DefaultRegistry().Camera(config.CameraID)
+		if !found {
+			return "", fmt.Errorf("camera with ID %s not found in the inventory", config.CameraID)
+		}
+		if config.IP != "" && cam.IP != config.IP {
+			return "", fmt.Errorf("camera %s has IP %s, not %s", cam.ID, cam.IP, config.IP)
+		}
+		return cam.ID, nil
+	}
+
+	cam, found := camera.FindCameraByIP(config.IP)
+	if !found {
+		return "", fmt.Errorf("no camera with IP %s in the inventory", config.IP)
+	}
+	return cam.ID, nil
+}
+
+// configRowPasses groups the resolved rows by pass, mapping each camera to its row
+func configRowPasses(targets []*ConfigRowTarget) ([][]string, []map[string]*ConfigData) {
+	var order [][]string
+	var configs []map[string]*ConfigData
+	for _, target := range targets {
+		if target.Error != nil {
+			continue
+		}
+		for len(order) <= target.Pass {
+			order = append(order, nil)
+			configs = append(configs, make(map[string]*ConfigData))
+		}
+		order[target.Pass] = append(order[target.Pass], target.CameraID)
+		configs[target.Pass][target.CameraID] = target.Config
+	}
+	return order, configs
+}
+
+// ApplyConfigRows applies every resolved row of a per-camera config CSV as one batch and
+// returns one result set per pass. Cameras with several rows are configured once per pass.
+func (cs *CameraService) ApplyConfigRows(targets []*ConfigRowTarget, opts pool.Options, rollbackPolicy string) []*ValidationResults {
+	order, configs := configRowPasses(targets)
+	log.Printf("Applying %d config rows in %d pass(es) (concurrency %d, timeout %s, rollback %s)", len(targets), len(order), opts.Concurrency, opts.Timeout, rollbackPolicy)
+
+	results := make([]*ValidationResults, 0, len(order))
+	for pass, cameraIDs := range order {
+		results = append(results, cs.applyConfigs(cameraIDs, configs[pass], "", opts, rollbackPolicy))
+	}
+	return results
+}
+
+// PlanConfigRows reports what applying every resolved row would change, one result set per pass
+func (cs *CameraService) PlanConfigRows(targets []*ConfigRowTarget, opts pool.Options) []*ValidationResults {
+	order, configs := configRowPasses(targets)
+
+	results := make([]*ValidationResults, 0, len(order))
+	for pass, cameraIDs := range order {
+		results = append(results, cs.planConfigs(cameraIDs, configs[pass], "", opts))
+	}
+	return results
+}
+
+// rowCameraIDs returns the cameras the resolved rows target, in the order of the rows
+func rowCameraIDs(targets []*ConfigRowTarget) []string {
+	var cameraIDs []string
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		if target.Error == nil && !seen[target.CameraID] {
+			seen[target.CameraID] = true
+			cameraIDs = append(cameraIDs, target.CameraID)
+		}
+	}
+	return cameraIDs
+}
+
+// rowTargetsOf returns the resolved rows that target one of the cameras
+func rowTargetsOf(targets []*ConfigRowTarget, cameraIDs []string) []*ConfigRowTarget {
+	cameras := make(map[string]bool, len(cameraIDs))
+	for _, cameraID := range cameraIDs {
+		cameras[cameraID] = true
+	}
+
+	var selected []*ConfigRowTarget
+	for _, target := range targets {
+		if target.Error == nil && cameras[target.CameraID] {
+			selected = append(selected, target)
+		}
+	}
+	return selected
+}
+
+// configRowLabel names a config row and its target in messages; rows resolved from a
+// template have no row number
+func configRowLabel(target *ConfigRowTarget) string {
+	config := target.Config
+	camera := config.CameraID
+	if camera == "" {
+		camera = config.IP
+	}
+	if target.CameraID != "" && target.CameraID != camera {
+		camera = fmt.Sprintf("%s (%s)", target.CameraID, camera)
+	}
+	settings := fmt.Sprintf("%s%s: %dx%d, %d FPS, %d kbps",
+		camera, profileLabel(config.Profile), config.Width, config.Height, config.FPS, config.Bitrate)
+	if config.Row == 0 {
+		return "Camera " + settings
+	}
+	return fmt.Sprintf("Row %d, camera %s", config.Row, settings)
+}
+
+// printConfigRowResults prints the configuration and validation outcome of every row and a summary
+func printConfigRowResults(targets []*ConfigRowTarget, passes []*ValidationResults) {
+	fmt.Printf("\n📊 Configuration Results per row:\n")
+
+	successCount, failureCount, validationPassCount, validationFailCount, rollbackCount := 0, 0, 0, 0, 0
+	for _, target := range targets {
+		fmt.Printf("   • %s\n", configRowLabel(target))
+		if target.Error != nil {
+			fmt.Printf("      ❌ SKIPPED - %v\n", target.Error)
+			failureCount++
+			continue
+		}
+
+		pass := passes[target.Pass]
+		result := pass.CameraResults[target.CameraID]
+		if !result.Success {
+			fmt.Printf("      ❌ FAILED - %v\n", result.Error)
+			failureCount++
+			continue
+		}
+		successCount++
+		if result.Profile != nil {
+			fmt.Printf("      ✅ SUCCESS [%s]\n", result.Profile)
+		} else {
+			fmt.Printf("      ✅ SUCCESS\n")
+		}
+
+		validation, exists := pass.ValidationResults[target.CameraID]
+		if !exists {
+			continue
+		}
+		if validation.IsValid {
+			fmt.Printf("      Validation: ✅ PASSED\n")
+			validationPassCount++
+		} else {
+			fmt.Printf("      Validation: ❌ FAILED - %s\n", validation.Error)
+			validationFailCount++
+		}
+		if rollback := validation.Rollback; rollback != nil {
+			rollbackCount++
+			fmt.Printf("      Rollback: %s\n", camera.RollbackSummary(*rollback))
+		}
+	}
+
+	fmt.Printf("\n📈 Summary:\n")
+	fmt.Printf("   • Rows: %d configured, %d failed or skipped\n", successCount, failureCount)
+	fmt.Printf("   • Validation: %d passed, %d failed\n", validationPassCount, validationFailCount)
+	if rollbackCount > 0 {
+		fmt.Printf("   • Rolled back: %d\n", rollbackCount)
+	}
+}
+
+// printConfigRowPlans prints the planned changes of every row and a summary
+func printConfigRowPlans(targets []*ConfigRowTarget, passes []*ValidationResults) {
+	var outcomes []string
+	for _, target := range targets {
+		fmt.Printf("\n🔍 %s\n", configRowLabel(target))
+		if target.Error != nil {
+			fmt.Printf("   ❌ Skipped: %v\n", target.Error)
+			outcomes = append(outcomes, planFailed)
+			continue
+		}
+		outcomes = append(outcomes, printPlanResult(target.CameraID, passes[target.Pass].CameraResults[target.CameraID]))
+	}
+	printPlanSummary(outcomes)
+}
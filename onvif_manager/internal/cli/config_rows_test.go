@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProcessConfigData(t *testing.T) {
+	tests := []struct {
+		name     string
+		csv      string
+		wantRows int
+		wantErr  string
+	}{
+		{name: "one configuration", csv: "width,height,fps\n1920,1080,25", wantRows: 1},
+		{name: "one row per profile", csv: "profile,width,height,fps\nmain,1920,1080,25\nsub,640,480,10", wantRows: 2},
+		{name: "several rows without a profile", csv: "width,height,fps\n1920,1080,25\n640,480,10", wantErr: "profile column is required"},
+		{name: "same profile twice", csv: "stream,width,height,fps\nSub,1920,1080,25\nsub,640,480,10", wantErr: "rows 2 and 3"},
+		{name: "empty profile is the main stream", csv: "profile,width,height,fps\n,1920,1080,25\nmain,640,480,10", wantErr: "rows 2 and 3"},
+		{name: "per camera", csv: "ip,width,height,fps\n10.0.0.1,1920,1080,25\n10.0.0.2,1920,1080,25", wantRows: 2},
+		{name: "per camera and profile", csv: "cam_id,profile,width,height,fps\n1,main,1920,1080,25\n1,sub,640,480,10\n2,,1920,1080,25", wantRows: 3},
+		{name: "same camera and profile twice", csv: "cam_ip,profile,width,height,fps\n10.0.0.1,,1920,1080,25\n10.0.0.1,MAIN,1280,720,25", wantErr: "rows 2 and 3"},
+		{name: "per camera row without a camera", csv: "ip,width,height,fps\n,1920,1080,25", wantErr: "ip or cam_id value is required"},
+		{name: "blank rows are skipped", csv: "width,height,fps\n,,\n1920,1080,25", wantRows: 1},
+		{name: "missing column", csv: "width,height\n1920,1080", wantErr: "'fps' not found"},
+		{name: "invalid value", csv: "width,height,fps\n1920,tall,25", wantErr: "row 2: invalid height"},
+	}
+
+	cs := NewCameraService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records [][]string
+			for _, line := range strings.Split(tt.csv, "\n") {
+				records = append(records, strings.Split(line, ","))
+			}
+
+			configs, err := cs.processConfigData(records)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("processConfigData() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("processConfigData() error = %v", err)
+			}
+			if len(configs) != tt.wantRows {
+				t.Errorf("processConfigData() returned %d configurations, want %d", len(configs), tt.wantRows)
+			}
+		})
+	}
+}
@@ -40,29 +40,26 @@ func (cs *ConfigService) SaveConfig(config *SavedConfig) error {
 // ImportFromConfigData updates the in-memory config
 func (cs *ConfigService) ImportFromConfigData(configData *ConfigData, source string) error {
 	inMemoryConfig = SavedConfig{
-		Width:       configData.Width,
-		Height:      configData.Height,
-		FPS:         configData.FPS,
-		Bitrate:     configData.Bitrate,
-		Encoding:    configData.Encoding,
-		Source:      source,
-		LastUpdated: time.Now().Format("2006-01-02 15:04:05"),
+		Width:            configData.Width,
+		Height:           configData.Height,
+		FPS:              configData.FPS,
+		Bitrate:          configData.Bitrate,
+		Encoding:         configData.Encoding,
+		Profile:          configData.Profile,
+		GOP:              configData.GOP,
+		EncoderProfile:   configData.EncoderProfile,
+		RateControl:      configData.RateControl,
+		Quality:          configData.Quality,
+		EncodingInterval: configData.EncodingInterval,
+		Source:           source,
+		LastUpdated:      time.Now().Format("2006-01-02 15:04:05"),
 	}
 	return nil
 }
 
 // UpdateManually updates the saved config with manual values
-func (cs *ConfigService) UpdateManually(width, height, fps, bitrate int, encoding string) error {
-	savedConfig := &SavedConfig{
-		Width:    width,
-		Height:   height,
-		FPS:      fps,
-		Bitrate:  bitrate,
-		Encoding: encoding,
-		Source:   "manual",
-	}
-
-	return cs.SaveConfig(savedConfig)
+func (cs *ConfigService) UpdateManually(configData *ConfigData) error {
+	return cs.ImportFromConfigData(configData, "manual")
 }
 
 // GetDefaultConfig returns the current in-memory configuration
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/discovery"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags of the discover command
+var (
+	discoverTimeout  time.Duration
+	discoverAddress  string
+	discoverAdd      bool
+	discoverUsername string
+	discoverPassword string
+)
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Find ONVIF cameras on the local network",
+	Long: `Send a WS-Discovery probe and list the ONVIF cameras that answer, showing which of
+them are already in the inventory. Use --add with credentials to add the new cameras.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDiscover()
+	},
+}
+
+func init() {
+	discoverCmd.Flags().DurationVar(&discoverTimeout, "timeout", discovery.DefaultTimeout, "how long to wait for cameras to answer")
+	discoverCmd.Flags().StringVar(&discoverAddress, "address", "", fmt.Sprintf("send the probe to this host:port instead of %s", discovery.MulticastAddress))
+	discoverCmd.Flags().BoolVar(&discoverAdd, "add", false, "add the discovered cameras that are not in the inventory yet")
+	discoverCmd.Flags().StringVar(&discoverUsername, "username", "", "username for the added cameras (required with --add)")
+	discoverCmd.Flags().StringVar(&discoverPassword, "password", "", "password for the added cameras")
+
+	RootCmd.AddCommand(discoverCmd)
+}
+
+// runDiscover probes the network and optionally adds the new cameras to the inventory
+func runDiscover() error {
+	if discoverAdd && discoverUsername == "" {
+		return fmt.Errorf("--username is required with --add")
+	}
+
+	fmt.Printf("🔍 Discovering ONVIF cameras (waiting %s)...\n", discoverTimeout)
+
+	devices, err := discovery.Probe(context.Background(), discovery.Options{
+		Timeout: discoverTimeout,
+		Address: discoverAddress,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to discover cameras: %w", err)
+	}
+
+	if len(devices) == 0 {
+		fmt.Println("No ONVIF cameras answered.")
+		return nil
+	}
+
+	candidates := discovery.MatchInventory(devices)
+
+	fmt.Printf("\n📋 Found %d camera(s):\n\n", len(candidates))
+	fmt.Printf("%-15s %-6s %-20s %-20s %-10s %s\n", "IP", "Port", "Name", "Hardware", "Inventory", "XAddrs")
+	fmt.Println(strings.Repeat("-", 100))
+
+	var newDevices []discovery.Device
+	for _, candidate := range candidates {
+		inventory := "new"
+		if candidate.InInventory {
+			inventory = "ID " + candidate.CameraID
+		} else {
+			newDevices = append(newDevices, candidate.Device)
+		}
+		fmt.Printf("%-15s %-6d %-20s %-20s %-10s %s\n",
+			candidate.IP, candidate.Port, candidate.Name, candidate.Hardware, inventory, strings.Join(candidate.XAddrs, " "))
+	}
+
+	if !discoverAdd {
+		if len(newDevices) > 0 {
+			fmt.Printf("\n💡 %d camera(s) are not in the inventory. Run again with --add --username <user> --password <pass> to add them.\n", len(newDevices))
+		}
+		return nil
+	}
+
+	if len(newDevices) == 0 {
+		fmt.Println("\n✅ All discovered cameras are already in the inventory.")
+		return nil
+	}
+
+	fmt.Printf("\n➕ Adding %d camera(s) to the inventory...\n", len(newDevices))
+	for _, result := range discovery.AddToInventory(newDevices, discoverUsername, discoverPassword) {
+		if result.Success {
+			fmt.Printf("   • Camera ID: %s - %s\n", result.CameraID, result.IP)
+		} else {
+			fmt.Printf("   ❌ %s: %s\n", result.IP, result.Error)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/history"
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/pkg/models"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags of the history commands
+var (
+	historyConcurrency int
+	historyTimeout     time.Duration
+	historyRevertYes   bool
+)
+
+// historyCmd groups the config history commands
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Track encoder config changes made outside onvif-manager",
+	Long: `Every encoder configuration onvif-manager applies is recorded, and the server polls the
+cameras for changes. These commands show the recorded history, report cameras whose
+configuration drifted from the one last applied and put the applied configuration back.`,
+}
+
+// historyDriftCmd represents the history drift command
+var historyDriftCmd = &cobra.Command{
+	Use:   "drift [camera-id...]",
+	Short: "Read the encoder configs of cameras and report drift from the last applied config",
+	Long: `Read the encoder configurations of the given cameras, or of every camera in the inventory
+when no IDs are given, record them in the history and list the settings that differ from
+the configuration onvif-manager last applied, with the time the change was first seen.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHistoryDrift(args)
+	},
+}
+
+// historyShowCmd represents the history show command
+var historyShowCmd = &cobra.Command{
+	Use:   "show [camera-id]",
+	Short: "Show the recorded encoder config history of a camera",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHistoryShow(args[0])
+	},
+}
+
+// historyRevertCmd represents the history revert command
+var historyRevertCmd = &cobra.Command{
+	Use:   "revert [camera-id] [config-token]",
+	Short: "Write the last applied encoder config back to a camera",
+	Long: `Write the configuration onvif-manager last applied back to every encoder configuration of
+the camera that drifted from it, or only to the given encoder configuration.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configToken := ""
+		if len(args) > 1 {
+			configToken = args[1]
+		}
+		return runHistoryRevert(args[0], configToken)
+	},
+}
+
+func init() {
+	historyDriftCmd.Flags().IntVar(&historyConcurrency, "concurrency", 0, fmt.Sprintf("number of cameras worked on in parallel (default %d, env %s)", pool.DefaultConcurrency, pool.ConcurrencyEnvVar))
+	historyDriftCmd.Flags().DurationVar(&historyTimeout, "timeout", 0, fmt.Sprintf("maximum time per camera (default %s, env %s)", pool.DefaultTimeout, pool.TimeoutEnvVar))
+	cameraSelection.register(historyDriftCmd)
+	historyRevertCmd.Flags().BoolVarP(&historyRevertYes, "yes", "y", false, "do not ask for confirmation")
+
+	historyCmd.AddCommand(historyDriftCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyRevertCmd)
+	RootCmd.AddCommand(historyCmd)
+}
+
+// runHistoryDrift polls the selected cameras and reports their drift
+func runHistoryDrift(cameraIDs []string) error {
+	cameraIDs, err := inventoryCameraIDs(cameraIDs)
+	if err != nil {
+		return err
+	}
+	if len(cameraIDs) == 0 {
+		fmt.Println("No cameras found in the inventory.")
+		return nil
+	}
+
+	fmt.Printf("🔍 Reading the encoder configs of %d camera(s)...\n\n", len(cameraIDs))
+	opts := pool.DefaultOptions().WithOverrides(historyConcurrency, historyTimeout)
+	results := history.Poll(context.Background(), history.Shared.Get(), cameraIDs, opts)
+
+	for _, cameraID := range results.CameraOrder {
+		result := results.CameraResults[cameraID]
+		if !result.Success {
+			fmt.Printf("❌ Camera %s: %s\n", cameraID, result.Error)
+			continue
+		}
+		for _, config := range result.Configs {
+			name := fmt.Sprintf("Camera %s, %s (%s)", cameraID, strings.Join(config.Profiles, ", "), config.ConfigToken)
+			switch {
+			case config.AppliedAt == nil:
+				fmt.Printf("ℹ️  %s: %s, never applied by onvif-manager\n", name, historyConfigSummary(config.Config))
+			case len(config.Drift) > 0:
+				fmt.Printf("⚠️  %s: changed %s, differs from the config applied %s\n",
+					name, config.ChangedAt.Local().Format("2006-01-02 15:04:05"), config.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+				for _, change := range config.Drift {
+					fmt.Printf("      %-17s %s (applied %s)\n", change.Setting, planValue(change.Current), planValue(change.Intended))
+				}
+			default:
+				fmt.Printf("✅ %s: matches the config applied %s\n", name, config.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+			}
+		}
+	}
+
+	summary := results.Summary
+	fmt.Printf("\n📊 Summary:\n")
+	fmt.Printf("   • Cameras: %d\n", summary.TotalCameras)
+	fmt.Printf("   • Changed since the last check: %d\n", summary.ChangedCams)
+	fmt.Printf("   • Drifted from the applied config: %d\n", summary.DriftedCams)
+	fmt.Printf("   • Failed: %d\n", summary.FailedCams)
+	if summary.DriftedCams > 0 {
+		fmt.Println("\nUse 'onvif-manager history revert [camera-id]' to put the applied config back.")
+	}
+	return nil
+}
+
+// runHistoryShow prints the recorded history of a camera
+func runHistoryShow(cameraID string) error {
+	histories, err := history.Shared.Get().History(cameraID)
+	if err != nil {
+		return err
+	}
+	if len(histories) == 0 {
+		fmt.Printf("No config history recorded for camera %s.\n", cameraID)
+		return nil
+	}
+
+	for _, configHistory := range histories {
+		fmt.Printf("📜 Camera %s, encoder config %s\n", cameraID, configHistory.ConfigToken)
+		for _, entry := range configHistory.Entries {
+			icon := "🔧"
+			if entry.Source == history.SourceObserved {
+				icon = "👀"
+			}
+			fmt.Printf("   %s %s  %-8s %s (last seen %s)\n", icon, entry.ObservedAt.Local().Format("2006-01-02 15:04:05"),
+				entry.Source, historyConfigSummary(entry.Config), entry.LastSeenAt.Local().Format("2006-01-02 15:04:05"))
+			for _, change := range entry.Changes {
+				fmt.Printf("         %-17s %s → %s\n", change.Setting, planValue(change.Current), planValue(change.Intended))
+			}
+			if len(entry.Drift) > 0 {
+				fmt.Printf("         ⚠️  differs from the applied config in %d setting(s)\n", len(entry.Drift))
+			}
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// runHistoryRevert writes the last applied config back to a camera
+func runHistoryRevert(cameraID, configToken string) error {
+	target := "every drifted encoder config"
+	if configToken != "" {
+		target = "encoder config " + configToken
+	}
+	if !historyRevertYes && !askForConfirmation(fmt.Sprintf("Write the last applied config back to %s of camera %s?", target, cameraID)) {
+		fmt.Println("Revert cancelled.")
+		return nil
+	}
+
+	results, err := history.Revert(history.Shared.Get(), cameraID, configToken)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Printf("✅ Camera %s has not drifted from the applied config, nothing to revert.\n", cameraID)
+		return nil
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Success {
+			fmt.Printf("✅ %s reverted to %s\n", result.ConfigToken, historyConfigSummary(result.Config))
+		} else {
+			failed++
+			fmt.Printf("❌ %s: %s\n", result.ConfigToken, result.Error)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d encoder config(s) could not be reverted", failed)
+	}
+	return nil
+}
+
+// historyConfigSummary formats the main settings of an encoder config on one line
+func historyConfigSummary(config models.EncoderConfig) string {
+	return fmt.Sprintf("%dx%d @ %d fps, %d kbps, %s", config.Resolution.Width, config.Resolution.Height,
+		config.FPS, config.Bitrate, camera.NormalizeEncoding(config.Encoding))
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+
+	"github.com/spf13/cobra"
+)
+
+// cameraSelectorFlags are the --group, --tag and --selector flags that pick cameras from the inventory
+type cameraSelectorFlags struct {
+	groups   []string
+	tags     []string
+	selector string
+}
+
+// cameraSelection holds the selector flags of the command being run
+var cameraSelection cameraSelectorFlags
+
+// register adds the selector flags to a command
+func (f *cameraSelectorFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&f.groups, "group", nil, "only cameras in one of these groups (repeatable or comma separated)")
+	cmd.Flags().StringSliceVar(&f.tags, "tag", nil, "only cameras with one of these tags (repeatable or comma separated)")
+	cmd.Flags().StringVar(&f.selector, "selector", "", "only cameras matching a selector expression, e.g. group=lobby,tag!=ptz")
+}
+
+// isSet reports whether any selector flag was given
+func (f *cameraSelectorFlags) isSet() bool {
+	return len(f.groups) > 0 || len(f.tags) > 0 || f.selector != ""
+}
+
+// cameraIDs returns the inventoried cameras matching the selector flags
+func (f *cameraSelectorFlags) cameraIDs() ([]string, error) {
+	groups, err := camera.NormalizeLabels(f.groups)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --group: %w", err)
+	}
+	tags, err := camera.NormalizeLabels(f.tags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --tag: %w", err)
+	}
+	selector, err := camera.ParseSelector(f.selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var cameraIDs []string
+	for _, cam := range camera.SelectCameras(camera.LabelSelector(groups, tags).And(selector)) {
+		cameraIDs = append(cameraIDs, cam.ID)
+	}
+	return cameraIDs, nil
+}
+
+// groupCmd groups the camera group commands
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Organize cameras in named groups",
+	Long: `Groups name the sites, buildings, floors, models or other sets a camera belongs to. A camera
+can be in several groups. Most commands accept --group to work on the cameras of a group.`,
+}
+
+// groupListCmd represents the group list command
+var groupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the groups with their cameras",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printLabelSets("groups", camera.GetGroups())
+	},
+}
+
+// groupAddCmd represents the group add command
+var groupAddCmd = &cobra.Command{
+	Use:   "add [group] [camera-id...]",
+	Short: "Add cameras to a group, creating it if needed",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpdateGroup(args[0], args[1:], camera.AddLabels)
+	},
+}
+
+// groupRemoveCmd represents the group remove command
+var groupRemoveCmd = &cobra.Command{
+	Use:   "remove [group] [camera-id...]",
+	Short: "Remove cameras from a group",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpdateGroup(args[0], args[1:], camera.RemoveLabels)
+	},
+}
+
+// groupDeleteCmd represents the group delete command
+var groupDeleteCmd = &cobra.Command{
+	Use:   "delete [group]",
+	Short: "Remove a group from every camera",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpdateGroup(args[0], inventoryIDs(), camera.RemoveLabels)
+	},
+}
+
+// tagCmd groups the camera tag commands
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Label cameras with tags",
+	Long:  `Tags are free-form labels such as outdoor or ptz. Most commands accept --tag to work on the cameras with a tag.`,
+}
+
+// tagListCmd represents the tag list command
+var tagListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the tags with their cameras",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printLabelSets("tags", camera.GetTags())
+	},
+}
+
+// tagAddCmd represents the tag add command
+var tagAddCmd = &cobra.Command{
+	Use:   "add [camera-id] [tag...]",
+	Short: "Add tags to a camera",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpdateTags(args[0], args[1:], camera.AddLabels)
+	},
+}
+
+// tagRemoveCmd represents the tag remove command
+var tagRemoveCmd = &cobra.Command{
+	Use:   "remove [camera-id] [tag...]",
+	Short: "Remove tags from a camera",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpdateTags(args[0], args[1:], camera.RemoveLabels)
+	},
+}
+
+func init() {
+	groupCmd.AddCommand(groupListCmd)
+	groupCmd.AddCommand(groupAddCmd)
+	groupCmd.AddCommand(groupRemoveCmd)
+	groupCmd.AddCommand(groupDeleteCmd)
+	RootCmd.AddCommand(groupCmd)
+
+	tagCmd.AddCommand(tagListCmd)
+	tagCmd.AddCommand(tagAddCmd)
+	tagCmd.AddCommand(tagRemoveCmd)
+	RootCmd.AddCommand(tagCmd)
+}
+
+// runUpdateGroup adds a group to or removes it from the given cameras
+func runUpdateGroup(name string, cameraIDs []string, update func(labels, names []string) []string) error {
+	group, err := camera.NormalizeLabel(name)
+	if err != nil {
+		return err
+	}
+	if err := camera.UpdateCameraLabels(cameraIDs, func(cam *models.Camera) {
+		cam.Groups = update(cam.Groups, []string{group})
+	}); err != nil {
+		return err
+	}
+
+	for _, set := range camera.GetGroups() {
+		if set.Name == group {
+			fmt.Printf("✅ Group %s: cameras %s\n", group, strings.Join(set.CameraIDs, ", "))
+			return nil
+		}
+	}
+	fmt.Printf("✅ Group %s has no cameras\n", group)
+	return nil
+}
+
+// runUpdateTags adds tags to or removes them from a camera
+func runUpdateTags(cameraID string, names []string, update func(labels, names []string) []string) error {
+	tags, err := camera.NormalizeLabels(names)
+	if err != nil {
+		return err
+	}
+	if err := camera.UpdateCameraLabels([]string{cameraID}, func(cam *models.Camera) {
+		cam.Tags = update(cam.Tags, tags)
+	}); err != nil {
+		return err
+	}
+
+	cam, _ := camera.DefaultRegistry().Camera(cameraID)
+	if len(cam.Tags) == 0 {
+		fmt.Printf("✅ Camera %s has no tags\n", cameraID)
+		return nil
+	}
+	fmt.Printf("✅ Camera %s: tags %s\n", cameraID, strings.Join(cam.Tags, ", "))
+	return nil
+}
+
+// printLabelSets prints groups or tags with their cameras
+func printLabelSets(kind string, sets []camera.LabelSet) error {
+	if len(sets) == 0 {
+		fmt.Printf("No %s defined.\n", kind)
+		return nil
+	}
+	fmt.Printf("%-20s %-8s %s\n", "Name", "Cameras", "IDs")
+	fmt.Println(strings.Repeat("-", 70))
+	for _, set := range sets {
+		fmt.Printf("%-20s %-8d %s\n", set.Name, len(set.CameraIDs), strings.Join(set.CameraIDs, ", "))
+	}
+	return nil
+}
+
+// inventoryIDs returns the IDs of every camera in the inventory
+func inventoryIDs() []string {
+	var cameraIDs []string
+	for _, cam := range camera.GetAllCameras() {
+		cameraIDs = append(cameraIDs, cam.ID)
+	}
+	return cameraIDs
+}
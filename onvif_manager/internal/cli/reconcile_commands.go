@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/internal/backend/reconcile"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags of the reconcile command
+var (
+	reconcileDryRun      bool
+	reconcileConcurrency int
+	reconcileTimeout     time.Duration
+)
+
+// reconcileCmd represents the reconcile command
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile [desired-state.yaml]",
+	Short: "Bring cameras in line with a desired-state file",
+	Long: `Read the desired encoder settings per profile from a YAML file, compare them with the
+actual configuration of each camera and change only the settings that differ. Changed
+profiles are validated by analyzing their stream. Use --dry-run to only report the drift.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReconcile(args[0])
+	},
+}
+
+func init() {
+	reconcileCmd.Flags().BoolVar(&reconcileDryRun, "dry-run", false, "only report the settings that differ, do not change the cameras")
+	reconcileCmd.Flags().IntVar(&reconcileConcurrency, "concurrency", 0, fmt.Sprintf("number of cameras worked on in parallel (default %d, env %s)", pool.DefaultConcurrency, pool.ConcurrencyEnvVar))
+	reconcileCmd.Flags().DurationVar(&reconcileTimeout, "timeout", 0, fmt.Sprintf("maximum time per camera (default %s, env %s)", pool.DefaultTimeout, pool.TimeoutEnvVar))
+
+	RootCmd.AddCommand(reconcileCmd)
+}
+
+// runReconcile reconciles the cameras of a desired-state file
+func runReconcile(path string) error {
+	state, err := reconcile.LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if reconcileDryRun {
+		fmt.Printf("🔍 Checking %d camera(s) against %s...\n", len(state.Cameras), path)
+	} else {
+		fmt.Printf("🔄 Reconciling %d camera(s) with %s...\n", len(state.Cameras), path)
+	}
+
+	opts := pool.DefaultOptions().WithOverrides(reconcileConcurrency, reconcileTimeout)
+	results := reconcile.Run(context.Background(), state, !reconcileDryRun, opts)
+
+	fmt.Println()
+	for _, cameraID := range results.CameraOrder {
+		result := results.CameraResults[cameraID]
+		fmt.Printf("%s Camera %s: %s\n", reconcileIcon(result.Status), cameraID, result.Status)
+		if result.Error != "" {
+			fmt.Printf("   %s\n", result.Error)
+		}
+		for _, profile := range result.Profiles {
+			fmt.Printf("   %s Profile %s: %s\n", reconcileIcon(profile.Status), profile.Profile, profile.Status)
+			for _, change := range profile.Changes {
+				fmt.Printf("      %-17s %s → %s\n", change.Setting, planValue(change.Current), planValue(change.Intended))
+			}
+			if profile.ResolutionAdjusted {
+				fmt.Printf("      ⚠️  desired resolution not offered, the closest one is used\n")
+			}
+			if profile.Error != "" {
+				fmt.Printf("      %s\n", profile.Error)
+			}
+		}
+	}
+
+	summary := results.Summary
+	fmt.Printf("\n📊 Summary:\n")
+	fmt.Printf("   • Cameras: %d\n", summary.TotalCameras)
+	fmt.Printf("   • In sync: %d\n", summary.InSyncCams)
+	if reconcileDryRun {
+		fmt.Printf("   • Drifted: %d (%d setting(s))\n", summary.DriftedCams, summary.Changes)
+	} else {
+		fmt.Printf("   • Reconciled: %d (%d setting(s))\n", summary.ReconciledCams, summary.Changes)
+	}
+	fmt.Printf("   • Failed: %d\n", summary.FailedCams)
+
+	if summary.FailedCams > 0 {
+		return fmt.Errorf("%d camera(s) could not be reconciled", summary.FailedCams)
+	}
+	return nil
+}
+
+// reconcileIcon returns the icon shown for a reconcile status
+func reconcileIcon(status string) string {
+	switch status {
+	case reconcile.StatusInSync:
+		return "✅"
+	case reconcile.StatusReconciled:
+		return "🔧"
+	case reconcile.StatusDrifted:
+		return "⚠️ "
+	default:
+		return "❌"
+	}
+}
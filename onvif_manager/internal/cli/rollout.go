@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"onvif_manager/internal/backend/rollout"
+)
+
+// Staged rollout flags of config apply
+var (
+	applyCanary           string
+	applyWaveSize         string
+	applyMaxFailureRate   float64
+	applyPauseAfterCanary bool
+)
+
+func init() {
+	applyConfigCmd.Flags().StringVar(&applyCanary, "canary", "", fmt.Sprintf("roll out in waves, starting with this many cameras, e.g. 2 or 5%% (default %s with --wave-size)", rollout.DefaultCanary))
+	applyConfigCmd.Flags().StringVar(&applyWaveSize, "wave-size", "", fmt.Sprintf("cameras per wave after the canary, e.g. 10 or 25%% (default %s with --canary)", rollout.DefaultWaveSize))
+	applyConfigCmd.Flags().Float64Var(&applyMaxFailureRate, "max-failure-rate", 0, "highest percentage of failed cameras before the rollout pauses (default 0, any failure pauses)")
+	applyConfigCmd.Flags().BoolVar(&applyPauseAfterCanary, "pause-after-canary", false, "ask before continuing after the canary wave")
+}
+
+// stagedRollout reports whether config apply rolls out in waves
+func stagedRollout() bool {
+	return applyCanary != "" || applyWaveSize != ""
+}
+
+// rolloutOptions returns the rollout options of the flags
+func rolloutOptions() rollout.Options {
+	return rollout.Options{
+		Canary:           applyCanary,
+		WaveSize:         applyWaveSize,
+		MaxFailureRate:   applyMaxFailureRate,
+		PauseAfterCanary: applyPauseAfterCanary,
+	}
+}
+
+// rolloutWaves splits the cameras into waves and prints them
+func rolloutWaves(cameraIDs []string) ([][]string, error) {
+	waves, err := rollout.Waves(cameraIDs, rolloutOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("🌊 Staged rollout in %d wave(s), pausing when more than %g%% of the cameras fail:\n", len(waves), applyMaxFailureRate)
+	for i, wave := range waves {
+		fmt.Printf("   • %s: %s\n", waveLabel(i+1), strings.Join(wave, ", "))
+	}
+	return waves, nil
+}
+
+// runRolloutWaves applies to one wave after the other with apply. When the rollout pauses
+// the user is asked whether to continue. It returns the results of every wave that ran.
+func runRolloutWaves(scanner *bufio.Scanner, waves [][]string, apply func(cameraIDs []string) []*ValidationResults) []*ValidationResults {
+	control := rollout.NewPromptControl(func(reason string) bool {
+		fmt.Printf("\n⏸️  Rollout paused: %s\n", reason)
+		fmt.Printf("🤔 Continue with the next wave? (y/N): ")
+		scanner.Scan()
+		response := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		return response == "y" || response == "yes"
+	})
+
+	var results []*ValidationResults
+	outcome := rollout.Run(context.Background(), waves, rolloutOptions(), control, func(ctx context.Context, wave int, cameraIDs []string) []string {
+		fmt.Printf("\n🌊 %s of %d: %s\n", waveLabel(wave), len(waves), strings.Join(cameraIDs, ", "))
+		waveResults := apply(cameraIDs)
+		results = append(results, waveResults...)
+		return failedCameras(cameraIDs, waveResults)
+	})
+
+	printRolloutSummary(outcome)
+	return results
+}
+
+// failedCameras returns the cameras that could not be configured or failed validation
+func failedCameras(cameraIDs []string, results []*ValidationResults) []string {
+	var failed []string
+	for _, cameraID := range cameraIDs {
+		for _, result := range results {
+			cameraResult, configured := result.CameraResults[cameraID]
+			validation, validated := result.ValidationResults[cameraID]
+			if (configured && !cameraResult.Success) || (validated && !validation.IsValid) {
+				failed = append(failed, cameraID)
+				break
+			}
+		}
+	}
+	return failed
+}
+
+// printRolloutSummary prints the outcome of every wave and the cameras that were skipped
+func printRolloutSummary(outcome *rollout.Result) {
+	fmt.Printf("\n🌊 Rollout %s:\n", outcome.Status)
+	for _, wave := range outcome.Waves {
+		fmt.Printf("   • %s: %d camera(s), %d failed", waveLabel(wave.Number), len(wave.CameraIDs), len(wave.Failed))
+		if len(wave.Failed) > 0 {
+			fmt.Printf(" (%s)", strings.Join(wave.Failed, ", "))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("   • Failure rate: %.1f%% of %d camera(s)\n", outcome.FailureRate, outcome.Applied)
+	if len(outcome.Skipped) > 0 {
+		fmt.Printf("⚠️  %d camera(s) skipped: %s\n", len(outcome.Skipped), strings.Join(outcome.Skipped, ", "))
+	}
+}
+
+// waveLabel names a wave in messages; wave 1 is the canary
+func waveLabel(wave int) string {
+	if wave == 1 {
+		return "Wave 1 (canary)"
+	}
+	return fmt.Sprintf("Wave %d", wave)
+}
@@ -0,0 +1,381 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/scheduler"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Flags of the schedule and window commands
+var (
+	scheduleRunsLimit int
+	windowStart       string
+	windowEnd         string
+	windowDays        []string
+	windowSelector    string
+	windowDescription string
+)
+
+// scheduleCmd groups the scheduled operation commands
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage scheduled camera operations",
+	Long: `Schedules run apply-config, validate, reboot or time-sync on a set of cameras, on a cron
+expression or once at a given time. The server runs them; cameras are only operated while one of
+their maintenance windows is open (see 'window').`,
+}
+
+// scheduleListCmd represents the schedule list command
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the schedules and their next run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runListSchedules()
+	},
+}
+
+// scheduleShowCmd represents the schedule show command
+var scheduleShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show a schedule",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runShowSchedule(args[0])
+	},
+}
+
+// scheduleSetCmd represents the schedule set command
+var scheduleSetCmd = &cobra.Command{
+	Use:   "set [name] [schedule-file]",
+	Short: "Create or replace a schedule from a YAML or JSON file",
+	Long: `Create a schedule, or replace the schedule with the same name, from a YAML or JSON file:
+
+  description: Nightly stream settings for the lobby
+  operation: apply-config
+  selector: group=lobby
+  cron: "30 2 * * 1-5"
+  params:
+    template: overview-1080p
+    rollbackPolicy: on-failure
+
+Operations are apply-config (params as in an /apply-config request, without cameras),
+validate (profile), reboot and time-sync (mode, ntpServers, driftToleranceSeconds). Use
+'at: 2026-11-01T02:00:00+01:00' instead of cron for a one-off run. Without cameraIds and
+selector every inventoried camera is operated.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSetSchedule(args[0], args[1])
+	},
+}
+
+// scheduleDeleteCmd represents the schedule delete command
+var scheduleDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a schedule and its runs",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := scheduler.Shared.Get().Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Schedule %s deleted\n", args[0])
+		return nil
+	},
+}
+
+// scheduleRunsCmd represents the schedule runs command
+var scheduleRunsCmd = &cobra.Command{
+	Use:   "runs [name]",
+	Short: "Show the recent runs of a schedule with the result of each camera",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runShowScheduleRuns(args[0])
+	},
+}
+
+// windowCmd groups the maintenance window commands
+var windowCmd = &cobra.Command{
+	Use:   "window",
+	Short: "Manage maintenance windows for scheduled operations",
+	Long: `Maintenance windows restrict scheduled operations to certain hours. A window applies to the
+cameras matching its selector, or to every camera without one. A camera that some windows apply
+to is only operated while one of them is open; cameras no window applies to are not restricted.`,
+}
+
+// windowListCmd represents the window list command
+var windowListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the maintenance windows",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runListWindows()
+	},
+}
+
+// windowSetCmd represents the window set command
+var windowSetCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Create or replace a maintenance window",
+	Long: `Create a maintenance window, or replace the window with the same name. Times are the server's
+local time; a window ending before it starts runs past midnight, e.g. --start 23:00 --end 05:00.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSetWindow(args[0])
+	},
+}
+
+// windowDeleteCmd represents the window delete command
+var windowDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a maintenance window",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := scheduler.Shared.Get().DeleteWindow(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Maintenance window %s deleted\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	scheduleRunsCmd.Flags().IntVar(&scheduleRunsLimit, "limit", 5, "number of runs to show, newest first")
+	windowSetCmd.Flags().StringVar(&windowStart, "start", "", "opening time, HH:MM")
+	windowSetCmd.Flags().StringVar(&windowEnd, "end", "", "closing time, HH:MM")
+	windowSetCmd.Flags().StringSliceVar(&windowDays, "days", nil, "days the window opens, e.g. mon,tue,wed (default every day)")
+	windowSetCmd.Flags().StringVar(&windowSelector, "selector", "", "cameras the window applies to, e.g. group=lobby (default every camera)")
+	windowSetCmd.Flags().StringVar(&windowDescription, "description", "", "description of the window")
+	windowSetCmd.MarkFlagRequired("start")
+	windowSetCmd.MarkFlagRequired("end")
+
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleShowCmd)
+	scheduleCmd.AddCommand(scheduleSetCmd)
+	scheduleCmd.AddCommand(scheduleDeleteCmd)
+	scheduleCmd.AddCommand(scheduleRunsCmd)
+	RootCmd.AddCommand(scheduleCmd)
+
+	windowCmd.AddCommand(windowListCmd)
+	windowCmd.AddCommand(windowSetCmd)
+	windowCmd.AddCommand(windowDeleteCmd)
+	RootCmd.AddCommand(windowCmd)
+}
+
+// runListSchedules prints every schedule
+func runListSchedules() error {
+	list, err := scheduler.Shared.Get().List()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println("No schedules defined.")
+		return nil
+	}
+
+	fmt.Printf("%-20s %-13s %-20s %-26s %s\n", "Name", "Operation", "When", "Next run", "Cameras")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, schedule := range list {
+		fmt.Printf("%-20s %-13s %-20s %-26s %s\n", schedule.Name, schedule.Operation, scheduleWhenLabel(schedule), nextRunLabel(schedule), scheduleCamerasLabel(schedule))
+	}
+	return nil
+}
+
+// runShowSchedule prints a schedule and its parameters
+func runShowSchedule(name string) error {
+	schedule, err := scheduler.Shared.Get().Get(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🗓️  Schedule %s: %s\n", schedule.Name, schedule.Operation)
+	if schedule.Description != "" {
+		fmt.Printf("   %s\n", schedule.Description)
+	}
+	fmt.Printf("   • When: %s\n", scheduleWhenLabel(schedule))
+	fmt.Printf("   • Next run: %s\n", nextRunLabel(schedule))
+	if schedule.LastRun != nil {
+		fmt.Printf("   • Last run: %s\n", schedule.LastRun.Local().Format(time.RFC3339))
+	}
+	fmt.Printf("   • Cameras: %s\n", scheduleCamerasLabel(schedule))
+	if schedule.AllowWithoutWindow {
+		fmt.Printf("   • Cameras without a maintenance window: operated at any time\n")
+	}
+	if len(schedule.Params) > 0 {
+		params, err := json.MarshalIndent(schedule.Params, "     ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("   • Parameters: %s\n", params)
+	}
+	return nil
+}
+
+// runSetSchedule creates or replaces a schedule from a YAML or JSON file
+func runSetSchedule(name, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read schedule file: %w", err)
+	}
+
+	// YAML is a superset of JSON, so both formats are read the same way
+	schedule := new(scheduler.Schedule)
+	if err := yaml.Unmarshal(data, schedule); err != nil {
+		return fmt.Errorf("failed to parse schedule file %s: %w", path, err)
+	}
+	schedule.Name = name
+
+	created, err := scheduler.Shared.Get().Put(schedule)
+	if err != nil {
+		return err
+	}
+	action := "updated"
+	if created {
+		action = "created"
+	}
+	fmt.Printf("✅ Schedule %s %s: %s %s, next run %s\n", schedule.Name, action, schedule.Operation, scheduleWhenLabel(schedule), nextRunLabel(schedule))
+	return nil
+}
+
+// runShowScheduleRuns prints the recent runs of a schedule and the result of each camera
+func runShowScheduleRuns(name string) error {
+	runs, err := scheduler.Shared.Get().Runs(name)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("Schedule %s has not run yet.\n", name)
+		return nil
+	}
+	if scheduleRunsLimit > 0 && len(runs) > scheduleRunsLimit {
+		runs = runs[:scheduleRunsLimit]
+	}
+
+	for _, run := range runs {
+		trigger := "scheduled"
+		if run.Manual {
+			trigger = "manual"
+		}
+		fmt.Printf("\n%s Run %s (%s) at %s: %s\n", runStatusIcon(run.Status), run.ID, trigger, run.ScheduledAt.Local().Format(time.RFC3339), run.Status)
+		if run.Error != "" {
+			fmt.Printf("   %s\n", run.Error)
+		}
+		for _, result := range run.Cameras {
+			fmt.Printf("   %s %s: %s", runStatusIcon(result.Status), result.CameraID, result.Status)
+			if result.Message != "" {
+				fmt.Printf(", %s", result.Message)
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// runListWindows prints every maintenance window
+func runListWindows() error {
+	windows, err := scheduler.Shared.Get().Windows()
+	if err != nil {
+		return err
+	}
+	if len(windows) == 0 {
+		fmt.Println("No maintenance windows defined, scheduled operations run at any time.")
+		return nil
+	}
+
+	now := time.Now()
+	fmt.Printf("%-20s %-13s %-28s %-24s %s\n", "Name", "Hours", "Days", "Cameras", "Open now")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, window := range windows {
+		days := "every day"
+		if len(window.Days) > 0 {
+			days = strings.Join(window.Days, ",")
+		}
+		selector := "all"
+		if window.Selector != "" {
+			selector = window.Selector
+		}
+		open := "no"
+		if window.OpenAt(now) {
+			open = "yes"
+		}
+		fmt.Printf("%-20s %-13s %-28s %-24s %s\n", window.Name, window.Start+"-"+window.End, days, selector, open)
+	}
+	return nil
+}
+
+// runSetWindow creates or replaces a maintenance window from the flags
+func runSetWindow(name string) error {
+	window := &scheduler.Window{
+		Name:        name,
+		Description: windowDescription,
+		Selector:    windowSelector,
+		Days:        windowDays,
+		Start:       windowStart,
+		End:         windowEnd,
+	}
+	created, err := scheduler.Shared.Get().PutWindow(window)
+	if err != nil {
+		return err
+	}
+	action := "updated"
+	if created {
+		action = "created"
+	}
+	fmt.Printf("✅ Maintenance window %s %s: %s-%s, next opens %s\n", window.Name, action, window.Start, window.End, window.NextOpen(time.Now()).Format(time.RFC3339))
+	return nil
+}
+
+// scheduleWhenLabel describes when a schedule runs
+func scheduleWhenLabel(schedule *scheduler.Schedule) string {
+	if schedule.At != nil {
+		return "at " + schedule.At.Local().Format("2006-01-02 15:04")
+	}
+	return schedule.Cron
+}
+
+// nextRunLabel describes the next run of a schedule
+func nextRunLabel(schedule *scheduler.Schedule) string {
+	switch {
+	case schedule.Disabled:
+		return "disabled"
+	case schedule.NextRun == nil:
+		return "none"
+	default:
+		return schedule.NextRun.Local().Format(time.RFC3339)
+	}
+}
+
+// scheduleCamerasLabel describes the cameras of a schedule
+func scheduleCamerasLabel(schedule *scheduler.Schedule) string {
+	var parts []string
+	if len(schedule.CameraIDs) > 0 {
+		parts = append(parts, strings.Join(schedule.CameraIDs, ","))
+	}
+	if schedule.Selector != "" {
+		parts = append(parts, schedule.Selector)
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, " ")
+}
+
+// runStatusIcon returns the icon of a run or camera status
+func runStatusIcon(status string) string {
+	switch status {
+	case scheduler.RunCompleted, scheduler.CameraSucceeded:
+		return "✅"
+	case scheduler.RunFailed:
+		return "❌"
+	case scheduler.RunOutsideWindow, scheduler.RunMissed:
+		return "⏸️ "
+	case scheduler.RunRunning:
+		return "⏳"
+	default:
+		return "•"
+	}
+}
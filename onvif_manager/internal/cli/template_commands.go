@@ -0,0 +1,314 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/internal/backend/templates"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Flags of the template commands
+var (
+	templateShowModel   string
+	templateDescription string
+	templateConcurrency int
+	templateTimeout     time.Duration
+)
+
+// templateCmd groups the configuration template commands
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage reusable configuration templates",
+	Long: `Templates are named stream configurations such as lpr-entrance or overview-1080p, with
+overrides per camera model. Apply one with 'config apply --template'; every camera gets the
+variant for the model its device service reports.`,
+}
+
+// templateListCmd represents the template list command
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configuration templates",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runListTemplates()
+	},
+}
+
+// templateShowCmd represents the template show command
+var templateShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show a template and its model variants",
+	Long:  `Show the streams and model variants of a template. With --model only the streams a camera of that model gets are shown.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runShowTemplate(args[0])
+	},
+}
+
+// templateSetCmd represents the template set command
+var templateSetCmd = &cobra.Command{
+	Use:   "set [name] [template-file]",
+	Short: "Create or replace a template from a YAML or JSON file",
+	Long: `Create a template, or replace the template with the same name, from a YAML or JSON file:
+
+  description: Entrance license plate cameras
+  streams:
+    - profile: main
+      width: 1920
+      height: 1080
+      fps: 25
+      bitrate: 6144
+      encoding: H264
+  models:
+    - model: DS-2CD2T47G2*
+      streams:
+        - profile: main
+          width: 2688
+          height: 1520
+
+Model variants only override the settings they set. A model ending in * matches every model starting with it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSetTemplate(args[0], args[1])
+	},
+}
+
+// templateDeleteCmd represents the template delete command
+var templateDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a template",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := templates.Shared.Get().Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Template %s deleted\n", args[0])
+		return nil
+	},
+}
+
+// templateResolveCmd represents the template resolve command
+var templateResolveCmd = &cobra.Command{
+	Use:   "resolve [name] [camera-id...]",
+	Short: "Show the streams a template resolves to for each camera",
+	Long:  `Read the model of each camera and show the template variant and streams it would get. Without camera IDs or selector flags every inventoried camera is shown.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runResolveTemplate(args[0], args[1:])
+	},
+}
+
+func init() {
+	templateShowCmd.Flags().StringVar(&templateShowModel, "model", "", "show the streams a camera of this model gets")
+	templateSetCmd.Flags().StringVar(&templateDescription, "description", "", "description of the template, replacing the one in the file")
+	templateResolveCmd.Flags().IntVar(&templateConcurrency, "concurrency", 0, fmt.Sprintf("number of cameras read in parallel (default %d, env %s)", pool.DefaultConcurrency, pool.ConcurrencyEnvVar))
+	templateResolveCmd.Flags().DurationVar(&templateTimeout, "timeout", 0, fmt.Sprintf("maximum time per camera (default %s, env %s)", pool.DefaultTimeout, pool.TimeoutEnvVar))
+	cameraSelection.register(templateResolveCmd)
+
+	templateCmd.AddCommand(templateListCmd)
+	templateCmd.AddCommand(templateShowCmd)
+	templateCmd.AddCommand(templateSetCmd)
+	templateCmd.AddCommand(templateDeleteCmd)
+	templateCmd.AddCommand(templateResolveCmd)
+	RootCmd.AddCommand(templateCmd)
+}
+
+// runListTemplates prints every template
+func runListTemplates() error {
+	list, err := templates.Shared.Get().List()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println("No templates defined.")
+		return nil
+	}
+
+	fmt.Printf("%-24s %-8s %-8s %s\n", "Name", "Streams", "Models", "Description")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, template := range list {
+		fmt.Printf("%-24s %-8d %-8d %s\n", template.Name, len(template.Streams), len(template.Models), template.Description)
+	}
+	return nil
+}
+
+// runShowTemplate prints a template, or the streams it resolves to for --model
+func runShowTemplate(name string) error {
+	template, err := templates.Shared.Get().Get(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📋 Template %s\n", template.Name)
+	if template.Description != "" {
+		fmt.Printf("   %s\n", template.Description)
+	}
+
+	if templateShowModel != "" {
+		streams, variant := template.Resolve(templateShowModel)
+		if variant == "" {
+			fmt.Printf("\nModel %s has no variant, it gets the template streams:\n", templateShowModel)
+		} else {
+			fmt.Printf("\nModel %s gets variant %s:\n", templateShowModel, variant)
+		}
+		printTemplateStreams(streams)
+		return nil
+	}
+
+	fmt.Printf("\nStreams:\n")
+	printTemplateStreams(template.Streams)
+	for _, variant := range template.Models {
+		fmt.Printf("\nModel %s:\n", variant.Model)
+		printTemplateStreams(variant.Streams)
+	}
+	return nil
+}
+
+// runSetTemplate creates or replaces a template from a YAML or JSON file
+func runSetTemplate(name, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	// YAML is a superset of JSON, so both formats are read the same way
+	template := new(templates.Template)
+	if err := yaml.Unmarshal(data, template); err != nil {
+		return fmt.Errorf("failed to parse template file %s: %w", path, err)
+	}
+	template.Name = name
+	if templateDescription != "" {
+		template.Description = templateDescription
+	}
+
+	created, err := templates.Shared.Get().Put(template)
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Printf("✅ Template %s created with %d stream(s) and %d model variant(s)\n", template.Name, len(template.Streams), len(template.Models))
+	} else {
+		fmt.Printf("✅ Template %s updated with %d stream(s) and %d model variant(s)\n", template.Name, len(template.Streams), len(template.Models))
+	}
+	return nil
+}
+
+// runResolveTemplate prints the variant and streams each camera would get
+func runResolveTemplate(name string, cameraIDs []string) error {
+	template, err := templates.Shared.Get().Get(name)
+	if err != nil {
+		return err
+	}
+	cameraIDs, err = inventoryCameraIDs(cameraIDs)
+	if err != nil {
+		return err
+	}
+
+	opts := pool.DefaultOptions().WithOverrides(templateConcurrency, templateTimeout)
+	for _, cam := range templates.ResolveForCameras(context.Background(), template, cameraIDs, opts) {
+		printCameraTemplate(cam)
+	}
+	return nil
+}
+
+// printCameraTemplate prints the variant and streams a camera gets
+func printCameraTemplate(cam templates.CameraStreams) {
+	switch {
+	case cam.Error != "":
+		fmt.Printf("❌ Camera %s: %s\n", cam.CameraID, cam.Error)
+		return
+	case cam.Variant == "":
+		fmt.Printf("📷 Camera %s (model %s): template streams\n", cam.CameraID, cam.Model)
+	default:
+		fmt.Printf("📷 Camera %s (model %s): variant %s\n", cam.CameraID, cam.Model, cam.Variant)
+	}
+	printTemplateStreams(cam.Streams)
+}
+
+// printTemplateStreams prints the settings of template streams, one line per profile
+func printTemplateStreams(streams []templates.Stream) {
+	for _, stream := range streams {
+		fmt.Printf("   • %s\n", templateStreamLabel(stream))
+	}
+}
+
+// templateStreamLabel describes the settings of a template stream that are set
+func templateStreamLabel(stream templates.Stream) string {
+	var settings []string
+	if stream.Width > 0 {
+		settings = append(settings, fmt.Sprintf("%dx%d", stream.Width, stream.Height))
+	}
+	if stream.FPS > 0 {
+		settings = append(settings, fmt.Sprintf("%d FPS", stream.FPS))
+	}
+	if stream.Bitrate > 0 {
+		settings = append(settings, fmt.Sprintf("%d kbps", stream.Bitrate))
+	}
+	if stream.Encoding != "" {
+		settings = append(settings, stream.Encoding)
+	}
+	if stream.GOP > 0 {
+		settings = append(settings, fmt.Sprintf("GOP %d", stream.GOP))
+	}
+	if stream.EncoderProfile != "" {
+		settings = append(settings, stream.EncoderProfile)
+	}
+	if stream.RateControl != "" {
+		settings = append(settings, stream.RateControl)
+	}
+	if stream.Quality > 0 {
+		settings = append(settings, fmt.Sprintf("quality %d", stream.Quality))
+	}
+	if stream.EncodingInterval > 0 {
+		settings = append(settings, fmt.Sprintf("interval %d", stream.EncodingInterval))
+	}
+	return fmt.Sprintf("%s: %s", stream.Profile, strings.Join(settings, ", "))
+}
+
+// templateConfigRows resolves a template for the cameras and returns one config row per
+// camera and stream. Cameras whose model cannot be read are reported and left out.
+func templateConfigRows(name string, cameraIDs []string, opts pool.Options) ([]*ConfigRowTarget, error) {
+	template, err := templates.Shared.Get().Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("📋 Resolving template %s for %d camera(s)...\n", template.Name, len(cameraIDs))
+	var targets []*ConfigRowTarget
+	for _, cam := range templates.ResolveForCameras(context.Background(), template, cameraIDs, opts) {
+		printCameraTemplate(cam)
+		if cam.Error != "" {
+			continue
+		}
+		for pass, stream := range cam.Streams {
+			targets = append(targets, &ConfigRowTarget{
+				Config: &ConfigData{
+					Width:            stream.Width,
+					Height:           stream.Height,
+					FPS:              stream.FPS,
+					Bitrate:          stream.Bitrate,
+					Encoding:         stream.Encoding,
+					Profile:          stream.Profile,
+					GOP:              stream.GOP,
+					EncoderProfile:   stream.EncoderProfile,
+					RateControl:      stream.RateControl,
+					Quality:          stream.Quality,
+					EncodingInterval: stream.EncodingInterval,
+					CameraID:         cam.CameraID,
+				},
+				CameraID: cam.CameraID,
+				Pass:     pass,
+			})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("template %s could not be resolved for any camera", template.Name)
+	}
+	return targets, nil
+}
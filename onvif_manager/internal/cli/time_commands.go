@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"onvif_manager/internal/backend/pool"
+	"onvif_manager/internal/backend/timesync"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags of the time sync command
+var (
+	timeSyncNTPServers  []string
+	timeSyncSetTime     bool
+	timeSyncTolerance   time.Duration
+	timeSyncConcurrency int
+	timeSyncTimeout     time.Duration
+)
+
+// timeCmd groups the camera clock commands
+var timeCmd = &cobra.Command{
+	Use:   "time",
+	Short: "Check and synchronize camera clocks",
+	Long:  `Read the date, time and NTP settings of cameras and bring their clocks in line.`,
+}
+
+// timeSyncCmd represents the time sync command
+var timeSyncCmd = &cobra.Command{
+	Use:   "sync [camera-id...]",
+	Short: "Report camera clock drift and optionally fix it",
+	Long: `Read the clock and NTP settings of the given cameras, or of every camera in the inventory
+when no IDs are given, and report how far each clock is off. Use --ntp to point the cameras
+at NTP servers, or --set-time to set drifted clocks to the local time.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTimeSync(args)
+	},
+}
+
+func init() {
+	timeSyncCmd.Flags().StringSliceVar(&timeSyncNTPServers, "ntp", nil, "NTP servers to configure on the cameras (comma separated)")
+	timeSyncCmd.Flags().BoolVar(&timeSyncSetTime, "set-time", false, "set the clock of drifted cameras to the local time")
+	timeSyncCmd.Flags().DurationVar(&timeSyncTolerance, "tolerance", timesync.DefaultDriftTolerance, "drift up to which a camera counts as in sync")
+	timeSyncCmd.Flags().IntVar(&timeSyncConcurrency, "concurrency", 0, fmt.Sprintf("number of cameras worked on in parallel (default %d, env %s)", pool.DefaultConcurrency, pool.ConcurrencyEnvVar))
+	timeSyncCmd.Flags().DurationVar(&timeSyncTimeout, "timeout", 0, fmt.Sprintf("maximum time per camera (default %s, env %s)", pool.DefaultTimeout, pool.TimeoutEnvVar))
+
+	cameraSelection.register(timeSyncCmd)
+
+	timeCmd.AddCommand(timeSyncCmd)
+	RootCmd.AddCommand(timeCmd)
+}
+
+// runTimeSync reads and optionally updates the clocks of the selected cameras
+func runTimeSync(cameraIDs []string) error {
+	request := timesync.Request{
+		Mode:           timesync.ModeReport,
+		NTPServers:     timeSyncNTPServers,
+		DriftTolerance: timeSyncTolerance,
+	}
+	switch {
+	case len(timeSyncNTPServers) > 0 && timeSyncSetTime:
+		return fmt.Errorf("--ntp and --set-time cannot be used together")
+	case len(timeSyncNTPServers) > 0:
+		request.Mode = timesync.ModeNTP
+	case timeSyncSetTime:
+		request.Mode = timesync.ModeManual
+	}
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	cameraIDs, err := inventoryCameraIDs(cameraIDs)
+	if err != nil {
+		return err
+	}
+	if len(cameraIDs) == 0 {
+		fmt.Println("No cameras found in the inventory.")
+		return nil
+	}
+
+	switch request.Mode {
+	case timesync.ModeNTP:
+		fmt.Printf("🕒 Setting NTP servers %s on %d camera(s)...\n", strings.Join(request.NTPServers, ", "), len(cameraIDs))
+	case timesync.ModeManual:
+		fmt.Printf("🕒 Setting the clock of cameras drifted more than %s on %d camera(s)...\n", request.DriftTolerance, len(cameraIDs))
+	default:
+		fmt.Printf("🕒 Reading the clock of %d camera(s)...\n", len(cameraIDs))
+	}
+
+	opts := pool.DefaultOptions().WithOverrides(timeSyncConcurrency, timeSyncTimeout)
+	results := timesync.Run(context.Background(), cameraIDs, request, opts)
+
+	fmt.Printf("\n%-6s %-20s %-10s %-8s %-30s %s\n", "ID", "Camera time (UTC)", "Drift", "Type", "NTP servers", "Result")
+	fmt.Println(strings.Repeat("-", 100))
+
+	for _, cameraID := range results.CameraOrder {
+		result := results.CameraResults[cameraID]
+		if result.Before == nil {
+			fmt.Printf("%-6s %-20s %-10s %-8s %-30s ❌ %s\n", cameraID, "", "", "", "", result.Error)
+			continue
+		}
+
+		status := result.Before
+		outcome := "✅ in sync"
+		if status.Drift().Abs() > request.DriftTolerance {
+			outcome = "⚠️  drifted"
+		}
+		switch {
+		case !result.Success:
+			outcome = "❌ " + result.Error
+		case result.After != nil:
+			outcome = fmt.Sprintf("🔧 updated (%s), drift now %+.1fs", result.Action, result.After.DriftSeconds)
+			status = result.After
+		}
+
+		fmt.Printf("%-6s %-20s %-10s %-8s %-30s %s\n",
+			cameraID, result.Before.CameraTime.Format("2006-01-02 15:04:05"), fmt.Sprintf("%+.1fs", result.Before.DriftSeconds),
+			status.DateTimeType, strings.Join(status.NTPServers, ","), outcome)
+	}
+
+	summary := results.Summary
+	fmt.Printf("\n📊 Summary:\n")
+	fmt.Printf("   • Cameras checked: %d\n", summary.TotalCameras)
+	fmt.Printf("   • Failed: %d\n", summary.FailedCams)
+	fmt.Printf("   • Drifted more than %s: %d\n", request.DriftTolerance, summary.DriftedCams)
+	fmt.Printf("   • Largest drift: %+.1fs\n", summary.MaxDriftSeconds)
+	if request.Mode != timesync.ModeReport {
+		fmt.Printf("   • Updated: %d\n", summary.UpdatedCams)
+	}
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package cli
 
-import "onvif_manager/pkg/models"
+import (
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/pkg/models"
+)
 
 // ImportResult represents the result of importing cameras from CSV
 type ImportResult struct {
@@ -15,6 +18,7 @@ type ImportResult struct {
 type ImportRowResult struct {
 	Row      int            `json:"row"`
 	Success  bool           `json:"success"`
+	Existing bool           `json:"existing,omitempty"` // Camera was already in the inventory
 	Error    string         `json:"error,omitempty"`
 	CameraID string         `json:"cameraId,omitempty"`
 	Camera   *models.Camera `json:"camera,omitempty"`
@@ -48,62 +52,93 @@ type ConfigData struct {
 	FPS      int    `json:"fps"`
 	Bitrate  int    `json:"bitrate"`
 	Encoding string `json:"encoding"`
+	Profile  string `json:"profile,omitempty"` // Profile token, name or role (main/sub); the main stream when empty
+
+	GOP            int    `json:"gop,omitempty"`            // Frames between keyframes; the current GOP is kept when 0
+	EncoderProfile string `json:"encoderProfile,omitempty"` // H.264/H.265 profile, e.g. High; the current one is kept when empty
+
+	RateControl      string `json:"rateControl,omitempty"`      // CBR or VBR; the current mode is kept when empty
+	Quality          int    `json:"quality,omitempty"`          // Quality level; the current quality is kept when 0
+	EncodingInterval int    `json:"encodingInterval,omitempty"` // Encode every n-th frame; the current interval is kept when 0
+
+	// Target camera of a row of a per-camera config CSV; empty when the row applies to every selected camera
+	Row      int    `json:"row,omitempty"`
+	CameraID string `json:"cameraId,omitempty"`
+	IP       string `json:"ip,omitempty"`
+}
+
+// HasTarget reports whether the configuration names its own target camera
+func (config *ConfigData) HasTarget() bool {
+	return config.CameraID != "" || config.IP != ""
 }
 
 // SavedConfig represents the persistent configuration stored in saved_config.json
 type SavedConfig struct {
-	Width       int    `json:"width"`
-	Height      int    `json:"height"`
-	FPS         int    `json:"fps"`
-	Bitrate     int    `json:"bitrate"`
-	Encoding    string `json:"encoding"`
-	LastUpdated string `json:"lastUpdated"`
-	Source      string `json:"source"` // "csv", "manual", "default"
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	FPS              int    `json:"fps"`
+	Bitrate          int    `json:"bitrate"`
+	Encoding         string `json:"encoding"`
+	Profile          string `json:"profile,omitempty"`
+	GOP              int    `json:"gop,omitempty"`
+	EncoderProfile   string `json:"encoderProfile,omitempty"`
+	RateControl      string `json:"rateControl,omitempty"`
+	Quality          int    `json:"quality,omitempty"`
+	EncodingInterval int    `json:"encodingInterval,omitempty"`
+	LastUpdated      string `json:"lastUpdated"`
+	Source           string `json:"source"` // "csv", "manual", "default"
 }
 
 // ToConfigData converts SavedConfig to ConfigData for applying to cameras
 func (sc *SavedConfig) ToConfigData() *ConfigData {
 	return &ConfigData{
-		Width:    sc.Width,
-		Height:   sc.Height,
-		FPS:      sc.FPS,
-		Bitrate:  sc.Bitrate,
-		Encoding: sc.Encoding,
+		Width:            sc.Width,
+		Height:           sc.Height,
+		FPS:              sc.FPS,
+		Bitrate:          sc.Bitrate,
+		Encoding:         sc.Encoding,
+		Profile:          sc.Profile,
+		GOP:              sc.GOP,
+		EncoderProfile:   sc.EncoderProfile,
+		RateControl:      sc.RateControl,
+		Quality:          sc.Quality,
+		EncodingInterval: sc.EncodingInterval,
 	}
 }
 
 // ValidationResults represents the overall validation results
 type ValidationResults struct {
+	Profile           string                       `json:"profile,omitempty"` // Profile the configuration was applied to
+	CameraOrder       []string                     `json:"cameraOrder"`       // Camera IDs in the order they were requested
 	CameraResults     map[string]*CameraResult     `json:"cameraResults"`
 	ValidationResults map[string]*ValidationResult `json:"validationResults"`
 }
 
 // CameraResult represents the result of applying configuration to a single camera
-type CameraResult struct {
-	CameraID           string                 `json:"cameraId"`
-	Success            bool                   `json:"success"`
-	Error              error                  `json:"error,omitempty"`
-	AppliedConfig      map[string]interface{} `json:"appliedConfig,omitempty"`
-	ResolutionAdjusted bool                   `json:"resolutionAdjusted"`
-	ProfileToken       string                 `json:"profileToken,omitempty"`
-	StreamURL          string                 `json:"streamUrl,omitempty"`
-}
+type CameraResult = camera.ApplyResult
 
 // ValidationResult represents the result of validating a camera stream
 type ValidationResult struct {
-	IsValid          bool    `json:"isValid"`
-	ExpectedWidth    int     `json:"expectedWidth"`
-	ExpectedHeight   int     `json:"expectedHeight"`
-	ExpectedFPS      int     `json:"expectedFPS"`
-	ExpectedBitrate  int     `json:"expectedBitrate"`
-	ExpectedEncoding string  `json:"expectedEncoding"`
-	ActualWidth      int     `json:"actualWidth"`
-	ActualHeight     int     `json:"actualHeight"`
-	ActualFPS        float64 `json:"actualFPS"`
-	ActualBitrate    int     `json:"actualBitrate"`
-	ActualEncoding   string  `json:"actualEncoding"`
-	Error            string  `json:"error,omitempty"`
-	Message          string  `json:"message,omitempty"`
+	IsValid             bool    `json:"isValid"`
+	ExpectedWidth       int     `json:"expectedWidth"`
+	ExpectedHeight      int     `json:"expectedHeight"`
+	ExpectedFPS         int     `json:"expectedFPS"`
+	ExpectedBitrate     int     `json:"expectedBitrate"`
+	ExpectedEncoding    string  `json:"expectedEncoding"`
+	ExpectedGOP         int     `json:"expectedGOP,omitempty"`
+	ExpectedRateControl string  `json:"expectedRateControl,omitempty"`
+	ActualWidth         int     `json:"actualWidth"`
+	ActualHeight        int     `json:"actualHeight"`
+	ActualFPS           float64 `json:"actualFPS"`
+	ActualBitrate       int     `json:"actualBitrate"`
+	ActualEncoding      string  `json:"actualEncoding"`
+	ActualGOP           int     `json:"actualGOP,omitempty"`
+	BitrateSamples      int     `json:"bitrateSamples,omitempty"`
+	BitrateVariation    float64 `json:"bitrateVariation,omitempty"` // Standard deviation of the sampled bitrate, in percent
+	Error               string  `json:"error,omitempty"`
+	Message             string  `json:"message,omitempty"`
+
+	Rollback *models.RollbackResult `json:"rollback,omitempty"` // Set when the previous configuration was restored
 }
 
 // Summary represents a summary of operations
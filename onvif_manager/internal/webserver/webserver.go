@@ -1,6 +1,7 @@
 package webserver
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"io/fs"
@@ -10,6 +11,11 @@ import (
 	"strings"
 
 	"onvif_manager/internal/backend/api"
+	"onvif_manager/internal/backend/camera"
+	"onvif_manager/internal/backend/history"
+	"onvif_manager/internal/backend/reconcile"
+	"onvif_manager/internal/backend/scheduler"
+	"onvif_manager/internal/backend/templates"
 	"onvif_manager/internal/cli"
 
 	"github.com/gorilla/handlers"
@@ -24,6 +30,12 @@ var webFiles embed.FS
 // StartApp handles CLI arguments and starts the appropriate mode
 func StartApp() {
 	if len(os.Args) > 1 {
+		// Load the shared camera inventory before any mode touches it
+		if err := initInventory(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Check for web server command
 		if os.Args[1] == "web" {
 			// Combined web server mode (API + Frontend)
@@ -32,7 +44,7 @@ func StartApp() {
 			fmt.Println("🔌 API endpoints will be available at http://localhost:8090/api")
 			fmt.Println("")
 
-			// Camera initialization is handled by in-memory storage
+			startBackgroundTasks()
 			StartWebServer(":8090")
 			return
 		}
@@ -44,7 +56,7 @@ func StartApp() {
 			fmt.Println("📊 API endpoints will be available at http://localhost:8090")
 			fmt.Println("")
 
-			// Camera initialization is handled by in-memory storage
+			startBackgroundTasks()
 			StartAPIServer(":8090")
 			return
 		}
@@ -71,6 +83,52 @@ func StartApp() {
 	fmt.Println("Use 'onvif-manager help [command]' for more information about a command.")
 }
 
+// initInventory opens the inventory store selected by ONVIF_MANAGER_INVENTORY, loads
+// the saved cameras into the camera package and opens the config history and templates
+func initInventory() error {
+	store, err := camera.OpenStoreFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to open camera inventory: %w", err)
+	}
+
+	if err := camera.UseStore(store); err != nil {
+		return err
+	}
+
+	if fileStore, ok := store.(*camera.JSONFileStore); ok {
+		log.Printf("Camera inventory: %s (%d cameras)", fileStore.Path(), len(camera.GetAllCameras()))
+	}
+
+	// Applied encoder configs are recorded in every mode so that drift can be detected later
+	historyStore, err := history.Shared.OpenFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to open config history: %w", err)
+	}
+	camera.DefaultRegistry().UseConfigRecorder(historyStore)
+
+	if _, err := templates.Shared.OpenFromEnv(); err != nil {
+		return fmt.Errorf("failed to open configuration templates: %w", err)
+	}
+	if _, err := scheduler.Shared.OpenFromEnv(); err != nil {
+		return fmt.Errorf("failed to open schedules: %w", err)
+	}
+	return nil
+}
+
+// startBackgroundTasks starts polling the cameras for config drift, the scheduler and
+// the periodic reconciler when a desired-state file is configured
+func startBackgroundTasks() {
+	if err := history.StartPollingFromEnv(context.Background(), history.Shared.Get()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	scheduler.Start(context.Background(), scheduler.Shared.Get())
+	if err := reconcile.StartFromEnv(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 // StartWebServer starts the combined web server with both API and frontend
 func StartWebServer(addr string) {
 	r := mux.NewRouter()
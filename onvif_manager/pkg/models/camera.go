@@ -8,24 +8,74 @@ type Camera struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	IsFake   bool   `json:"isFake"`
+
+	// DeviceURL is the device service address the camera advertised when it was discovered,
+	// kept for cameras with a non-default path or https; http://IP:Port/onvif/device_service when empty
+	DeviceURL string `json:"deviceUrl,omitempty"`
+
+	// Groups name the sites, buildings, floors or other sets the camera belongs to,
+	// Tags are free-form labels; both are used to select cameras for operations
+	Groups []string `json:"groups,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
 }
 
 type EncoderConfig struct {
-	Resolution Resolution
-	Quality    int
-	FPS        int
-	Bitrate    int
-	Encoding   string
+	Resolution       Resolution `json:"resolution"`
+	Quality          int        `json:"quality"`
+	FPS              int        `json:"fps"`
+	Bitrate          int        `json:"bitrate"`
+	Encoding         string     `json:"encoding"`
+	GOP              int        `json:"gop,omitempty"`              // Frames from one I-frame to the next (GovLength)
+	EncoderProfile   string     `json:"encoderProfile,omitempty"`   // H.264/H.265 profile such as Baseline, Main or High
+	RateControl      string     `json:"rateControl,omitempty"`      // CBR or VBR; empty when the camera does not report it
+	EncodingInterval int        `json:"encodingInterval,omitempty"` // Only every n-th frame is encoded (Media service only)
+}
+
+// RollbackResult is the outcome of restoring the encoder configuration a camera had
+// before a change whose validation failed
+type RollbackResult struct {
+	Policy         string        `json:"policy"`
+	Reason         string        `json:"reason"`          // Validation failure that triggered the rollback
+	RestoredConfig EncoderConfig `json:"restoredConfig"`  // Configuration written back
+	Restored       bool          `json:"restored"`        // Whether the configuration was written back
+	Valid          bool          `json:"valid"`           // Whether the stream validated after the rollback
+	Error          string        `json:"error,omitempty"` // Why restoring or validating failed
+}
+
+// EncoderOptions are the settings a video encoder configuration accepts, per encoding
+type EncoderOptions struct {
+	Service   string                      `json:"service"`   // ONVIF service the options were read from: media or media2
+	Encodings map[string]*EncodingOptions `json:"encodings"` // Keyed by ONVIF encoding name: H264, H265, JPEG
+}
+
+// EncodingOptions are the settings a video encoder configuration accepts for one encoding
+type EncodingOptions struct {
+	Encoding              string       `json:"encoding"`
+	Resolutions           []Resolution `json:"resolutions"`
+	FrameRates            []int        `json:"frameRates"`             // Supported frame rates, lowest first
+	BitrateRange          *Range       `json:"bitrateRange,omitempty"` // kbps
+	QualityRange          *Range       `json:"qualityRange,omitempty"`
+	GOPRange              *Range       `json:"gopRange,omitempty"`              // GOP length in frames
+	Profiles              []string     `json:"profiles,omitempty"`              // Encoder profiles such as Baseline, Main and High
+	ConstantBitRate       bool         `json:"constantBitRate"`                 // Whether a constant bitrate can be enforced
+	EncodingIntervalRange *Range       `json:"encodingIntervalRange,omitempty"` // Media service only
 }
 
-type EncoderOption struct {
-	Resolutions []Resolution
-	Quality     []int
-	FPSOptions  []int
-	Bitrate     []int
+// Range is an inclusive range of values
+type Range struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
 }
 
 type Resolution struct {
-	Width  int
-	Height int
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type DeviceInfo struct {
+	Manufacturer    string `json:"manufacturer"`
+	Model           string `json:"model"`
+	FirmwareVersion string `json:"firmwareVersion"`
+	SerialNumber    string `json:"serialNumber"`
+	HardwareID      string `json:"hardwareId"`
 }